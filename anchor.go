@@ -0,0 +1,320 @@
+package stc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/xdrpp/stc/stcdetail"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The parts of a stellar.toml file (SEP-1) that describe an
+// organization's accounts and the services of its anchor.  Field
+// names correspond to the file's keys, ignoring case.
+type StellarToml struct {
+	Version                 string
+	Network_passphrase      string
+	Horizon_url             string
+	Accounts                []string
+	Signing_key             string
+	Uri_request_signing_key string
+
+	// Endpoints of the organization's services, empty if it does not
+	// offer them: federation (SEP-2), deposit and withdrawal (SEP-6
+	// and, interactively, SEP-24), web authentication (SEP-10), KYC
+	// (SEP-12), cross-border payments (SEP-31), and quotes (SEP-38).
+	Federation_server       string
+	Transfer_server         string
+	Transfer_server_sep0024 string
+	Web_auth_endpoint       string
+	Kyc_server              string
+	Direct_payment_server   string
+	Anchor_quote_server     string
+
+	// The [DOCUMENTATION] table, e.g., ORG_NAME and ORG_URL.
+	Documentation map[string]string
+
+	Currencies []TomlCurrency
+}
+
+// An asset described in the [[CURRENCIES]] tables of stellar.toml.
+type TomlCurrency struct {
+	Code                    string
+	Issuer                  string
+	Code_template           string
+	Status                  string
+	Display_decimals        int
+	Name                    string
+	Desc                    string
+	Conditions              string
+	Image                   string
+	Fixed_number            int64
+	Max_number              int64
+	Is_unlimited            bool
+	Is_asset_anchored       bool
+	Anchor_asset_type       string
+	Anchor_asset            string
+	Attestation_of_reserve  string
+	Redemption_instructions string
+	Regulated               bool
+	Approval_server         string
+	Approval_criteria       string
+}
+
+// Returns the currency in code:issuer format, which can be scanned
+// into an stx.Asset.
+func (c *TomlCurrency) String() string {
+	return c.Code + ":" + c.Issuer
+}
+
+// Stores TOML values in the like-named (ignoring case) fields of
+// struct out, skipping keys with no corresponding field.
+func tomlDecode(kv map[string]interface{}, out reflect.Value) error {
+	t := out.Type()
+	for i := 0; i < t.NumField(); i++ {
+		var val interface{}
+		for k, v := range kv {
+			if strings.EqualFold(k, t.Field(i).Name) {
+				val = v
+				break
+			}
+		}
+		if val == nil {
+			continue
+		}
+		f := out.Field(i)
+		s, isString := val.(string)
+		var err error
+		switch f.Kind() {
+		case reflect.String:
+			if !isString {
+				err = fmt.Errorf("not a string")
+			}
+			f.SetString(s)
+		case reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(s)
+			f.SetBool(b)
+		case reflect.Int, reflect.Int64:
+			var n int64
+			n, err = strconv.ParseInt(s, 10, 64)
+			f.SetInt(n)
+		case reflect.Slice:
+			a, _ := val.([]interface{})
+			ss := make([]string, len(a))
+			for j := range a {
+				if ss[j], isString = a[j].(string); !isString {
+					err = fmt.Errorf("not an array of strings")
+				}
+			}
+			if a == nil {
+				err = fmt.Errorf("not an array")
+			}
+			f.Set(reflect.ValueOf(ss))
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Field(i).Name, err)
+		}
+	}
+	return nil
+}
+
+// Parses the contents of a stellar.toml file.
+func ParseStellarToml(data []byte) (*StellarToml, error) {
+	doc, err := stcdetail.ParseToml(data)
+	if err != nil {
+		return nil, fmt.Errorf("stellar.toml: %w", err)
+	}
+	ret := &StellarToml{}
+	if err = tomlDecode(doc.Top, reflect.ValueOf(ret).Elem()); err != nil {
+		return nil, fmt.Errorf("stellar.toml: %w", err)
+	}
+	if docs := doc.Tables["DOCUMENTATION"]; len(docs) > 0 {
+		ret.Documentation = make(map[string]string)
+		for k, v := range docs[0] {
+			if s, ok := v.(string); ok {
+				ret.Documentation[k] = s
+			}
+		}
+	}
+	for _, kv := range doc.Tables["CURRENCIES"] {
+		var c TomlCurrency
+		if err = tomlDecode(kv, reflect.ValueOf(&c).Elem()); err != nil {
+			return nil, fmt.Errorf("stellar.toml CURRENCIES: %w", err)
+		}
+		ret.Currencies = append(ret.Currencies, c)
+	}
+	return ret, nil
+}
+
+// Returns the currency with the given code and issuer, or nil if the
+// stellar.toml file does not describe it.
+func (st *StellarToml) Currency(code, issuer string) *TomlCurrency {
+	for i := range st.Currencies {
+		if c := &st.Currencies[i]; c.Code == code && c.Issuer == issuer {
+			return c
+		}
+	}
+	return nil
+}
+
+// Fetches and parses https://domain/.well-known/stellar.toml, aborting
+// if ctx (which may be nil) is done.  Fails if the file specifies a
+// NETWORK_PASSPHRASE other than net's.
+func (net *StellarNet) GetStellarToml(ctx context.Context, domain string) (
+	*StellarToml, error) {
+	body, _, err := getURLHeader(ctx, net.httpClient(), net.retryPolicy(),
+		"https://"+domain+"/.well-known/stellar.toml")
+	if err != nil {
+		return nil, err
+	}
+	ret, err := ParseStellarToml(body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", domain, err)
+	} else if ret.Network_passphrase != "" &&
+		ret.Network_passphrase != net.GetNetworkId() {
+		return nil, fmt.Errorf("%s: stellar.toml is for network %q",
+			domain, ret.Network_passphrase)
+	}
+	return ret, nil
+}
+
+// Fetches the stellar.toml file of the home domain of account acct
+// (typically an asset's issuer).
+func (net *StellarNet) GetAccountToml(ctx context.Context, acct string) (
+	*StellarToml, error) {
+	ae, err := net.GetAccountEntryCtx(ctx, acct)
+	if err != nil {
+		return nil, err
+	} else if ae.Home_domain == "" {
+		return nil, fmt.Errorf("account %s has no home domain", acct)
+	}
+	return net.GetStellarToml(ctx, ae.Home_domain)
+}
+
+// A field of information that an anchor requires from the user, as
+// described by its SEP-6 /info endpoint or SEP-12 /customer endpoint.
+type AnchorField struct {
+	// For SEP-12 fields, one of "string", "binary", "number", or
+	// "date".
+	Type        string
+	Description string
+	Choices     []string
+	Optional    bool
+}
+
+// Deposit or withdrawal parameters of one asset at a SEP-6 transfer
+// server.  Amounts are in units of the asset, and fees in the same
+// units (Fee_fixed) or percent (Fee_percent).
+type TransferAssetInfo struct {
+	Enabled                 bool
+	Authentication_required bool
+	Min_amount              float64
+	Max_amount              float64
+	Fee_fixed               float64
+	Fee_percent             float64
+	Fee_minimum             float64
+
+	// Information the user must supply, for deposits.
+	Fields map[string]AnchorField
+
+	// Withdrawal methods (e.g., "bank_account"), each with the
+	// information the user must supply.
+	Types map[string]struct {
+		Fields map[string]AnchorField
+	}
+}
+
+// The response of a SEP-6 transfer server's /info endpoint, with
+// deposit and withdrawal parameters indexed by asset code.
+type TransferInfo struct {
+	Deposit  map[string]TransferAssetInfo
+	Withdraw map[string]TransferAssetInfo
+	Fee      struct {
+		Enabled                 bool
+		Authentication_required bool
+	}
+	Features struct {
+		Account_creation   bool
+		Claimable_balances bool
+	}
+}
+
+// Fetches the /info endpoint of the SEP-6 transfer server whose base
+// URL is server (e.g., a StellarToml's Transfer_server).  ctx may be
+// nil.
+func (net *StellarNet) GetTransferInfo(ctx context.Context, server string) (
+	*TransferInfo, error) {
+	body, _, err := getURLHeader(ctx, net.httpClient(), net.retryPolicy(),
+		strings.TrimSuffix(server, "/")+"/info")
+	if err != nil {
+		return nil, err
+	}
+	var ret TransferInfo
+	if err = json.Unmarshal(body, &ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// Status of a customer's information (SEP-12), either in total or for
+// one field.  Customer statuses are "ACCEPTED", "PROCESSING",
+// "NEEDS_INFO", and "REJECTED".  Field statuses are "ACCEPTED",
+// "PROCESSING", "REJECTED", and "VERIFICATION_REQUIRED".
+type KYCProvidedField struct {
+	AnchorField
+	Status string
+	Error  string
+}
+
+// The response of a SEP-12 KYC server's GET /customer endpoint.
+type KYCCustomer struct {
+	Id              string
+	Status          string
+	Message         string
+	Fields          map[string]AnchorField
+	Provided_fields map[string]KYCProvidedField
+}
+
+// Fetches the status of a customer, and the information the anchor
+// still requires, from the SEP-12 KYC server whose base URL is server
+// (a StellarToml's Kyc_server, or its Transfer_server if that is
+// empty).  jwt is a SEP-10 authentication token, which must be
+// obtained separately.  params may identify the customer (e.g., by
+// "account" or "memo") and the kind of customer ("type").  ctx may be
+// nil.
+func (net *StellarNet) GetKYCCustomer(ctx context.Context, server,
+	jwt string, params url.Values) (*KYCCustomer, error) {
+	u := strings.TrimSuffix(server, "/") + "/customer"
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	} else if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	resp, err := net.retryPolicy().do(net.httpClient(), req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	} else if resp.StatusCode != 200 {
+		return nil, newHorizonError(resp.StatusCode, body)
+	}
+	var ret KYCCustomer
+	if err = json.Unmarshal(body, &ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
@@ -0,0 +1,336 @@
+package stc_test
+
+// Compile-time checks of the exported API that stc promises to keep
+// compatible (see the package documentation).  If a change makes
+// this file fail to compile, the change breaks programs that depend
+// on stc, and requires a new major version.  Add entries here when
+// adding stable API.
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/xdrpp/goxdr/xdr"
+	"github.com/xdrpp/stc"
+	"github.com/xdrpp/stc/horizon"
+	"github.com/xdrpp/stc/stcdetail"
+	"github.com/xdrpp/stc/stx"
+)
+
+// Networks and configuration
+var (
+	_ func(string) *stc.StellarNet                     = stc.DefaultStellarNet
+	_ func(string, ...string) (*stc.StellarNet, error) = stc.LoadStellarNet
+	_ func(string, string, ...string) (
+		*stc.StellarNet, *stc.HorizonRoot, error) = stc.DetectStellarNet
+	_ func(...string) string                       = stc.ConfigPath
+	_ func() error                                 = stc.MigrateConfigDir
+	_ func(string) (int, error)                    = stc.ReadConfigVersion
+	_ error                                        = stc.ErrConfigTooNew{}
+	_ func(*stc.StellarNet) error                  = (*stc.StellarNet).Save
+	_ func(*stc.StellarNet) string                 = (*stc.StellarNet).GetNetworkId
+	_ func(*stc.StellarNet, string, string)        = (*stc.StellarNet).AddHint
+	_ func(*stc.StellarNet, string, string)        = (*stc.StellarNet).AddSigner
+	_ func(*stc.StellarNet) stc.PrivateKey         = (*stc.StellarNet).RootKey
+	_ func(string) (*stc.HorizonRoot, error)       = stc.GetHorizonRoot
+	_ func(*stc.StellarNet, string) error          = (*stc.StellarNet).FundAccount
+	_ func(*stc.StellarNet, string) (string, bool) = (*stc.StellarNet).LookupAccountAlias
+	_ *http.Client                                 = stc.DefaultHTTPClient
+	_ *http.Client                                 = (&stc.StellarNet{}).HTTPClient
+	_ string                                       = (&stc.StellarNet{}).AppName
+	_ *stc.HTTPHooks                               = (&stc.StellarNet{}).Hooks
+	_ *stc.RateLimiter                             = (&stc.StellarNet{}).RateLimit
+	_ func(float64, int) *stc.RateLimiter          = stc.NewRateLimiter
+	_ stc.TxDefaults                               = (&stc.StellarNet{}).TxDefaults
+	_ func(*stc.StellarNet) (int64, error)         = (*stc.StellarNet).GetBaseReserve
+	_ func(*stc.StellarNet) string                 = (*stc.StellarNet).UserAgent
+	_ func() string                                = stc.Version
+	_ func() stc.BuildInfo                         = stc.GetBuildInfo
+	_ *string                                      = &stc.BuildCommit
+	_ func(*stc.StellarNet,
+		*stc.FeeStats) stc.FeeVal = (*stc.StellarNet).DefaultFee
+	_ func(*stc.StellarNet,
+		*stc.TransactionEnvelope) = (*stc.StellarNet).ApplyTimeout
+	_ *stc.HostRateLimits                                 = stc.DefaultHostRateLimits
+	_ func(*stc.HostRateLimits, string) *stc.RateLimiter  = (*stc.HostRateLimits).Get
+	_ func(*stc.HostRateLimits, string, *stc.RateLimiter) = (*stc.HostRateLimits).Set
+	_ map[stx.SignatureHint][]stc.SignerKeyInfo           = (&stc.StellarNet{}).Signers
+	_ map[string]string                                   = (&stc.StellarNet{}).Accounts
+)
+
+// Building transactions
+var (
+	_ func() *stc.TransactionEnvelope = stc.NewTransactionEnvelope
+	_ func(*stc.TransactionEnvelope, *stx.MuxedAccount,
+		stc.OperationBody) = (*stc.TransactionEnvelope).Append
+	_ func(*stc.TransactionEnvelope, uint32)         = (*stc.TransactionEnvelope).SetFee
+	_ func(*stc.TransactionEnvelope, uint32, uint32) = (*stc.TransactionEnvelope).SetLedgerBounds
+	_ func(*stc.TransactionEnvelope,
+		stx.IsAccount) = (*stc.TransactionEnvelope).SetSourceAccount
+	_ func(*stc.TransactionEnvelope,
+		stx.TimePoint, stx.TimePoint) = (*stc.TransactionEnvelope).SetTimeBounds
+	_ func(*stc.TransactionEnvelope) *stx.MuxedAccount  = (*stc.TransactionEnvelope).SourceAccount
+	_ func(*stc.TransactionEnvelope) *stx.TimeBounds    = (*stc.TransactionEnvelope).TimeBounds
+	_ func() stx.Asset                                  = stc.NativeAsset
+	_ func(stc.AccountID, string) stx.Asset             = stc.MkAsset
+	_ func(string) stx.AssetCode                        = stc.MkAssetCode
+	_ func(stc.PublicKey, uint32) *stx.Signer           = stc.NewSignerKey
+	_ func(stx.Hash, uint32) *stx.Signer                = stc.NewSignerHashX
+	_ func(*stc.AccountID, *uint64) *stc.MuxedAccount   = stc.MuxAcct
+	_ func(*stc.MuxedAccount) (*stc.AccountID, *uint64) = stc.DemuxAcct
+	_ func(uint32) *uint32                              = stc.NewUint
+	_ func(int64) *int64                                = stc.NewHyper
+	_ func(uint64) *uint64                              = stc.NewUhyper
+	_ func(string) *string                              = stc.NewString
+	_ func(xdr.XdrType, ...interface{})                 = stc.Set
+	_ func(*stc.AccountID, *stx.Memo) *stc.MuxedAccount = stc.MuxAcctMemo
+	_ func(*stc.MuxedAccount) (
+		*stc.AccountID, stx.Memo) = stc.DemuxAcctMemo
+	_ func(stc.PublicKey, []byte) stx.SignerKey = stc.SignedPayloadKey
+	_ func(stc.PublicKey, []byte,
+		uint32) *stx.Signer = stc.NewSignerSignedPayload
+	_ func() stx.ClaimPredicate                            = stc.Unconditional
+	_ func(a, b stx.ClaimPredicate) stx.ClaimPredicate     = stc.And
+	_ func(a, b stx.ClaimPredicate) stx.ClaimPredicate     = stc.Or
+	_ func(stx.ClaimPredicate) stx.ClaimPredicate          = stc.Not
+	_ func(time.Time) stx.ClaimPredicate                   = stc.BeforeAbs
+	_ func(time.Duration) stx.ClaimPredicate               = stc.BeforeRel
+	_ func(stc.AccountID, stx.ClaimPredicate) stx.Claimant = stc.NewClaimant
+	_ func(*stc.AccountID, stx.SequenceNumber,
+		uint32) stx.ClaimableBalanceID = stc.ClaimableBalanceID
+	_ func(*stc.TransactionEnvelope,
+		int) stx.ClaimableBalanceID = (*stc.TransactionEnvelope).ClaimableBalanceID
+	_ func(*stc.TransactionEnvelope, stx.Memo)     = (*stc.TransactionEnvelope).SetMemo
+	_ func(*stc.TransactionEnvelope, []byte) error = (*stc.TransactionEnvelope).SetMemoHash
+	_ func(*stc.TransactionEnvelope, []byte) error = (*stc.TransactionEnvelope).SetMemoReturn
+	_ func(*stc.TransactionEnvelope, *stc.MuxedAccount,
+		stc.AccountID) = (*stc.TransactionEnvelope).BeginSponsoring
+	_ func(*stc.TransactionEnvelope,
+		stc.AccountID) = (*stc.TransactionEnvelope).EndSponsoring
+	_ func(*stc.TransactionEnvelope, *stc.MuxedAccount, stc.AccountID,
+		func()) = (*stc.TransactionEnvelope).AppendSponsored
+	_ func(*stc.TransactionEnvelope) error = stc.CheckSponsorships
+	_ func(*stc.SetOptionsBuilder, uint8, uint8,
+		uint8) *stc.SetOptionsBuilder = (*stc.SetOptionsBuilder).SetThresholds
+	_ func() *stc.SetOptionsBuilder                              = stc.NewSetOptions
+	_ func(*stc.SetOptionsBuilder) stc.SetOptions                = (*stc.SetOptionsBuilder).Op
+	_ func(*stc.SetOptionsBuilder, uint8) *stc.SetOptionsBuilder = (*stc.SetOptionsBuilder).SetMasterWeight
+	_ func(*stc.SetOptionsBuilder, stx.SignerKey,
+		uint8) *stc.SetOptionsBuilder = (*stc.SetOptionsBuilder).AddSigner
+	_ func(*stc.SetOptionsBuilder, stx.SignerKey) *stc.SetOptionsBuilder = (*stc.SetOptionsBuilder).RemoveSigner
+	_ func(*stc.SetOptionsBuilder, string) *stc.SetOptionsBuilder        = (*stc.SetOptionsBuilder).SetHomeDomain
+	_ func(*stc.SetOptionsBuilder, stc.AccountID) *stc.SetOptionsBuilder = (*stc.SetOptionsBuilder).SetInflationDest
+	_ func(*stc.SetOptionsBuilder,
+		...stx.AccountFlags) *stc.SetOptionsBuilder = (*stc.SetOptionsBuilder).SetFlags
+	_ func(*stc.SetOptionsBuilder,
+		...stx.AccountFlags) *stc.SetOptionsBuilder = (*stc.SetOptionsBuilder).ClearFlags
+	_ func(*stc.SetOptionsBuilder) *stc.SetOptionsBuilder         = (*stc.SetOptionsBuilder).EnableClawback
+	_ func(stc.AccountID, stx.Asset, int64) (stc.Clawback, error) = stc.NewClawback
+	_ func(string) (stc.ClawbackClaimableBalance, error)          = stc.NewClawbackClaimableBalance
+	_ func(stc.AccountID, stx.Asset) stc.SetTrustLineFlags        = stc.DisableTrustLineClawback
+	_ func(string) (stx.ClaimableBalanceID,
+		error) = stc.ParseClaimableBalanceID
+	_ func(stc.AccountID, stx.Asset) stc.SetTrustLineFlags = stc.AuthorizeTrustLine
+	_ func(stc.AccountID, stx.Asset) stc.SetTrustLineFlags = stc.AuthorizeToMaintainLiabilities
+	_ func(stc.AccountID, stx.Asset) stc.SetTrustLineFlags = stc.DeauthorizeTrustLine
+	_ func(*stx.SetTrustLineFlagsOp) error                 = stc.CheckTrustLineFlags
+	_ stx.TrustLineFlags                                   = stc.AuthorizedFlag
+	_ stx.TrustLineFlags                                   = stc.AuthorizedToMaintainLiabilitiesFlag
+	_ stx.TrustLineFlags                                   = stc.TrustLineClawbackEnabledFlag
+	_ func(stc.AccountID, stx.Asset, []stx.TrustLineFlags,
+		[]stx.TrustLineFlags) stc.SetTrustLineFlags = stc.NewSetTrustLineFlags
+	_ func(stx.LedgerKey) stc.RevokeSponsorship            = stc.RevokeLedgerEntrySponsorship
+	_ func(stc.AccountID) stc.RevokeSponsorship            = stc.RevokeAccountSponsorship
+	_ func(stc.AccountID, stx.Asset) stc.RevokeSponsorship = stc.RevokeTrustLineSponsorship
+	_ func(stc.AccountID, int64) stc.RevokeSponsorship     = stc.RevokeOfferSponsorship
+	_ func(stc.AccountID, string) stc.RevokeSponsorship    = stc.RevokeDataSponsorship
+	_ func(stx.ClaimableBalanceID) stc.RevokeSponsorship   = stc.RevokeClaimableBalanceSponsorship
+	_ func(stc.AccountID,
+		stx.SignerKey) stc.RevokeSponsorship = stc.RevokeSignerSponsorship
+	_ func(stc.AccountID, stc.AccountID, int64) *stc.TransactionEnvelope = stc.CreateAccountTx
+	_ func(stc.AccountID, stc.AccountID, int64, []stc.AccountID,
+		uint8) (*stc.TransactionEnvelope, error) = stc.EscrowTx
+	_ func(stc.AccountID, stx.Asset, int64, stc.AccountID, stx.Asset,
+		int64) *stc.TransactionEnvelope = stc.SwapTx
+	_ func(*stc.StellarNet, stc.AccountID,
+		stc.MuxedAccount) (*stc.TransactionEnvelope, error) = (*stc.StellarNet).MergeAccountTx
+	_ func(*stc.TxTemplate)        = stc.RegisterTxTemplate
+	_ func(string) *stc.TxTemplate = stc.LookupTxTemplate
+	_ func() []string              = stc.TxTemplateNames
+	_ func(*stc.TxTemplate, *stc.StellarNet,
+		map[string]string) (*stc.TransactionEnvelope, error) = (*stc.TxTemplate).Instantiate
+	_ func(string) (int64, error)     = stc.ParseAmount
+	_ func(int64) string              = stc.FormatAmount
+	_ func(string) (stx.Asset, error) = stc.ParseAsset
+	_ func(stx.Asset) error           = stc.CheckAsset
+	_ stc.SequenceProvider            = (&stc.StellarNet{}).Sequences
+	_ stc.SequenceProvider            = stc.HorizonSequences{}
+	_ stc.SequenceProvider            = &stc.SequenceCache{}
+	_ stc.SequenceInvalidator         = &stc.SequenceCache{}
+	_ func(stc.AccountID, stx.SequenceNumber,
+		stx.SequenceNumber) *stc.SequenceRange = stc.NewSequenceRange
+	_ func(*stc.SequenceRange) int64 = (*stc.SequenceRange).Remaining
+	_ func(*stc.StellarNet,
+		stc.AccountID) (stx.SequenceNumber, error) = (*stc.StellarNet).NextSequence
+	_ func(*stc.StellarNet,
+		*stc.TransactionEnvelope) error = (*stc.StellarNet).SetNextSequence
+	_ func(*stc.TransactionEnvelope) bool           = (*stc.TransactionEnvelope).HasMaxTime
+	_ func(*stc.TransactionEnvelope, time.Duration) = (*stc.TransactionEnvelope).SetTimeout
+	_ func(*stc.StellarNet, *stc.TransactionEnvelope,
+		time.Duration) error = (*stc.StellarNet).SetTimeoutFromLedger
+	_ func(*stc.TransactionEnvelope) []stc.TxProblem = stc.ValidateTx
+	_ func(stc.TxProblem) string                     = stc.TxProblem.String
+	_ int                                            = stc.MinBaseFee
+	_ int                                            = stc.MaxTxSignatures
+)
+
+// Encoding transactions
+var (
+	_ func(string) (*stc.TransactionEnvelope, error)         = stc.TxFromRep
+	_ func(string) (*stc.TransactionEnvelope, error)         = stc.TxFromBase64
+	_ func(*stc.TransactionEnvelope) string                  = stc.TxToBase64
+	_ func(*stc.StellarNet, *stc.TransactionEnvelope) string = (*stc.StellarNet).TxToRep
+	_ func(*stc.StellarNet, io.Writer, string, xdr.XdrType)  = (*stc.StellarNet).WriteRep
+	_ func(*stc.StellarNet, xdr.XdrType) []stc.RenderRow     = (*stc.StellarNet).TxrepRows
+	_ func(*stc.StellarNet, *stc.TransactionEnvelope,
+		*stc.RiskContext) []stc.RenderRow = (*stc.StellarNet).ExplainRows
+	_ func(*stc.StellarNet,
+		*stc.TransactionEnvelope) []stc.RenderRow = (*stc.StellarNet).SignatureRows
+	_ stc.Severity = stc.SeverityWarning
+)
+
+// Keys and signatures
+var (
+	_ func(stx.PublicKeyType) stc.PrivateKey        = stc.NewPrivateKey
+	_ func(string) stc.PrivateKey                   = stc.NetworkRootKey
+	_ func(*stc.StellarNet, stx.Signable) *stx.Hash = (*stc.StellarNet).HashTx
+	_ func(*stc.StellarNet, stcdetail.PrivateKeyInterface,
+		*stc.TransactionEnvelope) error = (*stc.StellarNet).SignTx
+	_ func(string, stcdetail.PrivateKeyInterface,
+		*stc.TransactionEnvelope) error = stc.SignTxForNetwork
+	_ func(string, stx.Signable) *stx.Hash                  = stc.HashTxForNetwork
+	_ func(*stc.StellarNet, *stc.TransactionEnvelope) error = (*stc.StellarNet).CheckApprovals
+	_ func()                                                = stc.SetReadOnly
+	_ func() bool                                           = stc.IsReadOnly
+	_ error                                                 = stc.ErrReadOnly
+	_ error                                                 = stc.ErrLedgerEntryNotFound
+	_ stcdetail.PrivateKeyInterface                         = stc.PrivateKey{}
+	_ func(string, string) (*stc.Mnemonic, error)           = stc.NewMnemonic
+	_ func(string) (*stc.Mnemonic, error)                   = stc.LoadMnemonic
+	_ func(*stc.Mnemonic, uint32) stc.PrivateKey            = (*stc.Mnemonic).Key
+	_ func(string, uint32) map[string]string                = stc.DerivedKeyHeaders
+	_ func(string) (map[string]string, error)               = stc.KeyFileHeaders
+	_ func(stc.PrivateKey, string, []byte,
+		map[string]string) error = stc.PrivateKey.SaveWithHeaders
+	_ func(*stc.StellarNet, stcdetail.PrivateKeyInterface,
+		*stc.TransactionEnvelope, []byte) error = (*stc.StellarNet).SignTxPayload
+	_ func(*stc.StellarNet, *stc.TransactionEnvelope,
+		*stc.TransactionEnvelope, map[string]*stc.HorizonAccountEntry) (
+		int, error) = (*stc.StellarNet).MergeSignatures
+	_ func(*stc.StellarNet, string, string) (
+		*stc.TransactionEnvelope, int, error) = (*stc.StellarNet).CollectTx
+	_ func(*stc.StellarNet, stc.TxShare, string,
+		map[string]*stc.HorizonAccountEntry) (
+		*stc.TransactionEnvelope, int, error) = (*stc.StellarNet).CollectTxFrom
+	_ func(*stc.StellarNet, string,
+		*stc.TransactionEnvelope) (string, error) = (*stc.StellarNet).ShareTx
+	_ func(*stc.StellarNet, stc.TxShare,
+		*stc.TransactionEnvelope) (string, error) = (*stc.StellarNet).ShareTxTo
+	_ func(string) (stc.TxShare, error) = stc.OpenTxShare
+	_ stc.TxShare                       = stc.DirShare("")
+	_ stc.TxShare                       = &stc.WebDAVShare{}
+	_ stc.TxShare                       = &stc.S3Share{}
+)
+
+// Querying and submitting
+var (
+	_ func(*stc.StellarNet, string) ([]byte, error)                   = (*stc.StellarNet).Get
+	_ func(*stc.StellarNet, context.Context, string) ([]byte, error)  = (*stc.StellarNet).GetCtx
+	_ func(*stc.StellarNet, string, interface{}) error                = (*stc.StellarNet).GetJSON
+	_ func(*stc.StellarNet, string) (*stc.HorizonAccountEntry, error) = (*stc.StellarNet).GetAccountEntry
+	_ func(*stc.StellarNet, string) (*stc.HorizonTxResult, error)     = (*stc.StellarNet).GetTxResult
+	_ func(*stc.StellarNet, string) (*stc.HorizonTransaction, error)  = (*stc.StellarNet).GetTransaction
+	_ func(*stc.StellarNet, string) ([]stc.HorizonOffer, error)       = (*stc.StellarNet).GetAccountOffers
+	_ func(*stc.StellarNet, string) (map[string][]byte, error)        = (*stc.StellarNet).GetAccountData
+	_ func(*stc.StellarNet, context.Context,
+		stc.AccountFilter) ([]stc.HorizonAccountEntry, error) = (*stc.StellarNet).FindAccounts
+	_ func(*stc.StellarNet, context.Context, string) (
+		*stc.AccountSummary, error) = (*stc.StellarNet).GetAccountSummary
+	_ func(*stc.StellarNet, context.Context, string) (
+		*stc.Receipt, error) = (*stc.StellarNet).NewReceipt
+	_ func(string, *stc.Receipt,
+		stcdetail.PrivateKeyInterface) error = stc.AppendReceipt
+	_ func([]byte) ([]stc.Receipt, error)    = stc.VerifyReceipts
+	_ func([]byte) (*stc.StellarToml, error) = stc.ParseStellarToml
+	_ func(*stc.StellarNet, context.Context, string) (
+		*stc.StellarToml, error) = (*stc.StellarNet).GetStellarToml
+	_ func(*stc.StellarNet, context.Context, string) (
+		*stc.TransferInfo, error) = (*stc.StellarNet).GetTransferInfo
+	_ func(*stc.HorizonAccountEntry) (map[string][]byte, error)   = (*stc.HorizonAccountEntry).DataEntries
+	_ func(map[string][]byte, map[string][]byte) []stc.ManageData = stc.DataOps
+	_ func(*stc.HorizonOffer) stc.ManageSellOffer                 = (*stc.HorizonOffer).Cancel
+	_ func(*stc.StellarNet) (*stc.FeeStats, error)                = (*stc.StellarNet).GetFeeStats
+	_ func(*stc.StellarNet) (*stc.LedgerHeader, error)            = (*stc.StellarNet).GetLedgerHeader
+	_ func(*stc.StellarNet, *stc.TransactionEnvelope) (
+		*stc.TransactionResult, error) = (*stc.StellarNet).Post
+	_ func(*stc.StellarNet, context.Context, *stc.TransactionEnvelope) (
+		*stc.TransactionResult, error) = (*stc.StellarNet).PostCtx
+	_ func(*stc.StellarNet, context.Context, string,
+		stc.PageParams) *stc.HorizonPager = (*stc.StellarNet).NewPager
+	_ func(*stc.StellarNet, *stc.TransactionEnvelope) (
+		*stc.AsyncSubmission, error) = (*stc.StellarNet).PostAsync
+	_ func(*stc.StellarNet, string) (
+		*stc.HorizonTxResult, error) = (*stc.StellarNet).WaitForTx
+	_ func(*stc.StellarNet, context.Context, string,
+		interface{}) error = (*stc.StellarNet).IterateJSON
+	_ func(*stc.StellarNet, context.Context, string,
+		interface{}) error = (*stc.StellarNet).StreamJSON
+	_ func(*stc.StellarNet, context.Context,
+		func(*horizon.Ledger) error) error = (*stc.StellarNet).StreamLedgers
+	_ func(*stc.HorizonAccountEntry) stx.SequenceNumber = (*stc.HorizonAccountEntry).NextSeq
+	_ func(*stc.FeeStats, int) stc.FeeVal               = (*stc.FeeStats).Percentile
+	_ error                                             = stc.TxFailure{}
+	_ func(stc.TxFailure) []stc.OperationError          = stc.TxFailure.OperationErrors
+	_ error                                             = stc.OperationError{}
+	_ error                                             = &stc.HorizonError{}
+	_ func(*stc.HorizonError) string                    = (*stc.HorizonError).ProblemType
+	_ func(*stc.HorizonError) (string, []string)        = (*stc.HorizonError).ResultCodes
+	_ func(string, string, string,
+		*stc.TransactionEnvelope) (*stc.ScheduledTx, error) = stc.NewScheduledTx
+	_ func(*stc.ScheduledTx) (time.Time, error)                = (*stc.ScheduledTx).Next
+	_ func(*stc.ScheduledTx) (*stc.TransactionEnvelope, error) = (*stc.ScheduledTx).Template
+	_ func(*stc.ScheduledTx, string) error                     = (*stc.ScheduledTx).Save
+	_ func(string) (*stc.ScheduledTx, error)                   = stc.LoadScheduledTx
+	_ func(string) ([]string, error)                           = stc.ScheduledTxNames
+	_ func(string, string) string                              = stc.ScheduledTxPath
+	_ func(*stc.StellarNet, *stc.TransactionEnvelope) error    = (*stc.StellarNet).RefreshTx
+	_ func(*stc.StellarNet, *stc.ScheduledTx,
+		stcdetail.PrivateKeyInterface) (
+		*stc.TransactionEnvelope, error) = (*stc.StellarNet).PrepareScheduledTx
+	_ func(*stc.StellarNet,
+		*stc.HorizonAccountEntry) *stc.AccountControl = (*stc.StellarNet).AccountControlOf
+	_ func(*stc.StellarNet, string) (*stc.AccountControl, error) = (*stc.StellarNet).GetAccountControl
+	_ func(*stc.AccountControl) string                           = (*stc.AccountControl).String
+	_ func(string) (*stc.RecoveryPlan, error)                    = stc.LoadRecoveryPlan
+	_ func(*stc.StellarNet, *stc.HorizonAccountEntry,
+		*stc.RecoveryPlan) (*stc.TransactionEnvelope, error) = (*stc.StellarNet).RecoveryTx
+	_ func(*stc.StellarNet, stx.Asset,
+		stx.Asset) (*stc.HorizonLiquidityPool, error) = (*stc.StellarNet).GetLiquidityPoolFor
+	_ func(*stc.HorizonLiquidityPool) (stc.HorizonReserve,
+		stc.HorizonReserve, error) = (*stc.HorizonLiquidityPool).ReservesAB
+	_ func(*stc.HorizonLiquidityPool, int64, int64,
+		float64) (stc.LiquidityPoolDeposit, error) = (*stc.HorizonLiquidityPool).DepositOp
+	_ func(*stc.HorizonLiquidityPool, int64,
+		float64) (stc.LiquidityPoolWithdraw, error) = (*stc.HorizonLiquidityPool).WithdrawOp
+)
+
+// Horizon resources
+var (
+	_                                      = horizon.Transaction{}
+	_                                      = horizon.Ledger{}
+	_                                      = horizon.Account{}
+	_                                      = horizon.Problem{}
+	_ func(*horizon.SubmitResponse) string = (*horizon.SubmitResponse).ResultXdr
+)
@@ -0,0 +1,82 @@
+package stc
+
+import (
+	"encoding/json"
+	"github.com/xdrpp/stc/stcdetail"
+	"github.com/xdrpp/stc/stx"
+	"net/url"
+)
+
+// Statistics about an issued asset as returned by horizon's assets
+// endpoint.  Amount and Num_accounts count only authorized
+// trustlines; Accounts and Balances break the totals down by
+// authorization state.
+type HorizonAssetStat struct {
+	Net          *StellarNet `json:"-"`
+	Paging_token string
+	Asset        stx.Asset `json:"-"`
+	Amount       stcdetail.JsonInt64e7
+	Num_accounts uint32
+	Accounts     struct {
+		Authorized                         uint32
+		Authorized_to_maintain_liabilities uint32
+		Unauthorized                       uint32
+	}
+	Balances struct {
+		Authorized                         stcdetail.JsonInt64e7
+		Authorized_to_maintain_liabilities stcdetail.JsonInt64e7
+		Unauthorized                       stcdetail.JsonInt64e7
+	}
+	Num_claimable_balances    uint32
+	Claimable_balances_amount stcdetail.JsonInt64e7
+	Num_liquidity_pools       uint32
+	Liquidity_pools_amount    stcdetail.JsonInt64e7
+	Flags                     HorizonFlags
+}
+
+func (as *HorizonAssetStat) UnmarshalJSON(data []byte) error {
+	type jas HorizonAssetStat
+	var jasset horizonAsset
+	if err := json.Unmarshal(data, (*jas)(as)); err != nil {
+		return err
+	} else if err = json.Unmarshal(data, &jasset); err != nil {
+		return err
+	}
+	return jasset.toAsset(&as.Asset)
+}
+
+func (as *HorizonAssetStat) String() string {
+	return stcdetail.PrettyPrintAux(as.Net.prettyPrintAux, as)
+}
+
+// Fetches statistics about assets with the given code issued by
+// issuer (a strkey).  Either argument may be "" to match any code or
+// issuer.  The first element of opts, if any, selects the page; use
+// NewPager with the same query to walk through more assets.  Since
+// anyone can issue an asset with any code, it is a good idea to check
+// that an asset has the expected issuer, holders, and flags before
+// trusting it.  For example, to see which issuers have issued USD:
+//
+//	stats, err := net.GetAssets("USD", "")
+func (net *StellarNet) GetAssets(code, issuer string,
+	opts ...PageParams) ([]HorizonAssetStat, error) {
+	query := "assets"
+	v := url.Values{}
+	if code != "" {
+		v.Set("asset_code", code)
+	}
+	if issuer != "" {
+		v.Set("asset_issuer", issuer)
+	}
+	if len(v) > 0 {
+		query += "?" + v.Encode()
+	}
+	var params PageParams
+	if len(opts) > 0 {
+		params = opts[0]
+	}
+	var ret []HorizonAssetStat
+	pager := net.NewPager(nil, query, params)
+	pager.Next(&ret)
+	return ret, pager.Err()
+}
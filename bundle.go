@@ -0,0 +1,255 @@
+package stc
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/xdrpp/stc/stx"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+)
+
+// Version of the bundle format written by NewTxBundle.
+const TxBundleVersion = 1
+
+// A TxBundle packages a transaction together with the network state
+// needed to validate it, so that a signer on an air-gapped machine
+// can check sequence numbers, fees, signature thresholds, and risky
+// operations without network access.  Account entries and fee
+// statistics are stored exactly as horizon returned them, and parsed
+// with the same code used for online queries.  A bundle is stored as
+// a single JSON object; use json.Marshal and ParseTxBundle to convert
+// it to and from bytes.
+type TxBundle struct {
+	// Always TxBundleVersion; identifies the file as a bundle.
+	Version int `json:"stc_bundle"`
+
+	// The network passphrase of the network for which the
+	// transaction is intended.
+	NetworkId string `json:"network_id"`
+
+	// When the bundle was created.
+	Created time.Time `json:"created"`
+
+	// The transaction envelope in base64 XDR.
+	Tx string `json:"tx"`
+
+	// Horizon's JSON for each source account of the transaction,
+	// indexed by strkey.
+	Accounts map[string]json.RawMessage `json:"accounts"`
+
+	// Horizon's fee_stats JSON when the bundle was created.
+	FeeStats json.RawMessage `json:"fee_stats,omitempty"`
+}
+
+// Returns the source accounts of a transaction and its operations
+// (and the fee source of a fee bump), without duplicates.
+func txSourceAccounts(e *TransactionEnvelope) []string {
+	var ret []string
+	seen := make(map[string]bool)
+	add := func(acct *AccountID) {
+		if s := acct.String(); !seen[s] {
+			seen[s] = true
+			ret = append(ret, s)
+		}
+	}
+	if e.Type == stx.ENVELOPE_TYPE_TX_FEE_BUMP {
+		fs, _ := DemuxAcct(&e.FeeBump().Tx.FeeSource)
+		add(fs)
+	}
+	inner := innerTx(e)
+	if ops := inner.Operations(); ops != nil {
+		txsrc, _ := DemuxAcct(inner.SourceAccount())
+		add(txsrc)
+		for i := range *ops {
+			add(opSource(inner, &(*ops)[i]))
+		}
+	}
+	return ret
+}
+
+// Fetches the entries of the source accounts of a transaction and
+// its operations (and the fee source of a fee bump) from horizon,
+// indexed by strkey.  The result is suitable for SigWeights and
+// RiskContext.Accounts.
+func (net *StellarNet) GetSourceAccounts(e *TransactionEnvelope) (
+	map[string]*HorizonAccountEntry, error) {
+	ret := make(map[string]*HorizonAccountEntry)
+	for _, acct := range txSourceAccounts(e) {
+		ae, err := net.GetAccountEntry(acct)
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %w", acct, err)
+		}
+		ret[acct] = ae
+	}
+	return ret, nil
+}
+
+// Creates a bundle for transaction e by fetching the entries of its
+// source accounts and current fee statistics from horizon.
+func (net *StellarNet) NewTxBundle(e *TransactionEnvelope) (
+	*TxBundle, error) {
+	ret := &TxBundle{
+		Version:   TxBundleVersion,
+		NetworkId: net.GetNetworkId(),
+		Created:   time.Now().UTC().Truncate(time.Second),
+		Tx:        TxToBase64(e),
+		Accounts:  make(map[string]json.RawMessage),
+	}
+	for _, acct := range txSourceAccounts(e) {
+		body, err := net.Get("accounts/" + acct)
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %w", acct, err)
+		}
+		ret.Accounts[acct] = body
+	}
+	if body, err := net.Get("fee_stats"); err != nil {
+		return nil, err
+	} else {
+		ret.FeeStats = body
+	}
+	return ret, nil
+}
+
+// Returns true if input looks like a TxBundle (as opposed to some
+// other JSON object, such as a transaction in JSON format).
+func IsTxBundle(input []byte) bool {
+	var v struct {
+		Version *int `json:"stc_bundle"`
+	}
+	return json.Unmarshal(input, &v) == nil && v.Version != nil
+}
+
+// Parses a bundle written by json.Marshal.
+func ParseTxBundle(input []byte) (*TxBundle, error) {
+	var ret TxBundle
+	if err := json.Unmarshal(input, &ret); err != nil {
+		return nil, err
+	} else if ret.Version != TxBundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", ret.Version)
+	}
+	return &ret, nil
+}
+
+// Returns the bundled transaction.
+func (b *TxBundle) Envelope() (*TransactionEnvelope, error) {
+	return TxFromBase64(b.Tx)
+}
+
+// Parses the bundled account entries, indexed by strkey.
+func (b *TxBundle) AccountEntries(net *StellarNet) (
+	map[string]*HorizonAccountEntry, error) {
+	ret := make(map[string]*HorizonAccountEntry)
+	for acct, raw := range b.Accounts {
+		ae := &HorizonAccountEntry{Net: net}
+		if err := json.Unmarshal(raw, ae); err != nil {
+			return nil, fmt.Errorf("account %s: %w", acct, err)
+		}
+		ret[acct] = ae
+	}
+	return ret, nil
+}
+
+// Parses the bundled fee statistics.  Returns nil (and no error) if
+// the bundle has none.
+func (b *TxBundle) GetFeeStats() (*FeeStats, error) {
+	if len(b.FeeStats) == 0 {
+		return nil, nil
+	}
+	var ret FeeStats
+	if err := json.Unmarshal(b.FeeStats, &ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// Checks that the bundle is for network net and that the
+// transaction's sequence number is the next one for its source
+// account, returning the first problem found.  Use SigWeights and
+// TxRisks (with the bundle's account entries) for threshold and risk
+// analysis.
+func (b *TxBundle) Validate(net *StellarNet) error {
+	if b.NetworkId != net.GetNetworkId() {
+		return fmt.Errorf("bundle is for network %q, not %q", b.NetworkId,
+			net.GetNetworkId())
+	}
+	e, err := b.Envelope()
+	if err != nil {
+		return err
+	}
+	accts, err := b.AccountEntries(net)
+	if err != nil {
+		return err
+	}
+	inner := innerTx(e)
+	var seq stx.SequenceNumber
+	switch inner.Type {
+	case stx.ENVELOPE_TYPE_TX_V0:
+		seq = inner.V0().Tx.SeqNum
+	case stx.ENVELOPE_TYPE_TX:
+		seq = inner.V1().Tx.SeqNum
+	}
+	src, _ := DemuxAcct(inner.SourceAccount())
+	if ae := accts[src.String()]; ae == nil {
+		return fmt.Errorf("bundle lacks source account %s", src)
+	} else if next := ae.NextSeq(); seq != next {
+		return fmt.Errorf("sequence number %d should be %d", seq, next)
+	}
+	return nil
+}
+
+// Reads account entries saved in the files of directory dir, so that
+// signers and thresholds can be learned without network access.  Each
+// file may contain either a TxBundle or horizon's JSON for a single
+// account (as returned by the "accounts/G..." endpoint).  Other files,
+// and bundles for networks other than net, are ignored.  If several
+// files contain the same account, the entry with the highest
+// last_modified_ledger is used.  The result is indexed by strkey.
+func (net *StellarNet) ReadAccountDir(dir string) (
+	map[string]*HorizonAccountEntry, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]*HorizonAccountEntry)
+	add := func(acct string, ae *HorizonAccountEntry) {
+		if old := ret[acct]; old == nil ||
+			ae.Last_modified_ledger > old.Last_modified_ledger {
+			ret[acct] = ae
+		}
+	}
+	for _, fi := range fis {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		input, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if IsTxBundle(input) {
+			b, err := ParseTxBundle(input)
+			if err != nil || b.NetworkId != net.GetNetworkId() {
+				continue
+			}
+			accts, err := b.AccountEntries(net)
+			if err != nil {
+				continue
+			}
+			for acct, ae := range accts {
+				add(acct, ae)
+			}
+			continue
+		}
+		var id struct {
+			Account_id string
+		}
+		if json.Unmarshal(input, &id) != nil || id.Account_id == "" {
+			continue
+		}
+		ae := &HorizonAccountEntry{Net: net}
+		if json.Unmarshal(input, ae) == nil {
+			add(id.Account_id, ae)
+		}
+	}
+	return ret, nil
+}
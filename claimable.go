@@ -0,0 +1,297 @@
+package stc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/xdrpp/stc/stcdetail"
+	"github.com/xdrpp/stc/stx"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// A claim predicate as horizon represents it in JSON.
+type horizonPredicate struct {
+	Unconditional    bool
+	And              []horizonPredicate
+	Or               []horizonPredicate
+	Not              *horizonPredicate
+	Abs_before       string
+	Abs_before_epoch string
+	Rel_before       string
+}
+
+func (hp *horizonPredicate) toXdr(out *stx.ClaimPredicate) error {
+	switch {
+	case hp.Unconditional:
+		out.Type = stx.CLAIM_PREDICATE_UNCONDITIONAL
+	case hp.And != nil || hp.Or != nil:
+		sub := hp.And
+		out.Type = stx.CLAIM_PREDICATE_AND
+		if hp.Or != nil {
+			sub = hp.Or
+			out.Type = stx.CLAIM_PREDICATE_OR
+		}
+		preds := make([]stx.ClaimPredicate, len(sub))
+		for i := range sub {
+			if err := sub[i].toXdr(&preds[i]); err != nil {
+				return err
+			}
+		}
+		if out.Type == stx.CLAIM_PREDICATE_AND {
+			*out.AndPredicates() = preds
+		} else {
+			*out.OrPredicates() = preds
+		}
+	case hp.Not != nil:
+		out.Type = stx.CLAIM_PREDICATE_NOT
+		*out.NotPredicate() = &stx.ClaimPredicate{}
+		return hp.Not.toXdr(*out.NotPredicate())
+	case hp.Abs_before_epoch != "":
+		out.Type = stx.CLAIM_PREDICATE_BEFORE_ABSOLUTE_TIME
+		t, err := strconv.ParseInt(hp.Abs_before_epoch, 10, 64)
+		*out.AbsBefore() = t
+		return err
+	case hp.Abs_before != "":
+		// Older horizon versions only supply an RFC 3339 time.
+		out.Type = stx.CLAIM_PREDICATE_BEFORE_ABSOLUTE_TIME
+		t, err := time.Parse(time.RFC3339, hp.Abs_before)
+		*out.AbsBefore() = t.Unix()
+		return err
+	case hp.Rel_before != "":
+		out.Type = stx.CLAIM_PREDICATE_BEFORE_RELATIVE_TIME
+		t, err := strconv.ParseInt(hp.Rel_before, 10, 64)
+		*out.RelBefore() = t
+		return err
+	default:
+		return horizonFailure("unknown claim predicate")
+	}
+	return nil
+}
+
+// Converts the claimant to its XDR representation, as used in
+// CREATE_CLAIMABLE_BALANCE operations.
+func (c *HorizonClaimant) ToClaimant() (stx.Claimant, error) {
+	ret := stx.Claimant{Type: stx.CLAIMANT_TYPE_V0}
+	ret.V0().Destination = c.Destination
+	var hp horizonPredicate
+	err := json.Unmarshal(c.Predicate, &hp)
+	if err == nil {
+		err = hp.toXdr(&ret.V0().Predicate)
+	}
+	return ret, err
+}
+
+// A claimable balance as returned by horizon's claimable_balances
+// endpoints.  Id can be used directly in a CLAIM_CLAIMABLE_BALANCE
+// operation, for example:
+//
+//	txe.Append(nil, ClaimClaimableBalance{BalanceID: cb.Id})
+type HorizonClaimableBalance struct {
+	Net                  *StellarNet            `json:"-"`
+	Id                   stx.ClaimableBalanceID `json:"-"`
+	Paging_token         string
+	Asset                stx.Asset `json:"-"`
+	Amount               stcdetail.JsonInt64e7
+	Sponsor              *AccountID
+	Last_modified_ledger uint32
+	Last_modified_time   time.Time
+	Claimants            []stx.Claimant `json:"-"`
+	Flags                struct {
+		Clawback_enabled bool
+	}
+}
+
+func (cb *HorizonClaimableBalance) UnmarshalJSON(data []byte) error {
+	type jcb HorizonClaimableBalance
+	var j struct {
+		Id        string
+		Asset     string
+		Claimants []HorizonClaimant
+	}
+	if err := json.Unmarshal(data, (*jcb)(cb)); err != nil {
+		return err
+	} else if err = json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	var err error
+	if cb.Id, err = ParseClaimableBalanceID(j.Id); err != nil {
+		return err
+	}
+	// Horizon renders this asset as "native" or "CODE:ISSUER".
+	if _, err := fmt.Sscan(j.Asset, &cb.Asset); err != nil {
+		return err
+	}
+	cb.Claimants = make([]stx.Claimant, len(j.Claimants))
+	for i := range j.Claimants {
+		var err error
+		if cb.Claimants[i], err = j.Claimants[i].ToClaimant(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cb *HorizonClaimableBalance) String() string {
+	return stcdetail.PrettyPrintAux(cb.Net.prettyPrintAux, cb)
+}
+
+// Selects the claimable balances returned by GetClaimableBalances.
+// Claimant and Sponsor are account IDs in strkey format.  Fields
+// that are empty (or nil) do not restrict the results.
+type ClaimableBalanceFilter struct {
+	Claimant string
+	Sponsor  string
+	Asset    *stx.Asset
+}
+
+// Parses a claimable balance ID in the format horizon uses, which is
+// the hex XDR of a stx.ClaimableBalanceID (as output by stc -opid).
+func ParseClaimableBalanceID(id string) (stx.ClaimableBalanceID, error) {
+	var ret stx.ClaimableBalanceID
+	bin, err := hex.DecodeString(id)
+	if err == nil {
+		err = stcdetail.XdrFromBin(&ret, string(bin))
+	}
+	return ret, err
+}
+
+// Fetches the claimable balance with the given ID, which is the hex
+// XDR of a stx.ClaimableBalanceID (as output by stc -opid).
+func (net *StellarNet) GetClaimableBalance(id string) (
+	*HorizonClaimableBalance, error) {
+	ret := HorizonClaimableBalance{Net: net}
+	if err := net.GetJSON("claimable_balances/"+id, &ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// Fetches a page of the claimable balances selected by f.  The first
+// element of opts, if any, selects the page; use NewPager with the
+// same query to walk through more balances.  For example, to find
+// balances an account can claim:
+//
+//	cbs, err := net.GetClaimableBalances(
+//		ClaimableBalanceFilter{Claimant: acct})
+func (net *StellarNet) GetClaimableBalances(f ClaimableBalanceFilter,
+	opts ...PageParams) ([]HorizonClaimableBalance, error) {
+	query := "claimable_balances"
+	v := url.Values{}
+	if f.Claimant != "" {
+		v.Set("claimant", f.Claimant)
+	}
+	if f.Sponsor != "" {
+		v.Set("sponsor", f.Sponsor)
+	}
+	if f.Asset != nil {
+		v.Set("asset", f.Asset.String())
+	}
+	if len(v) > 0 {
+		query += "?" + v.Encode()
+	}
+	var params PageParams
+	if len(opts) > 0 {
+		params = opts[0]
+	}
+	var ret []HorizonClaimableBalance
+	pager := net.NewPager(nil, query, params)
+	pager.Next(&ret)
+	return ret, pager.Err()
+}
+
+// Returns a claim predicate that is always satisfied.
+func Unconditional() stx.ClaimPredicate {
+	return stx.ClaimPredicate{Type: stx.CLAIM_PREDICATE_UNCONDITIONAL}
+}
+
+// Returns a claim predicate satisfied when both a and b are.
+func And(a, b stx.ClaimPredicate) stx.ClaimPredicate {
+	ret := stx.ClaimPredicate{Type: stx.CLAIM_PREDICATE_AND}
+	*ret.AndPredicates() = []stx.ClaimPredicate{a, b}
+	return ret
+}
+
+// Returns a claim predicate satisfied when either a or b is.
+func Or(a, b stx.ClaimPredicate) stx.ClaimPredicate {
+	ret := stx.ClaimPredicate{Type: stx.CLAIM_PREDICATE_OR}
+	*ret.OrPredicates() = []stx.ClaimPredicate{a, b}
+	return ret
+}
+
+// Returns a claim predicate satisfied when p is not.
+func Not(p stx.ClaimPredicate) stx.ClaimPredicate {
+	ret := stx.ClaimPredicate{Type: stx.CLAIM_PREDICATE_NOT}
+	*ret.NotPredicate() = &p
+	return ret
+}
+
+// Returns a claim predicate satisfied before time t (to the second).
+func BeforeAbs(t time.Time) stx.ClaimPredicate {
+	ret := stx.ClaimPredicate{Type: stx.CLAIM_PREDICATE_BEFORE_ABSOLUTE_TIME}
+	*ret.AbsBefore() = t.Unix()
+	return ret
+}
+
+// Returns a claim predicate satisfied until d (rounded down to the
+// second) after the ledger in which the claimable balance is created.
+func BeforeRel(d time.Duration) stx.ClaimPredicate {
+	ret := stx.ClaimPredicate{Type: stx.CLAIM_PREDICATE_BEFORE_RELATIVE_TIME}
+	*ret.RelBefore() = int64(d / time.Second)
+	return ret
+}
+
+// Returns a claimant allowing dest to claim a balance when pred is
+// satisfied.  For example, to let acct claim a balance within a week
+// and have it revert to the creator self afterwards:
+//
+//	week := BeforeRel(7 * 24 * time.Hour)
+//	txe.Append(nil, CreateClaimableBalance{
+//		Asset:  NativeAsset(),
+//		Amount: 10 * 10000000,
+//		Claimants: []stx.Claimant{
+//			NewClaimant(acct, week),
+//			NewClaimant(self, Not(week)),
+//		},
+//	})
+func NewClaimant(dest AccountID, pred stx.ClaimPredicate) stx.Claimant {
+	ret := stx.Claimant{Type: stx.CLAIMANT_TYPE_V0}
+	ret.V0().Destination = dest
+	ret.V0().Predicate = pred
+	return ret
+}
+
+// Returns the ID of the claimable balance created by operation number
+// opNum (counting from 0) of a transaction with source account src
+// and sequence number seq.  Note that the source of the transaction
+// determines the ID even when the operation has its own source.
+func ClaimableBalanceID(src *AccountID, seq stx.SequenceNumber,
+	opNum uint32) stx.ClaimableBalanceID {
+	var opid stx.OperationID
+	opid.Type = stx.ENVELOPE_TYPE_OP_ID
+	opid.Id().SourceAccount = *src
+	opid.Id().SeqNum = seq
+	opid.Id().OpNum = opNum
+	var ret stx.ClaimableBalanceID
+	ret.Type = stx.CLAIMABLE_BALANCE_ID_TYPE_V0
+	*ret.V0() = stcdetail.XdrSHA256(&opid)
+	return ret
+}
+
+// Returns the ID of the claimable balance that operation number opNum
+// of txe would create (see ClaimableBalanceID).  For a fee bump, the
+// ID depends on the inner transaction.
+func (txe *TransactionEnvelope) ClaimableBalanceID(
+	opNum int) stx.ClaimableBalanceID {
+	inner := innerTx(txe)
+	var seq stx.SequenceNumber
+	switch inner.Type {
+	case stx.ENVELOPE_TYPE_TX_V0:
+		seq = inner.V0().Tx.SeqNum
+	case stx.ENVELOPE_TYPE_TX:
+		seq = inner.V1().Tx.SeqNum
+	}
+	src, _ := DemuxAcct(inner.SourceAccount())
+	return ClaimableBalanceID(src, seq, uint32(opNum))
+}
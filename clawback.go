@@ -0,0 +1,50 @@
+package stc
+
+import (
+	"fmt"
+	"github.com/xdrpp/stc/stx"
+)
+
+// Returns a CLAWBACK operation that takes amount of asset back from
+// account from, burning it.  The operation's source must be the
+// asset's issuer, and from's trustline must have clawback enabled,
+// which it does if the issuer had AuthClawbackEnabledFlag set when
+// the trustline was created (see SetOptionsBuilder.EnableClawback).
+func NewClawback(from AccountID, asset stx.Asset,
+	amount int64) (Clawback, error) {
+	ret := Clawback{
+		Asset:  asset,
+		From:   *from.ToMuxedAccount(),
+		Amount: amount,
+	}
+	if asset.Type == stx.ASSET_TYPE_NATIVE {
+		return ret, fmt.Errorf("cannot claw back the native asset")
+	} else if err := CheckAsset(asset); err != nil {
+		return ret, err
+	} else if amount <= 0 {
+		return ret, fmt.Errorf("clawback amount must be positive")
+	}
+	return ret, nil
+}
+
+// Returns a CLAWBACK_CLAIMABLE_BALANCE operation that reclaims the
+// claimable balance with the given ID, in the hex format of
+// ParseClaimableBalanceID.  The operation's source must be the issuer
+// of the balance's asset, and the balance must have clawback enabled.
+func NewClawbackClaimableBalance(id string) (
+	ClawbackClaimableBalance, error) {
+	bid, err := ParseClaimableBalanceID(id)
+	return ClawbackClaimableBalance{BalanceID: bid}, err
+}
+
+// Returns a SET_TRUST_LINE_FLAGS operation by which the issuer of
+// asset permanently disables clawback on trustor's trustline.  (An
+// issuer can clear TrustLineClawbackEnabledFlag, but never set it.)
+func DisableTrustLineClawback(trustor AccountID,
+	asset stx.Asset) SetTrustLineFlags {
+	return SetTrustLineFlags{
+		Trustor:    trustor,
+		Asset:      asset,
+		ClearFlags: uint32(TrustLineClawbackEnabledFlag),
+	}
+}
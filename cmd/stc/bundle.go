@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	. "github.com/xdrpp/stc"
+	"github.com/xdrpp/stc/stcdetail"
+)
+
+func bundleText(b *TxBundle) string {
+	out, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return string(out) + "\n"
+}
+
+// Write a bundle for transaction e to standard output.
+func doBundle(net *StellarNet, e *TransactionEnvelope) {
+	b, err := net.NewTxBundle(e)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create bundle: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Print(bundleText(b))
+}
+
+func mustWriteBundle(outfile string, b *TxBundle) {
+	if err := stcdetail.SafeWriteFile(outfile, bundleText(b),
+		0666); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+// Analyze a bundle without network access.  Returns false if the
+// transaction is invalid.
+func doCheckBundle(net *StellarNet, infile string) bool {
+	input, infile, err := readInput(infile)
+	if err == nil && !IsTxBundle(input) {
+		err = fmt.Errorf("%s: not a bundle", infile)
+	}
+	var b *TxBundle
+	if err == nil {
+		b, err = ParseTxBundle(input)
+	}
+	var e *TransactionEnvelope
+	if err == nil {
+		e, err = b.Envelope()
+	}
+	var accts map[string]*HorizonAccountEntry
+	if err == nil {
+		accts, err = b.AccountEntries(net)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("bundle created %s\n", b.Created.Local().Format(
+		"2006-01-02 15:04:05 MST"))
+	fmt.Println(net.ExplainTx(e))
+
+	ok := true
+	if err = b.Validate(net); err != nil {
+		fmt.Printf("INVALID: %s\n", err)
+		ok = false
+	}
+
+	if fs, err := b.GetFeeStats(); err == nil && fs != nil {
+		if fee, nops := txFee(e); nops > 0 {
+			fmt.Printf("fee: %d per operation; when bundled, congestion "+
+				"was %s and suggested fee was %d\n", fee/nops,
+				fs.Congestion(), fs.SuggestedFee())
+		}
+	}
+
+	for _, w := range net.SigWeights(e, accts) {
+		status := "needs more signatures"
+		if w.Sufficient() {
+			status = "sufficiently signed"
+		}
+		fmt.Printf("%s: weight %d of %d, %s\n",
+			net.DescribeAccount(w.Account), w.Have, w.Need, status)
+	}
+
+	for _, r := range net.TxRisks(e, &RiskContext{Accounts: accts}) {
+		fmt.Printf("warning: %s\n", r)
+	}
+	return ok
+}
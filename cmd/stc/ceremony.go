@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	. "github.com/xdrpp/stc"
+	"github.com/xdrpp/stc/stcdetail"
+	"github.com/xdrpp/stc/stx"
+)
+
+// Print a status line for each account's signature weight and return
+// the first account that has not yet signed with enough weight, or
+// nil if the transaction is fully authorized.
+func showSigWeights(net *StellarNet, weights []SigWeight) *SigWeight {
+	var ret *SigWeight
+	for i := range weights {
+		w := &weights[i]
+		if w.Sufficient() {
+			fmt.Printf("  %s: weight %d of %d, done\n",
+				net.DescribeAccount(w.Account), w.Have, w.Need)
+		} else {
+			fmt.Printf("  %s: weight %d of %d, needs %d more\n",
+				net.DescribeAccount(w.Account), w.Have, w.Need,
+				w.Need-w.Have)
+			if ret == nil {
+				ret = w
+			}
+		}
+	}
+	return ret
+}
+
+// Sign e with sk as a signer for account acct.  For a fee bump, the
+// fee source signs the outer envelope and other accounts sign the
+// inner transaction.
+func ceremonySign(net *StellarNet, e *TransactionEnvelope,
+	acct *AccountID, sk PrivateKey) error {
+	if e.Type != stx.ENVELOPE_TYPE_TX_FEE_BUMP {
+		return net.SignTx(sk, e)
+	} else if fs, _ := DemuxAcct(&e.FeeBump().Tx.FeeSource); fs.String() ==
+		acct.String() {
+		return net.SignTx(sk, e)
+	}
+	inner := &TransactionEnvelope{
+		TransactionEnvelope: &stx.TransactionEnvelope{
+			Type: stx.ENVELOPE_TYPE_TX,
+		},
+	}
+	*inner.V1() = *e.FeeBump().Tx.InnerTx.V1()
+	if err := net.SignTx(sk, inner); err != nil {
+		return err
+	}
+	e.FeeBump().Tx.InnerTx.V1().Signatures = inner.V1().Signatures
+	return nil
+}
+
+// Merge the signatures on all copies of transaction txid in shared
+// location dir, save the result back to dir, and report how close the
+// transaction is to being fully authorized.
+func doCollect(net *StellarNet, dir, txid string) {
+	share, err := OpenTxShare(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	e, n, err := net.CollectTxFrom(share, txid, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	name, err := net.ShareTxTo(share, e)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("merged signatures from %d files into %s in %s\n",
+		n, name, share)
+	accts, err := net.GetSourceAccounts(e)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot check thresholds: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Signatures:")
+	if showSigWeights(net, net.SigWeights(e, accts)) == nil {
+		fmt.Println("Transaction is fully authorized.")
+	}
+	if net.Approvals > 0 {
+		fmt.Printf("Approvers: %d of %d\n", len(net.TxApprovers(e)),
+			net.Approvals)
+	}
+}
+
+// Returns true if pk is a signer of the account described by ae.
+func isSignerOf(ae *HorizonAccountEntry, pk PublicKey) bool {
+	target := pk.String()
+	for i := range ae.Signers {
+		if ae.Signers[i].Weight > 0 &&
+			ae.Signers[i].Key.String() == target {
+			return true
+		}
+	}
+	return false
+}
+
+// Interactively collect signatures on a transaction until every
+// source account has signed with enough weight, then write the
+// transaction back to infile.  If infile is a bundle, account state
+// comes from the bundle and no network access is required.
+func doCeremony(net *StellarNet, infile string) {
+	if infile == "-" {
+		fmt.Fprintln(os.Stderr, "-ceremony requires a file, not stdin")
+		os.Exit(2)
+	}
+	input, _, err := readInput(infile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var bundle *TxBundle
+	var e *TransactionEnvelope
+	var accts map[string]*HorizonAccountEntry
+	infmt := guessFormat(string(input))
+	if infmt == fmt_bundle {
+		if bundle, err = ParseTxBundle(input); err == nil {
+			if err = bundle.Validate(net); err == nil {
+				e, _ = bundle.Envelope()
+				accts, err = bundle.AccountEntries(net)
+			}
+		}
+	} else {
+		if e, infmt, err = readTx(infile); err == nil {
+			accts, err = net.GetSourceAccounts(e)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Println(net.ExplainTx(e))
+	for _, r := range net.TxRisks(e, &RiskContext{Accounts: accts}) {
+		fmt.Printf("warning: %s\n", r)
+	}
+
+	used := make(map[string]bool)
+	save := func() {
+		if bundle != nil {
+			bundle.Tx = TxToBase64(e)
+			mustWriteBundle(infile, bundle)
+		} else {
+			mustWriteTx(infile, e, net, infmt)
+		}
+	}
+	for {
+		fmt.Println("Signatures:")
+		need := showSigWeights(net, net.SigWeights(e, accts))
+		if need == nil {
+			fmt.Println("Transaction is fully authorized.")
+			save()
+			return
+		}
+		ae := accts[need.Account.String()]
+		fmt.Printf("Signers of %s:\n", net.DescribeAccount(need.Account))
+		for _, s := range ae.Signers {
+			if s.Weight == 0 {
+				continue
+			}
+			if note := net.SignerNote(&s.Key); note != "" {
+				fmt.Printf("  %s weight %d (%s)\n", s.Key, s.Weight, note)
+			} else {
+				fmt.Printf("  %s weight %d\n", s.Key, s.Weight)
+			}
+		}
+		fmt.Print("Key name, \"-\" to type a secret key, " +
+			"or RETURN to stop: ")
+		line, err := stcdetail.ReadTextLine(os.Stdin)
+		name := strings.TrimSpace(string(line))
+		if name == "" {
+			if err == nil {
+				fmt.Println("Stopping before transaction is fully " +
+					"authorized.")
+			}
+			save()
+			return
+		} else if name == "-" {
+			name = ""
+		} else {
+			name = AdjustKeyName(name)
+		}
+		sk, err := getSecKey(name)
+		if err != nil {
+			continue
+		}
+		pk := sk.Public()
+		if !isSignerOf(ae, pk) {
+			fmt.Printf("%s is not a signer of %s\n", pk, need.Account)
+			continue
+		} else if used[need.Account.String()+pk.String()] {
+			fmt.Printf("%s has already signed\n", pk)
+			continue
+		}
+		if err = ceremonySign(net, e, &need.Account, sk); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		used[need.Account.String()+pk.String()] = true
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	. "github.com/xdrpp/stc"
+	"github.com/xdrpp/stc/ini"
+)
+
+type doctor struct {
+	problems int
+}
+
+func (d *doctor) ok(format string, args ...interface{}) {
+	fmt.Printf("ok:      "+format+"\n", args...)
+}
+
+func (d *doctor) problem(fix string, format string, args ...interface{}) {
+	d.problems++
+	fmt.Printf("PROBLEM: "+format+"\n", args...)
+	if fix != "" {
+		fmt.Printf("    fix: %s\n", fix)
+	}
+}
+
+func (d *doctor) checkPerm(path string, fi os.FileInfo, bad os.FileMode,
+	want string) {
+	if mode := fi.Mode().Perm(); mode&bad != 0 {
+		d.problem(fmt.Sprintf("chmod %s %s", want, path),
+			"%s has permissions %#o", path, mode)
+	}
+}
+
+func (d *doctor) checkConfigDir() {
+	dir := ConfigPath()
+	if fi, err := os.Stat(dir); err != nil {
+		d.problem("set $STCDIR to a writable directory",
+			"configuration directory: %s", err)
+		return
+	} else if !fi.IsDir() {
+		d.problem("remove it or set $STCDIR elsewhere",
+			"%s is not a directory", dir)
+		return
+	} else {
+		d.checkPerm(dir, fi, 0022, "go-w")
+	}
+	d.ok("configuration directory %s", dir)
+
+	keydir := ConfigPath("keys")
+	fi, err := os.Stat(keydir)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		d.problem("", "%s", err)
+		return
+	}
+	d.checkPerm(keydir, fi, 0077, "700")
+	for _, name := range GetKeyNames() {
+		path := filepath.Join(keydir, name)
+		fi, err := os.Stat(path)
+		if err != nil {
+			d.problem("", "%s", err)
+			continue
+		}
+		d.checkPerm(path, fi, 0077, "400")
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			d.problem("", "%s", err)
+		} else if !bytes.HasPrefix(contents, []byte("S")) &&
+			!bytes.HasPrefix(contents, []byte(PassKeyPrefix)) &&
+			!bytes.HasPrefix(contents, []byte("-----BEGIN PGP MESSAGE")) {
+			d.problem(fmt.Sprintf("re-import it with %s -import-key %s",
+				progname, name), "%s does not contain a private key", path)
+		}
+	}
+	d.ok("%d private keys", len(GetKeyNames()))
+}
+
+type nullSink struct{}
+
+func (nullSink) Item(ini.IniItem) error { return nil }
+
+func (d *doctor) checkConfigFile(path string, sink ini.IniSink) bool {
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false
+	} else if err != nil {
+		d.problem("", "%s", err)
+		return true
+	}
+	if err = ini.IniParseContents(sink, path, contents); err != nil {
+		d.problem("edit the file to fix the syntax error(s)",
+			"%s", strings.TrimSpace(err.Error()))
+	} else {
+		d.ok("parsed %s", path)
+	}
+	return true
+}
+
+// Returns the names of networks with configuration files.
+func doctorNetNames() []string {
+	var ret []string
+	matches, _ := filepath.Glob(ConfigPath("*.net"))
+	for _, m := range matches {
+		if fi, err := os.Lstat(m); err == nil &&
+			fi.Mode()&os.ModeSymlink != 0 {
+			continue // e.g., default.net
+		}
+		ret = append(ret, strings.TrimSuffix(filepath.Base(m), ".net"))
+	}
+	if len(ret) == 0 {
+		ret = append(ret, "main")
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (d *doctor) checkConfigFiles() {
+	for _, path := range SystemConfigPaths() {
+		if d.checkConfigFile(path, nullSink{}) {
+			break
+		}
+	}
+	d.checkConfigFile(ConfigPath("global.conf"), nullSink{})
+	for _, name := range doctorNetNames() {
+		net := StellarNet{Name: name}
+		d.checkConfigFile(ConfigPath(name+".net"), net.IniSink())
+	}
+}
+
+func (d *doctor) checkNetwork(name string) {
+	net, err := LoadNetworks().Get(name)
+	if err != nil {
+		d.problem(fmt.Sprintf("set net.network-id in %s",
+			ConfigPath(name+".net")), "network %s: %s", name, err)
+		return
+	} else if net.Horizon == "" {
+		d.problem(fmt.Sprintf("%s -net=%s -detect URL", progname, name),
+			"network %s has no horizon URL", name)
+		return
+	}
+	root, err := GetHorizonRoot(net.Horizon)
+	if err != nil {
+		d.problem(fmt.Sprintf("check net.horizon in %s",
+			ConfigPath(name+".net")),
+			"network %s: horizon %s unreachable: %s", name, net.Horizon,
+			strings.TrimSpace(err.Error()))
+		return
+	} else if root.Network_passphrase != net.NetworkId {
+		d.problem(fmt.Sprintf("%s -net=%s -detect %s", progname, name,
+			net.Horizon),
+			"network %s: network-id %q does not match horizon's %q",
+			name, net.NetworkId, root.Network_passphrase)
+		return
+	}
+	d.ok("network %s: horizon %s (protocol %d)", name, net.Horizon,
+		root.Current_protocol_version)
+
+	for _, h := range net.ProbeHorizons(nil) {
+		if h.Err != nil {
+			d.problem(fmt.Sprintf("check net.horizon-mirror in %s",
+				ConfigPath(name+".net")),
+				"network %s: horizon %s unhealthy: %s", name, h.URL,
+				strings.TrimSpace(h.Err.Error()))
+		} else {
+			d.ok("network %s: horizon %s responded in %s at ledger %d",
+				name, h.URL, h.Latency.Round(time.Millisecond), h.Ledger)
+		}
+	}
+
+	skew, err := net.GetClockSkew()
+	if err != nil {
+		d.problem("", "network %s: cannot fetch ledger header: %s",
+			name, err)
+		return
+	}
+	if skew.Excessive() {
+		d.problem("synchronize the system clock (e.g., enable NTP)",
+			"network %s: %s", name, skew)
+	} else {
+		d.ok("network %s: clock within %s of network", name,
+			MaxClockSkew)
+	}
+}
+
+// Diagnose common configuration problems and return the number
+// found.
+func doDoctor() int {
+	var d doctor
+	d.checkConfigDir()
+	d.checkConfigFiles()
+	for _, name := range doctorNetNames() {
+		d.checkNetwork(name)
+	}
+	if d.problems == 0 {
+		fmt.Println("no problems found")
+	} else {
+		fmt.Printf("%d problem(s) found\n", d.problems)
+	}
+	return d.problems
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	. "github.com/xdrpp/stc"
+)
+
+// Report every way acct can be controlled.  If planfile is not empty,
+// instead write the transaction executing the recovery plan in
+// planfile to outfile (or standard output) in format f.
+func doControl(net *StellarNet, acct, planfile, outfile string, f format) {
+	ae, err := net.GetAccountEntry(acct)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if planfile == "" {
+		fmt.Print(net.AccountControlOf(ae))
+		return
+	}
+	plan, err := LoadRecoveryPlan(planfile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	e, err := net.RecoveryTx(ae, plan)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", planfile, err)
+		os.Exit(1)
+	}
+	if fs, err := net.GetFeeCache(); err == nil {
+		e.SetFee(net.DefaultFee(fs))
+	}
+	mustWriteTx(outfile, e, net, f)
+}
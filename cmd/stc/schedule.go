@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/xdrpp/goxdr/xdr"
+	. "github.com/xdrpp/stc"
+)
+
+// Directory in which scheduled transactions are stored.
+func scheduleDir() string {
+	return ConfigPath("schedule")
+}
+
+// Schedule the transaction in file txfile to be submitted on net
+// according to cron, signed by key, under the name job.
+func doSchedule(net *StellarNet, job, txfile, cron, key string) {
+	if key == "" {
+		fmt.Fprintln(os.Stderr, "-schedule requires -key=NAME")
+		os.Exit(2)
+	} else if cron == "" {
+		fmt.Fprintln(os.Stderr, "-schedule requires -cron=SPEC")
+		os.Exit(2)
+	}
+	e, _ := mustReadTx(txfile)
+	s, err := NewScheduledTx(cron, net.Name, key, e)
+	if err == nil {
+		os.MkdirAll(scheduleDir(), 0700)
+		err = s.Save(ScheduledTxPath(scheduleDir(), job))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	next, _ := s.Next()
+	fmt.Printf("%s: next run %s\n", job, next.Format(time.RFC1123))
+}
+
+// List the scheduled transactions and their next run times.
+func doListSchedule() {
+	names, err := ScheduledTxNames(scheduleDir())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, name := range names {
+		s, err := LoadScheduledTx(ScheduledTxPath(scheduleDir(), name))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		next, err := s.Next()
+		if err != nil {
+			fmt.Printf("%s (%s on %s): %s\n", name, s.Cron, s.Network, err)
+			continue
+		}
+		fmt.Printf("%s (%s on %s, key %s): next run %s\n", name, s.Cron,
+			s.Network, s.Key, next.Format(time.RFC1123))
+	}
+}
+
+// Networks and keys loaded by runSchedule, so that -daemon does not
+// reload them (or prompt for passphrases) every time a job runs.
+type scheduleState struct {
+	nets map[string]*StellarNet
+	keys map[string]PrivateKey
+}
+
+// Submit the scheduled transaction job if it is due.  To avoid ever
+// paying twice, the job's last run time is updated before the
+// transaction is submitted, so a failed submission is not retried
+// until the next scheduled time.
+func (ss *scheduleState) runJob(job string, now time.Time) {
+	path := ScheduledTxPath(scheduleDir(), job)
+	s, err := LoadScheduledTx(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	if next, err := s.Next(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", job, err)
+		return
+	} else if next.After(now) {
+		return
+	}
+
+	net := ss.nets[s.Network]
+	if net == nil {
+		if net = DefaultStellarNet(s.Network); net == nil {
+			fmt.Fprintf(os.Stderr, "%s: unknown network %q\n", job,
+				s.Network)
+			return
+		}
+		net.Submissions = &SubmissionLog{
+			Path: ConfigPath(net.Name + ".submitted"),
+		}
+		ss.nets[s.Network] = net
+	}
+	sk, ok := ss.keys[s.Key]
+	if !ok {
+		if sk, err = getSecKey(AdjustKeyName(s.Key)); err != nil {
+			return
+		}
+		ss.keys[s.Key] = sk
+	}
+
+	e, err := net.PrepareScheduledTx(s, sk)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", job, err)
+		return
+	}
+	s.LastRun = now.Unix()
+	if err = s.Save(path); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", job, err)
+		return
+	}
+	res, err := net.Post(e)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: post transaction failed: %s\n", job, err)
+		return
+	}
+	fmt.Printf("%s: posted %x\n%s", job, *net.HashTx(e),
+		xdr.XdrToString(res))
+}
+
+// Submit all scheduled transactions that are due.  If daemon is true,
+// keep running, checking for due transactions once a minute.
+func doRunSchedule(daemon bool) {
+	ss := scheduleState{
+		nets: make(map[string]*StellarNet),
+		keys: make(map[string]PrivateKey),
+	}
+	for {
+		names, err := ScheduledTxNames(scheduleDir())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		now := time.Now()
+		for _, job := range names {
+			ss.runJob(job, now)
+		}
+		if !daemon {
+			return
+		}
+		time.Sleep(time.Until(now.Truncate(time.Minute).Add(time.Minute)))
+	}
+}
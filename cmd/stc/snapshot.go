@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	. "github.com/xdrpp/stc"
+	"github.com/xdrpp/stc/stcdetail"
+)
+
+// Write a snapshot of account acct to outfile (or standard output if
+// outfile is "").
+func doSnapshot(net *StellarNet, acct, outfile string) {
+	s, err := net.NewAccountSnapshot(acct)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	if outfile == "" {
+		fmt.Println(string(out))
+	} else if err = stcdetail.SafeWriteFile(outfile, string(out)+"\n",
+		0666); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Write the transactions needed to recreate a snapshotted account to
+// files prefix-1, prefix-2, ....  args are the snapshot file, funding
+// account, and optionally the account to create.
+func doRestore(net *StellarNet, prefix string, args []string) {
+	if prefix == "" {
+		fmt.Fprintln(os.Stderr, "-restore requires -o PREFIX")
+		os.Exit(2)
+	}
+	input, infile, err := readInput(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	s, err := ParseAccountSnapshot(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", infile, err)
+		os.Exit(1)
+	}
+	var funder AccountID
+	var acct *AccountID
+	if _, err = fmt.Sscan(resolveAccount(net, args[1]), &funder); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid funding account %s\n", args[1])
+		os.Exit(2)
+	}
+	if len(args) > 2 {
+		acct = new(AccountID)
+		if _, err = fmt.Sscan(resolveAccount(net, args[2]), acct); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid account %s\n", args[2])
+			os.Exit(2)
+		}
+	}
+	if s.NetworkId == net.GetNetworkId() && acct == nil {
+		fmt.Fprintln(os.Stderr, "warning: restoring account on the "+
+			"network it came from")
+	}
+	txs, err := net.RestoreTxs(s, funder, acct)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for i, e := range txs {
+		outfile := fmt.Sprintf("%s-%d", prefix, i+1)
+		mustWriteTx(outfile, e, net, fmt_txrep)
+		fmt.Println(outfile)
+	}
+}
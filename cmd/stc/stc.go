@@ -6,6 +6,8 @@ package main
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -13,8 +15,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	. "github.com/xdrpp/stc"
@@ -28,13 +32,18 @@ const (
 	fmt_compiled = format(iota)
 	fmt_txrep
 	fmt_json
+	fmt_bundle
 )
 
 type isSignerKey interface {
 	ToSignerKey() SignerKey
 }
 
-func getAccounts(net *StellarNet, e *TransactionEnvelope, usenet bool) {
+// Learn the signers of the accounts in e.  Signers come from saved
+// (indexed by strkey) if present, and otherwise from horizon if usenet
+// is true.
+func getAccounts(net *StellarNet, e *TransactionEnvelope, usenet bool,
+	saved map[string]*HorizonAccountEntry) {
 	accounts := make(map[string][]HorizonSigner)
 	record := func(ac isSignerKey) {
 		k := ac.ToSignerKey()
@@ -47,9 +56,18 @@ func getAccounts(net *StellarNet, e *TransactionEnvelope, usenet bool) {
 		record(ac)
 	})
 
+	var fetch []string
+	for ac := range accounts {
+		if ae := saved[ac]; ae != nil {
+			accounts[ac] = ae.Signers
+		} else {
+			fetch = append(fetch, ac)
+		}
+	}
+
 	if usenet {
 		c := make(chan func())
-		for ac := range accounts {
+		for _, ac := range fetch {
 			go func(ac string) {
 				if ae, err := net.GetAccountEntry(ac); err == nil {
 					c <- func() { accounts[ac] = ae.Signers }
@@ -58,7 +76,7 @@ func getAccounts(net *StellarNet, e *TransactionEnvelope, usenet bool) {
 				}
 			}(ac)
 		}
-		for i := len(accounts); i > 0; i-- {
+		for i := len(fetch); i > 0; i-- {
 			(<-c)()
 		}
 	}
@@ -106,7 +124,16 @@ func GetKeyNames() []string {
 	return names
 }
 
-func doKeyGen(outfile string) {
+// Save a private key, encrypting it with the passphrase in pass entry
+// passEntry if non-empty, and otherwise prompting for a passphrase.
+func saveKey(sk PrivateKey, outfile, passEntry string) error {
+	if passEntry != "" {
+		return sk.SaveWithPassPassphrase(outfile, passEntry)
+	}
+	return sk.Save(outfile, stcdetail.GetPass2("Passphrase: "))
+}
+
+func doKeyGen(outfile, passEntry string) {
 	sk := NewPrivateKey(stx.PUBLIC_KEY_TYPE_ED25519)
 	if outfile == "" {
 		fmt.Println(sk)
@@ -117,12 +144,7 @@ func doKeyGen(outfile string) {
 			fmt.Fprintf(os.Stderr, "%s: file already exists\n", outfile)
 			return
 		}
-		bytePassword := stcdetail.GetPass2("Passphrase: ")
-		if FileExists(outfile) {
-			fmt.Fprintf(os.Stderr, "%s: file already exists\n", outfile)
-			return
-		}
-		err := sk.Save(outfile, bytePassword)
+		err := saveKey(sk, outfile, passEntry)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err.Error())
 		} else {
@@ -132,6 +154,68 @@ func doKeyGen(outfile string) {
 	}
 }
 
+func AdjustMnemonicName(name string) string {
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "missing mnemonic name")
+		os.Exit(1)
+	}
+	if dir, _ := filepath.Split(name); dir != "" {
+		return name
+	}
+	os.MkdirAll(ConfigPath("mnemonics"), 0700)
+	return ConfigPath("mnemonics", name)
+}
+
+func doImportMnemonic(outfile, passEntry string) {
+	if FileExists(outfile) {
+		fmt.Fprintf(os.Stderr, "%s: file already exists\n", outfile)
+		os.Exit(1)
+	}
+	m, err := NewMnemonic(string(stcdetail.GetPass("Mnemonic: ")),
+		string(stcdetail.GetPass("Mnemonic passphrase (if any): ")))
+	if err == nil {
+		if passEntry != "" {
+			err = m.SaveWithPassPassphrase(outfile, passEntry)
+		} else {
+			err = m.Save(outfile, stcdetail.GetPass2("Passphrase: "))
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	// Let the user check the mnemonic against their wallet, since we
+	// cannot verify its checksum.
+	fmt.Println(m.Key(0).Public())
+}
+
+// Derive account number index from the mnemonic in file, and save it
+// in outfile with headers recording the mnemonic name and the path.
+func doDerive(file, name string, index uint32, outfile, passEntry string) {
+	if FileExists(outfile) {
+		fmt.Fprintf(os.Stderr, "%s: file already exists\n", outfile)
+		os.Exit(1)
+	}
+	m, err := LoadMnemonic(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	sk := m.Key(index)
+	headers := DerivedKeyHeaders(name, index)
+	var passphrase []byte
+	if passEntry != "" {
+		headers[PassPassphraseHeader] = passEntry
+	} else {
+		passphrase = stcdetail.GetPass2("Passphrase: ")
+	}
+	if err = sk.SaveWithHeaders(outfile, passphrase, headers); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	fmt.Println(sk.Public())
+}
+
 func getSecKey(file string) (PrivateKey, error) {
 	var sk PrivateKey
 	var err error
@@ -160,33 +244,148 @@ func isZeroAccount(ac isSignerKey) bool {
 		bytes.Compare(k.Ed25519()[:], u256zero[:]) == 0
 }
 
-func fixTx(net *StellarNet, e *TransactionEnvelope) {
+// Returns the fee a transaction offers and the number of operations
+// it pays for.
+func txFee(e *TransactionEnvelope) (fee, nops int64) {
+	switch e.Type {
+	case stx.ENVELOPE_TYPE_TX_V0:
+		fee = int64(e.V0().Tx.Fee)
+		nops = int64(len(e.V0().Tx.Operations))
+	case stx.ENVELOPE_TYPE_TX:
+		fee = int64(e.V1().Tx.Fee)
+		nops = int64(len(e.V1().Tx.Operations))
+	case stx.ENVELOPE_TYPE_TX_FEE_BUMP:
+		// Fee bumps pay for one extra operation
+		fee = e.FeeBump().Tx.Fee
+		nops = int64(len(e.FeeBump().Tx.InnerTx.V1().Tx.Operations)) + 1
+	}
+	return
+}
+
+// Warn if the network is congested and the transaction offers less
+// than the suggested fee.
+func warnCongestion(net *StellarNet, e *TransactionEnvelope) {
+	fs, err := net.GetFeeCache()
+	if err != nil || fs.Congestion() == CongestionLow {
+		return
+	}
+	fee, nops := txFee(e)
+	if suggested := fs.SuggestedFee(); nops > 0 &&
+		fee/nops < int64(suggested) {
+		fmt.Fprintf(os.Stderr, "warning: network congestion is %s " +
+			"(ledger capacity usage %.0f%%); suggested fee is %d " +
+			"per operation, but transaction offers %d\n",
+			fs.Congestion(), 100*fs.Ledger_capacity_usage,
+			suggested, fee/nops)
+	}
+}
+
+// Warn about dangerous operations in a transaction, such as ones
+// that lock an account.  Checks that need account state are only
+// performed if online, since they query horizon.
+func warnRisks(net *StellarNet, e *TransactionEnvelope, online bool) {
+	var rc *RiskContext
+	if online {
+		rc, _ = net.GetRiskContext(e)
+	}
+	for _, r := range net.TxRisks(e, rc) {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", r)
+	}
+}
+
+// Warn if the transaction has time bounds and the local clock is far
+// enough off that they were likely computed incorrectly.
+func warnClockSkew(net *StellarNet, e *TransactionEnvelope) {
+	if e.TimeBounds() == nil {
+		return
+	}
+	if skew, err := net.GetClockSkew(); err == nil && skew.Excessive() {
+		fmt.Fprintf(os.Stderr, "warning: %s; time bounds may be " +
+			"wrong\n", skew)
+	}
+}
+
+// Parse a -ledgers argument of the form [MIN]:[MAX], or just MAX.
+// Each bound is either an absolute ledger number or +N, meaning N
+// ledgers after the latest ledger cur.  A missing bound is 0.
+func parseLedgerBounds(spec string, cur uint32) (min, max uint32,
+	err error) {
+	minspec, maxspec := "", spec
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		minspec, maxspec = spec[:i], spec[i+1:]
+	}
+	parse := func(b string) (uint32, error) {
+		if b == "" {
+			return 0, nil
+		}
+		base := uint32(0)
+		if b[0] == '+' {
+			base, b = cur, b[1:]
+		}
+		n, err := strconv.ParseUint(b, 10, 32)
+		if err != nil || uint64(base)+n > 0xffffffff {
+			return 0, fmt.Errorf("invalid ledger bound %q", b)
+		}
+		return base + uint32(n), nil
+	}
+	if min, err = parse(minspec); err == nil {
+		max, err = parse(maxspec)
+	}
+	if err == nil && max != 0 && max <= min {
+		err = fmt.Errorf("empty ledger range %q", spec)
+	}
+	return
+}
+
+// Update the fee and sequence number of a transaction and, if
+// ledgers is not empty, set its ledger bounds as specified by
+// parseLedgerBounds, relative to the network's latest ledger.
+func fixTx(net *StellarNet, e *TransactionEnvelope, ledgers string) {
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		if h, err := net.GetFeeStats(); err == nil {
-			// 20 should be a parameter
-			e.SetFee(h.Percentile(20))
+			pct := 20
+			if net.TxDefaults.FeePercentile > 0 {
+				pct = net.TxDefaults.FeePercentile
+			}
+			e.SetFee(h.Percentile(pct))
 		}
 	}()
 	if !isZeroAccount(e.SourceAccount()) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if a, _ := net.GetAccountEntry(
-				e.SourceAccount().ToSignerKey().String());
-			a != nil {
-				switch e.Type {
-				case stx.ENVELOPE_TYPE_TX:
-					e.V1().Tx.SeqNum = a.NextSeq()
-				case stx.ENVELOPE_TYPE_TX_V0:
-					e.V0().Tx.SeqNum = a.NextSeq()
-				}
+			net.SetNextSequence(e)
+		}()
+	}
+	var lerr error
+	if ledgers != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var lh *LedgerHeader
+			if e.Type != stx.ENVELOPE_TYPE_TX {
+				lerr = fmt.Errorf("ledger bounds not supported in %s",
+					e.Type)
+				return
+			} else if lh, lerr = net.GetLedgerHeader(); lerr != nil {
+				return
+			}
+			var min, max uint32
+			min, max, lerr = parseLedgerBounds(ledgers, lh.LedgerSeq)
+			if lerr == nil {
+				e.SetLedgerBounds(min, max)
 			}
 		}()
 	}
 	wg.Wait()
+	if lerr != nil {
+		fmt.Fprintf(os.Stderr, "-ledgers: %s\n", lerr)
+		os.Exit(1)
+	}
+	net.ApplyTimeout(e)
 }
 
 // Guess whether input is key: value lines or compiled base64
@@ -201,6 +400,9 @@ func guessFormat(content string) format {
 		}
 	}
 	if content[0] == '{' {
+		if IsTxBundle([]byte(content)) {
+			return fmt_bundle
+		}
 		return fmt_json
 	}
 	return fmt_txrep
@@ -215,16 +417,21 @@ func (pe ParseError) Error() string {
 	return pe.FileError(pe.Filename)
 }
 
+// Read a file, or standard input if infile is "-".  Returns the
+// contents and a name for the file suitable for error messages.
+func readInput(infile string) ([]byte, string, error) {
+	if infile == "-" {
+		input, err := ioutil.ReadAll(os.Stdin)
+		return input, "(stdin)", err
+	}
+	input, err := ioutil.ReadFile(infile)
+	return input, infile, err
+}
+
 func readTx(infile string) (
 	txe *TransactionEnvelope, f format, err error) {
 	var input []byte
-	if infile == "-" {
-		input, err = ioutil.ReadAll(os.Stdin)
-		infile = "(stdin)"
-	} else {
-		input, err = ioutil.ReadFile(infile)
-	}
-	if err != nil {
+	if input, infile, err = readInput(infile); err != nil {
 		return
 	}
 	sinput := string(input)
@@ -243,6 +450,11 @@ func readTx(infile string) (
 		if err = stcdetail.JsonToXdr(e, input); err == nil {
 			txe = e
 		}
+	case fmt_bundle:
+		var b *TxBundle
+		if b, err = ParseTxBundle(input); err == nil {
+			txe, err = b.Envelope()
+		}
 	}
 	return
 }
@@ -282,6 +494,43 @@ func writeTx(outfile string, e *TransactionEnvelope, net *StellarNet,
 	return nil
 }
 
+// Parse the argument of -template, which is either a template or
+// @FILE to read the template from FILE.
+func mustParseTemplate(net *StellarNet, arg string) *template.Template {
+	text := arg
+	if strings.HasPrefix(arg, "@") {
+		contents, err := ioutil.ReadFile(arg[1:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		text = string(contents)
+	}
+	t, err := template.New("template").Funcs(net.TemplateFuncs()).Parse(text)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	return t
+}
+
+func mustExecTemplate(outfile string, t *template.Template,
+	data interface{}) {
+	var out strings.Builder
+	err := t.Execute(&out, data)
+	if err == nil {
+		if outfile == "" {
+			fmt.Print(out.String())
+		} else {
+			err = stcdetail.SafeWriteFile(outfile, out.String(), 0666)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
 func mustWriteTx(outfile string, e *TransactionEnvelope, net *StellarNet,
 	f format) {
 	if err := writeTx(outfile, e, net, f); err != nil {
@@ -290,7 +539,15 @@ func mustWriteTx(outfile string, e *TransactionEnvelope, net *StellarNet,
 	}
 }
 
-func signTx(net *StellarNet, key string, e *TransactionEnvelope) error {
+// Sign e with the key in file key (or typed at a prompt if key is
+// empty).  If payload is not nil, sign it for a signed-payload signer
+// instead of signing the transaction.
+func signTx(net *StellarNet, key string, e *TransactionEnvelope,
+	payload []byte) error {
+	if err := CheckSponsorships(e); err != nil {
+		fmt.Fprintf(os.Stderr, "Refusing to sign: %s\n", err)
+		return err
+	}
 	if key != "" {
 		key = AdjustKeyName(key)
 	}
@@ -298,14 +555,90 @@ func signTx(net *StellarNet, key string, e *TransactionEnvelope) error {
 	if err != nil {
 		return err
 	}
-	net.AddSigner(sk.Public().String(), "")
-	if err = net.SignTx(sk, e); err != nil {
+	if payload != nil {
+		spk := SignedPayloadKey(sk.Public(), payload)
+		net.AddSigner(spk.String(), "")
+		err = net.SignTxPayload(sk, e, payload)
+	} else {
+		net.AddSigner(sk.Public().String(), "")
+		err = net.SignTx(sk, e)
+	}
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return err
 	}
 	return nil
 }
 
+// Post a transaction and print the result, or exit on failure.
+func postTx(net *StellarNet, e *TransactionEnvelope) {
+	refuse := false
+	for _, p := range ValidateTx(e) {
+		if p.Severity == SeverityError {
+			fmt.Fprintf(os.Stderr, "Refusing to post: %s\n", p)
+			refuse = true
+		} else {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", p)
+		}
+	}
+	if refuse {
+		os.Exit(1)
+	}
+	if err := net.CheckApprovals(e); err != nil {
+		fmt.Fprintf(os.Stderr, "Refusing to post: %s\n", err)
+		os.Exit(1)
+	}
+	warnCongestion(net, e)
+	warnClockSkew(net, e)
+	net.Submissions = &SubmissionLog{
+		Path: ConfigPath(net.Name + ".submitted"),
+	}
+	res, err := net.Post(e)
+	if as, ok := err.(ErrAlreadySubmitted); ok {
+		fmt.Fprintf(os.Stderr, "Not posting: %s\n", as)
+		os.Exit(1)
+	} else if err == nil {
+		fmt.Print(xdr.XdrToString(res))
+	} else {
+		fmt.Fprintf(os.Stderr, "Post transaction failed: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// Append a receipt for posted transaction e to file path, or exit on
+// failure.
+func writeReceipt(net *StellarNet, e *TransactionEnvelope, path string,
+	sk PrivateKey) {
+	r, err := net.NewReceipt(nil, fmt.Sprintf("%x", *net.HashTx(e)))
+	if err == nil {
+		err = AppendReceipt(path, r, sk)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot write receipt: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// Print the receipts in file path, or exit with status 1 if any is
+// invalid.
+func verifyReceipts(path string) {
+	contents, _, err := stcdetail.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	rs, err := VerifyReceipts(contents)
+	for i := range rs {
+		fmt.Printf("%s ledger %d (%s) signed by %s\n", rs[i].Txid,
+			rs[i].Ledger, rs[i].Closed.Format(time.RFC3339), rs[i].Signer)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+		os.Exit(1)
+	}
+	fmt.Printf("%d receipts verified\n", len(rs))
+}
+
 func editor(args ...string) {
 	ed, ok := os.LookupEnv("STCEDITOR")
 	if !ok {
@@ -369,7 +702,7 @@ func doEdit(net *StellarNet, arg string) {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
-	getAccounts(net, e, false)
+	getAccounts(net, e, false, nil)
 
 	f, err := ioutil.TempFile("", progname)
 	if err != nil {
@@ -390,7 +723,7 @@ func doEdit(net *StellarNet, arg string) {
 
 		fi1, staterr := os.Stat(path)
 		if staterr != nil {
-			fmt.Println(err.Error())
+			fmt.Fprintln(os.Stderr, staterr.Error())
 			os.Exit(1)
 		}
 
@@ -399,9 +732,11 @@ func doEdit(net *StellarNet, arg string) {
 			fmt.Fprint(os.Stderr, err.Error())
 			fmt.Printf("Press return to run editor.")
 			b := make([]byte, 1)
-			for n, err := os.Stdin.Read(b);
-			err != nil && n > 0 && b[0] != '\n'; {
-				fmt.Printf("Read %c\n", b)
+			for {
+				if n, rerr := os.Stdin.Read(b); rerr != nil ||
+					(n > 0 && b[0] == '\n') {
+					break
+				}
 			}
 			if pe, ok := err.(ParseError); ok {
 				line = pe.TxrepError[0].Line
@@ -412,7 +747,7 @@ func doEdit(net *StellarNet, arg string) {
 		if err == nil {
 			fi2, staterr := os.Stat(path)
 			if staterr != nil {
-				fmt.Println(err.Error())
+				fmt.Fprintln(os.Stderr, staterr.Error())
 				os.Exit(1)
 			}
 			if fi1.Size() == fi2.Size() && fi1.ModTime() == fi2.ModTime() {
@@ -426,7 +761,7 @@ func doEdit(net *StellarNet, arg string) {
 			os.Exit(1)
 		}
 		err = nil
-		if newe, pe := TxFromRep(string(contents)); pe != nil {
+		if newe, pe := net.TxFromRep(string(contents)); pe != nil {
 			err = ParseError{pe.(stcdetail.TxrepError), path}
 		} else {
 			e = newe
@@ -436,6 +771,34 @@ func doEdit(net *StellarNet, arg string) {
 	mustWriteTx(arg, e, net, txfmt)
 }
 
+// Returns the account named by arg, which is either an account ID or
+// the exact comment on a single account in the network's accounts.
+func resolveAccount(net *StellarNet, arg string) string {
+	if acct, ok := net.LookupAccountAlias(arg); ok {
+		return acct
+	}
+	return arg
+}
+
+// Write to standard output a transaction sending all of src's XLM to
+// dest, merging src if possible.
+func doSweep(net *StellarNet, src, dest string) {
+	e, sweep, err := net.SweepTx(resolveAccount(net, src),
+		resolveAccount(net, dest))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, b := range sweep.Blockers {
+		fmt.Fprintf(os.Stderr, "cannot merge account: %s\n", b)
+	}
+	if len(sweep.Blockers) > 0 {
+		fmt.Fprintf(os.Stderr, "warning: paying %s XLM instead of " +
+			"merging account\n", FormatAmount(sweep.Amount))
+	}
+	mustWriteTx("", e, net, fmt_txrep)
+}
+
 func b2i(bs ...bool) int {
 	ret := 0
 	for _, b := range bs {
@@ -461,6 +824,8 @@ var dateFormats = []string {
 func main() {
 	opt_compile := flag.Bool("c", false, "Compile output to base64 XDR")
 	opt_json := flag.Bool("json", false, "Output transaction in JSON format")
+	opt_template := flag.String("template", "",
+		"Format output with Go text/template `TMPL` (or @FILE)")
 	opt_keygen := flag.Bool("keygen", false, "Create a new signing keypair")
 	opt_sec2pub := flag.Bool("pub", false, "Get public key from private")
 	opt_output := flag.String("o", "", "Output to `FILE` instead of stdout")
@@ -470,15 +835,33 @@ func main() {
 	opt_inplace := flag.Bool("i", false, "Edit the input file in place")
 	opt_sign := flag.Bool("sign", false, "Sign the transaction")
 	opt_key := flag.String("key", "", "Use secret signing key in `FILE`")
+	opt_payload := flag.String("payload", "",
+		"With -sign, sign hex `PAYLOAD` for a signed-payload signer")
 	opt_netname := flag.String("net", "",
 		"Use Network `NET` (e.g., test); default: $STCNET or \"default\"")
 	opt_update := flag.Bool("u", false,
 		"Query network to update fee and sequence number")
+	opt_ledgers := flag.String("ledgers", "",
+		"With -u, set ledger bounds to `[MIN]:[MAX]` (+N is relative)")
 	opt_learn := flag.Bool("l", false, "Learn new signers")
+	opt_learn_from := flag.String("learn-from", "",
+		"Learn new signers from bundles and account files in `DIR`")
 	opt_help := flag.Bool("help", false, "Print usage information")
 	opt_post := flag.Bool("post", false,
 		"Post transaction instead of editing it")
+	opt_receipt := flag.String("receipt", "",
+		"With -post, append a receipt signed with -key to `FILE`")
+	opt_verify_receipt := flag.Bool("verify-receipt", false,
+		"Check the signatures and hash chain of a receipt file")
 	opt_nopass := flag.Bool("nopass", false, "Never prompt for passwords")
+	opt_readonly := flag.Bool("readonly", false,
+		"Refuse to sign or post transactions (also set by $STCREADONLY)")
+	opt_timeout := flag.Duration("timeout", 2*time.Minute,
+		"Give up on network requests taking over `DURATION` (0 means never)")
+	opt_pass := flag.String("pass", "",
+		"With -import-key, use the seed in password store `ENTRY`")
+	opt_pass_passphrase := flag.String("pass-passphrase", "",
+		"Encrypt key with passphrase from password store `ENTRY`")
 	opt_edit := flag.Bool("edit", false,
 		"keep editing the file until it doesn't change")
 	opt_import_key := flag.Bool("import-key", false,
@@ -487,16 +870,46 @@ func main() {
 		"Export signing key from your $STCDIR directory")
 	opt_list_keys := flag.Bool("list-keys", false,
 		"List keys that have been stored in $STCDIR")
+	opt_import_mnemonic := flag.Bool("import-mnemonic", false,
+		"Import a BIP-39 mnemonic to your $STCDIR directory")
+	opt_derive := flag.Bool("derive", false,
+		"Derive a signing key from a mnemonic imported with -import-mnemonic")
+	opt_index := flag.Uint("index", 0,
+		"With -derive, derive SEP-5 account number `N`")
 	opt_fee_stats := flag.Bool("fee-stats", false,
 		"Dump fee stats from network")
 	opt_ledger_header := flag.Bool("ledger-header", false,
 		"Dump ledger header from network")
+	opt_ledger := flag.Bool("ledger", false,
+		"Summarize the transactions in a ledger")
 	opt_acctinfo := flag.Bool("qa", false,
 		"Query Horizon for information on account")
 	opt_txinfo := flag.Bool("qt", false,
 		"Query Horizon for information on transaction")
 	opt_txacct := flag.Bool("qta", false,
 		"Query Horizon for transactions on account")
+	opt_assetinfo := flag.Bool("qasset", false,
+		"Query Horizon for statistics on asset CODE[:ISSUER]")
+	opt_wizard := flag.Bool("wizard", false,
+		"Interactively build a new transaction")
+	opt_sweep := flag.Bool("sweep", false,
+		"Create transaction moving all XLM from account SRC to DEST")
+	opt_control := flag.Bool("control", false,
+		"Report every signer that can control an account")
+	opt_txtemplate := flag.String("txtemplate", "",
+		"Create transaction from built-in template `NAME` (see man page)")
+	opt_recovery := flag.String("recovery", "",
+		"With -control, create transaction executing recovery plan `FILE`")
+	opt_reconcile := flag.Bool("reconcile", false,
+		"Check an account's balances against its history")
+	opt_history := flag.Bool("history", false,
+		"Show an account's past transactions in txrep format")
+	opt_nofilter := flag.Bool("nofilter", false,
+		"With -qta, show dust and spam hidden by the network's filter")
+	opt_snapshot := flag.Bool("snapshot", false,
+		"Save the state of an account for recreating it with -restore")
+	opt_restore := flag.Bool("restore", false,
+		"Create transactions recreating a snapshot on a test network")
 	opt_mux := flag.Bool("mux", false,
 		"Created a MuxedAccount from an AccountID and uint64")
 	opt_demux := flag.Bool("demux", false,
@@ -509,10 +922,38 @@ func main() {
 		"Be more verbose for some operations")
 	opt_hint := flag.Bool("hint", false,
 		"Print signature hint for a public key")
+	opt_sigkeys := flag.Bool("sigkeys", false,
+		"Show which known keys match the hint of each signature")
+	opt_bundle := flag.Bool("bundle", false,
+		"Package transaction with account state for offline signing")
+	opt_check_bundle := flag.Bool("check-bundle", false,
+		"Validate a bundle created by -bundle without network access")
+	opt_ceremony := flag.Bool("ceremony", false,
+		"Interactively collect all signatures a transaction needs")
+	opt_collect := flag.Bool("collect", false,
+		"Merge signatures on a transaction from files in a shared location")
+	opt_explain := flag.Bool("explain", false,
+		"Describe what a transaction does in plain English")
+	opt_doctor := flag.Bool("doctor", false,
+		"Check configuration, keys, and network connectivity")
+	opt_detect := flag.Bool("detect", false,
+		"Configure network from horizon server at URL")
 	opt_print_default_config := flag.Bool("builtin-config", false,
 		"Print the built-in stc.conf file used when none is found")
+	opt_fields := flag.Bool("fields", false,
+		"List the txrep fields of an XDR type (default TransactionEnvelope)")
+	opt_version := flag.Bool("version", false,
+		"Print the version of stc and of the XDR it was built with")
 	opt_zerosig := flag.Bool("z", false, "Zero out the signatures vector")
 	opt_opid := flag.Bool("opid", false, "Calculate a balance entry ID")
+	opt_schedule := flag.Bool("schedule", false,
+		"Schedule a transaction for recurring submission (or list schedule)")
+	opt_cron := flag.String("cron", "",
+		"With -schedule, submit at times matching crontab(5) `SPEC`")
+	opt_run_schedule := flag.Bool("run-schedule", false,
+		"Sign and submit scheduled transactions that are due")
+	opt_daemon := flag.Bool("daemon", false,
+		"With -run-schedule, keep running and check every minute")
 	if pos := strings.LastIndexByte(os.Args[0], '/'); pos >= 0 {
 		progname = os.Args[0][pos+1:]
 	} else {
@@ -520,29 +961,56 @@ func main() {
 	}
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(),
-`Usage: %[1]s [-net=ID] [-z] [-sign] [-c|-json] [-l] [-u] \
-           [-i | -o OUTPUT-FILE] INPUT-FILE
+`Usage: %[1]s [-net=ID] [-z] [-sign [-payload=HEX]] \
+           [-c|-json|-template=TMPL] [-l] [-learn-from=DIR] \
+           [-u [-ledgers=[MIN]:[MAX]]] [-i | -o OUTPUT-FILE] INPUT-FILE
        %[1]s -edit [-net=ID] FILE
-       %[1]s -post [-net=ID] INPUT-FILE
+       %[1]s -wizard [-net=ID] FILE
+       %[1]s -post [-net=ID] [-receipt=FILE [-key=NAME]] INPUT-FILE
+       %[1]s -verify-receipt FILE
        %[1]s -preauth [-net=ID] INPUT-FILE
        %[1]s -txhash [-net=ID] _INPUT-FILE
+       %[1]s -sigkeys [-net=ID] INPUT-FILE
+       %[1]s -explain [-net=ID] INPUT-FILE
+       %[1]s -bundle [-net=ID] INPUT-FILE
+       %[1]s -check-bundle [-net=ID] BUNDLE-FILE
+       %[1]s -ceremony [-net=ID] FILE
+       %[1]s -collect [-net=ID] DIR TXHASH
        %[1]s -fee-stats
        %[1]s -ledger-header
-       %[1]s -qa [-net=ID] ACCT
-       %[1]s -qt [-net=ID] TXHASH
-       %[1]s -qta [-net=ID] ACCT
+       %[1]s -ledger [-v] [-net=ID] SEQNO
+       %[1]s -qa [-net=ID] [-template=TMPL] ACCT
+       %[1]s -qt [-net=ID] [-template=TMPL] TXHASH
+       %[1]s -qta [-net=ID] [-nofilter] ACCT
+       %[1]s -history [-net=ID] ACCT [CURSOR]
+       %[1]s -reconcile [-net=ID] ACCT [CURSOR]
+       %[1]s -qasset [-net=ID] CODE[:ISSUER]
        %[1]s -create [-net=ID] ACCT
-       %[1]s -keygen [NAME]
+       %[1]s -sweep [-net=ID] SRC DEST
+       %[1]s -snapshot [-net=ID] [-o FILE] ACCT
+       %[1]s -control [-net=ID] [-recovery=FILE [-o FILE]] ACCT
+       %[1]s -restore [-net=ID] -o PREFIX SNAPSHOT FUNDER [ACCT]
+       %[1]s -txtemplate=NAME [-net=ID] [-o FILE] [PARAM=VALUE ...]
+       %[1]s -detect -net=ID URL
+       %[1]s -keygen [-pass-passphrase=ENTRY] [NAME]
        %[1]s -pub [NAME]
-       %[1]s -import-key NAME
+       %[1]s -import-key [-pass=ENTRY | -pass-passphrase=ENTRY] NAME
        %[1]s -export-key NAME
        %[1]s -list-keys
+       %[1]s -import-mnemonic [-pass-passphrase=ENTRY] NAME
+       %[1]s -derive [-index=N] [-pass-passphrase=ENTRY] NAME [KEYNAME]
        %[1]s -date YYYY-MM-DD[Thh:mm:ss[Z]]
-       %[1]s -hint PUBKEY
+       %[1]s -hint [-v] PUBKEY
        %[1]s -mux ACCT U64
        %[1]s -demux ACCT
        %[1]s -opid ACCT SEQNO OPNO
+       %[1]s -schedule [-net=ID] -cron=SPEC -key=NAME JOB INPUT-FILE
+       %[1]s -schedule
+       %[1]s -run-schedule [-daemon]
        %[1]s -builtin-config
+       %[1]s -doctor
+       %[1]s -fields [TYPE]
+       %[1]s -version [-json]
 `, progname)
 		flag.PrintDefaults()
 	}
@@ -562,26 +1030,109 @@ func main() {
 		*opt_export_key, *opt_acctinfo, *opt_txinfo, *opt_txacct,
 		*opt_friendbot, *opt_list_keys, *opt_fee_stats,
 		*opt_ledger_header, *opt_print_default_config, *opt_mux,
-		*opt_demux, *opt_opid, *opt_hint)
+		*opt_demux, *opt_opid, *opt_hint, *opt_sigkeys, *opt_detect,
+		*opt_ledger, *opt_doctor, *opt_explain, *opt_bundle,
+		*opt_check_bundle, *opt_ceremony, *opt_collect, *opt_assetinfo,
+		*opt_sweep, *opt_snapshot, *opt_restore, *opt_history, *opt_wizard,
+		*opt_reconcile, *opt_verify_receipt, *opt_version, *opt_fields,
+		*opt_import_mnemonic, *opt_derive, *opt_schedule,
+		*opt_run_schedule, *opt_control, *opt_txtemplate != "")
 
 	argsMin, argsMax := 1, 1
 	switch {
 	case *opt_fee_stats || *opt_ledger_header ||
-		*opt_print_default_config || *opt_list_keys:
+		*opt_print_default_config || *opt_list_keys || *opt_doctor ||
+		*opt_version || *opt_run_schedule:
 		argsMin, argsMax = 0, 0
-	case *opt_keygen || *opt_sec2pub:
+	case *opt_schedule:
+		argsMin, argsMax = 0, 2
+	case *opt_keygen || *opt_sec2pub || *opt_fields:
 		argsMin = 0
-	case *opt_mux:
+	case *opt_mux || *opt_collect || *opt_sweep:
 		argsMin, argsMax = 2, 2
+	case *opt_restore:
+		argsMin, argsMax = 2, 3
+	case *opt_history || *opt_reconcile || *opt_derive:
+		argsMin, argsMax = 1, 2
 	case *opt_opid:
 		argsMax, argsMax = 3, 3
+	case *opt_txtemplate != "":
+		// One PARAM=VALUE per parameter; an unknown template is
+		// reported (with the list of templates) by doTxTemplate
+		argsMin, argsMax = 0, stx.MAX_OPS_PER_TX
+		if t := LookupTxTemplate(*opt_txtemplate); t != nil {
+			argsMax = len(t.Params)
+		}
 	}
 
-	if na := len(flag.Args()); nmode > 1 || na < argsMin || na > argsMax {
+	if na := len(flag.Args()); nmode > 1 || na < argsMin || na > argsMax ||
+		(*opt_schedule && na == 1) {
 		flag.Usage()
 		os.Exit(2)
 	}
 
+	if (*opt_pass != "" && !*opt_import_key) ||
+		(*opt_pass_passphrase != "" && !*opt_import_key && !*opt_keygen &&
+			!*opt_import_mnemonic && !*opt_derive) {
+		fmt.Fprintln(os.Stderr, "-pass and -pass-passphrase require "+
+			"-keygen, -import-key, -import-mnemonic, or -derive")
+		os.Exit(2)
+	} else if *opt_pass != "" && *opt_pass_passphrase != "" {
+		fmt.Fprintln(os.Stderr,
+			"-pass and -pass-passphrase are mutually exclusive")
+		os.Exit(2)
+	} else if *opt_index != 0 && !*opt_derive {
+		fmt.Fprintln(os.Stderr, "-index requires -derive")
+		os.Exit(2)
+	} else if *opt_index >= uint(stcdetail.HardenedIndex) {
+		fmt.Fprintln(os.Stderr, "-index must be less than 2^31")
+		os.Exit(2)
+	} else if *opt_cron != "" && !*opt_schedule {
+		fmt.Fprintln(os.Stderr, "-cron requires -schedule")
+		os.Exit(2)
+	} else if *opt_daemon && !*opt_run_schedule {
+		fmt.Fprintln(os.Stderr, "-daemon requires -run-schedule")
+		os.Exit(2)
+	} else if *opt_recovery != "" && !*opt_control {
+		fmt.Fprintln(os.Stderr, "-recovery requires -control")
+		os.Exit(2)
+	}
+
+	if *opt_readonly {
+		SetReadOnly()
+	}
+	if IsReadOnly() && (*opt_sign || *opt_key != "" || *opt_post ||
+		*opt_ceremony || *opt_run_schedule) {
+		fmt.Fprintln(os.Stderr, "-sign, -key, -post, -ceremony, and " +
+			"-run-schedule disabled in read-only mode")
+		os.Exit(2)
+	}
+
+	if *opt_version {
+		if bi := GetBuildInfo(); *opt_json {
+			out, _ := json.MarshalIndent(bi, "", "  ")
+			fmt.Println(string(out))
+		} else {
+			fmt.Print(bi)
+		}
+		return
+	}
+
+	if *opt_fields {
+		name := "TransactionEnvelope"
+		if len(flag.Args()) > 0 {
+			name = flag.Arg(0)
+		}
+		t := stcdetail.XdrTypeByName(name)
+		if t == nil {
+			fmt.Fprintf(os.Stderr, "unknown XDR type %s; known types:\n  %s\n",
+				name, strings.Join(stcdetail.XdrTypeNames(), "\n  "))
+			os.Exit(1)
+		}
+		stcdetail.XdrTxrepFields(os.Stdout, "", t)
+		return
+	}
+
 	outfmt := fmt_txrep
 	if *opt_compile {
 		outfmt = fmt_compiled
@@ -593,19 +1144,40 @@ func main() {
 		outfmt = fmt_json
 	}
 
+	if *opt_template != "" && (*opt_compile || *opt_json || *opt_inplace) {
+		fmt.Fprintln(os.Stderr,
+			"-template is incompatible with -c, -json, and -i")
+		os.Exit(2)
+	}
+
 	if nmode > 0 {
 		bail := false
-		if *opt_sign || *opt_key != "" {
+		if *opt_template != "" && !*opt_acctinfo && !*opt_txinfo {
 			fmt.Fprintln(os.Stderr,
-				"--sign and --key only availble in default mode")
+				"-template only available in default mode, -qa, and -qt")
 			bail = true
 		}
-		if *opt_learn || *opt_update {
-			fmt.Fprintln(os.Stderr, "-l and -u only availble in default mode")
+		if *opt_sign || (*opt_key != "" && *opt_receipt == "" &&
+			!*opt_schedule) || *opt_payload != "" {
+			fmt.Fprintln(os.Stderr,
+				"--sign, --key, and --payload only availble in default mode")
+			bail = true
+		}
+		if *opt_receipt != "" && !*opt_post {
+			fmt.Fprintln(os.Stderr, "-receipt only available with -post")
 			bail = true
 		}
-		if *opt_inplace || *opt_output != "" {
-			fmt.Fprintln(os.Stderr, "-i and -o only availble in default mode")
+		if *opt_learn || *opt_learn_from != "" || *opt_update ||
+			*opt_ledgers != "" {
+			fmt.Fprintln(os.Stderr, "-l, -learn-from, -u, and -ledgers " +
+				"only availble in default mode")
+			bail = true
+		}
+		if *opt_inplace || (*opt_output != "" && !*opt_snapshot &&
+			!*opt_restore && !*opt_control && *opt_txtemplate == "") {
+			fmt.Fprintln(os.Stderr,
+				"-i and -o only availble in default mode, -snapshot, " +
+				"-restore, -control, and -txtemplate")
 			bail = true
 		}
 		if *opt_compile {
@@ -626,6 +1198,21 @@ func main() {
 	} else if *opt_inplace && *opt_output != "" {
 		fmt.Fprintln(os.Stderr, "-i and -o are mutually exclusive")
 		os.Exit(2)
+	} else if *opt_ledgers != "" && !*opt_update {
+		fmt.Fprintln(os.Stderr, "-ledgers requires -u")
+		os.Exit(2)
+	} else if *opt_payload != "" && !*opt_sign && *opt_key == "" {
+		fmt.Fprintln(os.Stderr, "-payload requires -sign")
+		os.Exit(2)
+	}
+	var payload []byte
+	if *opt_payload != "" {
+		var err error
+		if payload, err = hex.DecodeString(*opt_payload); err != nil ||
+			len(payload) == 0 || len(payload) > 64 {
+			fmt.Fprintln(os.Stderr, "-payload must be 1 to 64 bytes in hex")
+			os.Exit(2)
+		}
 	}
 
 	var arg string
@@ -633,6 +1220,23 @@ func main() {
 		arg = flag.Args()[0]
 	}
 
+	// Modes that never read the configuration directory should not
+	// create it
+	if offline := *opt_verify_receipt || *opt_hint || *opt_opid ||
+		*opt_mux || *opt_demux || *opt_date; !offline {
+		if err := MigrateConfigDir(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	if *opt_timeout > 0 {
+		// Copy the client, so as not to lose its transport
+		c := *DefaultHTTPClient
+		c.Timeout = *opt_timeout
+		DefaultHTTPClient = &c
+	}
+
 	if *opt_nopass {
 		stcdetail.PassphraseFile = io.MultiReader()
 	} else if arg == "-" {
@@ -640,6 +1244,9 @@ func main() {
 	}
 
 	switch {
+	case *opt_verify_receipt:
+		verifyReceipts(arg)
+		return
 	case *opt_hint:
 		var pk PublicKey
 		if _, err := fmt.Sscan(arg, &pk); err != nil {
@@ -647,29 +1254,32 @@ func main() {
 			os.Exit(2)
 		}
 		fmt.Printf("%x\n", pk.Hint())
+		if *opt_verbose {
+			sk := pk.ToSignerKey()
+			fmt.Println(KeyFingerprint(&sk))
+		}
 		os.Exit(0)
 	case *opt_opid:
-		var opid stx.OperationID
-		opid.Type = stx.ENVELOPE_TYPE_OP_ID
-		if _, err := fmt.Sscan(arg, &opid.Id().SourceAccount); err != nil {
+		var src AccountID
+		var seq stx.SequenceNumber
+		var opnum uint32
+		if _, err := fmt.Sscan(arg, &src); err != nil {
 			fmt.Fprintf(os.Stderr, "invalid account ID %s\n", arg)
 			os.Exit(2)
 		}
 		arg = flag.Args()[1]
-		if _, err := fmt.Sscan(arg, &opid.Id().SeqNum); err != nil {
+		if _, err := fmt.Sscan(arg, &seq); err != nil {
 			fmt.Fprintf(os.Stderr, "invalid SequenceNumber %q (%s)\n",
 				arg, err)
 			os.Exit(2)
 		}
 		arg = flag.Args()[2]
-		if _, err := fmt.Sscan(arg, &opid.Id().OpNum); err != nil {
+		if _, err := fmt.Sscan(arg, &opnum); err != nil {
 			fmt.Fprintf(os.Stderr, "invalid operation number %q (%s)\n",
 				arg, err)
 			os.Exit(2)
 		}
-		var cbid stx.ClaimableBalanceID
-		cbid.Type = stx.CLAIMABLE_BALANCE_ID_TYPE_V0
-		*cbid.V0() = stcdetail.XdrSHA256(&opid)
+		cbid := ClaimableBalanceID(&src, seq, opnum)
 		fmt.Printf("%x\n", []byte(stcdetail.XdrToBin(&cbid)))
 		return
 	case *opt_mux:
@@ -722,7 +1332,7 @@ func main() {
 		if arg != "" {
 			arg = AdjustKeyName(arg)
 		}
-		doKeyGen(arg)
+		doKeyGen(arg, *opt_pass_passphrase)
 		return
 	case *opt_sec2pub:
 		if arg != "" {
@@ -732,9 +1342,22 @@ func main() {
 		return
 	case *opt_import_key:
 		arg = AdjustKeyName(arg)
-		sk, err := InputPrivateKey("Secret key: ")
-		if err == nil {
-			err = sk.Save(arg, stcdetail.GetPass2("Passphrase: "))
+		var err error
+		if *opt_pass != "" {
+			if err = SavePassKeyRef(arg, *opt_pass); err == nil {
+				var sk PrivateKey
+				if sk, err = LoadPrivateKey(arg); err == nil {
+					fmt.Println(sk.Public())
+				} else {
+					os.Remove(arg)
+				}
+			}
+		} else {
+			var sk PrivateKey
+			sk, err = InputPrivateKey("Secret key: ")
+			if err == nil {
+				err = saveKey(sk, arg, *opt_pass_passphrase)
+			}
 		}
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err.Error())
@@ -752,8 +1375,51 @@ func main() {
 		return
 	case *opt_list_keys:
 		for _, k := range GetKeyNames() {
-			fmt.Println(k)
+			if h, err := KeyFileHeaders(ConfigPath("keys", k)); err == nil &&
+				h[MnemonicHeader] != "" {
+				fmt.Printf("%s (%s %s)\n", k, h[MnemonicHeader],
+					h[DerivationPathHeader])
+			} else {
+				fmt.Println(k)
+			}
+		}
+		return
+	case *opt_import_mnemonic:
+		arg = AdjustMnemonicName(arg)
+		doImportMnemonic(arg, *opt_pass_passphrase)
+		return
+	case *opt_derive:
+		name := arg
+		keyname := fmt.Sprintf("%s-%d", name, *opt_index)
+		if len(flag.Args()) > 1 {
+			keyname = flag.Arg(1)
+		}
+		doDerive(AdjustMnemonicName(name), filepath.Base(name),
+			uint32(*opt_index), AdjustKeyName(keyname), *opt_pass_passphrase)
+		return
+	case *opt_schedule && arg == "":
+		doListSchedule()
+		return
+	case *opt_run_schedule:
+		doRunSchedule(*opt_daemon)
+		return
+	case *opt_doctor:
+		if doDoctor() > 0 {
+			os.Exit(1)
+		}
+		return
+	case *opt_detect:
+		if !ValidNetName(*opt_netname) {
+			fmt.Fprintln(os.Stderr, "-detect requires a valid -net=ID")
+			os.Exit(2)
+		}
+		_, root, err := DetectStellarNet(*opt_netname, arg,
+			ConfigPath(*opt_netname + ".net"), ConfigPath("global.conf"))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
+		fmt.Print(root)
 		return
 	}
 
@@ -762,6 +1428,14 @@ func main() {
 		fmt.Fprintf(os.Stderr, "unknown network %q\n", *opt_netname)
 		os.Exit(1)
 	}
+	net.Logf = func(format string, args ...interface{}) {
+		fmt.Fprintf(os.Stderr, "notice: "+format+"\n", args...)
+	}
+
+	var tmpl *template.Template
+	if *opt_template != "" {
+		tmpl = mustParseTemplate(net, *opt_template)
+	}
 
 	if *opt_acctinfo {
 		var acct AccountID
@@ -769,11 +1443,13 @@ func main() {
 			fmt.Fprintln(os.Stderr, "syntactically invalid account")
 			os.Exit(1)
 		}
-		if ae, err := net.GetAccountEntry(arg); err != nil {
+		if as, err := net.GetAccountSummary(nil, arg); err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
+		} else if tmpl != nil {
+			mustExecTemplate("", tmpl, as)
 		} else {
-			fmt.Print(ae)
+			fmt.Print(as)
 		}
 		return
 	}
@@ -783,9 +1459,11 @@ func main() {
 		if _, err := fmt.Sscanf(arg, "%v", stx.XDR_Hash(&txid)); err != nil {
 			fmt.Fprintln(os.Stderr, "syntactically invalid txid")
 			os.Exit(1)
-		} else if txr, err := net.GetTxResult(arg); err != nil {
+		} else if txr, err := net.GetTransaction(arg); err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
+		} else if tmpl != nil {
+			mustExecTemplate("", tmpl, txr)
 		} else if *opt_verbose {
 			fmt.Print(txr)
 		} else {
@@ -798,6 +1476,53 @@ func main() {
 		return
 	}
 
+	if *opt_history {
+		cursor := ""
+		if len(flag.Args()) > 1 {
+			cursor = flag.Args()[1]
+		}
+		txs, err := net.GetAccountTransactions(resolveAccount(net, arg),
+			cursor, 10)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		for i := range txs {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("txhash: %x\nledger: %d\ncreated_at: %d (%s)\n",
+				txs[i].Txhash, txs[i].Ledger, txs[i].Time.Unix(),
+				txs[i].Time.Format(time.UnixDate))
+			net.WriteRep(os.Stdout, "", &txs[i].Env)
+			net.WriteRep(os.Stdout, "", &txs[i].Result)
+		}
+		if len(txs) == 10 {
+			fmt.Fprintf(os.Stderr, "more: %s -history %s %s\n",
+				progname, arg, txs[len(txs)-1].PagingToken)
+		}
+		return
+	}
+
+	if *opt_reconcile {
+		cursor := ""
+		if len(flag.Args()) > 1 {
+			cursor = flag.Args()[1]
+		}
+		r, err := net.Reconcile(nil, resolveAccount(net, arg), cursor, nil)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Print(r)
+		for i := range r.Assets {
+			if r.Assets[i].OpeningKnown && r.Assets[i].Discrepancy() != 0 {
+				os.Exit(1)
+			}
+		}
+		return
+	}
+
 	if *opt_txacct {
 		var acct AccountID
 		if _, err := fmt.Sscan(arg, &acct); err != nil {
@@ -805,10 +1530,20 @@ func main() {
 			os.Exit(1)
 		}
 
+		filter := &net.Filter
+		if *opt_nofilter {
+			filter = nil
+		}
 		nl := false
+		hidden := 0
 		err := net.IterateJSON(nil, "accounts/" + arg +
 			"/transactions?order=desc&limit=200",
 			func(r *HorizonTxResult) {
+				if filter.IsSpam(&TransactionEnvelope{
+					TransactionEnvelope: &r.Env}, &acct) {
+					hidden++
+					return
+				}
 				if *opt_verbose {
 					if !nl {
 						nl = true
@@ -821,6 +1556,10 @@ func main() {
 					fmt.Printf(net.AccountDelta(&r.StellarMetas, &acct, "  "))
 				}
 			})
+		if hidden > 0 {
+			fmt.Fprintf(os.Stderr, "%d transactions hidden as dust or " +
+				"spam (use -nofilter to show)\n", hidden)
+		}
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
@@ -828,13 +1567,73 @@ func main() {
 		return
 	}
 
+	if *opt_assetinfo {
+		code, issuer := arg, ""
+		if i := strings.IndexByte(arg, ':'); i >= 0 {
+			if _, err := ParseAsset(arg); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			code, issuer = arg[:i], arg[i+1:]
+		} else if err := stcdetail.CheckAssetCode(code); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		stats, err := net.GetAssets(code, issuer)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		} else if len(stats) == 0 {
+			fmt.Fprintf(os.Stderr, "no asset %s found\n", arg)
+			os.Exit(1)
+		}
+		for i := range stats {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Print(&stats[i])
+		}
+		return
+	}
+
+	if *opt_snapshot {
+		doSnapshot(net, resolveAccount(net, arg), *opt_output)
+		return
+	}
+
+	if *opt_restore {
+		doRestore(net, *opt_output, flag.Args())
+		return
+	}
+
+	if *opt_control {
+		doControl(net, resolveAccount(net, arg), *opt_recovery, *opt_output,
+			outfmt)
+		return
+	}
+
+	if *opt_txtemplate != "" {
+		doTxTemplate(net, *opt_txtemplate, flag.Args(), *opt_output, outfmt)
+		return
+	}
+
+	if *opt_sweep {
+		doSweep(net, arg, flag.Args()[1])
+		return
+	}
+
+	if *opt_schedule {
+		doSchedule(net, arg, flag.Args()[1], *opt_cron, *opt_key)
+		return
+	}
+
 	if *opt_friendbot {
 		var acct AccountID
-		if _, err := fmt.Sscan(arg, &acct); err != nil {
+		if _, err := fmt.Sscan(resolveAccount(net, arg), &acct); err != nil {
 			fmt.Fprintln(os.Stderr, "syntactically invalid account")
 			os.Exit(1)
 		}
-		if _, err := net.Get("friendbot?addr=" + arg); err != nil {
+		if err := net.FundAccount(acct.String()); err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
@@ -863,37 +1662,125 @@ func main() {
 		return
 	}
 
+	if *opt_ledger {
+		var seq uint32
+		if _, err := fmt.Sscan(arg, &seq); err != nil {
+			fmt.Fprintln(os.Stderr, "invalid ledger number")
+			os.Exit(2)
+		}
+		ls, err := net.GetLedgerSummary(seq)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error fetching ledger: %s\n",
+				err.Error())
+			os.Exit(1)
+		}
+		fmt.Print(ls)
+		if *opt_verbose {
+			for i := range ls.Txs {
+				fmt.Printf("==== TRANSACTION %d ====\n", i)
+				fmt.Print(ls.Txs[i])
+			}
+		}
+		return
+	}
+
 	if *opt_edit {
 		doEdit(net, arg)
 		return
 	}
 
+	if *opt_wizard {
+		doWizard(net, arg)
+		return
+	}
+
+	if *opt_ceremony {
+		doCeremony(net, arg)
+		return
+	}
+
+	if *opt_collect {
+		doCollect(net, arg, flag.Args()[1])
+		return
+	}
+
+	if *opt_check_bundle {
+		if !doCheckBundle(net, arg) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	e, infmt := mustReadTx(arg)
+	if infmt == fmt_bundle && *opt_inplace {
+		fmt.Fprintln(os.Stderr, "-i cannot be used to overwrite a bundle")
+		os.Exit(2)
+	}
 	switch {
+	case *opt_bundle:
+		doBundle(net, e)
 	case *opt_post:
-		res, err := net.Post(e)
-		if err == nil {
-			fmt.Print(xdr.XdrToString(res))
-		} else {
-			fmt.Fprintf(os.Stderr, "Post transaction failed: %s\n", err)
+		if *opt_receipt == "" {
+			postTx(net, e)
+			break
+		}
+		key := *opt_key
+		if key != "" {
+			key = AdjustKeyName(key)
+		}
+		sk, err := getSecKey(key)
+		if err != nil {
 			os.Exit(1)
 		}
+		postTx(net, e)
+		writeReceipt(net, e, *opt_receipt, sk)
 	case *opt_txhash:
 		fmt.Printf("%x\n", *net.HashTx(e))
+	case *opt_explain:
+		fmt.Println(net.ExplainTx(e))
+		warnRisks(net, e, true)
+	case *opt_sigkeys:
+		warnRisks(net, e, true)
+		cands := net.SigCandidates(e)
+		for i, sig := range *e.Signatures() {
+			fmt.Printf("signatures[%d].hint: %x\n", i, sig.Hint)
+			if len(cands[i]) == 0 {
+				fmt.Println("  (no known key matches hint)")
+			}
+			for _, c := range cands[i] {
+				status := "INVALID"
+				if c.Valid {
+					status = "valid"
+				}
+				fmt.Printf("  %s %s\n", status, c.SignerKeyInfo)
+			}
+		}
 	case *opt_preauth:
 		sk := stx.SignerKey{Type: stx.SIGNER_KEY_TYPE_PRE_AUTH_TX}
 		*sk.PreAuthTx() = *net.HashTx(e)
 		fmt.Println(&sk)
 	default:
-		getAccounts(net, e, *opt_learn)
+		usenet := *opt_learn
+		var saved map[string]*HorizonAccountEntry
+		if *opt_learn_from != "" {
+			var err error
+			if saved, err = net.ReadAccountDir(*opt_learn_from);
+			err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			*opt_learn = true
+		}
+		getAccounts(net, e, usenet, saved)
 		if *opt_zerosig {
 			*e.Signatures() = nil
 		}
 		if *opt_update {
-			fixTx(net, e)
+			fixTx(net, e, *opt_ledgers)
 		}
 		if *opt_sign || *opt_key != "" {
-			if err := signTx(net, *opt_key, e); err != nil {
+			warnRisks(net, e, *opt_update || usenet)
+			if err := signTx(net, *opt_key, e, payload); err != nil {
 				os.Exit(1)
 			}
 		}
@@ -906,6 +1793,10 @@ func main() {
 				outfmt = infmt
 			}
 		}
+		if tmpl != nil {
+			mustExecTemplate(*opt_output, tmpl, e)
+			return
+		}
 		mustWriteTx(*opt_output, e, net, outfmt)
 	}
 }
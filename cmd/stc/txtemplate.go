@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	. "github.com/xdrpp/stc"
+)
+
+// Write to outfile (or standard output) in format f the transaction
+// produced by the transaction template name, given arguments of the
+// form PARAM=VALUE.  Lists the available templates if name is not
+// one of them.
+func doTxTemplate(net *StellarNet, name string, args []string,
+	outfile string, f format) {
+	t := LookupTxTemplate(name)
+	if t == nil {
+		fmt.Fprintf(os.Stderr, "unknown transaction template %q; "+
+			"available templates:\n", name)
+		for _, n := range TxTemplateNames() {
+			fmt.Fprint(os.Stderr, LookupTxTemplate(n))
+		}
+		os.Exit(2)
+	}
+	params := make(map[string]string)
+	for _, arg := range args {
+		i := strings.IndexByte(arg, '=')
+		if i <= 0 {
+			fmt.Fprintf(os.Stderr, "argument %q should be PARAM=VALUE\n",
+				arg)
+			os.Exit(2)
+		}
+		params[arg[:i]] = arg[i+1:]
+	}
+	e, err := t.Instantiate(net, params)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\nusage: %s", err, t)
+		os.Exit(1)
+	}
+	fixTx(net, e, "")
+	mustWriteTx(outfile, e, net, f)
+}
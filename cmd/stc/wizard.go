@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/xdrpp/stc"
+	"github.com/xdrpp/stc/stcdetail"
+	"github.com/xdrpp/stc/stx"
+)
+
+// Print prompt, followed by def in brackets if def is not empty, and
+// return the line the user types, or def if the line is empty.  Exits
+// at end of file.
+func ask(prompt, def string) string {
+	if def != "" {
+		fmt.Printf("%s [%s]: ", prompt, def)
+	} else {
+		fmt.Printf("%s: ", prompt)
+	}
+	line, err := stcdetail.ReadTextLine(os.Stdin)
+	ret := strings.TrimSpace(string(line))
+	if err != nil && ret == "" {
+		fmt.Println()
+		os.Exit(1)
+	} else if ret == "" {
+		return def
+	}
+	return ret
+}
+
+// Like ask, but repeat the question until parse accepts the answer.
+func askValid(prompt, def string, parse func(string) error) string {
+	for {
+		ret := ask(prompt, def)
+		if err := parse(ret); err != nil {
+			fmt.Printf("invalid input: %s\n", err)
+			continue
+		}
+		return ret
+	}
+}
+
+// Prompt for an account ID or the comment on a known account.
+func askAccount(net *StellarNet, prompt string) AccountID {
+	var ret AccountID
+	askValid(prompt, "", func(s string) error {
+		_, err := fmt.Sscan(resolveAccount(net, s), &ret)
+		return err
+	})
+	return ret
+}
+
+// Like askAccount, but also accept a muxed account (M...).
+func askMuxedAccount(net *StellarNet, prompt string) MuxedAccount {
+	var ret MuxedAccount
+	askValid(prompt, "", func(s string) error {
+		_, err := fmt.Sscan(resolveAccount(net, s), &ret)
+		return err
+	})
+	return ret
+}
+
+// Prompt for a positive amount such as 1.5.
+func askAmount(prompt, def string) int64 {
+	var ret int64
+	askValid(prompt, def, func(s string) error {
+		var err error
+		if ret, err = ParseAmount(s); err != nil {
+			return err
+		} else if ret <= 0 {
+			return fmt.Errorf("amount must be positive")
+		}
+		return nil
+	})
+	return ret
+}
+
+// Prompt for "native" or an asset in CODE:ISSUER format.
+func askAsset(prompt, def string) stx.Asset {
+	var ret stx.Asset
+	askValid(prompt, def, func(s string) error {
+		var err error
+		ret, err = ParseAsset(s)
+		return err
+	})
+	return ret
+}
+
+// Prompt for y or n.
+func askYes(prompt string, def bool) bool {
+	sdef := "y/N"
+	if def {
+		sdef = "Y/n"
+	}
+	for {
+		switch strings.ToLower(ask(prompt, sdef)) {
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		case strings.ToLower(sdef):
+			return def
+		}
+	}
+}
+
+// The operations the wizard knows how to build.
+var wizardOps = []struct {
+	name  string
+	build func(net *StellarNet) OperationBody
+}{
+	{"payment", func(net *StellarNet) OperationBody {
+		dest := askMuxedAccount(net, "Destination account")
+		asset := askAsset("Asset", "native")
+		return Payment{
+			Destination: dest,
+			Asset:       asset,
+			Amount:      askAmount("Amount", ""),
+		}
+	}},
+	{"create account", func(net *StellarNet) OperationBody {
+		dest := askAccount(net, "New account")
+		return CreateAccount{
+			Destination:     dest,
+			StartingBalance: askAmount("Starting balance", "1"),
+		}
+	}},
+	{"trust asset", func(net *StellarNet) OperationBody {
+		var asset stx.Asset
+		for asset.Type == stx.ASSET_TYPE_NATIVE {
+			asset = askAsset("Asset (CODE:ISSUER)", "")
+		}
+		line := stx.ChangeTrustAsset{Type: asset.Type}
+		if asset.Type == stx.ASSET_TYPE_CREDIT_ALPHANUM4 {
+			*line.AlphaNum4() = *asset.AlphaNum4()
+		} else {
+			*line.AlphaNum12() = *asset.AlphaNum12()
+		}
+		limit := int64(MaxInt64)
+		if !askYes("Trust an unlimited amount", true) {
+			limit = askAmount("Limit", "")
+		}
+		return ChangeTrust{Line: line, Limit: limit}
+	}},
+	{"set data entry", func(net *StellarNet) OperationBody {
+		name := askValid("Name", "", func(s string) error {
+			if s == "" {
+				return fmt.Errorf("name must not be empty")
+			} else if len(s) > 64 {
+				return fmt.Errorf("name exceeds 64 bytes")
+			}
+			return nil
+		})
+		val := askValid("Value (RETURN to delete the entry)", "",
+			func(s string) error {
+				if len(s) > 64 {
+					return fmt.Errorf("value exceeds 64 bytes")
+				}
+				return nil
+			})
+		ret := ManageData{DataName: name}
+		if val != "" {
+			dv := stx.DataValue(val)
+			ret.DataValue = &dv
+		}
+		return ret
+	}},
+	{"set home domain", func(net *StellarNet) OperationBody {
+		domain := askValid("Home domain", "", func(s string) error {
+			if len(s) > 32 {
+				return fmt.Errorf("domain exceeds 32 bytes")
+			}
+			return nil
+		})
+		return SetOptions{HomeDomain: NewString(domain)}
+	}},
+	{"claw back asset", func(net *StellarNet) OperationBody {
+		from := askAccount(net, "Account to claw back from")
+		var asset stx.Asset
+		for asset.Type == stx.ASSET_TYPE_NATIVE {
+			asset = askAsset("Asset (CODE:ISSUER)", "")
+		}
+		op, _ := NewClawback(from, asset, askAmount("Amount", ""))
+		return op
+	}},
+	{"claw back claimable balance", func(net *StellarNet) OperationBody {
+		var op ClawbackClaimableBalance
+		askValid("Balance ID (hex)", "", func(s string) error {
+			var err error
+			op, err = NewClawbackClaimableBalance(s)
+			return err
+		})
+		return op
+	}},
+	{"authorize trustline", func(net *StellarNet) OperationBody {
+		trustor := askAccount(net, "Trustor account")
+		var asset stx.Asset
+		for asset.Type == stx.ASSET_TYPE_NATIVE {
+			asset = askAsset("Asset (CODE:ISSUER)", "")
+		}
+		for {
+			switch ask("Authorization (full, maintain, or none)", "full") {
+			case "full":
+				return AuthorizeTrustLine(trustor, asset)
+			case "maintain":
+				return AuthorizeToMaintainLiabilities(trustor, asset)
+			case "none":
+				return DeauthorizeTrustLine(trustor, asset)
+			}
+		}
+	}},
+	{"enable clawback", func(net *StellarNet) OperationBody {
+		return NewSetOptions().EnableClawback().Op()
+	}},
+	{"merge account", func(net *StellarNet) OperationBody {
+		dest := askMuxedAccount(net, "Account to receive the balance")
+		return AccountMerge(dest)
+	}},
+}
+
+// Interactively build a transaction, write it to outfile in text
+// format, and optionally sign and post it.
+func doWizard(net *StellarNet, outfile string) {
+	if outfile == "-" {
+		fmt.Fprintln(os.Stderr, "-wizard requires a file, not stdout")
+		os.Exit(2)
+	} else if FileExists(outfile) {
+		fmt.Fprintf(os.Stderr, "%s already exists (use -edit to change "+
+			"it)\n", outfile)
+		os.Exit(1)
+	}
+
+	e := NewTransactionEnvelope()
+	src := askMuxedAccount(net, "Source account")
+	e.SetSourceAccount(&src)
+	if err := net.SetNextSequence(e); err != nil {
+		fmt.Printf("warning: cannot fetch %s (%s); using sequence "+
+			"number 0\n", net.DescribeAccount(&src), err)
+	}
+
+	for len(e.V1().Tx.Operations) < stx.MAX_OPS_PER_TX {
+		fmt.Println("Operations:")
+		for i := range wizardOps {
+			fmt.Printf("  %d. %s\n", i+1, wizardOps[i].name)
+		}
+		prompt := "Operation to add, or RETURN when done"
+		if len(e.V1().Tx.Operations) == 0 {
+			prompt = "Operation to add"
+		}
+		sop := askValid(prompt, "", func(s string) error {
+			if n, err := strconv.Atoi(s); s == "" &&
+				len(e.V1().Tx.Operations) > 0 {
+				return nil
+			} else if err != nil || n < 1 || n > len(wizardOps) {
+				return fmt.Errorf("enter a number from 1 to %d",
+					len(wizardOps))
+			}
+			return nil
+		})
+		if sop == "" {
+			break
+		}
+		n, _ := strconv.Atoi(sop)
+		e.Append(nil, wizardOps[n-1].build(net))
+	}
+
+	memoPrompt := "Memo text (RETURN for none)"
+	if net.TxDefaults.RequireMemo {
+		memoPrompt = "Memo text (required on this network)"
+	}
+	memo := askValid(memoPrompt, "", func(s string) error {
+		if s == "" && net.TxDefaults.RequireMemo {
+			return fmt.Errorf("this network's configuration requires a memo")
+		} else if len(s) > 28 {
+			return fmt.Errorf("memo text exceeds 28 bytes")
+		}
+		return nil
+	})
+	if memo != "" {
+		Set(&e.V1().Tx.Memo, stx.MEMO_TEXT, memo)
+	}
+
+	fee := uint32(100)
+	if fs, err := net.GetFeeCache(); err == nil {
+		fee = uint32(net.DefaultFee(fs))
+	} else if lh, err := net.GetLedgerHeader(); err == nil {
+		fee = uint32(lh.BaseFee)
+	}
+	sfee := askValid("Fee per operation in stroops",
+		strconv.FormatUint(uint64(fee), 10), func(s string) error {
+			_, err := strconv.ParseUint(s, 10, 32)
+			return err
+		})
+	fee64, _ := strconv.ParseUint(sfee, 10, 32)
+	e.SetFee(uint32(fee64))
+
+	defexp := "300"
+	if t := net.TxDefaults.Timeout; t > 0 {
+		defexp = strconv.FormatInt(int64(t/time.Second), 10)
+	}
+	sexp := askValid("Seconds until transaction expires (0 for never)",
+		defexp, func(s string) error {
+			_, err := strconv.ParseUint(s, 10, 32)
+			return err
+		})
+	if exp, _ := strconv.ParseUint(sexp, 10, 32); exp > 0 {
+		e.V1().Tx.Cond.Type = stx.PRECOND_TIME
+		e.V1().Tx.Cond.TimeBounds().MaxTime =
+			stx.TimePoint(time.Now().Unix() + int64(exp))
+	}
+
+	fmt.Println()
+	fmt.Println(net.ExplainTx(e))
+	warnRisks(net, e, true)
+	mustWriteTx(outfile, e, net, fmt_txrep)
+	fmt.Printf("Wrote %s\n", outfile)
+
+	for {
+		key := ask("Key name to sign with, \"-\" to type a secret key, "+
+			"or RETURN to stop", "")
+		if key == "" {
+			fmt.Printf("To sign later, run: %s -sign -i %s\n",
+				progname, outfile)
+			return
+		} else if key == "-" {
+			key = ""
+		}
+		if signTx(net, key, e, nil) == nil {
+			break
+		}
+	}
+	mustWriteTx(outfile, e, net, fmt_txrep)
+
+	if askYes(fmt.Sprintf("Post transaction to %s network", net.Name),
+		false) {
+		postTx(net, e)
+	} else {
+		fmt.Printf("To post later, run: %s -post %s\n", progname, outfile)
+	}
+}
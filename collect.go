@@ -0,0 +1,207 @@
+package stc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/xdrpp/stc/stcdetail"
+	"github.com/xdrpp/stc/stx"
+	"path/filepath"
+	"strings"
+)
+
+// Returns the keys whose signatures MergeSignatures accepts: the
+// signers of the accounts in accts and the keys in net.Approvers.
+func (net *StellarNet) mergeKeys(
+	accts map[string]*HorizonAccountEntry) []SignerKey {
+	var ret []SignerKey
+	for _, ae := range accts {
+		for i := range ae.Signers {
+			if ae.Signers[i].Weight > 0 {
+				ret = append(ret, ae.Signers[i].Key)
+			}
+		}
+	}
+	for approver := range net.Approvers {
+		var pk PublicKey
+		if _, err := fmt.Sscan(approver, &pk); err == nil {
+			ret = append(ret, pk.ToSignerKey())
+		}
+	}
+	return ret
+}
+
+// Appends to *dst the signatures in src that are valid on tx for one
+// of keys, skipping signatures by keys that have already signed *dst
+// and stopping at MaxTxSignatures.  Returns the number added.
+func (net *StellarNet) mergeSigs(dst *[]stx.DecoratedSignature,
+	src []stx.DecoratedSignature, tx stx.Signable, keys []SignerKey) int {
+	signer := func(sig *stx.DecoratedSignature) string {
+		for i := range keys {
+			if keys[i].Hint() == sig.Hint &&
+				net.VerifySig(&keys[i], tx, sig.Signature) {
+				return keys[i].String()
+			}
+		}
+		return ""
+	}
+	signed := make(map[string]bool)
+	for i := range *dst {
+		if k := signer(&(*dst)[i]); k != "" {
+			signed[k] = true
+		}
+	}
+	n := 0
+	for i := range src {
+		if len(*dst) >= MaxTxSignatures {
+			break
+		} else if k := signer(&src[i]); k != "" && !signed[k] {
+			signed[k] = true
+			*dst = append(*dst, src[i])
+			n++
+		}
+	}
+	return n
+}
+
+// Adds to dst the valid signatures on src that dst lacks, and returns
+// the number of signatures added.  Both envelopes must contain the
+// same transaction (i.e., have the same hash on net).  A signature is
+// valid if it verifies against the transaction hash for a signer of
+// one of the accounts in accts (as returned by GetSourceAccounts) or
+// for a key in net.Approvers.  Invalid signatures, signatures by a
+// key that has already signed dst, and signatures beyond
+// MaxTxSignatures are dropped.  (For fee-bump transactions, equal
+// hashes imply identical inner transactions, signatures included, so
+// only the outer signatures are merged.)
+func (net *StellarNet) MergeSignatures(dst, src *TransactionEnvelope,
+	accts map[string]*HorizonAccountEntry) (int, error) {
+	if *net.HashTx(dst) != *net.HashTx(src) {
+		return 0, fmt.Errorf("cannot merge signatures of different " +
+			"transactions")
+	}
+	return net.mergeSigs(dst.Signatures(), *src.Signatures(), dst,
+		net.mergeKeys(accts)), nil
+}
+
+// Parses a transaction in any of the formats stc writes: a TxBundle,
+// base64 XDR, JSON, or txrep.
+func parseTxFile(input []byte) (*TransactionEnvelope, error) {
+	sinput := strings.TrimSpace(string(input))
+	if IsTxBundle(input) {
+		b, err := ParseTxBundle(input)
+		if err != nil {
+			return nil, err
+		}
+		return b.Envelope()
+	} else if strings.HasPrefix(sinput, "{") {
+		e := NewTransactionEnvelope()
+		if err := stcdetail.JsonToXdr(e, input); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	if _, err := base64.StdEncoding.DecodeString(sinput); err != nil ||
+		sinput == "" {
+		return TxFromRep(string(input))
+	}
+	return TxFromBase64(sinput)
+}
+
+// Collects the signatures on copies of a transaction saved in the
+// files of directory dir, which is meant to be shared among the
+// parties who must sign the transaction (e.g., on a network file
+// system).  Equivalent to CollectTxFrom(DirShare(dir), txid, nil).
+func (net *StellarNet) CollectTx(dir, txid string) (
+	*TransactionEnvelope, int, error) {
+	return net.CollectTxFrom(DirShare(dir), txid, nil)
+}
+
+// Collects the signatures on copies of a transaction saved in share.
+// Each party who must sign the transaction drops a partially signed
+// copy into share (for instance with ShareTxTo), in any format stc
+// can read.  txid is the hex transaction hash on net, or an
+// unambiguous prefix of it.  Returns the transaction with the valid
+// signatures found (see MergeSignatures) and the number of files that
+// contained it.  If accts is nil, the transaction's source accounts
+// are fetched from horizon to check signatures against.  Files that
+// cannot be parsed or contain other transactions are ignored.
+func (net *StellarNet) CollectTxFrom(share TxShare, txid string,
+	accts map[string]*HorizonAccountEntry) (
+	*TransactionEnvelope, int, error) {
+	txid = strings.ToLower(txid)
+	if txid == "" || strings.Trim(txid, "0123456789abcdef") != "" {
+		return nil, 0, fmt.Errorf("invalid transaction hash %q", txid)
+	}
+	names, err := share.List()
+	if err != nil {
+		return nil, 0, err
+	}
+	var found []*TransactionEnvelope
+	var hash string
+	for _, name := range names {
+		input, err := share.Read(name)
+		if err != nil {
+			return nil, 0, err
+		}
+		e, err := parseTxFile(input)
+		if err != nil {
+			continue
+		}
+		h := fmt.Sprintf("%x", *net.HashTx(e))
+		if !strings.HasPrefix(h, txid) {
+			continue
+		} else if hash == "" {
+			hash = h
+		} else if h != hash {
+			return nil, 0, fmt.Errorf("transaction hash %s is ambiguous",
+				txid)
+		}
+		found = append(found, e)
+	}
+	if len(found) == 0 {
+		return nil, 0, fmt.Errorf("transaction %s not found in %s",
+			txid, share)
+	}
+	if accts == nil {
+		if accts, err = net.GetSourceAccounts(found[0]); err != nil {
+			return nil, 0, err
+		}
+	}
+	// Start from an unsigned copy so every signature gets checked
+	ret, err := TxFromBase64(TxToBase64(found[0]))
+	if err != nil {
+		return nil, 0, err
+	}
+	*ret.Signatures() = nil
+	for _, e := range found {
+		net.MergeSignatures(ret, e, accts)
+	}
+	return ret, len(found), nil
+}
+
+// Saves e in base64 XDR to a file in directory dir, for use with
+// CollectTx, and returns the path of the file.  Equivalent to
+// ShareTxTo(DirShare(dir), e) except for the return value.
+func (net *StellarNet) ShareTx(dir string, e *TransactionEnvelope) (
+	string, error) {
+	name, err := net.ShareTxTo(DirShare(dir), e)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// Saves e in base64 XDR to a file in share, for use with
+// CollectTxFrom, and returns the name of the file.  The file name
+// starts with the transaction's hash and depends on the signatures,
+// so parties with different signatures never overwrite each other's
+// files.
+func (net *StellarNet) ShareTxTo(share TxShare, e *TransactionEnvelope) (
+	string, error) {
+	sum := stcdetail.XdrSHA256(e)
+	name := fmt.Sprintf("%x-%x.tx", *net.HashTx(e), sum[:4])
+	if err := share.Write(name, []byte(TxToBase64(e)+"\n")); err != nil {
+		return "", err
+	}
+	return name, nil
+}
@@ -1,15 +1,23 @@
 package stc
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/xdrpp/stc/ini"
 	"github.com/xdrpp/stc/stcdetail"
+	"github.com/xdrpp/stc/stx"
 	"io/ioutil"
+	"math"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 const configFileName = "stc.conf"
@@ -30,15 +38,21 @@ native-asset = XLM
 [net "test"]
 horizon = https://horizon-testnet.stellar.org/
 native-asset = TestXLM
+friendbot = https://friendbot.stellar.org/
+
+[net "standalone"]
+network-id = "Standalone Network ; February 2017"
+horizon = http://localhost:8000/
+native-asset = XLM
+friendbot = http://localhost:8000/friendbot
 
 `)
 
 var globalConfigContents []byte
 
-func getGlobalConfigContents() []byte {
-	if globalConfigContents != nil {
-		return globalConfigContents
-	}
+// Returns the paths searched, in order, for the system stc.conf
+// configuration file.  The first one to exist is used.
+func SystemConfigPaths() []string {
 	confs := []string{
 		path.Join(getConfigDir(false), configFileName),
 		filepath.FromSlash("/etc/" + configFileName),
@@ -47,7 +61,14 @@ func getGlobalConfigContents() []byte {
 		confs = append(confs,
 			path.Join(path.Dir(path.Dir(exe)), "share", configFileName))
 	}
-	for _, conf := range confs {
+	return confs
+}
+
+func getGlobalConfigContents() []byte {
+	if globalConfigContents != nil {
+		return globalConfigContents
+	}
+	for _, conf := range SystemConfigPaths() {
 		if contents, err := ioutil.ReadFile(conf); err == nil {
 			globalConfigContents = contents
 			break
@@ -78,6 +99,7 @@ func getConfigDir(create bool) string {
 	}
 	if _, err := os.Stat(stcDir); os.IsNotExist(err) && create &&
 		os.MkdirAll(stcDir, 0777) == nil {
+		writeConfigVersion(stcDir)
 		if _, err = LoadStellarNet("main",
 			path.Join(stcDir, "main.net")); err == nil {
 				os.Symlink("main.net", path.Join(stcDir, "default.net"))
@@ -119,6 +141,76 @@ func ParseConfigFiles(sink ini.IniSink, paths...string) error {
 	return nil
 }
 
+// Prefix of environment variables that override configuration keys.
+// A variable STC_section_key=value behaves like "key = value" in an
+// unqualified [section], while STC_section__netname__key=value (with
+// two underscores on either side of netname) behaves like the same
+// line in [section "netname"].  Because environment variable names
+// cannot contain all the characters allowed in networks and keys,
+// each character of netname other than a letter or digit is written
+// as '_', and each '_' in key stands for '-'.  Thus,
+// STC_net__main__native_asset=XLM sets net.main.native-asset.  A
+// qualified variable applies only if its netname is exactly the
+// mangled name of the network being loaded, so STC_net__main__horizon
+// does not affect a network named main-net (which would need
+// STC_net__main_net__horizon).  Variables qualified by other networks
+// are ignored.
+const EnvOverridePrefix = "STC_"
+
+func mangleEnvName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// Return INI file contents corresponding to any configuration
+// overrides in the environment, or nil if there are none.
+func envOverrides(netname string) []byte {
+	var vars []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, EnvOverridePrefix) {
+			vars = append(vars, kv[len(EnvOverridePrefix):])
+		}
+	}
+	if len(vars) == 0 {
+		return nil
+	}
+	sort.Strings(vars)
+	mnetname := "__" + mangleEnvName(netname) + "__"
+	var out strings.Builder
+	for _, kv := range vars {
+		eq := strings.IndexByte(kv, '=')
+		us := strings.IndexByte(kv, '_')
+		if eq < 0 || us < 0 || us > eq {
+			continue
+		}
+		sec := ini.IniSection{Section: kv[:us]}
+		key, val := kv[us:eq], kv[eq+1:]
+		if !strings.HasPrefix(key, "__") {
+			key = key[1:]
+		} else if ValidNetName(netname) &&
+			strings.HasPrefix(key, mnetname) {
+			sec.Subsection = &netname
+			key = key[len(mnetname):]
+		} else {
+			continue
+		}
+		key = strings.ReplaceAll(key, "_", "-")
+		if !ini.ValidIniSection(sec.Section) || !ini.ValidIniKey(key) {
+			continue
+		}
+		fmt.Fprintf(&out, "%s\n%s = %s\n", sec.String(), key,
+			ini.EscapeIniValue(val))
+	}
+	if out.Len() == 0 {
+		return nil
+	}
+	return []byte(out.String())
+}
+
 func ValidNetName(name string) bool {
 	return len(name) > 0 && name[0] != '.' &&
 		ini.ValidIniSubsection(name) &&
@@ -140,6 +232,25 @@ type stellarNetParser struct {
 	// tells us we need to save it to the configuration file.
 	// (setName means set it in the configuration file.)
 	setName bool
+
+	// True once net.approvals or net.retries has been set (since 0
+	// is a valid setting).
+	setApprovals bool
+	setRetries   bool
+
+	// True once filter.min-amount has been set.
+	setMinAmount bool
+
+	// Keys of the tx section that have been set.
+	setTxKeys map[string]bool
+
+	// Keys seen in the prices section.
+	priceKeys map[string]bool
+
+	// True while parsing overrides from the environment, and once
+	// the environment has set net.horizon-mirror.
+	fromEnv    bool
+	envMirrors bool
 }
 
 func (snp *stellarNetParser) Item(ii ini.IniItem) error {
@@ -163,10 +274,60 @@ func (snp *stellarNetParser) doNet(ii ini.IniItem) error {
 		}
 	case "horizon":
 		target = &snp.Horizon
+	case "horizon-mirror":
+		if snp.fromEnv {
+			// A whitespace-separated list, which replaces any mirrors
+			// in the configuration files
+			snp.HorizonMirrors = strings.Fields(ii.Val())
+			snp.envMirrors = true
+		} else if snp.envMirrors {
+			// Overridden by the environment
+		} else if ii.Value == nil {
+			snp.HorizonMirrors = nil
+		} else {
+			snp.HorizonMirrors = append(snp.HorizonMirrors, ii.Val())
+		}
 	case "native-asset":
 		target = &snp.NativeAsset
+	case "soroban-rpc":
+		target = &snp.SorobanRPC
+	case "friendbot":
+		target = &snp.Friendbot
 	case "network-id":
 		target = &snp.NetworkId
+	case "approvals":
+		if ii.Value == nil {
+			snp.Approvals, snp.setApprovals = 0, false
+		} else if !snp.setApprovals {
+			n, err := strconv.Atoi(ii.Val())
+			if err != nil || n < 0 || n == 1 {
+				return ini.BadValue("approvals must be 0 (to disable " +
+					"the check) or at least 2")
+			}
+			snp.Approvals, snp.setApprovals = n, true
+		}
+	case "retries":
+		if ii.Value == nil {
+			snp.Retry, snp.setRetries = nil, false
+		} else if !snp.setRetries {
+			n, err := strconv.Atoi(ii.Val())
+			if err != nil || n < 0 {
+				return ini.BadValue("retries must be a non-negative integer")
+			}
+			rp := DefaultRetryPolicy
+			rp.MaxRetries = n
+			snp.Retry, snp.setRetries = &rp, true
+		}
+	case "rate-limit":
+		if ii.Value == nil {
+			snp.RateLimit = nil
+		} else if snp.RateLimit == nil {
+			rate, err := strconv.ParseFloat(ii.Val(), 64)
+			if err != nil || rate <= 0 {
+				return ini.BadValue("rate-limit must be a positive number")
+			}
+			snp.RateLimit = NewRateLimiter(rate, int(math.Ceil(rate)))
+		}
 	}
 	if target != nil {
 		if ii.Value == nil {
@@ -183,6 +344,8 @@ func (snp *stellarNetParser) doAccounts(ii ini.IniItem) error {
 	if _, err := fmt.Sscan(ii.Key, &acct); err != nil {
 		return ini.BadKey(err.Error())
 	}
+	snp.accountsLock.Lock()
+	defer snp.accountsLock.Unlock()
 	if ii.Value == nil {
 		delete(snp.Accounts, ii.Key)
 	} else if _, ok := snp.Accounts[ii.Key]; !ok {
@@ -191,15 +354,179 @@ func (snp *stellarNetParser) doAccounts(ii ini.IniItem) error {
 	return nil
 }
 
+func (snp *stellarNetParser) doApprovers(ii ini.IniItem) error {
+	var pk PublicKey
+	if _, err := fmt.Sscan(ii.Key, &pk); err != nil {
+		return ini.BadKey(err.Error())
+	}
+	if ii.Value == nil {
+		delete(snp.Approvers, ii.Key)
+	} else if _, ok := snp.Approvers[ii.Key]; !ok {
+		snp.Approvers[ii.Key] = *ii.Value
+	}
+	return nil
+}
+
+func (snp *stellarNetParser) doFilter(ii ini.IniItem) error {
+	f := &snp.Filter
+	switch ii.Key {
+	case "min-amount":
+		if ii.Value == nil {
+			f.MinAmount, snp.setMinAmount = 0, false
+		} else if !snp.setMinAmount {
+			var amount stcdetail.JsonInt64e7
+			if err := amount.UnmarshalText([]byte(ii.Val()));
+			err != nil || amount < 0 {
+				return ini.BadValue("min-amount must be a non-negative " +
+					"number")
+			}
+			f.MinAmount, snp.setMinAmount = int64(amount), true
+		}
+	case "assets":
+		if ii.Value == nil {
+			f.Assets = nil
+		} else if f.Assets == nil {
+			assets := []stx.Asset{}
+			for _, s := range strings.Fields(ii.Val()) {
+				asset, err := ParseAsset(s)
+				if err != nil {
+					return ini.BadValue(err.Error())
+				}
+				assets = append(assets, asset)
+			}
+			f.Assets = assets
+		}
+	case "memo-pattern":
+		if ii.Value == nil {
+			f.MemoPattern = nil
+		} else if f.MemoPattern == nil {
+			re, err := regexp.Compile(ii.Val())
+			if err != nil {
+				return ini.BadValue(err.Error())
+			}
+			f.MemoPattern = re
+		}
+	}
+	return nil
+}
+
+func (snp *stellarNetParser) doTx(ii ini.IniItem) error {
+	d := &snp.TxDefaults
+	if ii.Value == nil {
+		switch ii.Key {
+		case "fee-percentile":
+			d.FeePercentile = 0
+		case "timeout":
+			d.Timeout = 0
+		case "require-memo":
+			d.RequireMemo = false
+		case "base-reserve":
+			d.BaseReserve = 0
+		}
+		delete(snp.setTxKeys, ii.Key)
+		return nil
+	} else if snp.setTxKeys[ii.Key] {
+		return nil
+	}
+	switch ii.Key {
+	case "fee-percentile":
+		n, err := strconv.Atoi(ii.Val())
+		if err != nil || n < 1 || n > 99 {
+			return ini.BadValue("fee-percentile must be from 1 to 99")
+		}
+		d.FeePercentile = n
+	case "timeout":
+		t, err := time.ParseDuration(ii.Val())
+		if n, err2 := strconv.ParseUint(ii.Val(), 10, 32); err2 == nil {
+			t, err = time.Duration(n)*time.Second, nil
+		}
+		if err != nil || t < 0 {
+			return ini.BadValue("timeout must be a duration such as 5m")
+		}
+		d.Timeout = t
+	case "require-memo":
+		b, err := strconv.ParseBool(ii.Val())
+		if err != nil {
+			return ini.BadValue("require-memo must be true or false")
+		}
+		d.RequireMemo = b
+	case "base-reserve":
+		var amount stcdetail.JsonInt64e7
+		if err := amount.UnmarshalText([]byte(ii.Val()));
+		err != nil || amount < 0 {
+			return ini.BadValue("base-reserve must be a non-negative " +
+				"number")
+		}
+		d.BaseReserve = int64(amount)
+	default:
+		return nil
+	}
+	if snp.setTxKeys == nil {
+		snp.setTxKeys = make(map[string]bool)
+	}
+	snp.setTxKeys[ii.Key] = true
+	return nil
+}
+
+func (snp *stellarNetParser) doPrices(ii ini.IniItem) error {
+	if ii.Key == "currency" {
+		if ii.Value == nil {
+			snp.PriceCurrency = ""
+		} else if snp.PriceCurrency == "" {
+			snp.PriceCurrency = ii.Val()
+		}
+		return nil
+	}
+	// Other keys are arbitrary names, so an earlier undefinition
+	// just causes later definitions of the same name to be ignored.
+	if snp.priceKeys[ii.Key] {
+		return nil
+	} else if snp.priceKeys == nil {
+		snp.priceKeys = make(map[string]bool)
+	}
+	snp.priceKeys[ii.Key] = true
+	if ii.Value == nil {
+		return nil
+	}
+	fields := strings.Fields(ii.Val())
+	if len(fields) < 2 || len(fields) > 3 {
+		return ini.BadValue("expected ASSET URL [PATH]")
+	}
+	asset, err := ParseAsset(fields[0])
+	if err != nil {
+		return ini.BadValue(err.Error())
+	}
+	hps, _ := snp.Prices.(*HTTPPriceSource)
+	if hps == nil {
+		hps = &HTTPPriceSource{URLs: make(map[string]PriceURL)}
+		snp.Prices = hps
+	}
+	if _, ok := hps.URLs[asset.String()]; !ok {
+		pu := PriceURL{URL: fields[1]}
+		if len(fields) == 3 {
+			pu.Path = fields[2]
+		}
+		hps.URLs[asset.String()] = pu
+	}
+	return nil
+}
+
 func (snp *stellarNetParser) doSigners(ii ini.IniItem) error {
 	var signer SignerKey
 	if _, err := fmt.Sscan(ii.Key, &signer); err != nil {
 		return ini.BadKey(err.Error())
 	}
+	snp.signersLock.Lock()
+	defer snp.signersLock.Unlock()
 	if ii.Value == nil {
 		snp.Signers.Del(ii.Key)
+		if snp.deletedSigners == nil {
+			snp.deletedSigners = make(map[string]bool)
+		}
+		snp.deletedSigners[ii.Key] = true
 	} else {
 		snp.Signers.Add(ii.Key, *ii.Value)
+		delete(snp.deletedSigners, ii.Key)
 	}
 	return nil
 }
@@ -215,6 +542,14 @@ func (snp *stellarNetParser) Section(iss ini.IniSecStart) error {
 			snp.itemCB = snp.doAccounts
 		case "signers":
 			snp.itemCB = snp.doSigners
+		case "approvers":
+			snp.itemCB = snp.doApprovers
+		case "filter":
+			snp.itemCB = snp.doFilter
+		case "prices":
+			snp.itemCB = snp.doPrices
+		case "tx":
+			snp.itemCB = snp.doTx
 		}
 	}
 	return nil
@@ -247,16 +582,37 @@ func (net *StellarNet) IniSink() ini.IniSink {
 	if net.Accounts == nil {
 		net.Accounts = make(AccountHints)
 	}
+	if net.Approvers == nil {
+		net.Approvers = make(map[string]string)
+	}
 	return &stellarNetParser{
 		StellarNet: net,
 		setName: true,
 	}
 }
 
+// Feeds environment overrides to a stellarNetParser without calling
+// its Done method.
+type envSink struct {
+	snp *stellarNetParser
+}
+
+func (es envSink) Section(iss ini.IniSecStart) error {
+	return es.snp.Section(iss)
+}
+
+func (es envSink) Item(ii ini.IniItem) error {
+	es.snp.fromEnv = true
+	defer func() { es.snp.fromEnv = false }()
+	return es.snp.Item(ii)
+}
+
 // Load a Stellar network from an INI files.  If path[0] does not
 // exist but name is valid, the path will be created and net.name will
-// be set to name.  Otherwise the name argument is ignored.  After all
-// files in paths are parsed, the global stc.conf file will be parsed.
+// be set to name.  Otherwise the name argument is ignored.  Overrides
+// from the environment (see EnvOverridePrefix) take precedence over
+// all files.  After all files in paths are parsed, the global
+// stc.conf file will be parsed.
 // After that, there must be a valid NetworkId or the function will
 // return nil.
 func LoadStellarNet(name string, paths...string) (*StellarNet, error) {
@@ -266,24 +622,254 @@ func LoadStellarNet(name string, paths...string) (*StellarNet, error) {
 	}
 	if err := ParseConfigFiles(ret.IniSink(), paths...); err != nil {
 		return nil, err
-	} else if err = ret.Validate(); err != nil {
+	}
+	if env := envOverrides(ret.Name); env != nil {
+		// The network name may come from the configuration files, so
+		// now that we know it, start over with the environment first.
+		// Keep the name, so that [net "name"] sections still apply,
+		// and don't let the environment count as the end of a file,
+		// which would stop the files from setting the name.
+		ret = StellarNet{ Name: ret.Name, SavePath: ret.SavePath }
+		sink := ret.IniSink()
+		if err := ini.IniParseContents(envSink{sink.(*stellarNetParser)},
+			"(environment)", env); err != nil {
+			return nil, err
+		} else if err = ParseConfigFiles(sink, paths...); err != nil {
+			return nil, err
+		}
+	}
+	if err := ret.Validate(); err != nil {
 		return nil, err
 	}
 	ret.Save()
 	return &ret, nil
 }
 
-var netCache map[string]*StellarNet
+// Create or update a network by querying the root endpoint of the
+// horizon server at URL horizon, which supplies the network-id (and
+// friendbot URL if the server advertises one).  The resulting
+// settings are saved to paths[0] and take precedence over anything
+// already configured for the network.  Returns the network along with
+// the horizon root information, which describes the server's
+// capabilities.
+func DetectStellarNet(name, horizon string, paths...string) (
+	*StellarNet, *HorizonRoot, error) {
+	if !strings.HasSuffix(horizon, "/") {
+		horizon += "/"
+	}
+	root, err := GetHorizonRoot(horizon)
+	if err != nil {
+		return nil, nil, err
+	} else if root.Network_passphrase == "" {
+		return nil, nil, horizonFailure("horizon did not report network-id")
+	}
+	ret := StellarNet{
+		Name: name,
+		Horizon: horizon,
+		NetworkId: root.Network_passphrase,
+		Friendbot: root.Friendbot,
+	}
+	if len(paths) > 0 {
+		ret.SavePath = paths[0]
+	}
+	if err = ParseConfigFiles(ret.IniSink(), paths...); err != nil {
+		return nil, nil, err
+	} else if err = ret.Validate(); err != nil {
+		return nil, nil, err
+	}
+	ret.Edits.Set("net", "horizon", ret.Horizon)
+	ret.Edits.Set("net", "network-id", ret.NetworkId)
+	if ret.Friendbot != "" {
+		ret.Edits.Set("net", "friendbot", ret.Friendbot)
+	}
+	if err = ret.Save(); err != nil {
+		return nil, nil, err
+	}
+	return &ret, root, nil
+}
+
+// Adds signers from a binary signer cache file written by
+// SaveSignersBinary.  It is not an error for the file not to exist.
+// Signers deleted in the configuration files (with a key and no
+// value in the [signers] section) are not added, even if the file
+// contains them.
+func (net *StellarNet) LoadSignersBinary(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	net.signersLock.Lock()
+	defer net.signersLock.Unlock()
+	if net.Signers == nil {
+		net.Signers = make(SignerCache)
+	}
+	if err = net.Signers.ReadBinary(f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	net.dropDeletedSigners()
+	return nil
+}
+
+// Removes from net.Signers the signers deleted in the configuration
+// files.  Must be called with net.signersLock held.
+func (net *StellarNet) dropDeletedSigners() {
+	for key := range net.deletedSigners {
+		net.Signers.Del(key)
+	}
+}
+
+// Writes all signers known to the network to path in the compact
+// binary format of SignerCache.WriteBinary.  Saving learned signers
+// to ConfigPath(net.Name + ".signers") allows DefaultStellarNet to
+// load tens of thousands of signers much faster than from the
+// [signers] section of an INI file.
+//
+// So that concurrent processes do not lose each other's signers, the
+// file is locked and re-read, and signers other processes added to it
+// since it was loaded are merged into net.Signers before the file is
+// atomically replaced.  Hence, signers deleted from net.Signers are
+// not removed from the file if it still contains them, unless they
+// were deleted in the configuration files (see LoadSignersBinary).
+// (Signers in the [signers] section of an INI file are saved with
+// Save, which likewise edits the current file in place.)
+func (net *StellarNet) SaveSignersBinary(path string) error {
+	lf, err := stcdetail.LockFile(path, 0666)
+	if err != nil {
+		return err
+	}
+	defer lf.Abort()
+	contents, err := lf.ReadFile()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	net.signersLock.Lock()
+	defer net.signersLock.Unlock()
+	if net.Signers == nil {
+		net.Signers = make(SignerCache)
+	}
+	if len(contents) > 0 {
+		err = net.Signers.ReadBinary(bytes.NewReader(contents))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		net.dropDeletedSigners()
+	}
+	if err = net.Signers.WriteBinary(lf); err != nil {
+		return err
+	}
+	return lf.Commit()
+}
+
+// A registry of Stellar networks configured under ConfigPath().
+// Each network is parsed lazily the first time it is requested, and
+// the parsed result is reused until one of the underlying
+// configuration files changes on disk.  A Networks is safe for
+// concurrent use by multiple goroutines.
+type Networks struct {
+	lock sync.Mutex
+	nets map[string]*networksEntry
+}
+
+type networksEntry struct {
+	net   *StellarNet
+	paths []string
+	fis   []os.FileInfo // nil for files that did not exist
+}
+
+func statPaths(paths []string) []os.FileInfo {
+	ret := make([]os.FileInfo, len(paths))
+	for i := range paths {
+		ret[i], _ = os.Stat(paths[i])
+	}
+	return ret
+}
+
+func (e *networksEntry) stale() bool {
+	for i, fi := range statPaths(e.paths) {
+		if (fi == nil) != (e.fis[i] == nil) ||
+			(fi != nil && stcdetail.FileChanged(fi, e.fis[i])) {
+			return true
+		}
+	}
+	return false
+}
+
+var defaultNetworks Networks
+
+// Return the process-wide registry of networks, which is the one used
+// by DefaultStellarNet.
+func LoadNetworks() *Networks {
+	return &defaultNetworks
+}
+
+// Return the network called name, loading it from
+// ConfigPath(name+".net") and ConfigPath("global.conf"), plus any
+// binary signer cache in ConfigPath(name+".signers"), if it has not
+// been loaded yet or if any of these files has changed since it was
+// loaded.
+// Note that the returned StellarNet is shared with other callers of
+// Get until the configuration changes.  A reload returns a new
+// StellarNet; earlier callers keep the old one, with its old
+// configuration and caches, so long-running programs should call Get
+// again to observe changes.
+func (ns *Networks) Get(name string) (*StellarNet, error) {
+	if !ValidNetName(name) {
+		return nil, ErrInvalidNetName
+	}
+	if err := MigrateConfigDir(); err != nil {
+		return nil, err
+	}
+	ns.lock.Lock()
+	defer ns.lock.Unlock()
+	if e, ok := ns.nets[name]; ok && !e.stale() {
+		return e.net, nil
+	}
+	paths := []string{ConfigPath(name + ".net"), ConfigPath("global.conf"),
+		ConfigPath(name + ".signers")}
+	// Stat before parsing, so a change during parsing forces a re-parse
+	fis := statPaths(paths)
+	net, err := LoadStellarNet(name, paths[:2]...)
+	if err != nil {
+		return nil, err
+	} else if err = net.LoadSignersBinary(paths[2]); err != nil {
+		return nil, err
+	}
+	if ns.nets == nil {
+		ns.nets = make(map[string]*networksEntry)
+	}
+	ns.nets[name] = &networksEntry{net: net, paths: paths, fis: fis}
+	return net, nil
+}
+
+// Discard any cached copy of network name, or of all networks if
+// name is "".
+func (ns *Networks) Invalidate(name string) {
+	ns.lock.Lock()
+	defer ns.lock.Unlock()
+	if name == "" {
+		ns.nets = nil
+	} else {
+		delete(ns.nets, name)
+	}
+}
 
 // Load a network from under the ConfigPath() ($STCDIR) directory.  If
 // name is "", then it will look at the $STCNET environment variable
 // and if that is unset load a default network.  Returns nil if the
 // network name does not exist.  After loading the netname.net file,
-// also parses $STCDIR/global.conf.
+// also parses $STCDIR/global.conf and loads signers from
+// $STCDIR/netname.signers if it exists.  Networks are cached in the
+// registry returned by LoadNetworks(), so repeated calls only re-read
+// the configuration when it has changed, in which case they return a
+// new StellarNet and leave any previously returned one unchanged.
 //
-// Two pre-defined names are "main" and "test", with "main" being the
-// default.  Other networks can be created under ConfigPath(), or can
-// be pre-specified (and created on demand) in stc.conf.
+// Pre-defined names are "main", "test", and "standalone" (for a local
+// quickstart instance), with "main" being the default.  Other
+// networks can be created under ConfigPath(), or can be pre-specified
+// (and created on demand) in stc.conf.
 func DefaultStellarNet(name string) *StellarNet {
 	if !ValidNetName(name) {
 		name = os.Getenv("STCNET")
@@ -291,17 +877,9 @@ func DefaultStellarNet(name string) *StellarNet {
 			name = "default"
 		}
 	}
-	if netCache == nil {
-		netCache = make(map[string]*StellarNet)
-	} else if net, ok := netCache[name]; ok {
-		return net
-	}
-	ret, err := LoadStellarNet(name, ConfigPath(name + ".net"),
-		ConfigPath("global.conf"))
+	ret, err := LoadNetworks().Get(name)
 	if ret == nil {
 		fmt.Fprintln(os.Stderr, err)
-	} else {
-		netCache[name] = ret
 	}
 	return ret
 }
@@ -309,6 +887,8 @@ func DefaultStellarNet(name string) *StellarNet {
 // Save any changes to SavePath.  If SavePath does not exist, then
 // create it with permissions Perm (subject to umask, of course).
 func (net *StellarNet) SavePerm(perm os.FileMode) error {
+	net.editsLock.Lock()
+	defer net.editsLock.Unlock()
 	if len(net.Edits) == 0 {
 		return nil
 	}
@@ -0,0 +1,64 @@
+package stc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"github.com/xdrpp/stc/stx"
+	"sort"
+)
+
+// Returns the account's data entries (which horizon reports in
+// base64), decoded and keyed by name.
+func (ae *HorizonAccountEntry) DataEntries() (map[string][]byte, error) {
+	ret := make(map[string][]byte, len(ae.Data))
+	for name, val := range ae.Data {
+		bval, err := base64.StdEncoding.DecodeString(val)
+		if err != nil {
+			return nil, fmt.Errorf("data entry %q: %w", name, err)
+		}
+		ret[name] = bval
+	}
+	return ret, nil
+}
+
+// Fetches the decoded data entries of account acct.  To edit them,
+// modify a copy of the result, and append DataOps(original, copy) to
+// a transaction whose source is acct.
+func (net *StellarNet) GetAccountData(acct string) (map[string][]byte,
+	error) {
+	ae, err := net.GetAccountEntry(acct)
+	if err != nil {
+		return nil, err
+	}
+	return ae.DataEntries()
+}
+
+// Returns the ManageData operations, sorted by name, that change an
+// account's data entries from old to new: one setting each entry that
+// is added or changed in new, and one deleting each entry of old that
+// is missing from new.  Names and values longer than 64 bytes are not
+// valid on the network and cause the resulting transaction to fail.
+func DataOps(old, new map[string][]byte) []ManageData {
+	names := make([]string, 0, len(old)+len(new))
+	for name := range old {
+		if _, ok := new[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	for name, val := range new {
+		if oval, ok := old[name]; !ok || !bytes.Equal(oval, val) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	ret := make([]ManageData, len(names))
+	for i, name := range names {
+		ret[i].DataName = name
+		if val, ok := new[name]; ok {
+			dv := stx.DataValue(val)
+			ret[i].DataValue = &dv
+		}
+	}
+	return ret
+}
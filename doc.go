@@ -0,0 +1,43 @@
+// Package stc is a library for creating, editing, signing, and
+// submitting Stellar transactions, and for querying the Stellar
+// network through horizon.  It is also the engine behind the stc
+// command-line tool.
+//
+// A typical program loads a network configuration, builds a
+// transaction, signs it, and posts it:
+//
+//	net := stc.DefaultStellarNet("test")
+//	ae, err := net.GetAccountEntry(myAccount.String())
+//	...
+//	e := stc.NewTransactionEnvelope()
+//	e.SetSourceAccount(myAccount)
+//	e.V1().Tx.SeqNum = ae.NextSeq()
+//	e.Append(nil, stc.Payment{
+//		Destination: *dest.ToMuxedAccount(),
+//		Asset:       stc.NativeAsset(),
+//		Amount:      10 * 10000000,
+//	})
+//	e.SetFee(100)
+//	if err := net.SignTx(key, e); err != nil {
+//		...
+//	}
+//	result, err := net.Post(e)
+//
+// # Compatibility
+//
+// stc follows semantic versioning.  Within a major version, the
+// exported API of this package, of package stx (the XDR types of the
+// Stellar protocol), and of package horizon will not change in ways
+// that break programs that compile against it, except to track
+// incompatible changes to the Stellar protocol itself (which alter
+// the generated types in stx).  New functions, methods, types, struct
+// fields, and constants may be added in minor versions, so do not
+// rely on unkeyed struct literals of stc types or on the exact text of
+// error messages or human-readable output.  The file api_test.go
+// records the signatures covered by this promise.
+//
+// Package stcdetail is exported because stc's types use some of its
+// types (such as JsonInt64e7) and because its helpers are useful, but
+// it is not covered by the promise and may change in any release.
+// Code under internal/ is not importable outside stc.
+package stc
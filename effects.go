@@ -0,0 +1,217 @@
+package stc
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/xdrpp/stc/stcdetail"
+	"github.com/xdrpp/stc/stx"
+	"reflect"
+	"time"
+)
+
+// An effect (a change to the ledger caused by an operation) as
+// returned by horizon's effects endpoints.  The fields common to all
+// effects are decoded directly, while the type-specific fields are
+// decoded into Details.
+type HorizonEffect struct {
+	Net          *StellarNet `json:"-"`
+	Id           string
+	Paging_token string
+	Account      AccountID
+	Type         string
+	Type_i       int32
+	Created_at   time.Time
+
+	// A pointer to the Horizon*Effect structure corresponding to
+	// Type (e.g., *HorizonAmountEffect for "account_credited"), or a
+	// json.RawMessage containing the whole effect for types stc does
+	// not know.
+	Details interface{} `json:"-"`
+}
+
+var horizonEffectTypes = map[string]reflect.Type{
+	"account_created":             reflect.TypeOf(HorizonAccountCreatedEffect{}),
+	"account_credited":            reflect.TypeOf(HorizonAmountEffect{}),
+	"account_debited":             reflect.TypeOf(HorizonAmountEffect{}),
+	"account_thresholds_updated":  reflect.TypeOf(HorizonThresholdsEffect{}),
+	"account_home_domain_updated": reflect.TypeOf(HorizonHomeDomainEffect{}),
+	"account_flags_updated":       reflect.TypeOf(HorizonFlagsEffect{}),
+	"signer_created":              reflect.TypeOf(HorizonSignerEffect{}),
+	"signer_removed":              reflect.TypeOf(HorizonSignerEffect{}),
+	"signer_updated":              reflect.TypeOf(HorizonSignerEffect{}),
+	"trustline_created":           reflect.TypeOf(HorizonTrustlineEffect{}),
+	"trustline_removed":           reflect.TypeOf(HorizonTrustlineEffect{}),
+	"trustline_updated":           reflect.TypeOf(HorizonTrustlineEffect{}),
+	"trade":                       reflect.TypeOf(HorizonTradeEffect{}),
+	"data_created":                reflect.TypeOf(HorizonDataEffect{}),
+	"data_removed":                reflect.TypeOf(HorizonDataEffect{}),
+	"data_updated":                reflect.TypeOf(HorizonDataEffect{}),
+	"sequence_bumped":             reflect.TypeOf(HorizonSequenceBumpedEffect{}),
+	"claimable_balance_created":   reflect.TypeOf(HorizonClaimableBalanceEffect{}),
+	"claimable_balance_claimed":   reflect.TypeOf(HorizonClaimableBalanceEffect{}),
+}
+
+func (e *HorizonEffect) UnmarshalJSON(data []byte) error {
+	type jeff HorizonEffect
+	if err := json.Unmarshal(data, (*jeff)(e)); err != nil {
+		return err
+	}
+	if t, ok := horizonEffectTypes[e.Type]; ok {
+		e.Details = reflect.New(t).Interface()
+		return json.Unmarshal(data, e.Details)
+	}
+	e.Details = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func (e *HorizonEffect) String() string {
+	return stcdetail.PrettyPrintAux(e.Net.prettyPrintAux, e)
+}
+
+type HorizonAccountCreatedEffect struct {
+	Starting_balance stcdetail.JsonInt64e7
+}
+
+// Effects "account_credited" and "account_debited".
+type HorizonAmountEffect struct {
+	Amount stcdetail.JsonInt64e7
+	Asset  stx.Asset `json:"-"`
+}
+
+func (e *HorizonAmountEffect) UnmarshalJSON(data []byte) error {
+	type jeff HorizonAmountEffect
+	if err := json.Unmarshal(data, (*jeff)(e)); err != nil {
+		return err
+	}
+	return unmarshalAssets(data, map[string]*stx.Asset{"": &e.Asset})
+}
+
+type HorizonThresholdsEffect struct {
+	Low_threshold  uint32
+	Med_threshold  uint32
+	High_threshold uint32
+}
+
+type HorizonHomeDomainEffect struct {
+	Home_domain string
+}
+
+// Pointer fields are nil when the flag did not change.
+type HorizonFlagsEffect struct {
+	Auth_required_flag         *bool
+	Auth_revokable_flag        *bool
+	Auth_immutable_flag        *bool
+	Auth_clawback_enabled_flag *bool
+}
+
+// Effects "signer_created", "signer_removed", and "signer_updated".
+// Despite its name, horizon uses Public_key for the signer's key (in
+// strkey format) regardless of the key's type.
+type HorizonSignerEffect struct {
+	Public_key string
+	Weight     uint32
+}
+
+// Effects "trustline_created", "trustline_removed", and
+// "trustline_updated".  Asset is unset for liquidity pool shares, in
+// which case Liquidity_pool_id is set.
+type HorizonTrustlineEffect struct {
+	Limit             stcdetail.JsonInt64e7
+	Liquidity_pool_id string
+	Asset             stx.Asset `json:"-"`
+}
+
+func (e *HorizonTrustlineEffect) UnmarshalJSON(data []byte) error {
+	type jeff HorizonTrustlineEffect
+	if err := json.Unmarshal(data, (*jeff)(e)); err != nil {
+		return err
+	}
+	return unmarshalAssets(data, map[string]*stx.Asset{"": &e.Asset})
+}
+
+// A trade, from the point of view of the effect's account.
+type HorizonTradeEffect struct {
+	Seller        AccountID
+	Offer_id      stcdetail.JsonInt64
+	Sold_amount   stcdetail.JsonInt64e7
+	Bought_amount stcdetail.JsonInt64e7
+	Sold          stx.Asset `json:"-"`
+	Bought        stx.Asset `json:"-"`
+}
+
+func (e *HorizonTradeEffect) UnmarshalJSON(data []byte) error {
+	type jeff HorizonTradeEffect
+	if err := json.Unmarshal(data, (*jeff)(e)); err != nil {
+		return err
+	}
+	return unmarshalAssets(data, map[string]*stx.Asset{
+		"sold_":   &e.Sold,
+		"bought_": &e.Bought,
+	})
+}
+
+// Effects "data_created", "data_removed", and "data_updated".  Value
+// is base64-encoded.
+type HorizonDataEffect struct {
+	Name  string
+	Value string
+}
+
+type HorizonSequenceBumpedEffect struct {
+	New_seq stcdetail.JsonInt64
+}
+
+// Effects "claimable_balance_created" and "claimable_balance_claimed".
+type HorizonClaimableBalanceEffect struct {
+	Balance_id string
+	Amount     stcdetail.JsonInt64e7
+	Asset      stx.Asset `json:"-"`
+}
+
+func (e *HorizonClaimableBalanceEffect) UnmarshalJSON(data []byte) error {
+	type jeff HorizonClaimableBalanceEffect
+	var asset struct {
+		Asset string
+	}
+	if err := json.Unmarshal(data, (*jeff)(e)); err != nil {
+		return err
+	} else if err = json.Unmarshal(data, &asset); err != nil {
+		return err
+	}
+	// Horizon renders this asset as "native" or "CODE:ISSUER".
+	_, err := fmt.Sscan(asset.Asset, &e.Asset)
+	return err
+}
+
+// Kinds of horizon resources whose effects GetEffects can fetch.
+type EffectsOf string
+
+const (
+	AccountEffects   EffectsOf = "accounts"
+	TxEffects        EffectsOf = "transactions"
+	OperationEffects EffectsOf = "operations"
+	LedgerEffects    EffectsOf = "ledgers"
+)
+
+// Fetches a page of the effects of the account, transaction (by hex
+// hash), operation (by ID), or ledger (by sequence number) id, or of
+// all effects if id is "".  The first element of opts, if any,
+// selects the page; use NewPager with the same query to walk through
+// more effects.  For example, to see what a transaction did:
+//
+//	effects, err := net.GetEffects(TxEffects, txhash)
+func (net *StellarNet) GetEffects(of EffectsOf, id string,
+	opts ...PageParams) ([]HorizonEffect, error) {
+	query := "effects"
+	if id != "" {
+		query = string(of) + "/" + id + "/effects"
+	}
+	var params PageParams
+	if len(opts) > 0 {
+		params = opts[0]
+	}
+	var ret []HorizonEffect
+	pager := net.NewPager(nil, query, params)
+	pager.Next(&ret)
+	return ret, pager.Err()
+}
@@ -0,0 +1,532 @@
+package stc
+
+import (
+	"fmt"
+	"github.com/xdrpp/stc/stcdetail"
+	"github.com/xdrpp/stc/stx"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// An OpDescriber renders one operation as a short English phrase such
+// as "pay 50 XLM from A to B".  src is the operation's effective
+// source account (i.e., the transaction's source account if the
+// operation does not have its own).  Phrases should start with a
+// lower-case verb, since ExplainTx strings several together into one
+// sentence.  Describers should use net.DescribeAccount and
+// net.DescribeAmount so that output is consistent across operations.
+type OpDescriber func(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string
+
+var opDescribers = struct {
+	lock sync.RWMutex
+	m    map[stx.OperationType]OpDescriber
+}{m: map[stx.OperationType]OpDescriber{
+	stx.CREATE_ACCOUNT:                   describeCreateAccount,
+	stx.PAYMENT:                          describePayment,
+	stx.PATH_PAYMENT_STRICT_RECEIVE:      describePathPaymentStrictReceive,
+	stx.PATH_PAYMENT_STRICT_SEND:         describePathPaymentStrictSend,
+	stx.MANAGE_SELL_OFFER:                describeManageSellOffer,
+	stx.CREATE_PASSIVE_SELL_OFFER:        describeCreatePassiveSellOffer,
+	stx.MANAGE_BUY_OFFER:                 describeManageBuyOffer,
+	stx.SET_OPTIONS:                      describeSetOptions,
+	stx.CHANGE_TRUST:                     describeChangeTrust,
+	stx.ALLOW_TRUST:                      describeAllowTrust,
+	stx.ACCOUNT_MERGE:                    describeAccountMerge,
+	stx.MANAGE_DATA:                      describeManageData,
+	stx.BUMP_SEQUENCE:                    describeBumpSequence,
+	stx.CREATE_CLAIMABLE_BALANCE:         describeCreateClaimableBalance,
+	stx.BEGIN_SPONSORING_FUTURE_RESERVES: describeBeginSponsoring,
+	stx.END_SPONSORING_FUTURE_RESERVES:   describeEndSponsoring,
+	stx.REVOKE_SPONSORSHIP:               describeRevokeSponsorship,
+	stx.CLAWBACK:                         describeClawback,
+	stx.CLAWBACK_CLAIMABLE_BALANCE:       describeClawbackClaimableBalance,
+	stx.SET_TRUST_LINE_FLAGS:             describeSetTrustLineFlags,
+	stx.LIQUIDITY_POOL_DEPOSIT:           describeLiquidityPoolDeposit,
+	stx.LIQUIDITY_POOL_WITHDRAW:          describeLiquidityPoolWithdraw,
+}}
+
+// Register (or replace) the describer used by ExplainTx for
+// operations of type t.  Passing a nil describer reverts operations
+// of type t to a generic description.
+func RegisterOpDescriber(t stx.OperationType, d OpDescriber) {
+	opDescribers.lock.Lock()
+	defer opDescribers.lock.Unlock()
+	if d == nil {
+		delete(opDescribers.m, t)
+	} else {
+		opDescribers.m[t] = d
+	}
+}
+
+func lookupOpDescriber(t stx.OperationType) OpDescriber {
+	opDescribers.lock.RLock()
+	defer opDescribers.lock.RUnlock()
+	return opDescribers.m[t]
+}
+
+// Renders an account for use in an explanation, appending its
+// comment from the accounts section of the configuration, if any.
+func (net *StellarNet) DescribeAccount(acct fmt.Stringer) string {
+	s := acct.String()
+	if note := net.AccountIDNote(s); note != "" {
+		return fmt.Sprintf("%s (%s)", s, note)
+	}
+	return s
+}
+
+// Renders an asset for use in an explanation.  The native asset is
+// shown using the network's native-asset name.
+func (net *StellarNet) DescribeAsset(asset stx.Asset) string {
+	switch asset.Type {
+	case stx.ASSET_TYPE_NATIVE:
+		if net.NativeAsset != "" {
+			return net.NativeAsset
+		}
+		return "native"
+	case stx.ASSET_TYPE_CREDIT_ALPHANUM4:
+		return fmt.Sprintf("%s issued by %s",
+			stx.RenderAssetCode(asset.AlphaNum4().AssetCode[:]),
+			net.DescribeAccount(asset.AlphaNum4().Issuer))
+	case stx.ASSET_TYPE_CREDIT_ALPHANUM12:
+		return fmt.Sprintf("%s issued by %s",
+			stx.RenderAssetCode(asset.AlphaNum12().AssetCode[:]),
+			net.DescribeAccount(asset.AlphaNum12().Issuer))
+	}
+	return asset.String()
+}
+
+// Renders an amount of asset (in units of 10^-7) for use in an
+// explanation, e.g., "50 XLM", followed by its estimated value (e.g.,
+// "50 XLM (approx. 5.00 USD)") if net has a price for asset.
+func (net *StellarNet) DescribeAmount(amount int64, asset stx.Asset) string {
+	ret := FormatAmount(amount) + " " + net.DescribeAsset(asset)
+	if est := net.EstimateValue(amount, asset); est != "" {
+		ret += " (" + est + ")"
+	}
+	return ret
+}
+
+func describePrice(p stx.Price) string {
+	if p.D == 0 {
+		return fmt.Sprintf("%d/0", p.N)
+	} else if p.D == 1 {
+		return fmt.Sprint(p.N)
+	}
+	return fmt.Sprintf("%d/%d (%g)", p.N, p.D, float64(p.N)/float64(p.D))
+}
+
+// Returns the names of the bits set in flags, given the names of an
+// enum whose values are single bits.
+func describeFlags(flags uint32, names map[int32]string) string {
+	var ret []string
+	for bit := uint32(1); bit != 0; bit <<= 1 {
+		if flags&bit == 0 {
+			continue
+		} else if name, ok := names[int32(bit)]; ok {
+			ret = append(ret, name)
+		} else {
+			ret = append(ret, fmt.Sprintf("%#x", bit))
+		}
+	}
+	return strings.Join(ret, " and ")
+}
+
+func describeCreateAccount(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	op := body.CreateAccountOp()
+	return fmt.Sprintf("create account %s funded with %s from %s",
+		net.DescribeAccount(op.Destination),
+		net.DescribeAmount(op.StartingBalance, NativeAsset()),
+		net.DescribeAccount(src))
+}
+
+func describePayment(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	op := body.PaymentOp()
+	return fmt.Sprintf("pay %s from %s to %s",
+		net.DescribeAmount(op.Amount, op.Asset), net.DescribeAccount(src),
+		net.DescribeAccount(op.Destination))
+}
+
+func describePathPaymentStrictReceive(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	op := body.PathPaymentStrictReceiveOp()
+	return fmt.Sprintf("deliver %s to %s, paying at most %s from %s",
+		net.DescribeAmount(op.DestAmount, op.DestAsset),
+		net.DescribeAccount(op.Destination),
+		net.DescribeAmount(op.SendMax, op.SendAsset),
+		net.DescribeAccount(src))
+}
+
+func describePathPaymentStrictSend(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	op := body.PathPaymentStrictSendOp()
+	return fmt.Sprintf("send %s from %s, delivering at least %s to %s",
+		net.DescribeAmount(op.SendAmount, op.SendAsset),
+		net.DescribeAccount(src),
+		net.DescribeAmount(op.DestMin, op.DestAsset),
+		net.DescribeAccount(op.Destination))
+}
+
+func describeOffer(net *StellarNet, src *MuxedAccount, offerID int64,
+	what string) string {
+	if offerID != 0 {
+		return fmt.Sprintf("change offer %d of %s to %s", offerID,
+			net.DescribeAccount(src), what)
+	}
+	return fmt.Sprintf("offer %s from %s", what, net.DescribeAccount(src))
+}
+
+func describeManageSellOffer(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	op := body.ManageSellOfferOp()
+	if op.Amount == 0 {
+		return fmt.Sprintf("delete offer %d of %s", op.OfferID,
+			net.DescribeAccount(src))
+	}
+	return describeOffer(net, src, op.OfferID,
+		fmt.Sprintf("sell %s for %s at price %s",
+			net.DescribeAmount(op.Amount, op.Selling),
+			net.DescribeAsset(op.Buying), describePrice(op.Price)))
+}
+
+func describeCreatePassiveSellOffer(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	op := body.CreatePassiveSellOfferOp()
+	return describeOffer(net, src, 0,
+		fmt.Sprintf("passively sell %s for %s at price %s",
+			net.DescribeAmount(op.Amount, op.Selling),
+			net.DescribeAsset(op.Buying), describePrice(op.Price)))
+}
+
+func describeManageBuyOffer(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	op := body.ManageBuyOfferOp()
+	if op.BuyAmount == 0 {
+		return fmt.Sprintf("delete offer %d of %s", op.OfferID,
+			net.DescribeAccount(src))
+	}
+	return describeOffer(net, src, op.OfferID,
+		fmt.Sprintf("buy %s with %s at price %s",
+			net.DescribeAmount(op.BuyAmount, op.Buying),
+			net.DescribeAsset(op.Selling), describePrice(op.Price)))
+}
+
+func describeSetOptions(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	op := body.SetOptionsOp()
+	acct := net.DescribeAccount(src)
+	var af stx.AccountFlags
+	var ret []string
+	if op.InflationDest != nil {
+		ret = append(ret, fmt.Sprintf("set inflation destination of %s "+
+			"to %s", acct, net.DescribeAccount(*op.InflationDest)))
+	}
+	if op.ClearFlags != nil && *op.ClearFlags != 0 {
+		ret = append(ret, fmt.Sprintf("clear %s on %s",
+			describeFlags(*op.ClearFlags, af.XdrEnumNames()), acct))
+	}
+	if op.SetFlags != nil && *op.SetFlags != 0 {
+		ret = append(ret, fmt.Sprintf("set %s on %s",
+			describeFlags(*op.SetFlags, af.XdrEnumNames()), acct))
+	}
+	if op.MasterWeight != nil {
+		ret = append(ret, fmt.Sprintf("set master key weight of %s to %d",
+			acct, *op.MasterWeight))
+	}
+	for _, t := range []struct {
+		name string
+		val  *uint32
+	}{
+		{"low", op.LowThreshold},
+		{"medium", op.MedThreshold},
+		{"high", op.HighThreshold},
+	} {
+		if t.val != nil {
+			ret = append(ret, fmt.Sprintf("set %s threshold of %s to %d",
+				t.name, acct, *t.val))
+		}
+	}
+	if op.HomeDomain != nil {
+		ret = append(ret, fmt.Sprintf("set home domain of %s to %q",
+			acct, *op.HomeDomain))
+	}
+	if op.Signer != nil {
+		signer := op.Signer.Key.String()
+		if note := net.SignerNote(&op.Signer.Key); note != "" {
+			signer = fmt.Sprintf("%s (%s)", signer, note)
+		}
+		if op.Signer.Weight == 0 {
+			ret = append(ret, fmt.Sprintf("remove signer %s from %s",
+				signer, acct))
+		} else {
+			ret = append(ret, fmt.Sprintf("add signer %s with weight %d "+
+				"to %s", signer, op.Signer.Weight, acct))
+		}
+	}
+	if len(ret) == 0 {
+		return fmt.Sprintf("set no options on %s", acct)
+	}
+	return strings.Join(ret, ", ")
+}
+
+func describeChangeTrust(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	op := body.ChangeTrustOp()
+	var asset string
+	switch op.Line.Type {
+	case stx.ASSET_TYPE_CREDIT_ALPHANUM4:
+		a := stx.Asset{Type: op.Line.Type}
+		*a.AlphaNum4() = *op.Line.AlphaNum4()
+		asset = net.DescribeAsset(a)
+	case stx.ASSET_TYPE_CREDIT_ALPHANUM12:
+		a := stx.Asset{Type: op.Line.Type}
+		*a.AlphaNum12() = *op.Line.AlphaNum12()
+		asset = net.DescribeAsset(a)
+	case stx.ASSET_TYPE_POOL_SHARE:
+		cp := op.Line.LiquidityPool().ConstantProduct()
+		asset = fmt.Sprintf("shares of the pool for %s and %s",
+			net.DescribeAsset(cp.AssetA), net.DescribeAsset(cp.AssetB))
+	default:
+		asset = fmt.Sprintf("asset type %s", op.Line.Type)
+	}
+	acct := net.DescribeAccount(src)
+	switch op.Limit {
+	case 0:
+		return fmt.Sprintf("remove trustline of %s for %s", acct, asset)
+	case MaxInt64:
+		return fmt.Sprintf("trust %s from %s without limit", asset, acct)
+	}
+	return fmt.Sprintf("trust up to %s of %s from %s",
+		FormatAmount(op.Limit), asset, acct)
+}
+
+func describeAllowTrust(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	op := body.AllowTrustOp()
+	what := "revoke authorization of"
+	switch op.Authorize {
+	case uint32(stx.AUTHORIZED_FLAG):
+		what = "authorize"
+	case uint32(stx.AUTHORIZED_TO_MAINTAIN_LIABILITIES_FLAG):
+		what = "authorize only to maintain liabilities"
+	}
+	return fmt.Sprintf("%s %s to hold %s issued by %s", what,
+		net.DescribeAccount(op.Trustor), op.Asset, net.DescribeAccount(src))
+}
+
+func describeAccountMerge(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	return fmt.Sprintf("merge account %s into %s (deleting %[1]s)",
+		net.DescribeAccount(src), net.DescribeAccount(body.Destination()))
+}
+
+func describeManageData(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	op := body.ManageDataOp()
+	if op.DataValue == nil {
+		return fmt.Sprintf("delete data entry %q from %s", op.DataName,
+			net.DescribeAccount(src))
+	}
+	return fmt.Sprintf("set data entry %q of %s to %q", op.DataName,
+		net.DescribeAccount(src), *op.DataValue)
+}
+
+func describeBumpSequence(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	return fmt.Sprintf("bump sequence number of %s to %d",
+		net.DescribeAccount(src), body.BumpSequenceOp().BumpTo)
+}
+
+func describeCreateClaimableBalance(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	op := body.CreateClaimableBalanceOp()
+	var claimants []string
+	for i := range op.Claimants {
+		if op.Claimants[i].Type == stx.CLAIMANT_TYPE_V0 {
+			claimants = append(claimants, net.DescribeAccount(
+				op.Claimants[i].V0().Destination))
+		}
+	}
+	sort.Strings(claimants)
+	return fmt.Sprintf("set aside %s from %s, claimable by %s",
+		net.DescribeAmount(op.Amount, op.Asset), net.DescribeAccount(src),
+		strings.Join(claimants, " or "))
+}
+
+func describeBeginSponsoring(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	return fmt.Sprintf("have %s pay reserves for %s until it ends "+
+		"sponsorship", net.DescribeAccount(src), net.DescribeAccount(
+		body.BeginSponsoringFutureReservesOp().SponsoredID))
+}
+
+func describeEndSponsoring(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	return fmt.Sprintf("end sponsorship of reserves for %s",
+		net.DescribeAccount(src))
+}
+
+func describeRevokeSponsorship(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	op := body.RevokeSponsorshipOp()
+	var what string
+	switch op.Type {
+	case stx.REVOKE_SPONSORSHIP_SIGNER:
+		what = fmt.Sprintf("signer %s of %s", op.Signer().SignerKey,
+			net.DescribeAccount(op.Signer().AccountID))
+	case stx.REVOKE_SPONSORSHIP_LEDGER_ENTRY:
+		switch key := op.LedgerKey(); key.Type {
+		case stx.ACCOUNT:
+			what = "account " + net.DescribeAccount(key.Account().AccountID)
+		case stx.TRUSTLINE:
+			tl := key.TrustLine()
+			asset := fmt.Sprintf("asset type %s", tl.Asset.Type)
+			switch tl.Asset.Type {
+			case stx.ASSET_TYPE_CREDIT_ALPHANUM4:
+				a := stx.Asset{Type: tl.Asset.Type}
+				*a.AlphaNum4() = *tl.Asset.AlphaNum4()
+				asset = net.DescribeAsset(a)
+			case stx.ASSET_TYPE_CREDIT_ALPHANUM12:
+				a := stx.Asset{Type: tl.Asset.Type}
+				*a.AlphaNum12() = *tl.Asset.AlphaNum12()
+				asset = net.DescribeAsset(a)
+			}
+			what = fmt.Sprintf("the trustline of %s for %s",
+				net.DescribeAccount(tl.AccountID), asset)
+		case stx.OFFER:
+			what = fmt.Sprintf("offer %d of %s", key.Offer().OfferID,
+				net.DescribeAccount(key.Offer().SellerID))
+		case stx.DATA:
+			what = fmt.Sprintf("data entry %q of %s", key.Data().DataName,
+				net.DescribeAccount(key.Data().AccountID))
+		case stx.CLAIMABLE_BALANCE:
+			what = fmt.Sprintf("claimable balance %x",
+				stcdetail.XdrToBin(&key.ClaimableBalance().BalanceID))
+		default:
+			what = fmt.Sprintf("a %s entry", key.Type)
+		}
+	}
+	return fmt.Sprintf("revoke or transfer %s's sponsorship of %s",
+		net.DescribeAccount(src), what)
+}
+
+func describeClawback(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	op := body.ClawbackOp()
+	return fmt.Sprintf("claw back %s from %s",
+		net.DescribeAmount(op.Amount, op.Asset),
+		net.DescribeAccount(op.From))
+}
+
+func describeClawbackClaimableBalance(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	op := body.ClawbackClaimableBalanceOp()
+	return fmt.Sprintf("claw back claimable balance %x for %s",
+		stcdetail.XdrToBin(&op.BalanceID), net.DescribeAccount(src))
+}
+
+func describeSetTrustLineFlags(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	op := body.SetTrustLineFlagsOp()
+	var tf stx.TrustLineFlags
+	var ret []string
+	if op.ClearFlags != 0 {
+		ret = append(ret, "clear "+
+			describeFlags(op.ClearFlags, tf.XdrEnumNames()))
+	}
+	if op.SetFlags != 0 {
+		ret = append(ret, "set "+
+			describeFlags(op.SetFlags, tf.XdrEnumNames()))
+	}
+	if len(ret) == 0 {
+		ret = append(ret, "change no flags")
+	}
+	return fmt.Sprintf("%s on the trustline of %s for %s",
+		strings.Join(ret, " and "), net.DescribeAccount(op.Trustor),
+		net.DescribeAsset(op.Asset))
+}
+
+func describeLiquidityPoolDeposit(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	op := body.LiquidityPoolDepositOp()
+	return fmt.Sprintf("deposit up to %s of asset A and %s of asset B "+
+		"from %s into liquidity pool %x at a price between %s and %s",
+		FormatAmount(op.MaxAmountA), FormatAmount(op.MaxAmountB),
+		net.DescribeAccount(src), op.LiquidityPoolID[:],
+		describePrice(op.MinPrice), describePrice(op.MaxPrice))
+}
+
+func describeLiquidityPoolWithdraw(net *StellarNet, src *MuxedAccount,
+	body *stx.XdrAnon_Operation_Body) string {
+	op := body.LiquidityPoolWithdrawOp()
+	return fmt.Sprintf("redeem %s shares of liquidity pool %x for %s, "+
+		"receiving at least %s of asset A and %s of asset B",
+		FormatAmount(op.Amount), op.LiquidityPoolID[:],
+		net.DescribeAccount(src), FormatAmount(op.MinAmountA),
+		FormatAmount(op.MinAmountB))
+}
+
+// Describes a single operation, using the describer registered for
+// its type.  txsrc is the source account of the transaction
+// containing op.
+func (net *StellarNet) DescribeOp(txsrc *MuxedAccount,
+	op *stx.Operation) string {
+	src := txsrc
+	if op.SourceAccount != nil {
+		src = op.SourceAccount
+	}
+	if d := lookupOpDescriber(op.Body.Type); d != nil {
+		return d(net, src, &op.Body)
+	}
+	return fmt.Sprintf("perform %s on behalf of %s", op.Body.Type,
+		net.DescribeAccount(src))
+}
+
+// Returns a plain-English description of each operation in a
+// transaction, in order.  For a fee-bump transaction, the first
+// element describes the fee bump and the remainder describe the
+// inner transaction's operations.
+func (net *StellarNet) ExplainOps(e *TransactionEnvelope) []string {
+	var ret []string
+	if e.Type == stx.ENVELOPE_TYPE_TX_FEE_BUMP {
+		ret = append(ret, fmt.Sprintf("have %s pay a fee of up to %s",
+			net.DescribeAccount(&e.FeeBump().Tx.FeeSource),
+			net.DescribeAmount(e.FeeBump().Tx.Fee, NativeAsset())))
+	}
+	inner := innerTx(e)
+	if ops := inner.Operations(); ops != nil {
+		src := inner.SourceAccount()
+		for i := range *ops {
+			ret = append(ret, net.DescribeOp(src, &(*ops)[i]))
+		}
+	}
+	return ret
+}
+
+// Returns the inner transaction of a fee bump, or e itself for other
+// envelope types.
+func innerTx(e *TransactionEnvelope) *TransactionEnvelope {
+	if e.Type != stx.ENVELOPE_TYPE_TX_FEE_BUMP {
+		return e
+	}
+	inner := &TransactionEnvelope{
+		TransactionEnvelope: &stx.TransactionEnvelope{
+			Type: stx.ENVELOPE_TYPE_TX,
+		},
+	}
+	*inner.V1() = *e.FeeBump().Tx.InnerTx.V1()
+	return inner
+}
+
+// Returns a plain-English narrative of what a transaction does, such
+// as "Pay 50 XLM from A to B; then add signer S with weight 1 to A."
+func (net *StellarNet) ExplainTx(e *TransactionEnvelope) string {
+	ops := net.ExplainOps(e)
+	if len(ops) == 0 {
+		return "Do nothing."
+	}
+	return capitalize(strings.Join(ops, "; then ")) + "."
+}
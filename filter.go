@@ -0,0 +1,123 @@
+package stc
+
+import (
+	"github.com/xdrpp/stc/stx"
+	"regexp"
+)
+
+// Criteria for recognizing dust payments and airdrop spam sent to an
+// account, so that tools monitoring a busy account can hide them.
+// The zero value hides nothing.
+type TxFilter struct {
+	// Incoming payments of less than this amount (in units of 10^-7
+	// of any asset) are dust.
+	MinAmount int64
+
+	// If non-empty, incoming payments of other assets are spam.
+	Assets []stx.Asset
+
+	// Transactions with a text memo matching this pattern are spam.
+	MemoPattern *regexp.Regexp
+}
+
+// Returns the asset and amount received by acct in an operation, and
+// false if the operation is not a payment to acct.
+func incomingValue(body *stx.XdrAnon_Operation_Body, acct string) (
+	stx.Asset, int64, bool) {
+	isAcct := func(m *MuxedAccount) bool {
+		a, _ := DemuxAcct(m)
+		return a != nil && a.String() == acct
+	}
+	switch body.Type {
+	case stx.CREATE_ACCOUNT:
+		op := body.CreateAccountOp()
+		if op.Destination.String() == acct {
+			return NativeAsset(), op.StartingBalance, true
+		}
+	case stx.PAYMENT:
+		op := body.PaymentOp()
+		if isAcct(&op.Destination) {
+			return op.Asset, op.Amount, true
+		}
+	case stx.PATH_PAYMENT_STRICT_RECEIVE:
+		op := body.PathPaymentStrictReceiveOp()
+		if isAcct(&op.Destination) {
+			return op.DestAsset, op.DestAmount, true
+		}
+	case stx.PATH_PAYMENT_STRICT_SEND:
+		op := body.PathPaymentStrictSendOp()
+		if isAcct(&op.Destination) {
+			return op.DestAsset, op.DestMin, true
+		}
+	case stx.CREATE_CLAIMABLE_BALANCE:
+		op := body.CreateClaimableBalanceOp()
+		for i := range op.Claimants {
+			if op.Claimants[i].Type == stx.CLAIMANT_TYPE_V0 &&
+				op.Claimants[i].V0().Destination.String() == acct {
+				return op.Asset, op.Amount, true
+			}
+		}
+	}
+	return stx.Asset{}, 0, false
+}
+
+func (f *TxFilter) isDust(asset stx.Asset, amount int64) bool {
+	if amount < f.MinAmount {
+		return true
+	} else if len(f.Assets) == 0 {
+		return false
+	}
+	for i := range f.Assets {
+		if f.Assets[i].String() == asset.String() {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns true if e is dust or spam from the point of view of account
+// acct:  either e has a text memo matching f.MemoPattern, or every
+// operation of e is a payment to acct (including account creation and
+// claimable balances) that is smaller than f.MinAmount or not of an
+// asset in f.Assets.  Transactions or operations with acct as their
+// source are never considered spam.  A nil filter matches nothing.
+func (f *TxFilter) IsSpam(e *TransactionEnvelope, acct *AccountID) bool {
+	if f == nil {
+		return false
+	}
+	inner := innerTx(e)
+	target := acct.String()
+	if src, _ := DemuxAcct(inner.SourceAccount()); src != nil &&
+		src.String() == target {
+		return false
+	}
+	if f.MemoPattern != nil {
+		var memo *stx.Memo
+		switch inner.Type {
+		case stx.ENVELOPE_TYPE_TX_V0:
+			memo = &inner.V0().Tx.Memo
+		case stx.ENVELOPE_TYPE_TX:
+			memo = &inner.V1().Tx.Memo
+		}
+		if memo != nil && memo.Type == stx.MEMO_TEXT &&
+			f.MemoPattern.MatchString(*memo.Text()) {
+			return true
+		}
+	}
+	ops := inner.Operations()
+	if ops == nil || len(*ops) == 0 ||
+		(f.MinAmount <= 0 && len(f.Assets) == 0) {
+		return false
+	}
+	for i := range *ops {
+		op := &(*ops)[i]
+		if opSource(inner, op).String() == target {
+			return false
+		}
+		asset, amount, ok := incomingValue(&op.Body, target)
+		if !ok || !f.isDust(asset, amount) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package stc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/xdrpp/stc/horizon"
+	"github.com/xdrpp/stc/stcdetail"
+	"net/url"
+	"strings"
+)
+
+// Create and fund account addr (in strkey format) using the network's
+// friendbot, which exists only on test networks.  If net.Friendbot is
+// empty, uses the friendbot endpoint that some horizon servers
+// provide.  If friendbot submits a transaction that fails (e.g.,
+// because the account already exists), the error is a TxFailure.
+func (net *StellarNet) FundAccount(addr string) error {
+	return net.FundAccountCtx(nil, addr)
+}
+
+// Like FundAccount, but aborts the request if ctx is done.  ctx may
+// be nil.
+func (net *StellarNet) FundAccountCtx(ctx context.Context, addr string) error {
+	var acct AccountID
+	if _, err := fmt.Sscan(addr, &acct); err != nil {
+		return err
+	}
+	fb := net.Friendbot
+	if fb == "" {
+		if net.Horizon == "" {
+			return badHorizonURL
+		}
+		fb = net.Horizon + "friendbot"
+	}
+	if strings.IndexByte(fb, '?') >= 0 {
+		fb += "&"
+	} else {
+		fb += "?"
+	}
+	_, _, err := getURLHeader(ctx, net.httpClient(), net.retryPolicy(),
+		fb+"addr="+url.QueryEscape(acct.String()))
+	var he *HorizonError
+	if errors.As(err, &he) {
+		// Friendbot reports failed transactions the way horizon does
+		var res horizon.SubmitResponse
+		var txr TransactionResult
+		if json.Unmarshal(he.Body, &res) == nil &&
+			res.ResultXdr() != "" &&
+			stcdetail.XdrFromBase64(&txr, res.ResultXdr()) == nil {
+			return TxFailure{&txr}
+		}
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package stc
+
+import (
+	"net/http"
+	"time"
+)
+
+// Callbacks for observing the HTTP requests a StellarNet makes to
+// horizon (and to other servers of the network, such as friendbot),
+// so that an application can log, trace, or meter them.  Any field
+// may be nil.  Callbacks may be invoked concurrently from multiple
+// goroutines and should return quickly.
+type HTTPHooks struct {
+	// Called before each attempt to send a request, including
+	// retries.  The callback may add headers to req (e.g., to
+	// propagate a trace ID), but must not otherwise modify it.
+	OnRequest func(req *http.Request)
+
+	// Called after each attempt with the response (whose status and
+	// headers are available, but whose body the callback must not
+	// read or close) or error, and the time taken to receive the
+	// response headers.
+	OnResponse func(req *http.Request, resp *http.Response, err error,
+		elapsed time.Duration)
+
+	// Called when a failed attempt will be retried after waiting for
+	// wait.  retry is 1 for the first retry, 2 for the second, and so
+	// on.  err is the network error or, for an HTTP error status, a
+	// *HorizonError with only Status set.
+	OnRetry func(req *http.Request, retry int, wait time.Duration,
+		err error)
+}
+
+// A RoundTripper that invokes OnRequest and OnResponse hooks.
+type hookTransport struct {
+	base  http.RoundTripper
+	hooks *HTTPHooks
+}
+
+func (t hookTransport) RoundTrip(req *http.Request) (
+	*http.Response, error) {
+	if t.hooks.OnRequest != nil {
+		req = req.Clone(req.Context())
+		t.hooks.OnRequest(req)
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if t.hooks.OnResponse != nil {
+		t.hooks.OnResponse(req, resp, err, time.Since(start))
+	}
+	return resp, err
+}
+
+// Returns a copy of c that invokes hooks on every request, or c
+// itself if hooks is nil.
+func withHooks(c *http.Client, hooks *HTTPHooks) *http.Client {
+	if hooks == nil {
+		return c
+	}
+	ret := *c
+	base := c.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	ret.Transport = hookTransport{base: base, hooks: hooks}
+	return &ret
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/xdrpp/goxdr/xdr"
+	"github.com/xdrpp/stc/horizon"
 	"github.com/xdrpp/stc/stcdetail"
 	"github.com/xdrpp/stc/stx"
 	"io"
@@ -14,10 +15,12 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"path"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -53,33 +56,224 @@ func (e horizonFailure) Error() string {
 
 const badHorizonURL horizonFailure = "Missing or invalid horizon URL"
 
+// A non-200 HTTP response from horizon (or from another server stc
+// queries, such as friendbot or an anchor).  Use errors.As to
+// distinguish such responses from network failures.  Horizon
+// describes errors with RFC 7807 problem documents, which are parsed
+// into Problem; if the body is not a problem document, Problem is
+// zero.  Failed transaction submissions are reported as TxFailure
+// instead, whose result code (e.g., stx.TxBAD_SEQ) says why the
+// transaction failed.
+type HorizonError struct {
+	// The HTTP status code.
+	Status int
+
+	// The body of the response.
+	Body []byte
+
+	Problem horizon.Problem
+}
+
+func newHorizonError(status int, body []byte) *HorizonError {
+	ret := &HorizonError{Status: status, Body: body}
+	if json.Unmarshal(body, &ret.Problem) != nil {
+		ret.Problem = horizon.Problem{}
+	}
+	return ret
+}
+
+// Reads the body of a non-200 response into a HorizonError.
+func readHorizonError(resp *http.Response) *HorizonError {
+	body, _ := ioutil.ReadAll(resp.Body)
+	return newHorizonError(resp.StatusCode, body)
+}
+
+// Converts HTTP errors returned by stcdetail.StreamClient to
+// HorizonError, and returns other errors unchanged.
+func asHorizonError(err error) error {
+	if he, ok := err.(*stcdetail.HTTPerror); ok {
+		return newHorizonError(he.Resp.StatusCode, he.Body)
+	}
+	return err
+}
+
+func (e *HorizonError) Error() string {
+	switch {
+	case e.Problem.Title != "" && e.Problem.Detail != "":
+		return fmt.Sprintf("%s (HTTP %d): %s", e.Problem.Title, e.Status,
+			e.Problem.Detail)
+	case e.Problem.Title != "":
+		return fmt.Sprintf("%s (HTTP %d)", e.Problem.Title, e.Status)
+	case len(bytes.TrimSpace(e.Body)) > 0:
+		return string(e.Body)
+	}
+	return fmt.Sprintf("HTTP %d %s", e.Status, http.StatusText(e.Status))
+}
+
+// Returns the last component of the problem type, such as
+// "transaction_failed", "not_found", "rate_limit_exceeded", or
+// "timeout", or "" if the response was not a problem document.
+func (e *HorizonError) ProblemType() string {
+	if e.Problem.Type == "" {
+		return ""
+	}
+	return path.Base(e.Problem.Type)
+}
+
+// Returns the transaction result code (e.g., "tx_bad_seq") and
+// operation result codes from the problem's extras, if any.
+func (e *HorizonError) ResultCodes() (tx string, ops []string) {
+	if e.Problem.Extras == nil || e.Problem.Extras.Result_codes == nil {
+		return "", nil
+	}
+	rc := e.Problem.Extras.Result_codes
+	return rc.Transaction, rc.Operations
+}
+
+// Returns true if the request may succeed if retried later (HTTP 429
+// or a 5xx status other than 501).
+func (e *HorizonError) Temporary() bool {
+	return e.Status == http.StatusTooManyRequests ||
+		(e.Status >= 500 && e.Status != http.StatusNotImplemented)
+}
+
+// Returns true if err indicates that horizon does not implement the
+// requested endpoint (HTTP 404 or 501), as happens with optional
+// endpoints on older or stripped-down deployments.
+func endpointMissing(err error) bool {
+	var he *HorizonError
+	return errors.As(err, &he) && (he.Status == 404 || he.Status == 501)
+}
+
+// Returns true if err is a HorizonError with status 404.
+func notFound(err error) bool {
+	var he *HorizonError
+	return errors.As(err, &he) && he.Status == 404
+}
+
+// Reports a non-fatal problem through net.Logf, if set.
+func (net *StellarNet) logf(format string, args ...interface{}) {
+	if net.Logf != nil {
+		net.Logf(format, args...)
+	}
+}
+
+// The HTTP client used by StellarNets whose HTTPClient field is nil,
+// and for requests not associated with a StellarNet (such as fetching
+// prices or querying a horizon server with GetHorizonRoot).  Replace
+// it to route all of stc's requests through a proxy, use custom TLS
+// settings, or intercept requests in tests.
+var DefaultHTTPClient *http.Client = http.DefaultClient
+
+// Returns the HTTP client for net, which identifies requests with
+// net.UserAgent().
+func (net *StellarNet) httpClient() *http.Client {
+	c := DefaultHTTPClient
+	if net.HTTPClient != nil {
+		c = net.HTTPClient
+	}
+	return withUserAgent(withRateLimit(withHooks(c, net.Hooks),
+		net.RateLimit), net.UserAgent())
+}
+
 func getURL(url string) ([]byte, error) {
-	resp, err := http.Get(url)
+	body, _, err := getURLHeader(nil,
+		withUserAgent(withRateLimit(DefaultHTTPClient, nil),
+			"stc/"+Version()),
+		&DefaultRetryPolicy, url)
+	return body, err
+}
+
+// Like getURL, but also returns the response header, aborts the
+// request if ctx (which may be nil) is done, and sends the request
+// with client c, retrying according to rp.
+func getURLHeader(ctx context.Context, c *http.Client, rp *RetryPolicy,
+	url string) ([]byte, http.Header, error) {
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	} else if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := rp.do(c, req)
+	if err != nil {
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if resp.StatusCode != 200 {
-		return nil, horizonFailure(body)
+		return nil, nil, newHorizonError(resp.StatusCode, body)
 	}
-	return body, nil
+	return body, resp.Header, nil
 }
 
 // Send an HTTP request to horizon
 func (net *StellarNet) Get(query string) ([]byte, error) {
+	return net.GetCtx(nil, query)
+}
+
+// Like Get, but gives up if ctx is done.  ctx may be nil.
+// Concurrent requests for the same query (such as fetching the same
+// account from several goroutines) are coalesced into a single HTTP
+// request.  Since that request is shared, it is not tied to any one
+// caller's ctx:  it runs until it completes or exceeds the time the
+// retry policy and HTTP client timeout allow, while a caller whose ctx
+// is done returns ctx.Err() without waiting for it.
+func (net *StellarNet) GetCtx(ctx context.Context, query string) (
+	[]byte, error) {
 	if net.Horizon == "" {
 		return nil, badHorizonURL
 	}
-	return getURL(net.Horizon + query)
+	return net.getFrom(ctx, net.readHorizon(), query)
+}
+
+// Like GetCtx, but sends the request to the horizon server with base
+// URL base.
+func (net *StellarNet) getFrom(ctx context.Context, base, query string) (
+	[]byte, error) {
+	if base == "" {
+		return nil, badHorizonURL
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	} else if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ch := net.inflight.DoChan(base+query, func() (interface{}, error) {
+		c, rp := net.httpClient(), net.retryPolicy()
+		fctx, cancel := context.WithoutCancel(ctx), func() {}
+		if d := rp.maxDuration(c); d > 0 {
+			fctx, cancel = context.WithTimeout(fctx, d)
+		}
+		defer cancel()
+		body, _, err := getURLHeader(fctx, c, rp, base+query)
+		return body, err
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-ch:
+		body, _ := r.Val.([]byte)
+		if r.Shared {
+			body = append([]byte(nil), body...)
+		}
+		return body, r.Err
+	}
 }
 
 // Send an HTTP request to horizon and perse the result as JSON
 func (net *StellarNet) GetJSON(query string, out interface{}) error {
-	if body, err := net.Get(query); err != nil {
+	return net.GetJSONCtx(nil, query, out)
+}
+
+// Like GetJSON, but aborts the request if ctx is done.  ctx may be
+// nil.
+func (net *StellarNet) GetJSONCtx(ctx context.Context, query string,
+	out interface{}) error {
+	if body, err := net.GetCtx(ctx, query); err != nil {
 		return err
 	} else {
 		return json.Unmarshal(body, out)
@@ -128,27 +322,161 @@ func (net *StellarNet) StreamJSON(
 	if net.Horizon == "" {
 		return badHorizonURL
 	}
-	query = net.Horizon + query
+	query = net.readHorizon() + query
 
 	netval := reflect.ValueOf(net)
-	return stcdetail.Stream(ctx, query, func(evtype string, data []byte) error {
-		switch evtype {
-		case "error":
-			return ErrEventStream(data)
-		case "message":
-			v := reflect.New(tp)
-			setField(v, "Net", netval)
-			if err := json.Unmarshal(data, v.Interface()); err != nil {
-				return err
-			}
-			errs := cbv.Call([]reflect.Value{v})
-			if len(errs) != 0 {
-				if err, ok := errs[0].Interface().(error); ok && err != nil {
+	return asHorizonError(stcdetail.StreamClient(ctx, net.httpClient(),
+		query, func(evtype string, data []byte) error {
+			switch evtype {
+			case "error":
+				return ErrEventStream(data)
+			case "message":
+				v := reflect.New(tp)
+				setField(v, "Net", netval)
+				if err := json.Unmarshal(data, v.Interface()); err != nil {
 					return err
 				}
+				errs := cbv.Call([]reflect.Value{v})
+				if len(errs) != 0 {
+					if err, ok := errs[0].Interface().(error); ok && err != nil {
+						return err
+					}
+				}
 			}
+			return nil
+		}))
+}
+
+// Longest time StreamRecords waits before reconnecting after an error.
+const MaxStreamBackoff = time.Minute
+
+// Returns true if a streaming error is likely to go away by
+// reconnecting.
+func streamRetryable(err error) bool {
+	switch e := err.(type) {
+	case ErrEventStream:
+		return true
+	case *HorizonError:
+		return e.Status == 429 || e.Status >= 500
+	case *url.Error:
+		return true
+	}
+	return false
+}
+
+// Wraps errors returned by StreamRecords callbacks so they are not
+// retried.
+type streamCbError struct {
+	err error
+}
+
+func (e streamCbError) Error() string {
+	return e.err.Error()
+}
+
+// Stream records from a horizon endpoint that supports Server-Sent
+// Events, such as "transactions" or "accounts/G.../payments",
+// starting after cursor (or with new records only if cursor is "" or
+// "now").  cb receives the raw JSON of each record.  Unlike
+// StreamJSON, StreamRecords keeps track of the paging token of the
+// last record delivered and, if the connection fails or horizon
+// reports an error, reconnects with exponential backoff (up to
+// MaxStreamBackoff), resuming where it left off so that no record is
+// delivered twice or skipped.  It returns nil when ctx is done, or the
+// first non-nil error returned by cb or that reconnecting cannot fix
+// (such as a 404 for a non-existent account).
+func (net *StellarNet) StreamRecords(ctx context.Context, path string,
+	cursor string, cb func(data []byte) error) error {
+	if net.Horizon == "" {
+		return badHorizonURL
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if cursor == "" {
+		cursor = "now"
+	}
+	sep := "?"
+	if strings.IndexByte(path, '?') >= 0 {
+		sep = "&"
+	}
+	backoff := time.Second
+	for ctx.Err() == nil {
+		query := net.readHorizon() + path + sep + "cursor=" +
+			url.QueryEscape(cursor)
+		err := asHorizonError(stcdetail.StreamClient(ctx, net.httpClient(),
+			query,
+			func(evtype string, data []byte) error {
+				switch evtype {
+				case "error":
+					return ErrEventStream(data)
+				case "message":
+					var rec struct {
+						Paging_token string
+					}
+					if err := json.Unmarshal(data, &rec); err != nil {
+						return streamCbError{err}
+					} else if err = cb(data); err != nil {
+						return streamCbError{err}
+					}
+					if rec.Paging_token != "" {
+						cursor = rec.Paging_token
+					}
+					backoff = time.Second
+				}
+				return nil
+			}))
+		if ctx.Err() != nil {
+			break
+		} else if cberr, ok := err.(streamCbError); ok {
+			return cberr.err
+		} else if err != nil && !streamRetryable(err) {
+			return err
 		}
-		return nil
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > MaxStreamBackoff {
+			backoff = MaxStreamBackoff
+		}
+	}
+	return nil
+}
+
+// Stream transactions involving account acct (or all transactions if
+// acct is ""), as they are included in ledgers.  See StreamRecords for
+// the meaning of cursor and how errors are handled; to resume a
+// previous stream, pass the PagingToken of the last transaction
+// received.  Call this in a goroutine, and cancel ctx to stop.
+func (net *StellarNet) StreamTransactions(ctx context.Context, acct string,
+	cursor string, cb func(*HorizonTxResult) error) error {
+	path := "transactions"
+	if acct != "" {
+		path = "accounts/" + acct + "/transactions"
+	}
+	return net.StreamRecords(ctx, path, cursor, func(data []byte) error {
+		r := HorizonTxResult{Net: net}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return err
+		}
+		return cb(&r)
+	})
+}
+
+// Stream ledgers as they close, starting with the next one, so that a
+// long-running program can react to each new ledger (e.g., to re-check
+// pending transactions or refresh fee statistics).  See StreamRecords
+// for how errors are handled.  Call this in a goroutine, and cancel
+// ctx to stop.
+func (net *StellarNet) StreamLedgers(ctx context.Context,
+	cb func(*horizon.Ledger) error) error {
+	return net.StreamRecords(ctx, "ledgers", "now", func(data []byte) error {
+		var l horizon.Ledger
+		if err := json.Unmarshal(data, &l); err != nil {
+			return err
+		}
+		return cb(&l)
 	})
 }
 
@@ -159,6 +487,161 @@ func (ji *jsonInterface) UnmarshalJSON(data []byte) error {
 	return json.Unmarshal(data, ji.i)
 }
 
+// Parameters for paging through a horizon collection.  The zero value
+// requests horizon's defaults (the first page in ascending order).
+type PageParams struct {
+	// Start after the record with this paging token.
+	Cursor string
+
+	// Maximum number of records per page (horizon allows at most
+	// 200); 0 means horizon's default.
+	Limit int
+
+	// Walk the collection in descending order (newest first).
+	Desc bool
+}
+
+// Appends the parameters to query.
+func (pp *PageParams) addTo(query string) string {
+	v := url.Values{}
+	if pp.Cursor != "" {
+		v.Set("cursor", pp.Cursor)
+	}
+	if pp.Limit > 0 {
+		v.Set("limit", strconv.Itoa(pp.Limit))
+	}
+	if pp.Desc {
+		v.Set("order", "desc")
+	}
+	if len(v) == 0 {
+		return query
+	} else if strings.IndexByte(query, '?') >= 0 {
+		return query + "&" + v.Encode()
+	}
+	return query + "?" + v.Encode()
+}
+
+// A HorizonPager walks a horizon collection (such as
+// "accounts/G.../transactions") one page at a time, following the
+// _links.next URL of each page.  Use it like this:
+//
+//	pager := net.NewPager(ctx, "accounts/"+acct+"/operations",
+//		PageParams{Limit: 200})
+//	var page []json.RawMessage
+//	for pager.Next(&page) {
+//		// process page
+//	}
+//	if err := pager.Err(); err != nil {
+//		// handle error
+//	}
+type HorizonPager struct {
+	net *StellarNet
+	ctx context.Context
+	url string
+	err error
+}
+
+// Creates a pager for the collection at query (relative to the
+// horizon URL), which may already contain query parameters.  ctx may
+// be nil.
+func (net *StellarNet) NewPager(ctx context.Context, query string,
+	params PageParams) *HorizonPager {
+	ret := &HorizonPager{net: net, ctx: ctx}
+	if net.Horizon == "" {
+		ret.err = badHorizonURL
+	} else {
+		ret.url = net.readHorizon() + params.addTo(query)
+	}
+	return ret
+}
+
+var badPage error = errors.New(
+	"HorizonPager.Next argument must be of type *[]T")
+
+// Fetches the next page of records into out, which must be a pointer
+// to a slice of a type into which JSON can be unmarshalled (e.g.,
+// *[]HorizonTxResult or *[]json.RawMessage).  If the element type has
+// a field Net of type *StellarNet, it is set.  Returns false when
+// there are no more records, on error (see Err), or when ctx is done.
+// Failed requests are retried according to the StellarNet's
+// RetryPolicy.
+func (p *HorizonPager) Next(out interface{}) bool {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		panic(badPage)
+	}
+	v := rv.Elem()
+	v.Set(reflect.Zero(v.Type()))
+	if p.err != nil || p.url == "" {
+		return false
+	}
+
+	var j struct {
+		Links    horizon.PageLinks `json:"_links"`
+		Embedded struct {
+			Records jsonInterface
+		} `json:"_embedded"`
+	}
+	j.Embedded.Records.i = out
+
+	if p.ctx != nil && p.ctx.Err() != nil {
+		p.err = p.ctx.Err()
+		return false
+	}
+	req, err := http.NewRequest("GET", p.url, nil)
+	if err != nil {
+		p.err = err
+		return false
+	} else if p.ctx != nil {
+		req = req.WithContext(p.ctx)
+	}
+	resp, err := p.net.retryPolicy().do(p.net.httpClient(), req)
+	if err != nil {
+		p.err = err
+		return false
+	} else if resp.StatusCode != 200 {
+		p.err = readHorizonError(resp)
+		resp.Body.Close()
+		return false
+	}
+	err = json.NewDecoder(resp.Body).Decode(&j)
+	resp.Body.Close()
+	if err != nil {
+		p.err = err
+		return false
+	}
+
+	n := v.Len()
+	if n == 0 {
+		p.url = ""
+		return false
+	}
+	netval := reflect.ValueOf(p.net)
+	for i := 0; i < n; i++ {
+		setField(v.Index(i), "Net", netval)
+	}
+	p.url = j.Links.Next.Href
+	return true
+}
+
+// Returns the first error encountered by Next, if any.
+func (p *HorizonPager) Err() error {
+	return p.err
+}
+
+// Returns the cursor of the next page (the paging token of the last
+// record returned by Next), which can be saved and used as
+// PageParams.Cursor to resume paging later.  Returns "" if there are
+// no more pages.
+func (p *HorizonPager) Cursor() string {
+	if p.url == "" {
+		return ""
+	} else if u, err := url.Parse(p.url); err == nil {
+		return u.Query().Get("cursor")
+	}
+	return ""
+}
+
 // Send a request to horizon and iterate through a series of embedded
 // records in the response, continuing to fetch more records until
 // zero records are returned.  cb is a callback function which must
@@ -167,18 +650,6 @@ func (ji *jsonInterface) UnmarshalJSON(data []byte) error {
 // or the ctx argument is Done.
 func (net *StellarNet) IterateJSON(
 	ctx context.Context, query string, cb interface{}) error {
-	if net.Horizon == "" {
-		return badHorizonURL
-	}
-
-	var resp *http.Response
-	cleanup := func() {
-		if resp != nil && resp.Body != nil {
-			resp.Body.Close()
-		}
-	}
-	defer cleanup()
-
 	cbv := reflect.ValueOf(cb)
 	tp := cbv.Type()
 	if tp.Kind() != reflect.Func ||
@@ -189,60 +660,11 @@ func (net *StellarNet) IterateJSON(
 	}
 	tp = tp.In(0).Elem()
 
-	var j struct {
-		Links struct {
-			Next struct {
-				Href string
-			}
-		} `json:"_links"`
-		Embedded struct {
-			Records jsonInterface
-		} `json:"_embedded"`
-	}
-	j.Embedded.Records.i = reflect.New(reflect.SliceOf(tp)).Interface()
-
-	netval := reflect.ValueOf(net)
-
-	backoff := time.Second
-	for url := net.Horizon + query; ctx == nil || ctx.Err() == nil; url =
-		j.Links.Next.Href {
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return err
-		} else if ctx != nil {
-			req = req.WithContext(ctx)
-		}
-		cleanup()
-		resp, err = http.DefaultClient.Do(req)
-		if err != nil || ctx != nil && ctx.Err() != nil {
-			return err
-		} else if resp.StatusCode != 200 {
-			if resp.StatusCode != 429 {
-				return stcdetail.NewHTTPerror(resp)
-			}
-			if ctx != nil {
-				select {
-				case <-ctx.Done():
-				case <-time.After(backoff):
-				}
-			} else {
-				time.Sleep(backoff)
-			}
-			backoff *= 2
-			continue
-		}
-		backoff = time.Second
-		dec := json.NewDecoder(resp.Body)
-		if err = dec.Decode(&j); err != nil {
-			return err
-		}
-		v := reflect.ValueOf(j.Embedded.Records.i).Elem()
-		n := v.Len()
-		if n == 0 {
-			break
-		}
-		for i := 0; i < n; i++ {
-			setField(v.Index(i), "Net", netval)
+	pager := net.NewPager(ctx, query, PageParams{})
+	page := reflect.New(reflect.SliceOf(tp))
+	for pager.Next(page.Interface()) {
+		v := page.Elem()
+		for i, n := 0, v.Len(); i < n; i++ {
 			errs := cbv.Call([]reflect.Value{v.Index(i).Addr()})
 			if len(errs) != 0 {
 				if err, ok := errs[0].Interface().(error); ok && err != nil {
@@ -251,6 +673,10 @@ func (net *StellarNet) IterateJSON(
 			}
 		}
 	}
+	if err := pager.Err(); err != nil &&
+		(ctx == nil || err != ctx.Err()) {
+		return err
+	}
 	return nil
 }
 
@@ -260,67 +686,245 @@ type HorizonThresholds struct {
 	High_threshold uint8
 }
 type HorizonFlags struct {
-	Auth_required  bool
-	Auth_revocable bool
-	Auth_immutable bool
+	Auth_required         bool
+	Auth_revocable        bool
+	Auth_immutable        bool
+	Auth_clawback_enabled bool
 }
 type HorizonSigner struct {
-	Key    SignerKey
-	Weight uint32
+	Key     SignerKey
+	Weight  uint32
+	Sponsor *AccountID
 }
 
+// A balance of an account.  For liquidity pool shares,
+// Liquidity_pool_id is set and Asset.Type is stx.ASSET_TYPE_POOL_SHARE
+// (which is not otherwise valid in a stx.Asset).  Sponsor is the
+// account paying the trustline's reserve, if not the account itself.
 type HorizonBalance struct {
-	Balance             stcdetail.JsonInt64e7
-	Buying_liabilities  stcdetail.JsonInt64e7
-	Selling_liabilities stcdetail.JsonInt64e7
-	Limit               stcdetail.JsonInt64e7
-	Asset               stx.Asset `json:"-"`
+	Balance                               stcdetail.JsonInt64e7
+	Buying_liabilities                    stcdetail.JsonInt64e7
+	Selling_liabilities                   stcdetail.JsonInt64e7
+	Limit                                 stcdetail.JsonInt64e7
+	Liquidity_pool_id                     string
+	Asset                                 stx.Asset `json:"-"`
+	Sponsor                               *AccountID
+	Last_modified_ledger                  uint32
+	Is_authorized                         bool
+	Is_authorized_to_maintain_liabilities bool
+	Is_clawback_enabled                   bool
 }
 
 func (hb *HorizonBalance) UnmarshalJSON(data []byte) error {
 	type jhb HorizonBalance
-	var jasset struct {
-		Asset_type string
-		Asset_code string
-		Asset_issuer AccountID
-	}
+	var jasset horizonAsset
 	if err := json.Unmarshal(data, (*jhb)(hb)); err != nil {
 		return err
 	} else if err = json.Unmarshal(data, &jasset); err != nil {
 		return err
+	} else if jasset.Asset_type == "liquidity_pool_shares" {
+		hb.Asset.Type = stx.ASSET_TYPE_POOL_SHARE
+		return nil
 	}
+	return jasset.toAsset(&hb.Asset)
+}
+
+// An asset as horizon represents it in JSON.
+type horizonAsset struct {
+	Asset_type string
+	Asset_code string
+	Asset_issuer AccountID
+}
+
+func (ha *horizonAsset) toAsset(asset *stx.Asset) error {
 	var code []byte
-	switch jasset.Asset_type {
+	switch ha.Asset_type {
 	case "native":
-		hb.Asset.Type = stx.ASSET_TYPE_NATIVE
+		asset.Type = stx.ASSET_TYPE_NATIVE
 		return nil
 	case "credit_alphanum4":
-		hb.Asset.Type = stx.ASSET_TYPE_CREDIT_ALPHANUM4
-		a := hb.Asset.AlphaNum4()
-		a.Issuer = jasset.Asset_issuer
+		asset.Type = stx.ASSET_TYPE_CREDIT_ALPHANUM4
+		a := asset.AlphaNum4()
+		a.Issuer = ha.Asset_issuer
 		code = a.AssetCode[:]
 	case "credit_alphanum12":
-		hb.Asset.Type = stx.ASSET_TYPE_CREDIT_ALPHANUM12
-		a := hb.Asset.AlphaNum12()
-		a.Issuer = jasset.Asset_issuer
+		asset.Type = stx.ASSET_TYPE_CREDIT_ALPHANUM12
+		a := asset.AlphaNum12()
+		a.Issuer = ha.Asset_issuer
 		code = a.AssetCode[:]
 	default:
-		return horizonFailure("unknown asset type " + jasset.Asset_type)
+		return horizonFailure("unknown asset type " + ha.Asset_type)
 	}
 	for i := range code {
 		code[i] = 0
 	}
-	copy(code, jasset.Asset_code)
+	copy(code, ha.Asset_code)
 	return nil
 }
 
+// An offer as returned by horizon's offers endpoints.
+type HorizonOffer struct {
+	Net                  *StellarNet `json:"-"`
+	Id                   stcdetail.JsonInt64
+	Paging_token         string
+	Seller               AccountID
+	Selling              stx.Asset `json:"-"`
+	Buying               stx.Asset `json:"-"`
+	Amount               stcdetail.JsonInt64e7
+	Price_r              stx.Price
+	Price                string
+	Last_modified_ledger uint32
+	Sponsor              *AccountID
+}
+
+func (o *HorizonOffer) UnmarshalJSON(data []byte) error {
+	type jo HorizonOffer
+	var assets struct {
+		Selling horizonAsset
+		Buying  horizonAsset
+	}
+	if err := json.Unmarshal(data, (*jo)(o)); err != nil {
+		return err
+	} else if err = json.Unmarshal(data, &assets); err != nil {
+		return err
+	} else if err = assets.Selling.toAsset(&o.Selling); err != nil {
+		return err
+	}
+	return assets.Buying.toAsset(&o.Buying)
+}
+
+func (o *HorizonOffer) String() string {
+	return stcdetail.PrettyPrintAux(o.Net.prettyPrintAux, o)
+}
+
+// Returns an operation that deletes the offer.  The operation's
+// source must be the offer's Seller.
+func (o *HorizonOffer) Cancel() ManageSellOffer {
+	return ManageSellOffer{
+		Selling: o.Selling,
+		Buying:  o.Buying,
+		Amount:  0,
+		Price:   o.Price_r,
+		OfferID: int64(o.Id),
+	}
+}
+
+// Fetches all of account acct's open offers.  To cancel them all,
+// append the result of Cancel for each offer to a transaction (at
+// most stx.MAX_OPS_PER_TX per transaction) whose source is acct.
+func (net *StellarNet) GetAccountOffers(acct string) ([]HorizonOffer, error) {
+	return net.GetAccountOffersCtx(context.Background(), acct)
+}
+
+// Like GetAccountOffers, but takes a context.
+func (net *StellarNet) GetAccountOffersCtx(ctx context.Context,
+	acct string) ([]HorizonOffer, error) {
+	var ret, page []HorizonOffer
+	pager := net.NewPager(ctx, "accounts/"+acct+"/offers",
+		PageParams{Limit: 200})
+	for pager.Next(&page) {
+		ret = append(ret, page...)
+	}
+	if err := pager.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// A constant-product liquidity pool as returned by horizon's
+// liquidity_pools endpoints.  Fee_bp is the fee in basis points
+// (hundredths of a percent).
+type HorizonLiquidityPool struct {
+	Net                  *StellarNet `json:"-"`
+	Id                   stx.PoolID  `json:"-"`
+	Paging_token         string
+	Fee_bp               uint32
+	Type                 string
+	Total_trustlines     stcdetail.JsonInt64
+	Total_shares         stcdetail.JsonInt64e7
+	Reserves             []HorizonReserve
+	Last_modified_ledger uint32
+	Last_modified_time   time.Time
+}
+
+func (lp *HorizonLiquidityPool) UnmarshalJSON(data []byte) error {
+	type jlp HorizonLiquidityPool
+	var id struct {
+		Id string
+	}
+	if err := json.Unmarshal(data, (*jlp)(lp)); err != nil {
+		return err
+	} else if err = json.Unmarshal(data, &id); err != nil {
+		return err
+	}
+	_, err := fmt.Sscanf(id.Id, "%v", stx.XDR_Hash((*stx.Hash)(&lp.Id)))
+	return err
+}
+
+func (lp *HorizonLiquidityPool) String() string {
+	return stcdetail.PrettyPrintAux(lp.Net.prettyPrintAux, lp)
+}
+
+// Fetches the liquidity pool with the given ID (in hex, as returned
+// by fmt.Sprintf("%x", LiquidityPoolID(a, b))).
+func (net *StellarNet) GetLiquidityPool(id string) (
+	*HorizonLiquidityPool, error) {
+	ret := HorizonLiquidityPool{Net: net}
+	if err := net.GetJSON("liquidity_pools/"+id, &ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// Fetches a page of the liquidity pools holding all of the assets in
+// reserves (or all pools if reserves is empty) in which account acct
+// has a trustline (or regardless of trustlines if acct is "").  The
+// first element of opts, if any, selects the page; use NewPager with
+// the same query to walk through more pools.
+func (net *StellarNet) GetLiquidityPools(reserves []stx.Asset, acct string,
+	opts ...PageParams) ([]HorizonLiquidityPool, error) {
+	query := "liquidity_pools"
+	v := url.Values{}
+	if len(reserves) > 0 {
+		assets := make([]string, len(reserves))
+		for i := range reserves {
+			assets[i] = reserves[i].String()
+		}
+		v.Set("reserves", strings.Join(assets, ","))
+	}
+	if acct != "" {
+		v.Set("account", acct)
+	}
+	if len(v) > 0 {
+		query += "?" + v.Encode()
+	}
+	var params PageParams
+	if len(opts) > 0 {
+		params = opts[0]
+	}
+	var ret []HorizonLiquidityPool
+	pager := net.NewPager(nil, query, params)
+	pager.Next(&ret)
+	return ret, pager.Err()
+}
+
 // Structure into which you can unmarshal JSON returned by a query to
 // horizon for an account endpoint
 type HorizonAccountEntry struct {
 	Net                   *StellarNet `json:"-"`
+	Account_id            string
 	Sequence              stcdetail.JsonInt64
 	Balance               stcdetail.JsonInt64e7
+	// Liabilities of the native asset (like Balance, taken from the
+	// native entry of horizon's balances array)
+	Buying_liabilities    stcdetail.JsonInt64e7
+	Selling_liabilities   stcdetail.JsonInt64e7
+	Sequence_ledger       uint32
+	Sequence_time         stcdetail.JsonInt64
 	Subentry_count        uint32
+	Num_sponsoring        uint32
+	Num_sponsored         uint32
+	Sponsor               *AccountID
 	Inflation_destination *AccountID
 	Home_domain           string
 	Last_modified_ledger  uint32
@@ -344,11 +948,9 @@ func (net *StellarNet) prettyPrintAux(i interface{}) (string, bool) {
 		}
 	case stx.SignerKey:
 		b := stcdetail.XdrToBin(&v)
-		if skis, ok := net.Signers[v.Hint()]; ok {
-			for j := range skis {
-				if stcdetail.XdrToBin(&skis[j].Key) == b {
-					return fmt.Sprintf("%s (%s)", v, skis[j].Comment), true
-				}
+		for _, ski := range net.signersWithHint(v.Hint()) {
+			if stcdetail.XdrToBin(&ski.Key) == b {
+				return fmt.Sprintf("%s (%s)", v, ski.Comment), true
 			}
 		}
 	}
@@ -378,6 +980,8 @@ func (ae *HorizonAccountEntry) UnmarshalJSON(data []byte) error {
 	for i := range ae.Balances {
 		if ae.Balances[i].Asset.Type == stx.ASSET_TYPE_NATIVE {
 			ae.Balance = ae.Balances[i].Balance
+			ae.Buying_liabilities = ae.Balances[i].Buying_liabilities
+			ae.Selling_liabilities = ae.Balances[i].Selling_liabilities
 			ae.Balances = append(ae.Balances[:i], ae.Balances[i+1:]...)
 			break
 		}
@@ -389,13 +993,167 @@ func (ae *HorizonAccountEntry) UnmarshalJSON(data []byte) error {
 // network.
 func (net *StellarNet) GetAccountEntry(acct string) (
 	*HorizonAccountEntry, error) {
+	return net.GetAccountEntryCtx(nil, acct)
+}
+
+// Like GetAccountEntry, but aborts the request if ctx is done.  ctx
+// may be nil.
+func (net *StellarNet) GetAccountEntryCtx(ctx context.Context,
+	acct string) (*HorizonAccountEntry, error) {
 	ret := HorizonAccountEntry{ Net: net }
-	if err := net.GetJSON("accounts/"+acct, &ret); err != nil {
+	if err := net.GetJSONCtx(ctx, "accounts/"+acct, &ret); err != nil {
 		return nil, err
 	}
 	return &ret, nil
 }
 
+// Criteria for FindAccounts.  Horizon requires exactly one field to
+// be set.
+type AccountFilter struct {
+	// Accounts that have this signer (an account or signer key in
+	// strkey format).  Since an account is its own signer (unless its
+	// master weight is 0), the result may include the key's own
+	// account.
+	Signer string
+
+	// Accounts with a trustline to this asset.
+	Asset *stx.Asset
+
+	// Accounts, or accounts with trustlines or other subentries,
+	// sponsored by this account.
+	Sponsor string
+}
+
+func (f *AccountFilter) query() string {
+	v := url.Values{}
+	if f.Signer != "" {
+		v.Set("signer", f.Signer)
+	}
+	if f.Asset != nil {
+		v.Set("asset", f.Asset.String())
+	}
+	if f.Sponsor != "" {
+		v.Set("sponsor", f.Sponsor)
+	}
+	return "accounts?" + v.Encode()
+}
+
+// Fetches every account matching filter, walking all pages of
+// horizon's accounts endpoint.  For instance, FindAccounts(ctx,
+// AccountFilter{Signer: key}) returns all the accounts key can sign
+// for, with their thresholds and signer weights.  A widely held asset
+// may have too many accounts to fetch at once; to process them
+// incrementally, use NewPager with the same filters.  ctx may be nil.
+func (net *StellarNet) FindAccounts(ctx context.Context,
+	filter AccountFilter) ([]HorizonAccountEntry, error) {
+	var ret, page []HorizonAccountEntry
+	pager := net.NewPager(ctx, filter.query(), PageParams{Limit: 200})
+	for pager.Next(&page) {
+		ret = append(ret, page...)
+	}
+	if err := pager.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Number of concurrent horizon requests made by PrefetchSequences.
+var PrefetchParallelism = 8
+
+// A cache of account sequence numbers, such as one returned by
+// PrefetchSequences, from which transactions can be assigned
+// consecutive sequence numbers without further network round trips.
+// Safe for concurrent use.
+type SequenceCache struct {
+	lock sync.Mutex
+	seqs map[string]stx.SequenceNumber
+}
+
+// Record seq as the current (i.e., last used) sequence number of
+// acct.
+func (sc *SequenceCache) Set(acct AccountID, seq stx.SequenceNumber) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	if sc.seqs == nil {
+		sc.seqs = make(map[string]stx.SequenceNumber)
+	}
+	sc.seqs[acct.String()] = seq
+}
+
+// Reserve and return the next sequence number for acct.  Returns
+// false if acct is not in the cache.
+func (sc *SequenceCache) Next(acct AccountID) (stx.SequenceNumber, bool) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	seq, ok := sc.seqs[acct.String()]
+	if !ok {
+		return 0, false
+	}
+	seq++
+	sc.seqs[acct.String()] = seq
+	return seq, true
+}
+
+// Set the sequence number of a transaction to the next one for its
+// source account.  Returns false if the source account is not in the
+// cache or e is a fee-bump transaction, which has no sequence number.
+func (sc *SequenceCache) SetNextSeq(e *TransactionEnvelope) bool {
+	if e.Type != stx.ENVELOPE_TYPE_TX && e.Type != stx.ENVELOPE_TYPE_TX_V0 {
+		return false
+	}
+	acct, _ := DemuxAcct(e.SourceAccount())
+	if acct == nil {
+		return false
+	}
+	seq, ok := sc.Next(*acct)
+	if !ok {
+		return false
+	} else if e.Type == stx.ENVELOPE_TYPE_TX {
+		e.V1().Tx.SeqNum = seq
+	} else {
+		e.V0().Tx.SeqNum = seq
+	}
+	return true
+}
+
+// Fetch the current sequence numbers of many accounts concurrently
+// (with at most PrefetchParallelism requests outstanding), for
+// building batches of transactions from many source or channel
+// accounts.  On error, the returned cache still contains the
+// accounts that were successfully fetched, and the error is the
+// first one encountered.
+func (net *StellarNet) PrefetchSequences(accts []AccountID) (
+	*SequenceCache, error) {
+	ret := &SequenceCache{}
+	var wg sync.WaitGroup
+	var errlock sync.Mutex
+	var firstErr error
+	par := PrefetchParallelism
+	if par < 1 {
+		par = 1
+	}
+	sem := make(chan struct{}, par)
+	for i := range accts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(acct AccountID) {
+			defer func() { <-sem; wg.Done() }()
+			ae, err := net.getSubmitAccountEntry(nil, acct.String())
+			if err != nil {
+				errlock.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s: %w", acct, err)
+				}
+				errlock.Unlock()
+				return
+			}
+			ret.Set(acct, stx.SequenceNumber(ae.Sequence))
+		}(accts[i])
+	}
+	wg.Wait()
+	return ret, firstErr
+}
+
 // Returns the network ID, a string that is hashed into transaction
 // IDs to ensure that signature are not valid across networks (e.g., a
 // testnet signature cannot work on the public network).  If the
@@ -411,12 +1169,71 @@ func (net *StellarNet) GetNetworkId() string {
 		if err := net.GetJSON("/", &np); err == nil &&
 			np.Network_passphrase != "" {
 			net.NetworkId = np.Network_passphrase
-			net.Edits.Set("net", "network-id", net.NetworkId)
+			net.edit("net", "network-id", net.NetworkId)
 		}
 	}
 	return net.NetworkId
 }
 
+// Structure into which you can unmarshal the JSON returned by
+// horizon's root endpoint, which describes the server and the network
+// it serves.
+type HorizonRoot struct {
+	Horizon_version                 string
+	Core_version                    string
+	Network_passphrase              string
+	History_latest_ledger           uint32
+	History_elder_ledger            uint32
+	Core_latest_ledger              uint32
+	Current_protocol_version        uint32
+	Supported_protocol_version      uint32
+	Core_supported_protocol_version uint32
+	Friendbot                       string `json:"-"`
+}
+
+func (hr *HorizonRoot) UnmarshalJSON(data []byte) error {
+	type jhr HorizonRoot
+	var links struct {
+		Links struct {
+			Friendbot struct {
+				Href string
+			}
+		} `json:"_links"`
+	}
+	if err := json.Unmarshal(data, (*jhr)(hr)); err != nil {
+		return err
+	} else if err = json.Unmarshal(data, &links); err != nil {
+		return err
+	}
+	// Strip any URI template such as "{?addr}"
+	hr.Friendbot = links.Links.Friendbot.Href
+	if i := strings.IndexByte(hr.Friendbot, '{'); i >= 0 {
+		hr.Friendbot = hr.Friendbot[:i]
+	}
+	return nil
+}
+
+func (hr *HorizonRoot) String() string {
+	return stcdetail.PrettyPrint(hr)
+}
+
+// Query the root endpoint of a horizon server, which need not be
+// associated with any configured StellarNet.
+func GetHorizonRoot(horizon string) (*HorizonRoot, error) {
+	if horizon == "" {
+		return nil, badHorizonURL
+	}
+	body, err := getURL(horizon)
+	if err != nil {
+		return nil, err
+	}
+	var ret HorizonRoot
+	if err = json.Unmarshal(body, &ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
 func showLedgerKey(k stx.LedgerKey) string {
 	switch k.Type {
 	case stx.ACCOUNT:
@@ -502,17 +1319,18 @@ func (r HorizonTxResult) String() string {
 	return out.String()
 }
 
-func (r *HorizonTxResult) UnmarshalJSON(data []byte) error {
-	var j struct {
-		Envelope_xdr string
-		Result_xdr string
-		Result_meta_xdr string
-		Fee_meta_xdr string
-		Paging_token string
-		Hash string
-		Ledger uint32
-		Created_at string
+// Like stcdetail.XdrFromBase64, but leaves e unchanged if input is
+// empty, as horizon omits transaction metadata when configured not to
+// store it.
+func xdrFromOptionalBase64(e xdr.XdrType, input string) error {
+	if input == "" {
+		return nil
 	}
+	return stcdetail.XdrFromBase64(e, input)
+}
+
+func (r *HorizonTxResult) UnmarshalJSON(data []byte) error {
+	var j horizon.Transaction
 	if err := json.Unmarshal(data, &j); err != nil {
 		return err
 	} else if err = stcdetail.XdrFromBase64(&r.Env,
@@ -521,31 +1339,105 @@ func (r *HorizonTxResult) UnmarshalJSON(data []byte) error {
 	} else if err = stcdetail.XdrFromBase64(&r.Result,
 		j.Result_xdr); err != nil {
 		return err
-	} else if err = stcdetail.XdrFromBase64(
+	} else if err = xdrFromOptionalBase64(
 		stx.XDR_LedgerEntryChanges(&r.FeeMeta), j.Fee_meta_xdr); err != nil {
 			return err
-	} else if err = stcdetail.XdrFromBase64(&r.ResultMeta,
+	} else if err = xdrFromOptionalBase64(&r.ResultMeta,
 		j.Result_meta_xdr); err != nil {
 			return err
 	} else if _, err := fmt.Sscanf(j.Hash, "%v",
 		stx.XDR_Hash(&r.Txhash)); err != nil {
 		return err
-	} else if r.Time, err = time.ParseInLocation("2006-01-02T15:04:05Z",
-		j.Created_at, time.UTC); err != nil {
-			return err
 	}
-	r.Time = r.Time.Local()
+	r.Time = j.Created_at.Local()
 	r.Ledger = j.Ledger
 	r.PagingToken = j.Paging_token
 	return nil
 }
 
-func (net *StellarNet) GetTxResult(txid string) (*HorizonTxResult, error) {
-	ret := HorizonTxResult{ Net: net }
-	if err := net.GetJSON("transactions/"+txid, &ret); err != nil {
+func (net *StellarNet) GetTxResult(txid string) (*HorizonTxResult, error) {
+	return net.GetTxResultCtx(nil, txid)
+}
+
+// Like GetTxResult, but aborts the request if ctx is done.  ctx may be
+// nil.
+func (net *StellarNet) GetTxResultCtx(ctx context.Context, txid string) (
+	*HorizonTxResult, error) {
+	ret := HorizonTxResult{ Net: net }
+	if err := net.GetJSONCtx(ctx, "transactions/"+txid, &ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// A transaction as returned by GetTransaction.
+type HorizonTransaction struct {
+	HorizonTxResult
+
+	// The transaction as horizon described it, including information
+	// not found in the XDR, such as Successful, Fee_account, and
+	// Fee_charged.
+	Info horizon.Transaction
+}
+
+func (t *HorizonTransaction) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &t.Info); err != nil {
+		return err
+	}
+	return t.HorizonTxResult.UnmarshalJSON(data)
+}
+
+func (t HorizonTransaction) String() string {
+	return fmt.Sprintf("successful: %v\nfee_account: %s\nfee_charged: %s\n",
+		t.Info.Successful, t.Info.Fee_account, t.Info.Fee_charged) +
+		t.HorizonTxResult.String()
+}
+
+// Fetches the transaction with hash txid (in hex), decoding its
+// envelope, result, and metadata.  Unlike GetTxResult, checks that the
+// envelope (or, for a fee-bump transaction, either the envelope or
+// the inner transaction) hashes to txid on net, so that horizon cannot
+// substitute a different transaction.  The result and metadata are
+// taken on horizon's word.
+func (net *StellarNet) GetTransaction(txid string) (
+	*HorizonTransaction, error) {
+	return net.GetTransactionCtx(nil, txid)
+}
+
+// Like GetTransaction, but aborts the request if ctx is done.  ctx may
+// be nil.
+func (net *StellarNet) GetTransactionCtx(ctx context.Context,
+	txid string) (*HorizonTransaction, error) {
+	ret := HorizonTransaction{HorizonTxResult: HorizonTxResult{Net: net}}
+	if err := net.GetJSONCtx(ctx, "transactions/"+txid, &ret); err != nil {
 		return nil, err
 	}
-	return &ret, nil
+	hashes := []*stx.Hash{net.HashTx(&ret.Env)}
+	if ret.Env.Type == stx.ENVELOPE_TYPE_TX_FEE_BUMP {
+		hashes = append(hashes,
+			net.HashTx(&ret.Env.FeeBump().Tx.InnerTx.V1().Tx))
+	}
+	for _, h := range hashes {
+		if strings.EqualFold(fmt.Sprintf("%x", *h), txid) {
+			return &ret, nil
+		}
+	}
+	return nil, fmt.Errorf("transaction %s: horizon returned a "+
+		"transaction with hash %x", txid, *hashes[0])
+}
+
+// Fetches up to limit transactions that affected account acct, most
+// recent first, with their envelopes, results, and metadata decoded.
+// If cursor is non-empty, returns transactions older than the one with
+// that paging token; to fetch the next batch, pass the PagingToken of
+// the last transaction returned.  A limit of 0 uses horizon's default.
+func (net *StellarNet) GetAccountTransactions(acct, cursor string,
+	limit int) ([]HorizonTxResult, error) {
+	var ret []HorizonTxResult
+	pager := net.NewPager(nil, "accounts/"+acct+"/transactions",
+		PageParams{Cursor: cursor, Limit: limit, Desc: true})
+	pager.Next(&ret)
+	return ret, pager.Err()
 }
 
 // A Fee Value is currently 32 bits, but could become 64 bits if
@@ -553,13 +1445,29 @@ func (net *StellarNet) GetTxResult(txid string) (*HorizonTxResult, error) {
 type FeeVal = uint32
 const feeValSize = 32
 
-func parseFeeVal(i interface{}) (FeeVal, error) {
-	// Annoyingly, Horizion always returns strings instead of numbers
-	// for the /fee_stats endpoint.  Because this behavior is
-	// annoying, we want to be prepared for it to change, which is why
-	// we Sprint and then Parse.
-	n, err := strconv.ParseUint(fmt.Sprint(i), 10, feeValSize)
-	return uint32(n), err
+// Returns the text of a JSON number, or the contents of a JSON string.
+// Annoyingly, Horizon returns strings instead of numbers for the
+// /fee_stats endpoint.  Because this behavior is annoying, we want to
+// be prepared for it to change, so accept both.
+func jsonScalar(raw json.RawMessage) (string, error) {
+	if len(raw) > 0 && raw[0] == '"' {
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	}
+	return string(raw), nil
+}
+
+func parseFeeVal(raw json.RawMessage) (FeeVal, error) {
+	s, err := jsonScalar(raw)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(s, 10, feeValSize)
+	if err != nil {
+		return 0, horizonFailure(fmt.Sprintf("invalid fee %s", raw))
+	}
+	return FeeVal(n), nil
 }
 
 type FeePercentile = struct {
@@ -575,80 +1483,100 @@ type FeeDist struct {
 	Percentiles []FeePercentile
 }
 
-func getPercentage(k string) (bool, int) {
-	if len(k) < 2 || k[0] != 'p' || len(k) > 4 {
-		return false, -1
+// Parses a percentile key such as "p10" (or, with suffix
+// "_accepted_fee", "p10_accepted_fee").
+func parsePercentileKey(k, suffix string) (int, bool) {
+	if !strings.HasPrefix(k, "p") || !strings.HasSuffix(k, suffix) {
+		return 0, false
 	}
-	r := 0
-	for i := 1; i < len(k); i++ {
-		if k[i] < '0' || k[i] > '9' {
-			return false, -1
-		}
-		r = r * 10 + int(k[i]-'0')
-	}
-	return true, r
-}
-
-func setVal(v reflect.Value, s string) bool {
-	if !v.IsValid() {
-		return false
+	digits := k[1 : len(k)-len(suffix)]
+	if len(digits) == 0 || len(digits) > 3 {
+		return 0, false
 	}
-	switch v.Kind() {
-	case reflect.Uint32:
-		if n, err := strconv.ParseUint(s, 10, 32); err == nil {
-			v.SetUint(n)
-			return true
-		}
-	case reflect.Uint64:
-		if n, err := strconv.ParseUint(s, 10, 64); err == nil {
-			v.SetUint(n)
-			return true
-		}
-	case reflect.Float64:
-		if n, err := strconv.ParseFloat(s, 64); err == nil {
-			v.SetFloat(n)
-			return true
-		}
+	p, err := strconv.Atoi(digits)
+	if err != nil || p < 0 || p > 100 || strconv.Itoa(p) != digits {
+		return 0, false
 	}
-	return false
+	return p, true
 }
 
-func (fd *FeeDist) UnmarshalJSON(data []byte) error {
-	dec := json.NewDecoder(bytes.NewReader(data))
-	dec.UseNumber()
-	var obj map[string]interface{}
-	if err := dec.Decode(&obj); err != nil {
-		return err
-	}
-
-	rv := reflect.ValueOf(fd).Elem()
-	for k := range obj {
-		if ok, p := getPercentage(k); ok {
-			if fee, err := parseFeeVal(obj[k]); err == nil {
-				fd.Percentiles = append(fd.Percentiles, FeePercentile{
-						Percentile: int(p),
-						Fee: fee,
-				})
+// Fills in fd from the fields of obj named "min"+suffix,
+// "mode"+suffix, "max"+suffix, and "p"+N+suffix for each percentile N.
+// Missing max defaults to the highest percentile.  Unknown fields are
+// ignored, but malformed fees are errors.
+func (fd *FeeDist) fromFields(obj map[string]json.RawMessage,
+	suffix string) error {
+	*fd = FeeDist{}
+	var haveMax bool
+	for k, raw := range obj {
+		var target *FeeVal
+		switch k {
+		case "min" + suffix:
+			target = &fd.Min
+		case "mode" + suffix:
+			target = &fd.Mode
+		case "max" + suffix:
+			target, haveMax = &fd.Max, true
+		default:
+			p, ok := parsePercentileKey(k, suffix)
+			if !ok {
 				continue
 			}
+			fee, err := parseFeeVal(raw)
+			if err != nil {
+				return err
+			}
+			fd.Percentiles = append(fd.Percentiles, FeePercentile{
+				Percentile: p,
+				Fee: fee,
+			})
+			continue
 		}
-		capk := capitalize(k)
-		if capk == "Percentiles" {
-			continue			// Server is trolling us
+		var err error
+		if *target, err = parseFeeVal(raw); err != nil {
+			return err
 		}
-		setVal(rv.FieldByName(capk), fmt.Sprint(obj[k]))
 	}
-	if fd.Min == 0 || fd.Max == 0 || len(fd.Percentiles) == 0 {
-		// Something's wrong; don't return garbage
-		return horizonFailure("Garbled fee_stats")
-	}
-
 	sort.Slice(fd.Percentiles, func(i, j int) bool {
 		return fd.Percentiles[i].Percentile < fd.Percentiles[j].Percentile
 	})
+	if !haveMax && len(fd.Percentiles) > 0 {
+		fd.Max = fd.Percentiles[len(fd.Percentiles)-1].Fee
+	}
+	return fd.validate()
+}
+
+// Rejects distributions that cannot be right, so that we never
+// return garbage fees.
+func (fd *FeeDist) validate() error {
+	if fd.Min == 0 || len(fd.Percentiles) == 0 {
+		return horizonFailure("Garbled fee_stats: missing fees")
+	} else if fd.Max < fd.Min || fd.Mode > fd.Max ||
+		(fd.Mode != 0 && fd.Mode < fd.Min) {
+		return horizonFailure("Garbled fee_stats: inconsistent min/max/mode")
+	}
+	for i := range fd.Percentiles {
+		p := &fd.Percentiles[i]
+		if p.Fee < fd.Min || p.Fee > fd.Max {
+			return horizonFailure(fmt.Sprintf(
+				"Garbled fee_stats: p%d out of range", p.Percentile))
+		} else if i > 0 && (p.Percentile == fd.Percentiles[i-1].Percentile ||
+			p.Fee < fd.Percentiles[i-1].Fee) {
+			return horizonFailure(fmt.Sprintf(
+				"Garbled fee_stats: p%d not monotonic", p.Percentile))
+		}
+	}
 	return nil
 }
 
+func (fd *FeeDist) UnmarshalJSON(data []byte) error {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	return fd.fromFields(obj, "")
+}
+
 // Conservatively returns a fee that is a known fee for the target or
 // the closest higher known percentile.  Does not interpolate--e.g.,
 // if you ask for the 51st percentile but only the 50th and 60th are
@@ -708,38 +1636,59 @@ type FeeStats struct {
 	Offered FeeDist
 }
 
+// Parses either of the schemas horizon has used for fee_stats.
+// Current versions report distributions of the fees charged and
+// offered in "fee_charged" and "max_fee" objects.  Versions before 1.0
+// instead report flat fields "min_accepted_fee", "mode_accepted_fee",
+// and "p10_accepted_fee" through "p99_accepted_fee", which describe
+// offered fees; for such versions, Charged is left zero and
+// Offered.Max is the highest percentile.
 func (fs *FeeStats) UnmarshalJSON(data []byte) error {
-	type feeNumbers struct {
-		Last_ledger json.Number
-		Last_ledger_base_fee json.Number
-		Ledger_capacity_usage json.Number
-		Fee_charged FeeDist
-		Max_fee FeeDist
-	}
-	dec := json.NewDecoder(bytes.NewReader(data))
-	dec.UseNumber()
-	var obj feeNumbers
-	if err := dec.Decode(&obj); err != nil {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
 		return err
 	}
-	if n, err := obj.Last_ledger.Int64(); err != nil {
+	field := func(k string) (string, error) {
+		if raw, ok := obj[k]; ok {
+			return jsonScalar(raw)
+		}
+		return "", horizonFailure("fee_stats missing " + k)
+	}
+
+	*fs = FeeStats{}
+	if s, err := field("last_ledger"); err != nil {
 		return err
-	} else {
-		fs.Last_ledger = uint64(n)
+	} else if fs.Last_ledger, err = strconv.ParseUint(s, 10, 64);
+	err != nil {
+		return horizonFailure("invalid last_ledger " + s)
 	}
-	if n, err := obj.Last_ledger_base_fee.Int64(); err != nil {
+	if s, err := field("last_ledger_base_fee"); err != nil {
 		return err
+	} else if n, err := strconv.ParseUint(s, 10, 32); err != nil || n == 0 {
+		return horizonFailure("invalid last_ledger_base_fee " + s)
 	} else {
 		fs.Last_ledger_base_fee = uint32(n)
 	}
-	if n, err := obj.Ledger_capacity_usage.Float64(); err != nil {
+	if s, err := field("ledger_capacity_usage"); err != nil {
 		return err
-	} else {
-		fs.Ledger_capacity_usage = n
+	} else if fs.Ledger_capacity_usage, err = strconv.ParseFloat(s, 64);
+	err != nil || fs.Ledger_capacity_usage < 0 ||
+		fs.Ledger_capacity_usage > 1 {
+		return horizonFailure("invalid ledger_capacity_usage " + s)
 	}
-	fs.Charged = obj.Fee_charged
-	fs.Offered = obj.Max_fee
-	return nil
+
+	if _, ok := obj["max_fee"]; ok {
+		if _, ok = obj["fee_charged"]; !ok {
+			return horizonFailure("fee_stats missing fee_charged")
+		} else if err := json.Unmarshal(obj["fee_charged"],
+			&fs.Charged); err != nil {
+			return err
+		}
+		return json.Unmarshal(obj["max_fee"], &fs.Offered)
+	} else if _, ok = obj["min_accepted_fee"]; ok {
+		return fs.Offered.fromFields(obj, "_accepted_fee")
+	}
+	return horizonFailure("unrecognized fee_stats format")
 }
 
 // Conservatively a known offered fee for the target or a higher
@@ -752,6 +1701,62 @@ func (fs *FeeStats) Percentile(target int) FeeVal {
 	return fee
 }
 
+// Rough level of network congestion, as estimated by
+// FeeStats.Congestion.
+type Congestion int
+
+const (
+	CongestionLow Congestion = iota
+	CongestionMedium
+	CongestionHigh
+)
+
+func (c Congestion) String() string {
+	switch c {
+	case CongestionLow:
+		return "low"
+	case CongestionMedium:
+		return "medium"
+	case CongestionHigh:
+		return "high"
+	}
+	return fmt.Sprintf("Congestion(%d)", int(c))
+}
+
+// True if recent ledgers were surge pricing, meaning even the
+// cheapest included transactions paid more than the base fee.
+func (fs *FeeStats) SurgePricing() bool {
+	return fs.Charged.Min > fs.Last_ledger_base_fee
+}
+
+// Estimates network congestion from ledger capacity usage and whether
+// the network is surge pricing.  Congestion is high if recent ledgers
+// were surge pricing or at least 90% full, and medium if they were at
+// least half full.
+func (fs *FeeStats) Congestion() Congestion {
+	switch {
+	case fs.SurgePricing() || fs.Ledger_capacity_usage >= 0.9:
+		return CongestionHigh
+	case fs.Ledger_capacity_usage >= 0.5:
+		return CongestionMedium
+	}
+	return CongestionLow
+}
+
+// Returns a per-operation fee likely to get a transaction included
+// promptly at the current level of congestion:  the base fee when
+// congestion is low, and the 50th or 90th percentile offered fee when
+// congestion is medium or high, respectively.
+func (fs *FeeStats) SuggestedFee() FeeVal {
+	switch fs.Congestion() {
+	case CongestionHigh:
+		return fs.Percentile(90)
+	case CongestionMedium:
+		return fs.Percentile(50)
+	}
+	return fs.Last_ledger_base_fee
+}
+
 func (fs FeeStats) String() string {
 	out := &strings.Builder{}
 	printFsField(out, "last_ledger", fs.Last_ledger)
@@ -762,6 +1767,124 @@ func (fs FeeStats) String() string {
 	return out.String()
 }
 
+// Percentiles reported by horizon's fee_stats endpoint, and hence by
+// NewFeeDist.
+var FeeStatsPercentiles = []int{10, 20, 30, 40, 50, 60, 70, 80, 90, 95, 99}
+
+// Compute the distribution of a set of fees (as for FeeDist).
+// Percentiles use the nearest-rank method.  Sorts fees in place.
+func NewFeeDist(fees []FeeVal) FeeDist {
+	var ret FeeDist
+	if len(fees) == 0 {
+		return ret
+	}
+	sort.Slice(fees, func(i, j int) bool { return fees[i] < fees[j] })
+	ret.Min, ret.Max = fees[0], fees[len(fees)-1]
+	for i, n, best := 0, 0, 0; i < len(fees); i += n {
+		for n = 1; i+n < len(fees) && fees[i+n] == fees[i]; n++ {
+		}
+		if n > best {
+			ret.Mode, best = fees[i], n
+		}
+	}
+	for _, p := range FeeStatsPercentiles {
+		rank := (p*len(fees) + 99) / 100
+		if rank < 1 {
+			rank = 1
+		}
+		ret.Percentiles = append(ret.Percentiles, FeePercentile{
+			Percentile: p,
+			Fee:        fees[rank-1],
+		})
+	}
+	return ret
+}
+
+// Fee statistics aggregated from the transactions in recent ledgers
+// (see GetFeeHistory).  As with FeeStats, fees are per operation.
+type FeeHistory struct {
+	// Time of the oldest and newest transactions considered
+	Start, End time.Time
+
+	// Range of ledgers from which transactions were considered
+	FirstLedger, LastLedger uint32
+
+	// Number of transactions considered
+	Transactions int
+
+	Charged FeeDist
+	Offered FeeDist
+}
+
+// Conservatively returns a known offered fee for the target or a
+// higher percentile, as with FeeStats.
+func (fh *FeeHistory) Percentile(target int) FeeVal {
+	return fh.Offered.Percentile(target)
+}
+
+func (fh FeeHistory) String() string {
+	out := &strings.Builder{}
+	printFsField(out, "start", fh.Start.Format(time.RFC3339))
+	printFsField(out, "end", fh.End.Format(time.RFC3339))
+	printFsField(out, "first_ledger", fh.FirstLedger)
+	printFsField(out, "last_ledger", fh.LastLedger)
+	printFsField(out, "transactions", fh.Transactions)
+	fh.Charged.withPrefix(out, "fee_charged.")
+	fh.Offered.withPrefix(out, "max_fee.")
+	return out.String()
+}
+
+var errStopIteration = errors.New("stop iteration")
+
+// Aggregates the fees of all transactions submitted to the network
+// during the last window (e.g., 10*time.Minute), to obtain a steadier
+// picture of fees than the single snapshot returned by GetFeeStats.
+// Since this walks horizon's transaction history, it may require many
+// requests for a long window on a busy network.
+func (net *StellarNet) GetFeeHistory(window time.Duration) (
+	*FeeHistory, error) {
+	ret := &FeeHistory{}
+	cutoff := time.Now().Add(-window)
+	var charged, offered []FeeVal
+	err := net.IterateJSON(nil,
+		"transactions?order=desc&limit=200&include_failed=true",
+		func(r *horizon.Transaction) error {
+			if r.Created_at.Before(cutoff) {
+				return errStopIteration
+			}
+			ops := uint64(r.Operation_count)
+			if r.Fee_bump_transaction != nil {
+				// Fee bumps pay for one extra operation
+				ops++
+			}
+			if ops == 0 {
+				return nil
+			}
+			fc, err := strconv.ParseUint(r.Fee_charged.String(), 10, 64)
+			if err != nil {
+				return err
+			}
+			mf, err := strconv.ParseUint(r.Max_fee.String(), 10, 64)
+			if err != nil {
+				return err
+			}
+			charged = append(charged, FeeVal(fc/ops))
+			offered = append(offered, FeeVal(mf/ops))
+			if ret.Transactions == 0 {
+				ret.End, ret.LastLedger = r.Created_at, r.Ledger
+			}
+			ret.Start, ret.FirstLedger = r.Created_at, r.Ledger
+			ret.Transactions++
+			return nil
+		})
+	if err != nil && err != errStopIteration {
+		return nil, err
+	}
+	ret.Charged = NewFeeDist(charged)
+	ret.Offered = NewFeeDist(offered)
+	return ret, nil
+}
+
 func capitalize(s string) string {
 	if len(s) > 0 && s[0] >= 'a' && s[0] <= 'z' {
 		return string(s[0]&^0x20) + s[1:]
@@ -769,11 +1892,21 @@ func capitalize(s string) string {
 	return s
 }
 
-// Queries the network for the latest fee statistics.
+// Queries the network for the latest fee statistics.  If horizon has
+// no fee_stats endpoint, falls back to the base fee of the latest
+// ledger (see BaseFeeStats) and reports the fact through net.Logf.
 func (net *StellarNet) GetFeeStats() (*FeeStats, error) {
 	var ret FeeStats
 	now := time.Now()
-	if err := net.GetJSON("fee_stats", &ret); err != nil {
+	if err := net.GetJSON("fee_stats", &ret); endpointMissing(err) {
+		lh, err := net.GetLedgerHeader()
+		if err != nil {
+			return nil, err
+		}
+		net.logf("%s: horizon has no fee_stats; using base fee of ledger %d",
+			net.Name, lh.LedgerSeq)
+		ret = *BaseFeeStats(lh)
+	} else if err != nil {
 		return nil, err
 	}
 	net.FeeCache = &ret
@@ -781,6 +1914,19 @@ func (net *StellarNet) GetFeeStats() (*FeeStats, error) {
 	return &ret, nil
 }
 
+// Returns fee statistics in which every fee is the base fee of ledger
+// lh, for use when better statistics are unavailable.  Ledger capacity
+// usage is unknown and reported as 0.
+func BaseFeeStats(lh *LedgerHeader) *FeeStats {
+	dist := NewFeeDist([]FeeVal{lh.BaseFee})
+	return &FeeStats{
+		Last_ledger:          uint64(lh.LedgerSeq),
+		Last_ledger_base_fee: lh.BaseFee,
+		Charged:              dist,
+		Offered:              dist,
+	}
+}
+
 // Like GetFeeStats but a version cached for 1 minute
 func (net *StellarNet) GetFeeCache() (*FeeStats, error) {
 	now := time.Now()
@@ -796,27 +1942,209 @@ func (net *StellarNet) GetLedgerHeader() (*LedgerHeader, error) {
 	if err != nil {
 		return nil, err
 	}
+	return parseLatestLedger(body)
+}
 
-	var lhx struct {
-		Embedded struct {
-			Records []struct {
-				Header_xdr string
-			}
-		} `json:"_embedded"`
-	}
-	if err = json.Unmarshal(body, &lhx); err != nil {
+func parseLatestLedger(body []byte) (*LedgerHeader, error) {
+	var lhx horizon.LedgersPage
+	if err := json.Unmarshal(body, &lhx); err != nil {
 		return nil, err
 	} else if len(lhx.Embedded.Records) == 0 {
 		return nil, horizonFailure("Horizon returned no ledgers")
 	}
 
 	ret := &LedgerHeader{}
-	if err = stcdetail.XdrFromBase64(ret, lhx.Embedded.Records[0].Header_xdr); err != nil {
+	if err := stcdetail.XdrFromBase64(ret, lhx.Embedded.Records[0].Header_xdr); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Clock differences larger than this are considered excessive.  The
+// latest ledger normally closed less than 6 seconds ago, and
+// horizon's Date header has a resolution of one second, so any
+// reasonably synchronized clock is well within this bound.  Larger
+// skew causes transactions with time bounds to fail and breaks SEP-10
+// authentication, whose challenges are only valid for a few minutes.
+const MaxClockSkew = 30 * time.Second
+
+// The difference between the local clock and the network's notion of
+// time, as measured by StellarNet.GetClockSkew.  Positive values mean
+// the local clock is ahead.
+type ClockSkew struct {
+	// Local time minus the close time of the latest ledger.  Since
+	// ledgers close every 5-6 seconds, this is usually a few seconds
+	// positive even with a perfectly accurate clock.
+	Ledger time.Duration
+
+	// Local time minus the Date header of horizon's response, or
+	// zero if horizon did not supply one.
+	Horizon time.Duration
+
+	// The latest ledger header, from which Ledger was computed.
+	Header *LedgerHeader
+}
+
+// Returns whichever of s.Ledger and s.Horizon has the larger
+// magnitude.
+func (s *ClockSkew) Max() time.Duration {
+	abs := func(d time.Duration) time.Duration {
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	if abs(s.Horizon) > abs(s.Ledger) {
+		return s.Horizon
+	}
+	return s.Ledger
+}
+
+// True if either measurement exceeds MaxClockSkew.
+func (s *ClockSkew) Excessive() bool {
+	m := s.Max()
+	return m > MaxClockSkew || m < -MaxClockSkew
+}
+
+func (s *ClockSkew) String() string {
+	return fmt.Sprintf("local clock is %s ahead of latest ledger close, " +
+		"%s ahead of horizon", s.Ledger.Round(time.Second),
+		s.Horizon.Round(time.Second))
+}
+
+// Measures the local clock's skew relative to the close time of the
+// latest ledger and to horizon's clock.  Local time is taken halfway
+// through the request to compensate for network latency.
+func (net *StellarNet) GetClockSkew() (*ClockSkew, error) {
+	if net.Horizon == "" {
+		return nil, badHorizonURL
+	}
+	start := time.Now()
+	body, hdr, err := getURLHeader(nil, net.httpClient(),
+		net.retryPolicy(), net.readHorizon() + "ledgers?limit=1&order=desc")
+	if err != nil {
+		return nil, err
+	}
+	now := start.Add(time.Since(start) / 2)
+	lh, err := parseLatestLedger(body)
+	if err != nil {
+		return nil, err
+	}
+	ret := &ClockSkew{
+		Ledger: now.Sub(time.Unix(int64(lh.ScpValue.CloseTime), 0)),
+		Header: lh,
+	}
+	if date, err := http.ParseTime(hdr.Get("Date")); err == nil {
+		ret.Horizon = now.Sub(date)
+	}
+	return ret, nil
+}
+
+// Fetch the header of a particular ledger over the network.
+func (net *StellarNet) GetLedger(seq uint32) (*LedgerHeader, error) {
+	var lhx horizon.Ledger
+	if err := net.GetJSON(fmt.Sprintf("ledgers/%d", seq), &lhx); err != nil {
+		return nil, err
+	}
+	ret := &LedgerHeader{}
+	if err := stcdetail.XdrFromBase64(ret, lhx.Header_xdr); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Summary of the contents of a ledger, as returned by
+// GetLedgerSummary.
+type LedgerSummary struct {
+	Seq    uint32
+	Header *LedgerHeader
+
+	// All transactions in the ledger, including failed ones
+	Txs []HorizonTxResult
+
+	// Number of transactions that failed
+	Failed int
+
+	// Total fees charged by all transactions in the ledger
+	FeeCharged int64
+
+	// Number of operations of each type
+	OpCounts map[stx.OperationType]int
+}
+
+// Returns the operations of a transaction envelope, looking inside
+// fee-bump transactions.
+func envelopeOps(e *stx.TransactionEnvelope) []stx.Operation {
+	if e.Type == stx.ENVELOPE_TYPE_TX_FEE_BUMP {
+		return e.FeeBump().Tx.InnerTx.V1().Tx.Operations
+	} else if ops := e.Operations(); ops != nil {
+		return *ops
+	}
+	return nil
+}
+
+// Fetch the header and all transactions of ledger seq, and tally
+// fees, failures, and operation types.
+func (net *StellarNet) GetLedgerSummary(seq uint32) (*LedgerSummary, error) {
+	hdr, err := net.GetLedger(seq)
+	if err != nil {
+		return nil, err
+	}
+	ret := &LedgerSummary{
+		Seq:      seq,
+		Header:   hdr,
+		OpCounts: make(map[stx.OperationType]int),
+	}
+	err = net.IterateJSON(nil, fmt.Sprintf(
+		"ledgers/%d/transactions?include_failed=true&limit=200", seq),
+		func(r *HorizonTxResult) {
+			ret.Txs = append(ret.Txs, *r)
+			if !r.Success() {
+				ret.Failed++
+			}
+			ret.FeeCharged += r.Result.FeeCharged
+			for _, op := range envelopeOps(&r.Env) {
+				ret.OpCounts[op.Body.Type]++
+			}
+		})
+	if err != nil {
 		return nil, err
 	}
 	return ret, nil
 }
 
+func (ls *LedgerSummary) String() string {
+	out := strings.Builder{}
+	fmt.Fprintf(&out, "ledger: %d\n", ls.Seq)
+	if ls.Header != nil {
+		fmt.Fprintf(&out, "closeTime: %d (%s)\n",
+			ls.Header.ScpValue.CloseTime,
+			time.Unix(int64(ls.Header.ScpValue.CloseTime), 0).Format(
+				time.UnixDate))
+		fmt.Fprintf(&out, "protocolVersion: %d\n",
+			ls.Header.LedgerVersion)
+		fmt.Fprintf(&out, "baseFee: %d\n", ls.Header.BaseFee)
+	}
+	fmt.Fprintf(&out, "transactions: %d (%d failed)\n",
+		len(ls.Txs), ls.Failed)
+	fmt.Fprintf(&out, "feeCharged: %d (%s)\n", ls.FeeCharged,
+		stcdetail.ScaleFmt(ls.FeeCharged, 7))
+	types := make([]stx.OperationType, 0, len(ls.OpCounts))
+	for t := range ls.OpCounts {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if ls.OpCounts[types[i]] != ls.OpCounts[types[j]] {
+			return ls.OpCounts[types[i]] > ls.OpCounts[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	for _, t := range types {
+		fmt.Fprintf(&out, "ops.%s: %d\n", t, ls.OpCounts[t])
+	}
+	return out.String()
+}
+
 type enumComments interface {
 	XdrEnumComments() map[int32]string
 }
@@ -838,93 +2166,332 @@ type TxFailure struct {
 }
 
 type codeExtractor struct {
-	msg string
+	code xdr.XdrEnum
 }
 func (x *codeExtractor) Sprintf(string, ...interface{}) string {
 	return ""
 }
 func (x *codeExtractor) Marshal(name string, val xdr.XdrType) {
-	if x.msg != "" {
+	if x.code != nil {
 		return
 	}
 	switch t := val.(type) {
 	case xdr.XdrEnum:
-		x.msg = enumDesc(t)
+		x.code = t
 	case xdr.XdrAggregate:
 		t.XdrRecurse(x, "")
 	}
 }
 
-func extractCode(t xdr.XdrType) string {
-	e := codeExtractor{}
-	e.Marshal("", t)
-	if e.msg != "" {
-		return e.msg
-	}
+// The failure of one operation in a failed transaction.
+type OperationError struct {
+	// Position of the operation in the transaction.
+	Index int
 
-	out := strings.Builder{}
-	stcdetail.XdrToTxrep(&out, "", t)
-	return strings.TrimSuffix(out.String(), "\n")
+	// The operation's result code.  If the operation was executed,
+	// this is the code specific to its type (e.g.,
+	// stx.PAYMENT_UNDERFUNDED); otherwise it is an
+	// stx.OperationResultCode (e.g., stx.OpNO_ACCOUNT).
+	Code xdr.XdrEnum
 }
 
-func (e TxFailure) Error() string {
-	msg := enumDesc(&e.Result.Code)
+func (oe OperationError) Error() string {
+	return fmt.Sprintf("operation %d: %s", oe.Index, enumDesc(oe.Code))
+}
+
+// Returns the operation results of the failed transaction, or of the
+// inner transaction if a fee bump's inner transaction failed.
+func (e TxFailure) opResults() []stx.OperationResult {
 	switch e.Result.Code {
 	case stx.TxFAILED:
-		out := strings.Builder{}
-		out.WriteString(msg)
-		for i := range *e.Result.Results() {
-			fmt.Fprintf(&out, "\noperation %d: ", i)
-			if code := (*e.Result.Results())[i].Code; code != stx.OpINNER {
-				out.WriteString(enumDesc(&code))
-			} else {
-				out.WriteString(extractCode(
-					(*e.Result.Results())[i].Tr().XdrUnionBody()))
-			}
+		return *e.Result.Results()
+	case stx.TxFEE_BUMP_INNER_FAILED:
+		inner := &e.Result.InnerResultPair().Result.Result
+		if inner.Code == stx.TxFAILED {
+			return *inner.Results()
 		}
-		return out.String()
-	default:
-		return msg
 	}
+	return nil
+}
+
+// Returns the operations that caused the transaction to fail, in
+// order, omitting operations that succeeded.  Returns nil if the
+// transaction failed for a reason other than its operations (e.g., a
+// bad sequence number or insufficient fee).
+func (e TxFailure) OperationErrors() []OperationError {
+	var ret []OperationError
+	results := e.opResults()
+	for i := range results {
+		var code xdr.XdrEnum = &results[i].Code
+		if results[i].Code == stx.OpINNER {
+			x := codeExtractor{}
+			x.Marshal("", results[i].Tr().XdrUnionBody())
+			code = x.code
+		}
+		// Success is 0 for every operation result code
+		if code != nil && code.GetU32() != 0 {
+			ret = append(ret, OperationError{Index: i, Code: code})
+		}
+	}
+	return ret
+}
+
+func (e TxFailure) Error() string {
+	out := strings.Builder{}
+	out.WriteString(enumDesc(&e.Result.Code))
+	if e.Result.Code == stx.TxFEE_BUMP_INNER_FAILED {
+		fmt.Fprintf(&out, "\ninner transaction: %s",
+			enumDesc(&e.Result.InnerResultPair().Result.Result.Code))
+	}
+	for _, oe := range e.OperationErrors() {
+		out.WriteString("\n")
+		out.WriteString(oe.Error())
+	}
+	return out.String()
 }
 
 // Post a new transaction to the network.  In the event that the
 // transaction is successfully submitted to horizon but rejected by
 // the Stellar network, the error will be of type TxFailure, which
-// contains the transaction result.
+// contains the transaction result.  If net.Submissions shows the
+// transaction was already included in a ledger, returns
+// ErrAlreadySubmitted without submitting it again.  If the
+// transaction was not included in a ledger, Post invalidates its
+// source account's sequence number in net.Sequences (see
+// SequenceInvalidator).
 func (net *StellarNet) Post(e *TransactionEnvelope) (
 	*TransactionResult, error) {
-	if net.Horizon == "" {
-		return nil, badHorizonURL
+	return net.PostCtx(nil, e)
+}
+
+// Like Post, but aborts the request if ctx is done.  ctx may be nil.
+// Note that if ctx is done after horizon has received the
+// transaction, the transaction may still execute; use GetTxResult to
+// find out.
+func (net *StellarNet) PostCtx(ctx context.Context,
+	e *TransactionEnvelope) (*TransactionResult, error) {
+	ret, err := net.postCtx(ctx, e)
+	var txf TxFailure
+	var dup ErrAlreadySubmitted
+	if err == nil || errors.As(err, &dup) {
+		return ret, err
+	} else if !errors.As(err, &txf) ||
+		(txf.Result.Code != stx.TxFAILED &&
+			txf.Result.Code != stx.TxFEE_BUMP_INNER_FAILED) {
+		// Not included in a ledger (or, after a timeout, maybe not)
+		net.invalidateSequence(e)
+	}
+	return ret, err
+}
+
+func (net *StellarNet) postCtx(ctx context.Context,
+	e *TransactionEnvelope) (*TransactionResult, error) {
+	var txid string
+	if net.Submissions != nil {
+		txid = fmt.Sprintf("%x", *net.HashTx(e))
+		if err := net.checkSubmitted(ctx, txid); err != nil {
+			return nil, err
+		}
 	}
-	tx := stcdetail.XdrToBase64(e)
-	resp, err := http.PostForm(net.Horizon + "transactions/",
-		url.Values{"tx": {tx}})
+	resp, err := net.postTx(ctx, "transactions/", e)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	js := json.NewDecoder(resp.Body)
-	var res struct {
-		Result_xdr string
-		Extras     struct {
-			Result_xdr string
+	var res horizon.SubmitResponse
+	if err = json.Unmarshal(body, &res); err != nil ||
+		res.ResultXdr() == "" {
+		if resp.StatusCode != 200 {
+			return nil, newHorizonError(resp.StatusCode, body)
+		} else if err == nil {
+			err = horizonFailure("horizon returned no transaction result")
 		}
-	}
-	if err = js.Decode(&res); err != nil {
 		return nil, err
 	}
-	if res.Result_xdr == "" {
-		res.Result_xdr = res.Extras.Result_xdr
-	}
 
 	var ret TransactionResult
-	if err = stcdetail.XdrFromBase64(&ret, res.Result_xdr); err != nil {
+	if err = stcdetail.XdrFromBase64(&ret, res.ResultXdr()); err != nil {
 		return nil, err
 	}
+	net.recordSubmission(txid, res.Transaction.Ledger)
 	if ret.Result.Code != stx.TxSUCCESS {
 		return nil, TxFailure{&ret}
 	}
 	return &ret, nil
 }
+
+// Submits e to horizon endpoint, retrying according to net's
+// RetryPolicy.
+func (net *StellarNet) postTx(ctx context.Context, endpoint string,
+	e *TransactionEnvelope) (*http.Response, error) {
+	if IsReadOnly() {
+		return nil, ErrReadOnly
+	} else if net.Horizon == "" {
+		return nil, badHorizonURL
+	}
+	tx := stcdetail.XdrToBase64(e)
+	req, err := http.NewRequest("POST", net.Horizon + endpoint,
+		strings.NewReader(url.Values{"tx": {tx}}.Encode()))
+	if err != nil {
+		return nil, err
+	} else if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return net.retryPolicy().do(net.httpClient(), req)
+}
+
+// The status of a transaction submitted with PostAsync.
+type TxStatus string
+
+const (
+	// stellar-core accepted the transaction and will try to include
+	// it in a ledger.
+	TxPending TxStatus = "PENDING"
+	// stellar-core had already received the transaction.
+	TxDuplicate TxStatus = "DUPLICATE"
+	// stellar-core is too busy to accept the transaction, which
+	// should be submitted again later.
+	TxTryAgainLater TxStatus = "TRY_AGAIN_LATER"
+	// stellar-core rejected the transaction.
+	TxError TxStatus = "ERROR"
+)
+
+// The outcome of PostAsync.
+type AsyncSubmission struct {
+	// The transaction's hash in hex, for use with WaitForTx.
+	Hash string
+
+	Status TxStatus
+
+	// Why stellar-core rejected the transaction, when Status is
+	// TxError.
+	Result *TransactionResult
+}
+
+// Submit a transaction to the network without waiting for it to be
+// included in a ledger.  Returns as soon as stellar-core has decided
+// whether to accept the transaction; use WaitForTx to learn its
+// result.  The error is nil when Status is TxPending or TxDuplicate;
+// when Status is TxError, it is a TxFailure.  Like Post, PostAsync
+// returns ErrAlreadySubmitted (with Status TxDuplicate) for a
+// transaction that net.Submissions shows was already included in a
+// ledger.  If horizon does not
+// support asynchronous submission, PostAsync falls back to Post, so
+// that a pending transaction has already executed by the time it
+// returns.
+func (net *StellarNet) PostAsync(e *TransactionEnvelope) (
+	*AsyncSubmission, error) {
+	return net.PostAsyncCtx(nil, e)
+}
+
+// Like PostAsync, but aborts the request if ctx is done.  ctx may be
+// nil.
+func (net *StellarNet) PostAsyncCtx(ctx context.Context,
+	e *TransactionEnvelope) (*AsyncSubmission, error) {
+	ret := &AsyncSubmission{Hash: fmt.Sprintf("%x", *net.HashTx(e))}
+	if err := net.checkSubmitted(ctx, ret.Hash); err != nil {
+		if _, ok := err.(ErrAlreadySubmitted); ok {
+			ret.Status = TxDuplicate
+			return ret, err
+		}
+		return nil, err
+	}
+	resp, err := net.postTx(ctx, "transactions_async", e)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var res horizon.AsyncSubmitResponse
+	if err = json.Unmarshal(body, &res); err != nil || res.Tx_status == "" {
+		err = newHorizonError(resp.StatusCode, body)
+		if !endpointMissing(err) {
+			return nil, err
+		}
+		net.logf("horizon does not support asynchronous submission; " +
+			"waiting for transaction")
+		ret.Status = TxPending
+		if _, err = net.PostCtx(ctx, e); err != nil {
+			var txf TxFailure
+			if !errors.As(err, &txf) {
+				return nil, err
+			}
+			ret.Status, ret.Result = TxError, txf.TransactionResult
+		}
+		return ret, err
+	}
+
+	if res.Hash != "" {
+		ret.Hash = res.Hash
+	}
+	ret.Status = TxStatus(res.Tx_status)
+	switch ret.Status {
+	case TxPending, TxDuplicate:
+		net.recordSubmission(ret.Hash, 0)
+		return ret, nil
+	case TxError:
+		ret.Result = &TransactionResult{}
+		if err = stcdetail.XdrFromBase64(ret.Result,
+			res.Error_result_xdr); err != nil {
+			return nil, err
+		}
+		net.invalidateSequence(e)
+		return ret, TxFailure{ret.Result}
+	case TxTryAgainLater:
+		net.invalidateSequence(e)
+		return ret, horizonFailure(
+			"stellar-core is busy; submit the transaction again later")
+	}
+	return ret, horizonFailure(
+		fmt.Sprintf("unknown transaction status %q", res.Tx_status))
+}
+
+// How often WaitForTx asks horizon whether a transaction has been
+// included in a ledger.
+var TxPollInterval = 2 * time.Second
+
+// Wait for the transaction with hex hash txid to be included in a
+// ledger, and return its result.  If the transaction failed, the
+// error is a TxFailure.  A transaction that is never included (e.g.,
+// because it expired or was dropped) causes WaitForTx to wait
+// forever, so use WaitForTxCtx with a deadline unless the transaction
+// has time bounds.
+func (net *StellarNet) WaitForTx(txid string) (*HorizonTxResult, error) {
+	return net.WaitForTxCtx(nil, txid)
+}
+
+// Like WaitForTx, but gives up when ctx is done.  ctx may be nil.
+func (net *StellarNet) WaitForTxCtx(ctx context.Context, txid string) (
+	*HorizonTxResult, error) {
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	for {
+		txr, err := net.GetTxResultCtx(ctx, txid)
+		if err == nil {
+			switch txr.Result.Result.Code {
+			case stx.TxSUCCESS, stx.TxFEE_BUMP_INNER_SUCCESS:
+				return txr, nil
+			}
+			return txr, TxFailure{&txr.Result}
+		} else if !notFound(err) {
+			return nil, err
+		}
+		select {
+		case <-done:
+			return nil, ctx.Err()
+		case <-time.After(TxPollInterval):
+		}
+	}
+}
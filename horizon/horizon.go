@@ -0,0 +1,304 @@
+// Package horizon contains Go types for the JSON resources returned
+// by Stellar's horizon servers.  Fields are named after horizon's
+// JSON keys (capitalized, so encoding/json matches them) and keep
+// horizon's representation:  amounts, 64-bit integers, and XDR are
+// strings, exactly as horizon sends them.  The stc package decodes
+// many of these resources further into XDR types (e.g.,
+// stc.HorizonTxResult and stc.HorizonAccountEntry); use the types in
+// this package when you need a field stc does not expose, or when you
+// want horizon's data verbatim.
+package horizon
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// A hyperlink in a resource's _links object.  If Templated is true,
+// Href is a URI template such as ".../transactions{?cursor,limit}".
+type Link struct {
+	Href      string
+	Templated bool
+}
+
+// The links of a page of a collection.
+type PageLinks struct {
+	Self Link
+	Next Link
+	Prev Link
+}
+
+// A page of any collection, with the records left undecoded.  See
+// the typed pages (e.g., LedgersPage) for particular collections.
+type Page struct {
+	Links    PageLinks `json:"_links"`
+	Embedded struct {
+		Records []json.RawMessage
+	} `json:"_embedded"`
+}
+
+// A ledger, as returned by the ledgers endpoints.
+type Ledger struct {
+	Id                           string
+	Paging_token                 string
+	Hash                         string
+	Prev_hash                    string
+	Sequence                     uint32
+	Successful_transaction_count int32
+	Failed_transaction_count     int32
+	Operation_count              int32
+	Tx_set_operation_count       int32
+	Closed_at                    time.Time
+	Total_coins                  string
+	Fee_pool                     string
+	Base_fee_in_stroops          uint32
+	Base_reserve_in_stroops      uint32
+	Max_tx_set_size              uint32
+	Protocol_version             uint32
+
+	// Base64 XDR of the stx.LedgerHeader.
+	Header_xdr string
+}
+
+type LedgersPage struct {
+	Links    PageLinks `json:"_links"`
+	Embedded struct {
+		Records []Ledger
+	} `json:"_embedded"`
+}
+
+// The fee-bump portion of a fee-bump transaction.
+type FeeBumpTransaction struct {
+	Hash       string
+	Signatures []string
+}
+
+// The inner transaction of a fee-bump transaction.
+type InnerTransaction struct {
+	Hash       string
+	Signatures []string
+	Max_fee    json.Number
+}
+
+// A transaction's time bounds.  Horizon renders the times as decimal
+// strings of Unix time, with an empty Max_time meaning no bound.
+type TimeBounds struct {
+	Min_time string
+	Max_time string
+}
+
+// A transaction's ledger bounds.
+type LedgerBounds struct {
+	Min_ledger uint32
+	Max_ledger uint32
+}
+
+// A transaction's preconditions (protocol 19 and later).
+type Preconditions struct {
+	Timebounds                      *TimeBounds
+	Ledgerbounds                    *LedgerBounds
+	Min_account_sequence            string
+	Min_account_sequence_age        string
+	Min_account_sequence_ledger_gap uint32
+	Extra_signers                   []string
+}
+
+// A transaction, as returned by the transactions endpoints and by a
+// successful submission.
+type Transaction struct {
+	Id                      string
+	Paging_token            string
+	Successful              bool
+	Hash                    string
+	Ledger                  uint32
+	Created_at              time.Time
+	Source_account          string
+	Account_muxed           string
+	Account_muxed_id        string
+	Source_account_sequence string
+	Fee_account             string
+	Fee_account_muxed       string
+	Fee_account_muxed_id    string
+
+	// Horizon renders these as strings in some versions and numbers
+	// in others.
+	Fee_charged json.Number
+	Max_fee     json.Number
+
+	Operation_count uint32
+	Envelope_xdr    string
+	Result_xdr      string
+	Result_meta_xdr string
+	Fee_meta_xdr    string
+	Memo_type       string
+	Memo            string
+	Memo_bytes      string
+	Signatures      []string
+	Valid_after     string
+	Valid_before    string
+	Preconditions   *Preconditions
+
+	// Non-nil only for fee-bump transactions.
+	Fee_bump_transaction *FeeBumpTransaction
+	Inner_transaction    *InnerTransaction
+}
+
+type TransactionsPage struct {
+	Links    PageLinks `json:"_links"`
+	Embedded struct {
+		Records []Transaction
+	} `json:"_embedded"`
+}
+
+// The result codes of a failed transaction submission, in horizon's
+// string form (e.g., "tx_failed" and "op_underfunded").
+type ResultCodes struct {
+	Transaction       string
+	Inner_transaction string
+	Operations        []string
+}
+
+// Extra information in a Problem returned by transaction submission.
+type ProblemExtras struct {
+	Envelope_xdr string
+	Result_xdr   string
+	Result_codes *ResultCodes
+	Hash         string
+}
+
+// An error response (RFC 7807 "problem details"), which horizon
+// returns with content type application/problem+json.
+type Problem struct {
+	Type     string
+	Title    string
+	Status   int
+	Detail   string
+	Instance string
+	Extras   *ProblemExtras
+}
+
+// The response to a transaction submission, which is a Transaction on
+// success and a Problem on failure.  Use ResultXdr to get the
+// transaction result in either case.
+type SubmitResponse struct {
+	Transaction
+	Problem
+}
+
+// Returns the base64 XDR of the stx.TransactionResult, or "" if the
+// response does not contain one.
+func (r *SubmitResponse) ResultXdr() string {
+	if r.Transaction.Result_xdr != "" {
+		return r.Transaction.Result_xdr
+	} else if r.Extras != nil {
+		return r.Extras.Result_xdr
+	}
+	return ""
+}
+
+// The response to an asynchronous transaction submission (the
+// transactions_async endpoint).  Tx_status is "PENDING", "DUPLICATE",
+// "TRY_AGAIN_LATER", or "ERROR".  For "ERROR", Error_result_xdr is
+// the base64 XDR of the stx.TransactionResult.
+type AsyncSubmitResponse struct {
+	Tx_status        string
+	Hash             string
+	Error_result_xdr string
+}
+
+// The thresholds of an account.
+type Thresholds struct {
+	Low_threshold  uint8
+	Med_threshold  uint8
+	High_threshold uint8
+}
+
+// The flags of an account.
+type Flags struct {
+	Auth_required         bool
+	Auth_revocable        bool
+	Auth_immutable        bool
+	Auth_clawback_enabled bool
+}
+
+// A balance of an account.  Asset_type is "native", "credit_alphanum4",
+// "credit_alphanum12", or "liquidity_pool_shares" (in which case
+// Liquidity_pool_id is set instead of Asset_code and Asset_issuer).
+type Balance struct {
+	Balance                               string
+	Limit                                 string
+	Buying_liabilities                    string
+	Selling_liabilities                   string
+	Sponsor                               string
+	Last_modified_ledger                  uint32
+	Is_authorized                         bool
+	Is_authorized_to_maintain_liabilities bool
+	Is_clawback_enabled                   bool
+	Asset_type                            string
+	Asset_code                            string
+	Asset_issuer                          string
+	Liquidity_pool_id                     string
+}
+
+// A signer of an account.  Type is "ed25519_public_key", "sha256_hash",
+// "preauth_tx", or "ed25519_signed_payload".
+type Signer struct {
+	Key     string
+	Weight  uint32
+	Type    string
+	Sponsor string
+}
+
+// An account, as returned by the accounts endpoints.
+type Account struct {
+	Id                    string
+	Account_id            string
+	Paging_token          string
+	Sequence              string
+	Sequence_ledger       uint32
+	Sequence_time         string
+	Subentry_count        int32
+	Inflation_destination string
+	Home_domain           string
+	Last_modified_ledger  uint32
+	Last_modified_time    time.Time
+	Thresholds            Thresholds
+	Flags                 Flags
+	Balances              []Balance
+	Signers               []Signer
+	Num_sponsoring        uint32
+	Num_sponsored         uint32
+	Sponsor               string
+
+	// Values are base64.
+	Data map[string]string
+}
+
+// An asset as horizon renders it in offers, with Asset_type "native",
+// "credit_alphanum4", or "credit_alphanum12".
+type Asset struct {
+	Asset_type   string
+	Asset_code   string
+	Asset_issuer string
+}
+
+// A price as the rational number N/D.
+type Price struct {
+	N json.Number
+	D json.Number
+}
+
+// An offer, as returned by the offers endpoints.
+type Offer struct {
+	Id                   json.Number
+	Paging_token         string
+	Seller               string
+	Selling              Asset
+	Buying               Asset
+	Amount               string
+	Price_r              Price
+	Price                string
+	Last_modified_ledger uint32
+	Last_modified_time   time.Time
+	Sponsor              string
+}
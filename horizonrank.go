@@ -0,0 +1,177 @@
+package stc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// The latency and health of a horizon server, as measured by
+// ProbeHorizons.
+type HorizonHealth struct {
+	// Base URL of the server.
+	URL string
+
+	// Time taken to fetch the server's root resource.
+	Latency time.Duration
+
+	// The latest ledger the server has ingested.
+	Ledger uint32
+
+	// Why the server is unhealthy, or nil if it is healthy.
+	Err error
+}
+
+// A horizon server more than this many ledgers behind the most
+// up-to-date server of its network is considered unhealthy.
+var MaxHorizonLag uint32 = 5
+
+// How often reads re-measure the horizon servers when mirrors are
+// configured, and how long each measurement may take.
+var HorizonProbeInterval = 5 * time.Minute
+var HorizonProbeTimeout = 5 * time.Second
+
+// The outcome of the most recent ProbeHorizons.
+type horizonRanking struct {
+	lock    sync.Mutex
+	best    string
+	probed  time.Time
+	probing bool
+}
+
+// Returns net's horizon servers:  Horizon followed by HorizonMirrors.
+func (net *StellarNet) horizons() []string {
+	ret := make([]string, 0, 1+len(net.HorizonMirrors))
+	if net.Horizon != "" {
+		ret = append(ret, net.Horizon)
+	}
+	return append(ret, net.HorizonMirrors...)
+}
+
+// Returns the base URL of the horizon server to read from, which is
+// the fastest healthy server found by the last ProbeHorizons, or
+// net.Horizon if there has been no probe or no server was healthy.
+// If net has mirrors and the last probe is older than
+// HorizonProbeInterval, starts a new probe in the background, so
+// that servers are only measured by programs that use the network
+// and reads never wait for the measurements.
+func (net *StellarNet) readHorizon() string {
+	net.ranking.lock.Lock()
+	defer net.ranking.lock.Unlock()
+	if len(net.HorizonMirrors) > 0 && !net.ranking.probing &&
+		time.Since(net.ranking.probed) > HorizonProbeInterval {
+		net.ranking.probing = true
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(),
+				HorizonProbeTimeout)
+			defer cancel()
+			net.ProbeHorizons(ctx)
+		}()
+	}
+	if net.ranking.best != "" {
+		return net.ranking.best
+	}
+	return net.Horizon
+}
+
+// Measures the latency and health of net.Horizon and each of
+// net.HorizonMirrors concurrently, and directs subsequent reads
+// (though not transaction submissions or sequence number lookups,
+// which always go to net.Horizon) to the fastest healthy server.
+// A server is healthy if it responds, serves the network identified
+// by net.NetworkId, and is no more than MaxHorizonLag ledgers behind
+// the other servers.  Returns the measurements in the same order as
+// the servers.  Reads call this automatically in the background (see
+// HorizonProbeInterval), so there is normally no need to call it
+// except to report the measurements.
+func (net *StellarNet) ProbeHorizons(ctx context.Context) []HorizonHealth {
+	urls := net.horizons()
+	ret := make([]HorizonHealth, len(urls))
+	var wg sync.WaitGroup
+	for i := range urls {
+		ret[i].URL = urls[i]
+		wg.Add(1)
+		go func(h *HorizonHealth) {
+			defer wg.Done()
+			start := time.Now()
+			body, _, err := getURLHeader(ctx, net.httpClient(),
+				&RetryPolicy{}, h.URL)
+			h.Latency = time.Since(start)
+			var root HorizonRoot
+			if err == nil {
+				err = json.Unmarshal(body, &root)
+			}
+			if err == nil && net.NetworkId != "" &&
+				root.Network_passphrase != net.NetworkId {
+				err = fmt.Errorf("serves network %q",
+					root.Network_passphrase)
+			}
+			h.Ledger, h.Err = root.History_latest_ledger, err
+		}(&ret[i])
+	}
+	wg.Wait()
+
+	var latest uint32
+	for i := range ret {
+		if ret[i].Err == nil && ret[i].Ledger > latest {
+			latest = ret[i].Ledger
+		}
+	}
+	var best *HorizonHealth
+	for i := range ret {
+		h := &ret[i]
+		if h.Err == nil && h.Ledger+MaxHorizonLag < latest {
+			h.Err = fmt.Errorf("%d ledgers behind", latest-h.Ledger)
+		}
+		if h.Err == nil && (best == nil || h.Latency < best.Latency) {
+			best = h
+		}
+	}
+
+	net.ranking.lock.Lock()
+	defer net.ranking.lock.Unlock()
+	if best != nil {
+		net.ranking.best = best.URL
+	} else {
+		net.ranking.best = ""
+	}
+	net.ranking.probed, net.ranking.probing = time.Now(), false
+	return ret
+}
+
+// Like GetAccountEntryCtx, but always reads from net.Horizon, where
+// Post submits transactions, since a mirror up to MaxHorizonLag
+// ledgers behind could return a stale sequence number.
+func (net *StellarNet) getSubmitAccountEntry(ctx context.Context,
+	acct string) (*HorizonAccountEntry, error) {
+	ret := HorizonAccountEntry{Net: net}
+	if body, err := net.getFrom(ctx, net.Horizon,
+		"accounts/"+acct); err != nil {
+		return nil, err
+	} else if err = json.Unmarshal(body, &ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// Calls ProbeHorizons in the background now and every interval
+// thereafter, until ctx (which may be nil to probe forever) is done.
+func (net *StellarNet) ProbeHorizonsEvery(ctx context.Context,
+	interval time.Duration) {
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	go func() {
+		for {
+			net.ProbeHorizons(ctx)
+			select {
+			case <-done:
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
@@ -0,0 +1,74 @@
+// Package singleflight suppresses duplicate concurrent calls, in the
+// style of golang.org/x/sync/singleflight (on which stc avoids
+// depending).
+package singleflight
+
+import (
+	"sync"
+)
+
+// Suppresses duplicate concurrent calls with the same key.  The zero
+// value is ready to use.  A Group must not be copied after first use.
+type Group struct {
+	lock  sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done chan struct{}
+	dups int
+	val  interface{}
+	err  error
+}
+
+// Calls fn and returns its results, unless a call with the same key is
+// already in progress, in which case Do waits for that call and
+// returns its results instead.  shared is true if the results were
+// returned to more than one caller, in which case the caller must not
+// modify val.
+func (sf *Group) Do(key string, fn func() (interface{}, error)) (
+	val interface{}, err error, shared bool) {
+	sf.lock.Lock()
+	if c, ok := sf.calls[key]; ok {
+		c.dups++
+		sf.lock.Unlock()
+		<-c.done
+		return c.val, c.err, true
+	}
+	if sf.calls == nil {
+		sf.calls = make(map[string]*flightCall)
+	}
+	c := &flightCall{done: make(chan struct{})}
+	sf.calls[key] = c
+	sf.lock.Unlock()
+
+	defer func() {
+		sf.lock.Lock()
+		delete(sf.calls, key)
+		shared = c.dups > 0
+		sf.lock.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = fn()
+	return c.val, c.err, false
+}
+
+// The results of a call made through DoChan.
+type Result struct {
+	Val    interface{}
+	Err    error
+	Shared bool
+}
+
+// Like Do, but returns immediately, delivering the results on the
+// returned channel once they are ready.  Callers that stop waiting do
+// not cancel the call, which still completes for the other callers.
+func (sf *Group) DoChan(key string,
+	fn func() (interface{}, error)) <-chan Result {
+	ch := make(chan Result, 1)
+	go func() {
+		val, err, shared := sf.Do(key, fn)
+		ch <- Result{val, err, shared}
+	}()
+	return ch
+}
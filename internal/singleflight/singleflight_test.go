@@ -0,0 +1,64 @@
+package singleflight
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSingleFlight(t *testing.T) {
+	var sf Group
+	var calls, nshared int32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, shared := sf.Do("k", func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return 7, nil
+			})
+			if err != nil || v.(int) != 7 {
+				t.Errorf("Do returned %v, %v", v, err)
+			}
+			if shared {
+				atomic.AddInt32(&nshared, 1)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls < 1 || calls+nshared < 8 {
+		t.Errorf("%d calls, %d shared results", calls, nshared)
+	}
+	if calls == 8 {
+		t.Error("no calls were coalesced")
+	}
+}
+
+func TestDoChan(t *testing.T) {
+	var sf Group
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		return 7, nil
+	}
+	a := sf.DoChan("k", fn)
+	time.Sleep(20 * time.Millisecond)
+	b := sf.DoChan("k", fn)
+	select {
+	case <-a:
+		t.Fatal("DoChan result ready before call finished")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	for _, ch := range []<-chan Result{a, b} {
+		if r := <-ch; r.Err != nil || r.Val.(int) != 7 || !r.Shared {
+			t.Errorf("DoChan returned %+v", r)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package stc
+
+import (
+	"fmt"
+	"github.com/xdrpp/stc/stx"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Fetches the constant-product liquidity pool for assets a and b (in
+// either order).
+func (net *StellarNet) GetLiquidityPoolFor(a, b stx.Asset) (
+	*HorizonLiquidityPool, error) {
+	id := LiquidityPoolID(a, b)
+	return net.GetLiquidityPool(fmt.Sprintf("%x", id[:]))
+}
+
+// Returns the pool's reserves of its assets A and B, in the order
+// used by LIQUIDITY_POOL_DEPOSIT and LIQUIDITY_POOL_WITHDRAW
+// operations (see NewLiquidityPoolParameters).
+func (lp *HorizonLiquidityPool) ReservesAB() (a, b HorizonReserve,
+	err error) {
+	if len(lp.Reserves) != 2 {
+		return a, b, fmt.Errorf("liquidity pool has %d reserves, "+
+			"should have 2", len(lp.Reserves))
+	}
+	a, b = lp.Reserves[0], lp.Reserves[1]
+	cp := NewLiquidityPoolParameters(a.Asset, b.Asset).ConstantProduct()
+	if a.Asset.String() != cp.AssetA.String() {
+		a, b = b, a
+	}
+	return a, b, nil
+}
+
+// Returns the closest price to r whose numerator and denominator fit
+// in an int32, computed from the continued fraction expansion of r.
+func ratToPrice(r *big.Rat) (stx.Price, error) {
+	if r.Sign() <= 0 {
+		return stx.Price{}, fmt.Errorf("price %s is not positive",
+			r.RatString())
+	}
+	max := big.NewInt(math.MaxInt32)
+	h, h1 := big.NewInt(1), big.NewInt(0)
+	k, k1 := big.NewInt(0), big.NewInt(1)
+	x := new(big.Rat).Set(r)
+	var ret stx.Price
+	for {
+		a := new(big.Int).Quo(x.Num(), x.Denom())
+		nh := new(big.Int).Add(new(big.Int).Mul(a, h), h1)
+		nk := new(big.Int).Add(new(big.Int).Mul(a, k), k1)
+		if nh.Cmp(max) > 0 || nk.Cmp(max) > 0 {
+			break
+		}
+		h, h1, k, k1 = nh, h, nk, k
+		ret = stx.Price{N: int32(h.Int64()), D: int32(k.Int64())}
+		x.Sub(x, new(big.Rat).SetInt(a))
+		if x.Sign() == 0 {
+			break
+		}
+		x.Inv(x)
+	}
+	if ret.N <= 0 || ret.D <= 0 {
+		return stx.Price{}, fmt.Errorf("price %s cannot be represented",
+			r.RatString())
+	}
+	return ret, nil
+}
+
+// Returns slippage as an exact fraction, converting from the shortest
+// decimal representation so that, e.g., 0.01 is exactly 1/100.
+func slippageRat(slippage float64) (*big.Rat, error) {
+	if !(slippage >= 0 && slippage < 1) {
+		return nil, fmt.Errorf("slippage %g must be at least 0 and "+
+			"less than 1", slippage)
+	}
+	ret, _ := new(big.Rat).SetString(
+		strconv.FormatFloat(slippage, 'g', -1, 64))
+	return ret, nil
+}
+
+// Returns a LIQUIDITY_POOL_DEPOSIT operation depositing up to maxA of
+// the pool's asset A and maxB of its asset B (see ReservesAB), which
+// fails if the pool's price of A in terms of B moves by more than a
+// fraction slippage (e.g., 0.01 for 1%) from its current price
+// according to the pool's reserves.  If the pool is empty, the
+// deposit sets the price, so the bounds are computed from maxA/maxB
+// instead.
+func (lp *HorizonLiquidityPool) DepositOp(maxA, maxB int64,
+	slippage float64) (LiquidityPoolDeposit, error) {
+	ret := LiquidityPoolDeposit{
+		LiquidityPoolID: lp.Id,
+		MaxAmountA:      maxA,
+		MaxAmountB:      maxB,
+	}
+	s, err := slippageRat(slippage)
+	if err != nil {
+		return ret, err
+	} else if maxA <= 0 || maxB <= 0 {
+		return ret, fmt.Errorf("deposit amounts must be positive")
+	}
+	a, b, err := lp.ReservesAB()
+	if err != nil {
+		return ret, err
+	}
+	price := big.NewRat(int64(a.Amount), 1)
+	if a.Amount > 0 && b.Amount > 0 {
+		price.Quo(price, big.NewRat(int64(b.Amount), 1))
+	} else {
+		price = big.NewRat(maxA, maxB)
+	}
+	one := big.NewRat(1, 1)
+	min := new(big.Rat).Mul(price, new(big.Rat).Sub(one, s))
+	max := new(big.Rat).Mul(price, new(big.Rat).Add(one, s))
+	if ret.MinPrice, err = ratToPrice(min); err != nil {
+		return ret, err
+	} else if ret.MaxPrice, err = ratToPrice(max); err != nil {
+		return ret, err
+	}
+	return ret, nil
+}
+
+// Returns a LIQUIDITY_POOL_WITHDRAW operation redeeming shares pool
+// shares, which fails unless it yields at least the current value of
+// the shares in each of the pool's assets less a fraction slippage
+// (e.g., 0.01 for 1%).
+func (lp *HorizonLiquidityPool) WithdrawOp(shares int64,
+	slippage float64) (LiquidityPoolWithdraw, error) {
+	ret := LiquidityPoolWithdraw{
+		LiquidityPoolID: lp.Id,
+		Amount:          shares,
+	}
+	s, err := slippageRat(slippage)
+	if err != nil {
+		return ret, err
+	} else if shares <= 0 || shares > int64(lp.Total_shares) {
+		return ret, fmt.Errorf("cannot withdraw %s of %s pool shares",
+			FormatAmount(shares), FormatAmount(int64(lp.Total_shares)))
+	}
+	a, b, err := lp.ReservesAB()
+	if err != nil {
+		return ret, err
+	}
+	frac := big.NewRat(shares, int64(lp.Total_shares))
+	frac.Mul(frac, new(big.Rat).Sub(big.NewRat(1, 1), s))
+	min := func(reserve int64) int64 {
+		r := new(big.Rat).Mul(frac, big.NewRat(reserve, 1))
+		return new(big.Int).Quo(r.Num(), r.Denom()).Int64()
+	}
+	ret.MinAmountA = min(int64(a.Amount))
+	ret.MinAmountB = min(int64(b.Amount))
+	return ret, nil
+}
@@ -0,0 +1,218 @@
+package stc
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The version of the configuration directory layout ($STCDIR) that
+// this version of stc reads and writes.  It is recorded in the file
+// "version" in the directory; a directory without that file predates
+// versioning and is version 0.
+const ConfigVersion = 1
+
+// A step upgrading the configuration directory from one version to
+// the next.  configMigrations[i] upgrades version i to version i+1,
+// so len(configMigrations) must equal ConfigVersion.
+type configMigration struct {
+	desc string
+	run  func(dir string) error
+}
+
+var configMigrations = []configMigration{
+	{"make private keys readable only by their owner", restrictKeys},
+}
+
+// Makes dir/keys and the private keys in it accessible only by their
+// owner.
+func restrictKeys(dir string) error {
+	keydir := filepath.Join(dir, "keys")
+	if _, err := os.Stat(keydir); os.IsNotExist(err) {
+		return nil
+	} else if err = os.Chmod(keydir, 0700); err != nil {
+		return err
+	}
+	fis, err := ioutil.ReadDir(keydir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		if fi.Mode().IsRegular() && fi.Mode().Perm()&0077 != 0 {
+			err = os.Chmod(filepath.Join(keydir, fi.Name()),
+				fi.Mode().Perm()&0700)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Error returned when the configuration directory was written by a
+// newer version of stc, whose layout this version does not understand.
+type ErrConfigTooNew struct {
+	Dir     string
+	Version int
+}
+
+func (e ErrConfigTooNew) Error() string {
+	return fmt.Sprintf("%s has version %d, but this stc only supports "+
+		"version %d or older; upgrade stc", e.Dir, e.Version, ConfigVersion)
+}
+
+const configVersionFile = "version"
+
+// Returns the layout version of configuration directory dir.
+func ReadConfigVersion(dir string) (int, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(dir, configVersionFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("%s: invalid version %q",
+			filepath.Join(dir, configVersionFile), contents)
+	}
+	return v, nil
+}
+
+func writeConfigVersion(dir string) error {
+	return ioutil.WriteFile(filepath.Join(dir, configVersionFile),
+		[]byte(strconv.Itoa(ConfigVersion)+"\n"), 0666)
+}
+
+// Recursively copies the contents of directory src into empty
+// directory dst, preserving permissions and symbolic links.
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, fi os.FileInfo,
+		err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch mode := fi.Mode(); {
+		case mode.IsDir() && rel == ".":
+			return os.Chmod(target, mode.Perm()|0700)
+		case mode.IsDir():
+			return os.Mkdir(target, mode.Perm()|0700)
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err == nil {
+				err = os.Symlink(link, target)
+			}
+			return err
+		case mode.IsRegular():
+			in, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+			out, err := os.OpenFile(target,
+				os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode.Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, in)
+			if err2 := out.Close(); err == nil {
+				err = err2
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// Upgrades configuration directory dir to ConfigVersion.  The
+// migrations run on a copy of the directory, which replaces dir only
+// once every migration has succeeded, so a failure leaves dir
+// unchanged.  The original directory is kept alongside it, as
+// dir.vN-TIME where N is its old version, but made accessible only by
+// its owner, since it still contains private keys.
+func migrateConfigDir(dir string) error {
+	if phys, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = phys
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	v, err := ReadConfigVersion(dir)
+	if err != nil {
+		return err
+	} else if v > ConfigVersion {
+		return ErrConfigTooNew{Dir: dir, Version: v}
+	} else if v == ConfigVersion {
+		return nil
+	}
+
+	// Creating tmp also keeps concurrent processes from migrating.
+	tmp := dir + ".migrating"
+	if err = os.Mkdir(tmp, 0700); os.IsExist(err) {
+		return fmt.Errorf("%s exists; another stc may be upgrading %s "+
+			"(if not, remove %s)", tmp, dir, tmp)
+	} else if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	// Another process may have upgraded dir before we created tmp
+	if v, err = ReadConfigVersion(dir); err != nil {
+		return err
+	} else if v > ConfigVersion {
+		return ErrConfigTooNew{Dir: dir, Version: v}
+	} else if v == ConfigVersion {
+		return nil
+	} else if err = copyTree(dir, tmp); err != nil {
+		return err
+	}
+	from := v
+	for ; v < ConfigVersion; v++ {
+		if err = configMigrations[v].run(tmp); err != nil {
+			return fmt.Errorf("upgrading %s to version %d (%s): %w",
+				dir, v+1, configMigrations[v].desc, err)
+		}
+	}
+	if err = writeConfigVersion(tmp); err != nil {
+		return err
+	}
+
+	backup := fmt.Sprintf("%s.v%d-%s", dir, from,
+		time.Now().Format("20060102T150405"))
+	if err = os.Rename(dir, backup); err != nil {
+		return err
+	} else if err = os.Rename(tmp, dir); err != nil {
+		os.Rename(backup, dir)
+		return err
+	}
+	if err = os.Chmod(backup, 0700); err != nil {
+		return err
+	}
+	return restrictKeys(backup)
+}
+
+var migrateOnce sync.Once
+var migrateErr error
+
+// Checks the version of the configuration directory (ConfigPath()),
+// upgrading it if it was written by an older version of stc.  Returns
+// ErrConfigTooNew if it was written by a newer version.  The check is
+// only made once per process; later calls return the same result.
+// LoadNetworks().Get, and hence DefaultStellarNet, call this
+// automatically.
+func MigrateConfigDir() error {
+	migrateOnce.Do(func() {
+		migrateErr = migrateConfigDir(getConfigDir(true))
+	})
+	return migrateErr
+}
@@ -0,0 +1,102 @@
+package stc
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"github.com/xdrpp/stc/stcdetail"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// Key file header naming the mnemonic from which a key was derived.
+const MnemonicHeader = "Stc-Mnemonic"
+
+// Key file header recording the SLIP-10 path along which a key was
+// derived from its mnemonic, e.g., "m/44'/148'/3'".
+const DerivationPathHeader = "Stc-Derivation-Path"
+
+// A BIP-39 mnemonic with its optional passphrase, from which SEP-5
+// derives a family of Stellar accounts.  This lets users back up a
+// single mnemonic instead of one seed per account, and is compatible
+// with wallets that implement SEP-5.
+type Mnemonic struct {
+	Words      string
+	Passphrase string
+}
+
+var InvalidMnemonicFile = errors.New("Invalid mnemonic file")
+
+// Returns a Mnemonic after checking and normalizing its words (see
+// stcdetail.NormalizeMnemonic).
+func NewMnemonic(words, passphrase string) (*Mnemonic, error) {
+	norm, err := stcdetail.NormalizeMnemonic(words)
+	if err != nil {
+		return nil, err
+	}
+	return &Mnemonic{Words: norm, Passphrase: passphrase}, nil
+}
+
+// Returns the private key of account number index, which SEP-5
+// derives along SLIP-10 path m/44'/148'/index'.  Index 0 is the
+// account most wallets show first.
+func (m *Mnemonic) Key(index uint32) PrivateKey {
+	seed := stcdetail.SLIP10Ed25519(
+		stcdetail.MnemonicSeed(m.Words, m.Passphrase),
+		stcdetail.SEP5Path(index)...)
+	return PrivateKey{stcdetail.Ed25519Priv(ed25519.NewKeyFromSeed(seed))}
+}
+
+// Returns the headers recording that a key is account number index of
+// the mnemonic called name, for use with PrivateKey.SaveWithHeaders.
+func DerivedKeyHeaders(name string, index uint32) map[string]string {
+	return map[string]string{
+		MnemonicHeader: name,
+		DerivationPathHeader: stcdetail.SLIP10PathString(
+			stcdetail.SEP5Path(index)),
+	}
+}
+
+// Writes a mnemonic to a file, with the passphrase (if any) on a
+// second line.  As with PrivateKey.Save, the file is encrypted if
+// passphrase has non-zero length.
+func (m *Mnemonic) Save(file string, passphrase []byte) error {
+	return saveSecret(file, m.secret(), passphrase, nil)
+}
+
+// Like Save, but encrypts the mnemonic with the passphrase in a
+// password store entry (see PrivateKey.SaveWithPassPassphrase).
+func (m *Mnemonic) SaveWithPassPassphrase(file string, entry string) error {
+	return saveSecret(file, m.secret(), nil,
+		map[string]string{PassPassphraseHeader: entry})
+}
+
+func (m *Mnemonic) secret() string {
+	if m.Passphrase != "" {
+		return m.Words + "\n" + m.Passphrase
+	}
+	return m.Words
+}
+
+// Reads a mnemonic saved by Mnemonic.Save, prompting for a passphrase
+// if the file is encrypted.
+func LoadMnemonic(file string) (*Mnemonic, error) {
+	input, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if plain, err := decryptSecret(file, input); err == nil {
+		input = plain
+	} else if err != io.EOF {
+		return nil, err
+	}
+	lines := strings.SplitN(strings.TrimRight(string(input), "\n"), "\n", 2)
+	m, err := NewMnemonic(lines[0], "")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, InvalidMnemonicFile)
+	} else if len(lines) > 1 {
+		m.Passphrase = lines[1]
+	}
+	return m, nil
+}
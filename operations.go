@@ -0,0 +1,432 @@
+package stc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/xdrpp/stc/stcdetail"
+	"github.com/xdrpp/stc/stx"
+	"reflect"
+	"time"
+)
+
+// An operation as returned by horizon's operations endpoints.  The
+// fields common to all operations are decoded directly, while the
+// type-specific fields are decoded into Details.
+type HorizonOperation struct {
+	Net                    *StellarNet `json:"-"`
+	Id                     string
+	Paging_token           string
+	Transaction_successful bool
+	Source_account         AccountID
+	Type                   string
+	Type_i                 stx.OperationType
+	Created_at             time.Time
+	Transaction_hash       string
+
+	// A pointer to the Horizon*Op structure corresponding to Type_i
+	// (e.g., *HorizonPaymentOp for PAYMENT), or a json.RawMessage
+	// containing the whole operation for types stc does not know.
+	Details interface{} `json:"-"`
+}
+
+var horizonOpTypes = map[stx.OperationType]reflect.Type{
+	stx.CREATE_ACCOUNT:                   reflect.TypeOf(HorizonCreateAccountOp{}),
+	stx.PAYMENT:                          reflect.TypeOf(HorizonPaymentOp{}),
+	stx.PATH_PAYMENT_STRICT_RECEIVE:      reflect.TypeOf(HorizonPathPaymentOp{}),
+	stx.PATH_PAYMENT_STRICT_SEND:         reflect.TypeOf(HorizonPathPaymentOp{}),
+	stx.MANAGE_SELL_OFFER:                reflect.TypeOf(HorizonManageOfferOp{}),
+	stx.MANAGE_BUY_OFFER:                 reflect.TypeOf(HorizonManageOfferOp{}),
+	stx.CREATE_PASSIVE_SELL_OFFER:        reflect.TypeOf(HorizonManageOfferOp{}),
+	stx.SET_OPTIONS:                      reflect.TypeOf(HorizonSetOptionsOp{}),
+	stx.CHANGE_TRUST:                     reflect.TypeOf(HorizonChangeTrustOp{}),
+	stx.ALLOW_TRUST:                      reflect.TypeOf(HorizonAllowTrustOp{}),
+	stx.ACCOUNT_MERGE:                    reflect.TypeOf(HorizonAccountMergeOp{}),
+	stx.MANAGE_DATA:                      reflect.TypeOf(HorizonManageDataOp{}),
+	stx.BUMP_SEQUENCE:                    reflect.TypeOf(HorizonBumpSequenceOp{}),
+	stx.CREATE_CLAIMABLE_BALANCE:         reflect.TypeOf(HorizonCreateClaimableBalanceOp{}),
+	stx.CLAIM_CLAIMABLE_BALANCE:          reflect.TypeOf(HorizonClaimClaimableBalanceOp{}),
+	stx.BEGIN_SPONSORING_FUTURE_RESERVES: reflect.TypeOf(HorizonBeginSponsoringOp{}),
+	stx.END_SPONSORING_FUTURE_RESERVES:   reflect.TypeOf(HorizonEndSponsoringOp{}),
+	stx.REVOKE_SPONSORSHIP:               reflect.TypeOf(HorizonRevokeSponsorshipOp{}),
+	stx.CLAWBACK:                         reflect.TypeOf(HorizonClawbackOp{}),
+	stx.CLAWBACK_CLAIMABLE_BALANCE:       reflect.TypeOf(HorizonClawbackClaimableBalanceOp{}),
+	stx.SET_TRUST_LINE_FLAGS:             reflect.TypeOf(HorizonSetTrustLineFlagsOp{}),
+	stx.LIQUIDITY_POOL_DEPOSIT:           reflect.TypeOf(HorizonLiquidityPoolDepositOp{}),
+	stx.LIQUIDITY_POOL_WITHDRAW:          reflect.TypeOf(HorizonLiquidityPoolWithdrawOp{}),
+}
+
+func (op *HorizonOperation) UnmarshalJSON(data []byte) error {
+	type jop HorizonOperation
+	if err := json.Unmarshal(data, (*jop)(op)); err != nil {
+		return err
+	}
+	if t, ok := horizonOpTypes[op.Type_i]; ok {
+		op.Details = reflect.New(t).Interface()
+		return json.Unmarshal(data, op.Details)
+	}
+	op.Details = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func (op *HorizonOperation) String() string {
+	return stcdetail.PrettyPrintAux(op.Net.prettyPrintAux, op)
+}
+
+// Decodes assets represented in horizon JSON by fields
+// prefix+"asset_type", prefix+"asset_code", and prefix+"asset_issuer",
+// for each prefix in assets.  Assets whose type is missing (or is a
+// liquidity pool share) are left unchanged.
+func unmarshalAssets(data []byte, assets map[string]*stx.Asset) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for prefix, asset := range assets {
+		var ha horizonAsset
+		for name, out := range map[string]interface{}{
+			"asset_type":   &ha.Asset_type,
+			"asset_code":   &ha.Asset_code,
+			"asset_issuer": &ha.Asset_issuer,
+		} {
+			if raw, ok := fields[prefix+name]; ok {
+				if err := json.Unmarshal(raw, out); err != nil {
+					return err
+				}
+			}
+		}
+		if ha.Asset_type == "" || ha.Asset_type == "liquidity_pool_shares" {
+			continue
+		}
+		if err := ha.toAsset(asset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type HorizonCreateAccountOp struct {
+	Funder           AccountID
+	Account          AccountID
+	Starting_balance stcdetail.JsonInt64e7
+}
+
+type HorizonPaymentOp struct {
+	From   AccountID
+	To     AccountID
+	Amount stcdetail.JsonInt64e7
+	Asset  stx.Asset `json:"-"`
+}
+
+func (op *HorizonPaymentOp) UnmarshalJSON(data []byte) error {
+	type jop HorizonPaymentOp
+	if err := json.Unmarshal(data, (*jop)(op)); err != nil {
+		return err
+	}
+	return unmarshalAssets(data, map[string]*stx.Asset{"": &op.Asset})
+}
+
+// Both strict-receive and strict-send path payments.  Source_max is
+// only set for the former, and Destination_min for the latter.
+type HorizonPathPaymentOp struct {
+	From            AccountID
+	To              AccountID
+	Amount          stcdetail.JsonInt64e7
+	Source_amount   stcdetail.JsonInt64e7
+	Source_max      stcdetail.JsonInt64e7
+	Destination_min stcdetail.JsonInt64e7
+	Asset           stx.Asset   `json:"-"`
+	Source_asset    stx.Asset   `json:"-"`
+	Path            []stx.Asset `json:"-"`
+}
+
+func (op *HorizonPathPaymentOp) UnmarshalJSON(data []byte) error {
+	type jop HorizonPathPaymentOp
+	var path struct {
+		Path []horizonAsset
+	}
+	if err := json.Unmarshal(data, (*jop)(op)); err != nil {
+		return err
+	} else if err = json.Unmarshal(data, &path); err != nil {
+		return err
+	}
+	op.Path = make([]stx.Asset, len(path.Path))
+	for i := range path.Path {
+		if err := path.Path[i].toAsset(&op.Path[i]); err != nil {
+			return err
+		}
+	}
+	return unmarshalAssets(data, map[string]*stx.Asset{
+		"":        &op.Asset,
+		"source_": &op.Source_asset,
+	})
+}
+
+// Manage sell offer, manage buy offer, and create passive sell offer
+// operations.
+type HorizonManageOfferOp struct {
+	Offer_id stcdetail.JsonInt64
+	Amount   stcdetail.JsonInt64e7
+	Price    string
+	Price_r  struct {
+		N int32
+		D int32
+	}
+	Selling stx.Asset `json:"-"`
+	Buying  stx.Asset `json:"-"`
+}
+
+func (op *HorizonManageOfferOp) UnmarshalJSON(data []byte) error {
+	type jop HorizonManageOfferOp
+	if err := json.Unmarshal(data, (*jop)(op)); err != nil {
+		return err
+	}
+	return unmarshalAssets(data, map[string]*stx.Asset{
+		"selling_": &op.Selling,
+		"buying_":  &op.Buying,
+	})
+}
+
+// Pointer fields are nil when the operation does not set them.
+type HorizonSetOptionsOp struct {
+	Inflation_dest    *AccountID
+	Home_domain       *string
+	Master_key_weight *uint32
+	Signer_key        *SignerKey
+	Signer_weight     *uint32
+	Low_threshold     *uint32
+	Med_threshold     *uint32
+	High_threshold    *uint32
+	Set_flags_s       []string
+	Clear_flags_s     []string
+}
+
+// Trustee is nil and Asset unset when the trustline is for liquidity
+// pool shares, in which case Liquidity_pool_id is set.
+type HorizonChangeTrustOp struct {
+	Trustor           AccountID
+	Trustee           *AccountID
+	Limit             stcdetail.JsonInt64e7
+	Liquidity_pool_id string
+	Asset             stx.Asset `json:"-"`
+}
+
+func (op *HorizonChangeTrustOp) UnmarshalJSON(data []byte) error {
+	type jop HorizonChangeTrustOp
+	if err := json.Unmarshal(data, (*jop)(op)); err != nil {
+		return err
+	}
+	return unmarshalAssets(data, map[string]*stx.Asset{"": &op.Asset})
+}
+
+type HorizonAllowTrustOp struct {
+	Trustor                           AccountID
+	Trustee                           AccountID
+	Authorize                         bool
+	Authorize_to_maintain_liabilities bool
+	Asset                             stx.Asset `json:"-"`
+}
+
+func (op *HorizonAllowTrustOp) UnmarshalJSON(data []byte) error {
+	type jop HorizonAllowTrustOp
+	if err := json.Unmarshal(data, (*jop)(op)); err != nil {
+		return err
+	}
+	return unmarshalAssets(data, map[string]*stx.Asset{"": &op.Asset})
+}
+
+type HorizonAccountMergeOp struct {
+	Account AccountID
+	Into    AccountID
+}
+
+// Value is base64-encoded, and empty when the operation deletes the
+// entry.
+type HorizonManageDataOp struct {
+	Name  string
+	Value string
+}
+
+type HorizonBumpSequenceOp struct {
+	Bump_to stcdetail.JsonInt64
+}
+
+type HorizonClaimant struct {
+	Destination AccountID
+	// The claim predicate in horizon's JSON format.
+	Predicate json.RawMessage
+}
+
+type HorizonCreateClaimableBalanceOp struct {
+	Amount    stcdetail.JsonInt64e7
+	Claimants []HorizonClaimant
+	Asset     stx.Asset `json:"-"`
+}
+
+func (op *HorizonCreateClaimableBalanceOp) UnmarshalJSON(data []byte) error {
+	type jop HorizonCreateClaimableBalanceOp
+	var asset struct {
+		Asset string
+	}
+	if err := json.Unmarshal(data, (*jop)(op)); err != nil {
+		return err
+	} else if err = json.Unmarshal(data, &asset); err != nil {
+		return err
+	}
+	// Horizon renders this asset as "native" or "CODE:ISSUER".
+	_, err := fmt.Sscan(asset.Asset, &op.Asset)
+	return err
+}
+
+type HorizonClaimClaimableBalanceOp struct {
+	Balance_id string
+	Claimant   AccountID
+}
+
+type HorizonBeginSponsoringOp struct {
+	Sponsored_id AccountID
+}
+
+type HorizonEndSponsoringOp struct {
+	Begin_sponsor AccountID
+}
+
+// Only the fields identifying the kind of ledger entry or signer
+// whose sponsorship is revoked are non-empty.
+type HorizonRevokeSponsorshipOp struct {
+	Account_id                  string
+	Claimable_balance_id        string
+	Data_account_id             string
+	Data_name                   string
+	Offer_id                    string
+	Trustline_account_id        string
+	Trustline_asset             string
+	Trustline_liquidity_pool_id string
+	Signer_account_id           string
+	Signer_key                  string
+}
+
+type HorizonClawbackOp struct {
+	From   AccountID
+	Amount stcdetail.JsonInt64e7
+	Asset  stx.Asset `json:"-"`
+}
+
+func (op *HorizonClawbackOp) UnmarshalJSON(data []byte) error {
+	type jop HorizonClawbackOp
+	if err := json.Unmarshal(data, (*jop)(op)); err != nil {
+		return err
+	}
+	return unmarshalAssets(data, map[string]*stx.Asset{"": &op.Asset})
+}
+
+type HorizonClawbackClaimableBalanceOp struct {
+	Balance_id string
+}
+
+type HorizonSetTrustLineFlagsOp struct {
+	Trustor       AccountID
+	Set_flags_s   []string
+	Clear_flags_s []string
+	Asset         stx.Asset `json:"-"`
+}
+
+func (op *HorizonSetTrustLineFlagsOp) UnmarshalJSON(data []byte) error {
+	type jop HorizonSetTrustLineFlagsOp
+	if err := json.Unmarshal(data, (*jop)(op)); err != nil {
+		return err
+	}
+	return unmarshalAssets(data, map[string]*stx.Asset{"": &op.Asset})
+}
+
+// An amount of one of a liquidity pool's reserves.
+type HorizonReserve struct {
+	Asset  stx.Asset `json:"-"`
+	Amount stcdetail.JsonInt64e7
+}
+
+func (r *HorizonReserve) UnmarshalJSON(data []byte) error {
+	type jr HorizonReserve
+	var asset struct {
+		Asset string
+	}
+	if err := json.Unmarshal(data, (*jr)(r)); err != nil {
+		return err
+	} else if err = json.Unmarshal(data, &asset); err != nil {
+		return err
+	}
+	// Horizon renders this asset as "native" or "CODE:ISSUER".
+	_, err := fmt.Sscan(asset.Asset, &r.Asset)
+	return err
+}
+
+type HorizonLiquidityPoolDepositOp struct {
+	Liquidity_pool_id  string
+	Reserves_max       []HorizonReserve
+	Min_price          string
+	Max_price          string
+	Reserves_deposited []HorizonReserve
+	Shares_received    stcdetail.JsonInt64e7
+}
+
+type HorizonLiquidityPoolWithdrawOp struct {
+	Liquidity_pool_id string
+	Reserves_min      []HorizonReserve
+	Shares            stcdetail.JsonInt64e7
+	Reserves_received []HorizonReserve
+}
+
+// Fetches a single operation by its ID.
+func (net *StellarNet) GetOperation(id string) (*HorizonOperation, error) {
+	ret := HorizonOperation{Net: net}
+	if err := net.GetJSON("operations/"+id, &ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// Fetches a page of the operations on account acct (or of all
+// operations if acct is ""), including those of failed transactions.
+// The first element of opts, if any, selects the page; use NewPager
+// with the same query to walk through more operations.
+func (net *StellarNet) GetOperations(acct string, opts ...PageParams) (
+	[]HorizonOperation, error) {
+	query := "operations?include_failed=true"
+	if acct != "" {
+		query = "accounts/" + acct + "/operations?include_failed=true"
+	}
+	var params PageParams
+	if len(opts) > 0 {
+		params = opts[0]
+	}
+	var ret []HorizonOperation
+	pager := net.NewPager(nil, query, params)
+	pager.Next(&ret)
+	return ret, pager.Err()
+}
+
+// Stream payments to and from account acct as they are included in
+// ledgers, following horizon's payments endpoint.  cb is invoked for
+// each payment-like operation (PAYMENT, PATH_PAYMENT_STRICT_RECEIVE,
+// PATH_PAYMENT_STRICT_SEND, CREATE_ACCOUNT, and ACCOUNT_MERGE), whose
+// Details field holds the corresponding Horizon*Op structure; other
+// records horizon may return are skipped.  See StreamRecords for the
+// meaning of cursor and how errors are handled.  Call this in a
+// goroutine, and cancel ctx to stop.
+func (net *StellarNet) StreamPayments(ctx context.Context, acct string,
+	cursor string, cb func(*HorizonOperation) error) error {
+	return net.StreamRecords(ctx, "accounts/"+acct+"/payments", cursor,
+		func(data []byte) error {
+			op := HorizonOperation{Net: net}
+			if err := json.Unmarshal(data, &op); err != nil {
+				return err
+			}
+			switch op.Type_i {
+			case stx.PAYMENT, stx.PATH_PAYMENT_STRICT_RECEIVE,
+				stx.PATH_PAYMENT_STRICT_SEND, stx.CREATE_ACCOUNT,
+				stx.ACCOUNT_MERGE:
+				return cb(&op)
+			}
+			return nil
+		})
+}
@@ -0,0 +1,114 @@
+package stc
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/xdrpp/stc/stx"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A source of approximate asset prices, used to show estimated values
+// in a display currency next to amounts in explanations.
+type PriceSource interface {
+	// Returns the approximate value of one unit (10^7 stroops) of
+	// asset in the display currency, or false if it is not known.
+	Price(asset stx.Asset) (float64, bool)
+}
+
+// Where HTTPPriceSource fetches the price of an asset.
+type PriceURL struct {
+	// URL returning JSON that contains the price.
+	URL string
+
+	// Dot-separated field names leading to the price within the JSON
+	// (e.g., "stellar.usd" for {"stellar":{"usd":0.1}}), or "" if
+	// the whole document is the price.  The price may be a JSON
+	// number or a string containing a number.
+	Path string
+}
+
+// A PriceSource that queries a web service for each asset, such as an
+// exchange's ticker endpoint.  Each price is fetched at most once, so
+// an HTTPPriceSource is best used for short-lived operations such as
+// explaining a transaction.  Safe for concurrent use.
+type HTTPPriceSource struct {
+	// Where to fetch prices, indexed by asset (in the format of
+	// stx.Asset's String method, e.g., "native" or "USD:G...").
+	URLs map[string]PriceURL
+
+	lock   sync.Mutex
+	prices map[string]*float64
+}
+
+// Extracts the number at path (dot-separated field names) in JSON
+// document body.
+func jsonPathNumber(body []byte, path string) (float64, error) {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return 0, err
+	}
+	if path != "" {
+		for _, field := range strings.Split(path, ".") {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return 0, fmt.Errorf("no field %q in price", field)
+			}
+			v = m[field]
+		}
+	}
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case string:
+		return strconv.ParseFloat(n, 64)
+	}
+	return 0, fmt.Errorf("price is not a number")
+}
+
+func (ps *HTTPPriceSource) Price(asset stx.Asset) (float64, bool) {
+	key := asset.String()
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	if p, ok := ps.prices[key]; ok {
+		return derefPrice(p)
+	} else if ps.prices == nil {
+		ps.prices = make(map[string]*float64)
+	}
+	pu, ok := ps.URLs[key]
+	if !ok {
+		return 0, false
+	}
+	var p *float64
+	if body, err := getURL(pu.URL); err == nil {
+		if n, err := jsonPathNumber(body, pu.Path); err == nil {
+			p = &n
+		}
+	}
+	ps.prices[key] = p
+	return derefPrice(p)
+}
+
+func derefPrice(p *float64) (float64, bool) {
+	if p == nil {
+		return 0, false
+	}
+	return *p, true
+}
+
+// Returns the estimated value of amount (in units of 10^-7) of asset
+// in net.PriceCurrency, such as "approx. 12.34 USD", or "" if
+// net.Prices or net.PriceCurrency is unset or the price of asset is
+// unknown.
+func (net *StellarNet) EstimateValue(amount int64, asset stx.Asset) string {
+	if net.Prices == nil || net.PriceCurrency == "" {
+		return ""
+	}
+	p, ok := net.Prices.Price(asset)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("approx. %.2f %s", p*float64(amount)/1e7,
+		net.PriceCurrency)
+}
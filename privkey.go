@@ -3,6 +3,7 @@ package stc
 import (
 	"bytes"
 	"crypto/ed25519"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"github.com/xdrpp/stc/stcdetail"
@@ -12,6 +13,9 @@ import (
 	"golang.org/x/crypto/openpgp/packet"
 	"io"
 	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
 	"strings"
 )
 
@@ -53,15 +57,69 @@ func NewPrivateKey(pkt stx.PublicKeyType) PrivateKey {
 	}
 }
 
+// Returns the private key of a network's root account, which
+// stellar-core derives from the network ID by using
+// SHA256(networkId) as an ed25519 seed.  On a fresh standalone
+// network the root account holds all of the native asset, so this key
+// lets local integration tests fund accounts without copying secrets
+// around.  Since anyone can compute it, the key is worthless on any
+// real network.
+func NetworkRootKey(networkId string) PrivateKey {
+	seed := sha256.Sum256([]byte(networkId))
+	return PrivateKey{
+		stcdetail.Ed25519Priv(ed25519.NewKeyFromSeed(seed[:])),
+	}
+}
+
+// Returns the private key of the network's root account.  See
+// NetworkRootKey.
+func (net *StellarNet) RootKey() PrivateKey {
+	return NetworkRootKey(net.GetNetworkId())
+}
+
 // Writes the a private key to a file in strkey format.  If passphrase
 // has non-zero length, then the key is symmetrically encrypted in
 // ASCII-armored GPG format.
 func (sk PrivateKey) Save(file string, passphrase []byte) error {
+	return sk.SaveWithHeaders(file, passphrase, nil)
+}
+
+// Like Save, but writes headers along with the key.  For encrypted
+// keys, headers become ASCII armor headers; otherwise they follow the
+// key as "Name: value" lines.  Either way, KeyFileHeaders can read
+// them back without decrypting the key.  If passphrase is empty and
+// headers contains PassPassphraseHeader, the key is encrypted with
+// the passphrase in that password store entry (see
+// SaveWithPassPassphrase).
+func (sk PrivateKey) SaveWithHeaders(file string, passphrase []byte,
+	headers map[string]string) error {
+	return saveSecret(file, sk.String(), passphrase, headers)
+}
+
+func saveSecret(file string, secret string, passphrase []byte,
+	headers map[string]string) error {
+	if entry := headers[PassPassphraseHeader]; entry != "" &&
+		len(passphrase) == 0 {
+		var err error
+		if passphrase, err = passShow(entry); err != nil {
+			return err
+		} else if len(passphrase) == 0 {
+			return InvalidPassphrase
+		}
+	}
 	out := &strings.Builder{}
 	if len(passphrase) == 0 {
-		fmt.Fprintln(out, sk.String())
+		fmt.Fprintln(out, secret)
+		names := make([]string, 0, len(headers))
+		for k := range headers {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			fmt.Fprintf(out, "%s: %s\n", k, headers[k])
+		}
 	} else {
-		w0, err := armor.Encode(out, "PGP MESSAGE", nil)
+		w0, err := armor.Encode(out, "PGP MESSAGE", headers)
 		if err != nil {
 			return err
 		}
@@ -75,7 +133,7 @@ func (sk PrivateKey) Save(file string, passphrase []byte) error {
 			w0.Close()
 			return err
 		}
-		fmt.Fprintln(w, sk.String())
+		fmt.Fprintln(w, secret)
 		w.Close()
 		w0.Close()
 		out.WriteString("\n")
@@ -83,11 +141,59 @@ func (sk PrivateKey) Save(file string, passphrase []byte) error {
 	return stcdetail.SafeCreateFile(file, out.String(), 0400)
 }
 
+// Command used to read secrets from the standard unix password store
+// (https://www.passwordstore.org/).  It is invoked as "PassCommand
+// show ENTRY", and only the first line of its output is used.
+var PassCommand = "pass"
+
+// Key files that refer to a seed in the password store consist of
+// this prefix followed by the name of the entry.
+const PassKeyPrefix = "pass:"
+
+// ASCII armor header naming the password store entry that holds the
+// passphrase of an encrypted key file.
+const PassPassphraseHeader = "Stc-Passphrase-Pass"
+
+// Returns the first line of a password store entry.
+func passShow(entry string) ([]byte, error) {
+	cmd := exec.Command(PassCommand, "show", entry)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s show %s: %w", PassCommand, entry, err)
+	}
+	if i := bytes.IndexByte(out, '\n'); i >= 0 {
+		out = out[:i]
+	}
+	return out, nil
+}
+
+// Writes a key file that refers to an entry in the password store
+// instead of containing a key.  The first line of the entry must be
+// the key's seed in strkey format ("S...").  LoadPrivateKey retrieves
+// the seed with PassCommand, so the key is protected by pass's own
+// GPG encryption rather than a separate passphrase.
+func SavePassKeyRef(file string, entry string) error {
+	return stcdetail.SafeCreateFile(file, PassKeyPrefix+entry+"\n", 0400)
+}
+
+// Like Save, but encrypts the key with a passphrase stored in the
+// first line of a password store entry, and records the entry name in
+// the file so that LoadPrivateKey can retrieve the passphrase without
+// prompting.
+func (sk PrivateKey) SaveWithPassPassphrase(file string,
+	entry string) error {
+	return sk.SaveWithHeaders(file, nil,
+		map[string]string{PassPassphraseHeader: entry})
+}
+
 var InvalidPassphrase = errors.New("Invalid passphrase")
 var InvalidKeyFile = errors.New("Invalid private key file")
 
 // Reads a private key from a file, prompting for a passphrase if the
-// key is in ASCII-armored symmetrically-encrypted GPG format.
+// key is in ASCII-armored symmetrically-encrypted GPG format.  If the
+// file was written by SavePassKeyRef or SaveWithPassPassphrase, the
+// seed or passphrase is retrieved from the password store instead.
 func LoadPrivateKey(file string) (PrivateKey, error) {
 	input, err := ioutil.ReadFile(file)
 	if err != nil {
@@ -96,14 +202,55 @@ func LoadPrivateKey(file string) (PrivateKey, error) {
 	ret := PrivateKey{}
 	if _, err = fmt.Fscan(bytes.NewBuffer(input), &ret); err == nil {
 		return ret, nil
+	} else if bytes.HasPrefix(input, []byte(PassKeyPrefix)) {
+		entry := strings.TrimSpace(string(input[len(PassKeyPrefix):]))
+		seed, err := passShow(entry)
+		if err != nil {
+			return ret, err
+		} else if _, err = fmt.Fscan(bytes.NewBuffer(seed),
+			&ret); err != nil {
+			return ret, fmt.Errorf("%s: pass entry %s: %w", file, entry,
+				InvalidKeyFile)
+		}
+		return ret, nil
+	}
+
+	plain, err := decryptSecret(file, input)
+	if err == io.EOF {
+		return ret, InvalidKeyFile
+	} else if err != nil {
+		return ret, err
+	} else if _, err = fmt.Fscan(bytes.NewBuffer(plain), &ret); err != nil {
+		return ret, err
 	}
+	return ret, nil
+}
 
+// Decrypts the contents of a file in ASCII-armored
+// symmetrically-encrypted GPG format, prompting for a passphrase
+// unless the armor headers name a password store entry holding it.
+// Returns io.EOF if input is not in that format.
+func decryptSecret(file string, input []byte) ([]byte, error) {
 	block, err := armor.Decode(bytes.NewBuffer(input))
 	if err != nil {
-		return ret, InvalidKeyFile
+		return nil, io.EOF
 	}
+	entry := block.Header[PassPassphraseHeader]
 	md, err := openpgp.ReadMessage(block.Body, nil,
 		func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+			if entry != "" {
+				// Only try the password store once, then prompt
+				passphrase, err := passShow(entry)
+				if err == nil && len(passphrase) > 0 {
+					return passphrase, nil
+				} else if err == nil {
+					fmt.Fprintf(os.Stderr, "%s show %s: empty passphrase\n",
+						PassCommand, entry)
+				} else {
+					fmt.Fprintln(os.Stderr, err)
+				}
+				entry = ""
+			}
 			passphrase :=
 				stcdetail.GetPass(fmt.Sprintf("Passphrase for %s: ", file))
 			if len(passphrase) > 0 {
@@ -112,12 +259,35 @@ func LoadPrivateKey(file string) (PrivateKey, error) {
 			return nil, InvalidPassphrase
 		}, nil)
 	if err != nil {
-		return ret, err
-	} else if _, err = fmt.Fscan(md.UnverifiedBody, &ret); err != nil {
-		return ret, err
-	} else if io.Copy(ioutil.Discard,
-		md.UnverifiedBody); md.SignatureError != nil {
-		return ret, md.SignatureError
+		return nil, err
+	}
+	plain, err := ioutil.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return nil, err
+	} else if md.SignatureError != nil {
+		return nil, md.SignatureError
+	}
+	return plain, nil
+}
+
+// Returns the headers a key file was saved with (see
+// SaveWithHeaders), without decrypting the key.
+func KeyFileHeaders(file string) (map[string]string, error) {
+	input, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	} else if bytes.HasPrefix(input, []byte(PassKeyPrefix)) {
+		return map[string]string{}, nil
+	} else if block, err := armor.Decode(bytes.NewBuffer(input));
+		err == nil {
+		return block.Header, nil
+	}
+	ret := map[string]string{}
+	lines := strings.Split(string(input), "\n")
+	for _, line := range lines[1:] {
+		if i := strings.Index(line, ": "); i > 0 {
+			ret[line[:i]] = strings.TrimSpace(line[i+2:])
+		}
 	}
 	return ret, nil
 }
@@ -0,0 +1,156 @@
+package stc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A token-bucket rate limiter for requests to horizon, which keeps
+// batch jobs (e.g., fixing or posting hundreds of transactions) under
+// the server's rate limits instead of getting throttled or banned
+// partway through.  The bucket holds up to Burst tokens and refills
+// at Rate tokens per second, and each request (including each retry)
+// consumes one token.  A RateLimiter may be shared by several
+// StellarNets and used from multiple goroutines.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// Returns a RateLimiter allowing rate requests per second on average
+// and bursts of up to burst requests.  burst is raised to 1 if it is
+// smaller, and a rate of 0 or less means no limit.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+// Returns the average number of requests per second allowed.
+func (rl *RateLimiter) Rate() float64 {
+	return rl.rate
+}
+
+// Waits until a request may be sent, or returns ctx.Err() if ctx
+// (which may be nil) is done first.
+func (rl *RateLimiter) Wait(ctx context.Context) error {
+	if rl.rate <= 0 {
+		return nil
+	} else if ctx == nil {
+		ctx = context.Background()
+	}
+	rl.mu.Lock()
+	now := time.Now()
+	if !rl.last.IsZero() {
+		rl.tokens += now.Sub(rl.last).Seconds() * rl.rate
+		if rl.tokens > rl.burst {
+			rl.tokens = rl.burst
+		}
+	}
+	rl.last = now
+	rl.tokens--
+	var wait time.Duration
+	if rl.tokens < 0 {
+		wait = time.Duration(-rl.tokens / rl.rate * float64(time.Second))
+	}
+	rl.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		// Give back the token we did not use
+		rl.mu.Lock()
+		rl.tokens++
+		rl.mu.Unlock()
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+// Rate limits applied per host to every request stc makes in the
+// process, whichever StellarNet (if any) makes it, so that several
+// StellarNets (or goroutines) using the same horizon server share
+// one budget.
+type HostRateLimits struct {
+	mu    sync.Mutex
+	hosts map[string]*RateLimiter
+}
+
+// The per-host rate limits used by all requests.  There are none by
+// default; a program sending many requests to a public horizon server
+// can add one with Set (e.g., a rate of 1 and burst of 60 stays under
+// SDF's limit of 3600 requests per hour per client).
+var DefaultHostRateLimits = &HostRateLimits{}
+
+// Returns the rate limiter for host (a host name without a port, as
+// returned by url.URL.Hostname), or nil if requests to host are not
+// limited.
+func (h *HostRateLimits) Get(host string) *RateLimiter {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.hosts[strings.ToLower(host)]
+}
+
+// Limits requests to host to rl, or removes the limit if rl is nil.
+// The same RateLimiter may be used for several hosts, for example
+// when they are mirrors behind a common rate limit.
+func (h *HostRateLimits) Set(host string, rl *RateLimiter) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if rl == nil {
+		delete(h.hosts, strings.ToLower(host))
+		return
+	} else if h.hosts == nil {
+		h.hosts = make(map[string]*RateLimiter)
+	}
+	h.hosts[strings.ToLower(host)] = rl
+}
+
+// A RoundTripper that waits for a RateLimiter (if not nil) and for
+// the DefaultHostRateLimits of the request's host before each
+// request.
+type rateLimitTransport struct {
+	base http.RoundTripper
+	rl   *RateLimiter
+}
+
+func (t rateLimitTransport) RoundTrip(req *http.Request) (
+	*http.Response, error) {
+	if t.rl != nil {
+		if err := t.rl.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+	if hrl := DefaultHostRateLimits.Get(req.URL.Hostname()); hrl != nil {
+		if err := hrl.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// Returns a copy of c whose requests wait for rl (if not nil) and for
+// DefaultHostRateLimits.
+func withRateLimit(c *http.Client, rl *RateLimiter) *http.Client {
+	ret := *c
+	base := c.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	ret.Transport = rateLimitTransport{base: base, rl: rl}
+	return &ret
+}
@@ -0,0 +1,146 @@
+package stc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/xdrpp/stc/stcdetail"
+	"os"
+	"time"
+)
+
+// A signed statement that a transaction was included in a ledger,
+// which third parties can check with VerifyReceipts.  A receipt file
+// holds one receipt per line in JSON format, and each receipt records
+// the hash of the line before it, so that deleting, reordering, or
+// altering receipts in the middle of the file is detected.
+type Receipt struct {
+	// The network passphrase.
+	NetworkId string `json:"network_id"`
+
+	// The transaction's hash in hex.
+	Txid string `json:"txid"`
+
+	// The ledger that included the transaction, and when it closed.
+	Ledger uint32    `json:"ledger"`
+	Closed time.Time `json:"closed"`
+
+	// The TransactionResult in base64 XDR.
+	Result string `json:"result"`
+
+	// When the receipt was signed.
+	Time time.Time `json:"time"`
+
+	// Hex SHA-256 hash of the previous line of the receipt file, or
+	// empty for the first receipt.
+	Prev string `json:"prev"`
+
+	// The public key (in strkey format) that signed the receipt, and
+	// its signature.
+	Signer    string `json:"signer"`
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// Returns the message signed by a receipt's Signature.
+func (r *Receipt) signedMessage() []byte {
+	c := *r
+	c.Signature = nil
+	body, _ := json.Marshal(&c)
+	sum := sha256.Sum256(body)
+	return append([]byte("stc receipt\n"), sum[:]...)
+}
+
+// Checks the receipt's signature.
+func (r *Receipt) Verify() error {
+	var pk PublicKey
+	if _, err := fmt.Sscan(r.Signer, &pk); err != nil {
+		return fmt.Errorf("receipt for %s: bad signer: %w", r.Txid, err)
+	} else if !stcdetail.Verify(&pk, r.signedMessage(), r.Signature) {
+		return fmt.Errorf("receipt for %s: invalid signature", r.Txid)
+	}
+	return nil
+}
+
+// Fetches the result of transaction txid (in hex) from horizon and
+// returns an unsigned receipt for it.  ctx may be nil.
+func (net *StellarNet) NewReceipt(ctx context.Context, txid string) (
+	*Receipt, error) {
+	txr, err := net.GetTxResultCtx(ctx, txid)
+	if err != nil {
+		return nil, err
+	}
+	return &Receipt{
+		NetworkId: net.GetNetworkId(),
+		Txid:      txid,
+		Ledger:    txr.Ledger,
+		Closed:    txr.Time.UTC(),
+		Result:    stcdetail.XdrToBase64(&txr.Result),
+	}, nil
+}
+
+// Signs r with key, chains it to the last receipt in file path, and
+// appends it to the file, which is created if it does not exist.
+func AppendReceipt(path string, r *Receipt,
+	key stcdetail.PrivateKeyInterface) error {
+	lf, err := stcdetail.LockFile(path, 0666)
+	if err != nil {
+		return err
+	}
+	defer lf.Abort()
+	contents, err := lf.ReadFile()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	r.Prev = ""
+	if len(contents) > 0 {
+		lines := bytes.Split(bytes.TrimRight(contents, "\n"), []byte("\n"))
+		r.Prev = fmt.Sprintf("%x", sha256.Sum256(lines[len(lines)-1]))
+	}
+	r.Time = time.Now().UTC().Truncate(time.Second)
+	r.Signer = key.Public().String()
+	if r.Signature, err = key.Sign(r.signedMessage()); err != nil {
+		return err
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	lf.Write(contents)
+	lf.Write(line)
+	lf.Write([]byte("\n"))
+	return lf.Commit()
+}
+
+// Parses the contents of a receipt file, checking each receipt's
+// signature and the chain of hashes linking them.  On failure,
+// returns the receipts that precede the first bad one along with an
+// error.  A valid file only shows that its receipts were signed by
+// their Signers in order; to rule out a forged file, check that the
+// Signers are keys you trust, and, if needed, that the transactions
+// appear in the network's history.
+func VerifyReceipts(data []byte) ([]Receipt, error) {
+	var ret []Receipt
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	prev := ""
+	for i, line := range bytes.Split(bytes.TrimRight(data, "\n"),
+		[]byte("\n")) {
+		var r Receipt
+		if err := json.Unmarshal(line, &r); err != nil {
+			return ret, fmt.Errorf("line %d: %w", i+1, err)
+		} else if r.Prev != prev {
+			return ret, fmt.Errorf("line %d: receipt for %s does not "+
+				"follow the previous line", i+1, r.Txid)
+		} else if err = r.Verify(); err != nil {
+			return ret, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		ret = append(ret, r)
+		prev = fmt.Sprintf("%x", sha256.Sum256(line))
+	}
+	return ret, nil
+}
@@ -0,0 +1,327 @@
+package stc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/xdrpp/stc/horizon"
+	"github.com/xdrpp/stc/stcdetail"
+	"github.com/xdrpp/stc/stx"
+	"strconv"
+	"strings"
+)
+
+// The reconciliation of an account's balance of one asset.
+type AssetReconciliation struct {
+	Asset stx.Asset
+
+	// The balance before the first effect examined, and whether it is
+	// known.  When reconciling from an account's creation, the
+	// opening balance is 0.  Otherwise it must be supplied by the
+	// caller of Reconcile.
+	Opening      int64
+	OpeningKnown bool
+
+	// Totals of the amounts credited to and debited from the account
+	// by the effects examined, and (for the native asset only) of the
+	// transaction fees the account paid in the same period.
+	Credits int64
+	Debits  int64
+	Fees    int64
+
+	// The account's balance according to horizon.
+	Actual int64
+
+	// The range of ledgers in which the examined effects changed the
+	// balance (0 if none did).  A discrepancy arose in this window.
+	FirstLedger uint32
+	LastLedger  uint32
+}
+
+// The change in the balance over the effects examined.
+func (ar *AssetReconciliation) Change() int64 {
+	return ar.Credits - ar.Debits - ar.Fees
+}
+
+// The difference between the actual balance and the balance computed
+// from the opening balance and the effects examined.  Meaningless
+// unless OpeningKnown.
+func (ar *AssetReconciliation) Discrepancy() int64 {
+	return ar.Actual - ar.Opening - ar.Change()
+}
+
+func (ar *AssetReconciliation) note(ledger uint32) {
+	if ar.FirstLedger == 0 {
+		ar.FirstLedger = ledger
+	}
+	ar.LastLedger = ledger
+}
+
+// The result of Reconcile.
+type Reconciliation struct {
+	Net     *StellarNet
+	Account string
+
+	// Paging tokens of the first and last effects examined (empty if
+	// there were none).
+	FirstCursor string
+	LastCursor  string
+
+	// The ledger whose state the actual balances reflect.  Effects and
+	// transactions in later ledgers are not examined.
+	Ledger uint32
+
+	// One entry per asset the account holds or that the examined
+	// effects changed.
+	Assets []AssetReconciliation
+
+	// Number of examined effects, by type, that stc does not know how
+	// to reconcile (e.g., liquidity pool deposits).  These may explain
+	// discrepancies.
+	Unhandled map[string]int
+}
+
+// Returns true if every asset has a known opening balance and no
+// discrepancy.
+func (r *Reconciliation) OK() bool {
+	for i := range r.Assets {
+		if !r.Assets[i].OpeningKnown || r.Assets[i].Discrepancy() != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Reconciliation) asset(a *stx.Asset) *AssetReconciliation {
+	key := a.String()
+	for i := range r.Assets {
+		if r.Assets[i].Asset.String() == key {
+			return &r.Assets[i]
+		}
+	}
+	r.Assets = append(r.Assets, AssetReconciliation{Asset: *a})
+	return &r.Assets[len(r.Assets)-1]
+}
+
+func (r *Reconciliation) String() string {
+	out := &strings.Builder{}
+	fmt.Fprintf(out, "account: %s\nledger: %d\n", r.Account, r.Ledger)
+	if r.FirstCursor != "" {
+		fmt.Fprintf(out, "effects: %s to %s\n", r.FirstCursor, r.LastCursor)
+	}
+	for i := range r.Assets {
+		ar := &r.Assets[i]
+		fmt.Fprintf(out, "%s:\n", r.Net.DescribeAsset(ar.Asset))
+		if ar.OpeningKnown {
+			fmt.Fprintf(out, "  opening: %s\n",
+				stcdetail.JsonInt64e7(ar.Opening))
+		}
+		fmt.Fprintf(out, "  credits: %s\n  debits: %s\n",
+			stcdetail.JsonInt64e7(ar.Credits),
+			stcdetail.JsonInt64e7(ar.Debits))
+		if ar.Fees != 0 {
+			fmt.Fprintf(out, "  fees: %s\n", stcdetail.JsonInt64e7(ar.Fees))
+		}
+		fmt.Fprintf(out, "  actual: %s\n", stcdetail.JsonInt64e7(ar.Actual))
+		if !ar.OpeningKnown {
+			fmt.Fprintf(out, "  implied opening: %s\n",
+				stcdetail.JsonInt64e7(ar.Actual-ar.Change()))
+		} else if d := ar.Discrepancy(); d == 0 {
+			fmt.Fprintf(out, "  reconciled\n")
+		} else {
+			fmt.Fprintf(out, "  DISCREPANCY: %s between ledgers %d and %d\n",
+				stcdetail.JsonInt64e7(d), ar.FirstLedger, ar.LastLedger)
+		}
+	}
+	for t, n := range r.Unhandled {
+		fmt.Fprintf(out, "warning: %d %s effects not reconciled\n", n, t)
+	}
+	return out.String()
+}
+
+// Types of effects that change an account's balances but that
+// Reconcile cannot decode.
+var unreconciledEffects = map[string]bool{
+	"liquidity_pool_deposited": true,
+	"liquidity_pool_withdrew":  true,
+	"liquidity_pool_revoked":   true,
+}
+
+// Returns the ledger and numeric ID of a horizon paging token (which
+// for effects, operations, and transactions encodes the ledger in the
+// upper 32 bits of the ID), or 0 if tok is not such a token.
+func pagingTokenLedger(tok string) (uint32, int64) {
+	if i := strings.IndexByte(tok, '-'); i >= 0 {
+		tok = tok[:i]
+	}
+	id, err := strconv.ParseInt(tok, 10, 64)
+	if err != nil {
+		return 0, 0
+	}
+	return uint32(id >> 32), id
+}
+
+// Walks the effects on account acct after cursor (or from the
+// account's creation if cursor is ""), recomputes the running balance
+// of each asset, and compares the result with the account's current
+// balances.  Transaction fees, which are not effects, are taken from
+// the transactions the account paid for in the same period.  When
+// cursor is not "", opening contains the balances (keyed by asset in
+// the format of stx.Asset.String, e.g., "native" or "USD:GABC...")
+// as of the effect at cursor; assets missing from opening are
+// reported with OpeningKnown false.
+//
+// Since a trustline can only be removed when its balance is zero, a
+// "trustline_removed" effect supplies an opening balance that would
+// otherwise be unknown.  Likewise, an "account_removed" effect (the
+// account was merged and later re-created) means every balance was
+// zero, so reconciliation restarts from that point.
+func (net *StellarNet) Reconcile(ctx context.Context, acct, cursor string,
+	opening map[string]int64) (*Reconciliation, error) {
+	ret := &Reconciliation{
+		Net:       net,
+		Account:   acct,
+		Unhandled: make(map[string]int),
+	}
+
+	body, hdr, err := getURLHeader(ctx, net.httpClient(),
+		net.retryPolicy(), net.readHorizon()+"accounts/"+acct)
+	if err != nil {
+		return nil, err
+	}
+	ae := HorizonAccountEntry{Net: net}
+	if err = json.Unmarshal(body, &ae); err != nil {
+		return nil, err
+	}
+	latest := hdr.Get("Latest-Ledger")
+	if l, err := strconv.ParseUint(latest, 10, 32); err == nil {
+		ret.Ledger = uint32(l)
+	}
+	native := NativeAsset()
+	ret.asset(&native).Actual = int64(ae.Balance)
+	for i := range ae.Balances {
+		if b := &ae.Balances[i]; b.Asset.Type != stx.ASSET_TYPE_POOL_SHARE {
+			ret.asset(&b.Asset).Actual = int64(b.Balance)
+		}
+	}
+	for i := range ret.Assets {
+		ar := &ret.Assets[i]
+		if cursor == "" {
+			ar.OpeningKnown = true
+		} else if o, ok := opening[ar.Asset.String()]; ok {
+			ar.Opening, ar.OpeningKnown = o, true
+		}
+	}
+	// Ledger in which the account was last merged away, if any
+	var removed uint32
+	newAsset := func(a *stx.Asset) *AssetReconciliation {
+		ar := ret.asset(a)
+		if !ar.OpeningKnown && removed != 0 {
+			ar.OpeningKnown = true
+		} else if !ar.OpeningKnown {
+			if o, ok := opening[a.String()]; ok || cursor == "" {
+				ar.Opening, ar.OpeningKnown = o, true
+			}
+		}
+		return ar
+	}
+
+	pager := net.NewPager(ctx, "accounts/"+acct+"/effects",
+		PageParams{Cursor: cursor, Limit: 200})
+	var effects []HorizonEffect
+walk:
+	for pager.Next(&effects) {
+		for i := range effects {
+			e := &effects[i]
+			ledger, _ := pagingTokenLedger(e.Paging_token)
+			if ret.Ledger != 0 && ledger > ret.Ledger {
+				break walk
+			} else if ret.FirstCursor == "" {
+				ret.FirstCursor = e.Paging_token
+			}
+			ret.LastCursor = e.Paging_token
+			switch d := e.Details.(type) {
+			case *HorizonAccountCreatedEffect:
+				ar := newAsset(&native)
+				ar.Credits += int64(d.Starting_balance)
+				ar.note(ledger)
+			case *HorizonAmountEffect:
+				ar := newAsset(&d.Asset)
+				if e.Type == "account_credited" {
+					ar.Credits += int64(d.Amount)
+				} else {
+					ar.Debits += int64(d.Amount)
+				}
+				ar.note(ledger)
+			case *HorizonTradeEffect:
+				ar := newAsset(&d.Bought)
+				ar.Credits += int64(d.Bought_amount)
+				ar.note(ledger)
+				ar = newAsset(&d.Sold)
+				ar.Debits += int64(d.Sold_amount)
+				ar.note(ledger)
+			case *HorizonTrustlineEffect:
+				if e.Type != "trustline_removed" ||
+					d.Liquidity_pool_id != "" {
+					break
+				}
+				ar := newAsset(&d.Asset)
+				if !ar.OpeningKnown {
+					ar.Opening, ar.OpeningKnown = -ar.Change(), true
+				}
+				ar.note(ledger)
+			case json.RawMessage:
+				if e.Type == "account_removed" {
+					// Fees up to this ledger are left out below, so
+					// Change() covers only what follows
+					removed = ledger
+					for j := range ret.Assets {
+						ar := &ret.Assets[j]
+						ar.Opening, ar.OpeningKnown = -ar.Change(), true
+					}
+				} else if unreconciledEffects[e.Type] {
+					ret.Unhandled[e.Type]++
+				}
+			}
+		}
+	}
+	if err = pager.Err(); err != nil {
+		return nil, err
+	}
+
+	// Fees are charged before a transaction's operations execute, so
+	// the fee of the transaction containing the effect at cursor
+	// precedes the cursor.  Transaction IDs are operation IDs with the
+	// low 12 bits (the operation index) cleared.
+	txcursor := ""
+	if _, id := pagingTokenLedger(cursor); id != 0 {
+		txcursor = strconv.FormatInt(id&^0xfff, 10)
+	}
+	pager = net.NewPager(ctx, "accounts/"+acct+
+		"/transactions?include_failed=true",
+		PageParams{Cursor: txcursor, Limit: 200})
+	var txs []horizon.Transaction
+txwalk:
+	for pager.Next(&txs) {
+		for i := range txs {
+			tx := &txs[i]
+			if ret.Ledger != 0 && tx.Ledger > ret.Ledger {
+				break txwalk
+			} else if tx.Fee_account != acct || tx.Ledger <= removed {
+				continue
+			}
+			fee, err := strconv.ParseInt(tx.Fee_charged.String(), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("transaction %s: bad fee_charged %q",
+					tx.Hash, tx.Fee_charged)
+			}
+			ar := newAsset(&native)
+			ar.Fees += fee
+			ar.note(tx.Ledger)
+		}
+	}
+	if err = pager.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,285 @@
+package stc
+
+import (
+	"fmt"
+	"github.com/xdrpp/stc/ini"
+	"github.com/xdrpp/stc/stcdetail"
+	"github.com/xdrpp/stc/stx"
+	"sort"
+	"strings"
+)
+
+// One signer of an account, as reported by AccountControl.
+type ControlSigner struct {
+	HorizonSigner
+
+	// Human-readable kind of signer, such as "master key" or
+	// "pre-authorized transaction"
+	Kind string
+
+	// Comment on the signer in net.Signers, if any
+	Comment string
+
+	// Which of the account's low, medium, and high thresholds the
+	// signer can meet on its own
+	Alone [3]bool
+}
+
+// Every way an account can currently be controlled:  its signers,
+// and how many of them it takes to meet each threshold.  Use it to
+// rehearse the response to a compromised key, e.g., to check that no
+// single key can take over an account.
+type AccountControl struct {
+	Account    string
+	Thresholds HorizonThresholds
+	Signers    []ControlSigner
+
+	// The fewest signers that together meet the low, medium, and high
+	// thresholds, or 0 if the signers cannot meet the threshold, in
+	// which case the operations it guards are locked forever.  Note
+	// that high-threshold operations include changing signers, so a
+	// set of this many keys can take over the account.
+	MinSigners [3]int
+
+	// Number of reserves the account pays for other accounts, and
+	// that other accounts pay for it (including for its signers)
+	NumSponsoring, NumSponsored uint32
+}
+
+func signerKind(acct string, key *SignerKey) string {
+	switch key.Type {
+	case stx.SIGNER_KEY_TYPE_ED25519:
+		if key.String() == acct {
+			return "master key"
+		}
+		return "ed25519 key"
+	case stx.SIGNER_KEY_TYPE_PRE_AUTH_TX:
+		return "pre-authorized transaction"
+	case stx.SIGNER_KEY_TYPE_HASH_X:
+		return "hash-x (anyone knowing the preimage)"
+	case stx.SIGNER_KEY_TYPE_ED25519_SIGNED_PAYLOAD:
+		return "ed25519 signed payload"
+	}
+	return key.Type.String()
+}
+
+// Analyzes who controls the account in ae.  net (which may be nil)
+// supplies comments on signers.
+func (net *StellarNet) AccountControlOf(
+	ae *HorizonAccountEntry) *AccountControl {
+	ret := &AccountControl{
+		Account:       ae.Account_id,
+		Thresholds:    ae.Thresholds,
+		NumSponsoring: ae.Num_sponsoring,
+		NumSponsored:  ae.Num_sponsored,
+	}
+	thresholds := [3]uint32{
+		uint32(ae.Thresholds.Low_threshold),
+		uint32(ae.Thresholds.Med_threshold),
+		uint32(ae.Thresholds.High_threshold),
+	}
+	for i := range thresholds {
+		// A threshold of 0 still requires a signature
+		if thresholds[i] == 0 {
+			thresholds[i] = 1
+		}
+	}
+	var weights []uint32
+	for _, s := range ae.Signers {
+		if s.Weight == 0 {
+			continue
+		}
+		cs := ControlSigner{
+			HorizonSigner: s,
+			Kind:          signerKind(ae.Account_id, &s.Key),
+		}
+		if net != nil {
+			cs.Comment = net.SignerNote(&s.Key)
+		}
+		w := s.Weight
+		if w > 255 {
+			w = 255
+		}
+		for i := range thresholds {
+			cs.Alone[i] = w >= thresholds[i]
+		}
+		ret.Signers = append(ret.Signers, cs)
+		weights = append(weights, w)
+	}
+	sort.Slice(weights, func(i, j int) bool {
+		return weights[i] > weights[j]
+	})
+	for i := range thresholds {
+		var total uint32
+		for n, w := range weights {
+			if total += w; total >= thresholds[i] {
+				ret.MinSigners[i] = n + 1
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// Fetches an account from horizon and analyzes who controls it.
+func (net *StellarNet) GetAccountControl(acct string) (
+	*AccountControl, error) {
+	ae, err := net.GetAccountEntry(acct)
+	if err != nil {
+		return nil, err
+	}
+	return net.AccountControlOf(ae), nil
+}
+
+func (ac *AccountControl) String() string {
+	out := &strings.Builder{}
+	names := [3]string{"low", "medium", "high"}
+	fmt.Fprintf(out, "account %s\n", ac.Account)
+	fmt.Fprintf(out, "thresholds: low %d, medium %d, high %d\n",
+		ac.Thresholds.Low_threshold, ac.Thresholds.Med_threshold,
+		ac.Thresholds.High_threshold)
+	for _, s := range ac.Signers {
+		fmt.Fprintf(out, "signer %s\n    %s, weight %d", s.Key, s.Kind,
+			s.Weight)
+		if s.Comment != "" {
+			fmt.Fprintf(out, " (%s)", s.Comment)
+		}
+		var alone []string
+		for i := range names {
+			if s.Alone[i] {
+				alone = append(alone, names[i])
+			}
+		}
+		if len(alone) > 0 {
+			fmt.Fprintf(out, "\n    alone meets %s threshold",
+				strings.Join(alone, ", "))
+		}
+		if s.Sponsor != nil {
+			fmt.Fprintf(out, "\n    reserve sponsored by %s", s.Sponsor)
+		}
+		fmt.Fprintln(out)
+	}
+	for i := range names {
+		if n := ac.MinSigners[i]; n == 0 {
+			fmt.Fprintf(out, "%s threshold cannot be met (locked)\n",
+				names[i])
+		} else {
+			fmt.Fprintf(out, "%s threshold requires at least %d signer(s)\n",
+				names[i], n)
+		}
+	}
+	if ac.MinSigners[2] == 1 {
+		fmt.Fprintf(out, "WARNING: a single signer can take over the "+
+			"account\n")
+	}
+	fmt.Fprintf(out, "sponsoring %d reserve(s), sponsored for %d\n",
+		ac.NumSponsoring, ac.NumSponsored)
+	return out.String()
+}
+
+// The signing configuration an account should have after a recovery,
+// for example after one of its keys is compromised.  Recovery plans
+// are normally kept in INI files, such as:
+//
+//	[recovery]
+//		signer = GBACKUP1... 1
+//		signer = GBACKUP2... 1
+//		master-weight = 0
+//		low-threshold = 1
+//		med-threshold = 2
+//		high-threshold = 2
+type RecoveryPlan struct {
+	// Signers to install, each as a signer key in strkey format, a
+	// space, and a weight
+	Signers []string `ini:"signer"`
+
+	MasterWeight  uint32 `ini:"master-weight"`
+	LowThreshold  uint32 `ini:"low-threshold"`
+	MedThreshold  uint32 `ini:"med-threshold"`
+	HighThreshold uint32 `ini:"high-threshold"`
+}
+
+// Reads a recovery plan from an INI file.
+func LoadRecoveryPlan(path string) (*RecoveryPlan, error) {
+	contents, _, err := stcdetail.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ret RecoveryPlan
+	sink := ini.NewGenericSink("recovery")
+	sink.AddStruct(&ret)
+	if err = ini.IniParseContents(sink, path, contents); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// Returns a transaction that gives the account in ae the signing
+// configuration of plan:  it removes every signer (including
+// pre-authorized transactions and hash-x signers) not in the plan,
+// adds or reweights the plan's signers, and sets the master key weight
+// and thresholds.  The transaction must be signed by keys meeting the
+// account's current high threshold, and fails if the account's
+// sequence number changes first, so it should be built and submitted
+// promptly (or used to rehearse the recovery on a test network).
+// Returns an error if plan is malformed or would lock the account.
+func (net *StellarNet) RecoveryTx(ae *HorizonAccountEntry,
+	plan *RecoveryPlan) (*TransactionEnvelope, error) {
+	var acct AccountID
+	if _, err := fmt.Sscan(ae.Account_id, &acct); err != nil {
+		return nil, err
+	}
+	want := make(map[string]uint32)
+	var keys []stx.SignerKey
+	total := plan.MasterWeight
+	for _, s := range plan.Signers {
+		var key SignerKey
+		var weight uint32
+		if _, err := fmt.Sscan(s, &key, &weight); err != nil {
+			return nil, fmt.Errorf("recovery signer %q: %w", s, err)
+		} else if weight == 0 || weight > 255 {
+			return nil, fmt.Errorf("recovery signer %q: weight must be "+
+				"1 to 255", s)
+		} else if key.String() == ae.Account_id {
+			return nil, fmt.Errorf("recovery signer %q: use master-weight "+
+				"for the master key", s)
+		}
+		if _, ok := want[key.String()]; !ok {
+			keys = append(keys, key)
+		}
+		want[key.String()] = weight
+		total += weight
+	}
+	for _, t := range []uint32{plan.LowThreshold, plan.MedThreshold,
+		plan.HighThreshold} {
+		if t > 255 {
+			return nil, fmt.Errorf("recovery threshold %d exceeds 255", t)
+		} else if total < t || total == 0 {
+			return nil, fmt.Errorf("recovery plan would lock the account")
+		}
+	}
+
+	e := NewTransactionEnvelope()
+	e.SetSourceAccount(acct)
+	e.V1().Tx.SeqNum = ae.NextSeq()
+	// Remove signers first, so as never to exceed the maximum number
+	for _, s := range ae.Signers {
+		if _, ok := want[s.Key.String()]; !ok &&
+			s.Key.String() != ae.Account_id {
+			e.Append(nil, SetOptions{Signer: &stx.Signer{Key: s.Key}})
+		}
+	}
+	for i := range keys {
+		e.Append(nil, SetOptions{Signer: &stx.Signer{
+			Key:    keys[i],
+			Weight: want[keys[i].String()],
+		}})
+	}
+	e.Append(nil, SetOptions{
+		MasterWeight:  NewUint(plan.MasterWeight),
+		LowThreshold:  NewUint(plan.LowThreshold),
+		MedThreshold:  NewUint(plan.MedThreshold),
+		HighThreshold: NewUint(plan.HighThreshold),
+	})
+	return e, nil
+}
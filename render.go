@@ -0,0 +1,143 @@
+package stc
+
+import (
+	"fmt"
+	"github.com/xdrpp/goxdr/xdr"
+	"github.com/xdrpp/stc/stcdetail"
+	"github.com/xdrpp/stc/stx"
+	"strings"
+)
+
+// How much attention a RenderRow deserves.  Front ends can use this to
+// choose a style, such as highlighting warnings and errors.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// One labeled line of a rendered transaction.  The TxrepRows,
+// ExplainRows, and SignatureRows methods return the same information
+// the stc command prints, but as structured data, so that GUIs and
+// web front ends embedding this library can present transactions with
+// their own styling.
+type RenderRow struct {
+	// What the row describes, e.g., a txrep field name or "operation 0"
+	Label string
+
+	// The value of the field or the description of the operation
+	Value string
+
+	// Optional annotation, such as an account's comment
+	Comment string
+
+	Severity Severity
+}
+
+func (r RenderRow) String() string {
+	if r.Comment != "" {
+		return fmt.Sprintf("%s: %s (%s)", r.Label, r.Value, r.Comment)
+	}
+	return fmt.Sprintf("%s: %s", r.Label, r.Value)
+}
+
+// Returns the Txrep of an XDR structure (as written by WriteRep) as
+// one row per field.  Signatures that cannot be verified and fields
+// that cannot be rendered are flagged with SeverityError.
+func (net *StellarNet) TxrepRows(txe xdr.XdrType) []RenderRow {
+	rows, bad := stcdetail.XdrToTxrepRows("", net.annotate(txe))
+	ret := make([]RenderRow, 0, len(rows)+len(bad))
+	for _, r := range rows {
+		row := RenderRow{Label: r.Field, Value: r.Value, Comment: r.Comment}
+		if net != nil && strings.HasSuffix(r.Field, ".hint") &&
+			r.Comment == net.badSigNote() {
+			row.Severity = SeverityError
+		}
+		ret = append(ret, row)
+	}
+	for _, b := range bad {
+		ret = append(ret, RenderRow{
+			Label:    b.Field,
+			Comment:  b.Msg,
+			Severity: SeverityError,
+		})
+	}
+	return ret
+}
+
+// Returns the plain-English description of each operation in e (see
+// ExplainOps), followed by any dangers TxRisks finds, with rc as in
+// TxRisks.  Operation rows are labeled "operation N" (or "fee bump"
+// for the fee-bump wrapper), and risks have SeverityWarning.
+func (net *StellarNet) ExplainRows(e *TransactionEnvelope,
+	rc *RiskContext) []RenderRow {
+	var ret []RenderRow
+	ops := net.ExplainOps(e)
+	if e.Type == stx.ENVELOPE_TYPE_TX_FEE_BUMP && len(ops) > 0 {
+		ret = append(ret, RenderRow{Label: "fee bump", Value: ops[0]})
+		ops = ops[1:]
+	}
+	for i, op := range ops {
+		ret = append(ret, RenderRow{
+			Label: fmt.Sprintf("operation %d", i),
+			Value: op,
+		})
+	}
+	for _, r := range net.TxRisks(e, rc) {
+		label := "transaction"
+		if r.Op >= 0 {
+			label = fmt.Sprintf("operation %d", r.Op)
+		}
+		ret = append(ret, RenderRow{
+			Label:    label,
+			Value:    r.Message,
+			Severity: SeverityWarning,
+		})
+	}
+	return ret
+}
+
+// Returns one row per signature on e, labeled as in Txrep, whose value
+// is the signature hint and whose comment is the known signer that
+// produced the signature.  Signatures not valid for any signer in
+// net.Signers have SeverityError.
+func (net *StellarNet) SignatureRows(e *TransactionEnvelope) []RenderRow {
+	cands := net.SigCandidates(e)
+	prefix := "signatures"
+	if e.Type == stx.ENVELOPE_TYPE_TX_FEE_BUMP {
+		prefix = "feeBump.signatures"
+	}
+	ret := make([]RenderRow, len(cands))
+	for i, sig := range *e.Signatures() {
+		ret[i] = RenderRow{
+			Label:    fmt.Sprintf("%s[%d]", prefix, i),
+			Value:    fmt.Sprintf("%x", sig.Hint),
+			Severity: SeverityError,
+		}
+		for _, c := range cands[i] {
+			if c.Valid {
+				ret[i].Comment = c.SignerKeyInfo.String()
+				ret[i].Severity = SeverityInfo
+				break
+			}
+		}
+		if ret[i].Severity == SeverityError {
+			ret[i].Comment = "no known key produced this signature"
+		}
+	}
+	return ret
+}
@@ -0,0 +1,150 @@
+package stc
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// How requests to horizon are retried when horizon is rate-limiting
+// the client (HTTP 429), returns a server error (HTTP 5xx other than
+// 501 Not Implemented, which retrying cannot fix), or cannot be
+// reached because of a timeout or other transient network error.
+// Resubmitting a transaction is safe, since the network executes a
+// given transaction at most once.
+type RetryPolicy struct {
+	// Maximum number of times to retry a request (0 to never retry).
+	MaxRetries int
+
+	// Delay before the first retry.  The delay doubles with each
+	// subsequent retry, up to MaxDelay.  A random jitter of up to 25%
+	// is added to each delay, so that many clients rate-limited at
+	// the same time do not all retry at once.
+	InitialDelay time.Duration
+
+	// Upper bound on the delay between retries (0 for no bound).
+	MaxDelay time.Duration
+
+	// The StellarNet's hooks, whose OnRetry is called before retries.
+	hooks *HTTPHooks
+}
+
+// The RetryPolicy used when StellarNet.Retry is nil.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:   5,
+	InitialDelay: time.Second,
+	MaxDelay:     time.Minute,
+}
+
+// Returns the RetryPolicy for net.
+func (net *StellarNet) retryPolicy() *RetryPolicy {
+	rp := &DefaultRetryPolicy
+	if net.Retry != nil {
+		rp = net.Retry
+	}
+	if net.Hooks != nil && net.Hooks.OnRetry != nil {
+		c := *rp
+		c.hooks = net.Hooks
+		rp = &c
+	}
+	return rp
+}
+
+// Returns true if an HTTP response with status code may succeed if
+// the request is retried.
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests ||
+		(code >= 500 && code != http.StatusNotImplemented)
+}
+
+// Returns true if a failed request may succeed if retried.
+func retryableErr(err error) bool {
+	var t interface{ Timeout() bool }
+	return IsTemporary(err) || (errors.As(err, &t) && t.Timeout())
+}
+
+// Returns the delay requested by a Retry-After header (either a
+// number of seconds or an HTTP date), or def if there is none.
+func retryAfter(h http.Header, def time.Duration) time.Duration {
+	ra := h.Get("Retry-After")
+	if ra == "" {
+		return def
+	} else if secs, err := strconv.Atoi(ra); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(ra); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return def
+}
+
+// Returns an upper bound on how long rp.do can spend on a request
+// sent with client c (not counting delays a server requests with
+// Retry-After), or 0 if there is none because c has no Timeout.
+func (rp *RetryPolicy) maxDuration(c *http.Client) time.Duration {
+	if c.Timeout <= 0 {
+		return 0
+	}
+	ret := time.Duration(rp.MaxRetries+1) * c.Timeout
+	delay := rp.InitialDelay
+	for try := 0; try < rp.MaxRetries; try++ {
+		ret += delay + delay/4
+		if delay *= 2; rp.MaxDelay > 0 && delay > rp.MaxDelay {
+			delay = rp.MaxDelay
+		}
+	}
+	return ret
+}
+
+// Sends req with client c, retrying according to rp.  Gives up early
+// if req's context is done.  A request with a body must have GetBody
+// set (as http.NewRequest does for common body types).  When retries
+// are exhausted, returns the last response even if its status code
+// indicates failure.
+func (rp *RetryPolicy) do(c *http.Client, req *http.Request) (
+	*http.Response, error) {
+	ctx := req.Context()
+	delay := rp.InitialDelay
+	for try := 0; ; try++ {
+		if try > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		resp, err := c.Do(req)
+		var wait time.Duration
+		switch {
+		case try >= rp.MaxRetries || ctx.Err() != nil:
+			return resp, err
+		case err != nil:
+			if !retryableErr(err) {
+				return nil, err
+			}
+			wait = delay
+		case retryableStatus(resp.StatusCode):
+			wait = retryAfter(resp.Header, delay)
+			resp.Body.Close()
+			err = &HorizonError{Status: resp.StatusCode}
+		default:
+			return resp, nil
+		}
+		wait += time.Duration(rand.Int63n(int64(wait)/4 + 1))
+		if rp.hooks != nil && rp.hooks.OnRetry != nil {
+			rp.hooks.OnRetry(req, try+1, wait, err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		if delay *= 2; rp.MaxDelay > 0 && delay > rp.MaxDelay {
+			delay = rp.MaxDelay
+		}
+	}
+}
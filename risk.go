@@ -0,0 +1,276 @@
+package stc
+
+import (
+	"fmt"
+	"github.com/xdrpp/stc/horizon"
+	"github.com/xdrpp/stc/stx"
+)
+
+// A potentially dangerous aspect of a transaction, as reported by
+// TxRisks.
+type TxRisk struct {
+	// Index of the offending operation in the (inner) transaction,
+	// or -1 if the danger is not specific to one operation.
+	Op int
+
+	// Human-readable description of the danger.
+	Message string
+}
+
+func (r TxRisk) String() string {
+	if r.Op < 0 {
+		return r.Message
+	}
+	return fmt.Sprintf("operation %d: %s", r.Op, r.Message)
+}
+
+// Account state consulted by TxRisks.  Fields may be nil or incomplete,
+// in which case TxRisks skips checks that depend on the missing
+// information.
+type RiskContext struct {
+	// Current entries of accounts, indexed by strkey.
+	Accounts map[string]*HorizonAccountEntry
+
+	// Accounts known to have issued assets, indexed by strkey.
+	Issuers map[string]bool
+}
+
+// Fetches the state TxRisks needs to check e from horizon:  the
+// entries of accounts whose options e sets or that e merges, and
+// which of the merged accounts are issuers.  Accounts that cannot be
+// fetched (e.g., because they do not exist yet) are omitted from the
+// result; the first such error is returned along with whatever state
+// could be fetched.
+func (net *StellarNet) GetRiskContext(e *TransactionEnvelope) (
+	*RiskContext, error) {
+	ret := &RiskContext{
+		Accounts: make(map[string]*HorizonAccountEntry),
+		Issuers:  make(map[string]bool),
+	}
+	var err error
+	setErr := func(e error) {
+		if err == nil {
+			err = e
+		}
+	}
+	inner := innerTx(e)
+	ops := inner.Operations()
+	if ops == nil {
+		return ret, nil
+	}
+	for i := range *ops {
+		op := &(*ops)[i]
+		switch op.Body.Type {
+		case stx.SET_OPTIONS, stx.ACCOUNT_MERGE:
+		default:
+			continue
+		}
+		acct := opSource(inner, op).String()
+		if _, ok := ret.Accounts[acct]; !ok {
+			if ae, e := net.GetAccountEntry(acct); e != nil {
+				setErr(e)
+			} else {
+				ret.Accounts[acct] = ae
+			}
+		}
+		if _, ok := ret.Issuers[acct]; op.Body.Type == stx.ACCOUNT_MERGE &&
+			!ok {
+			var assets horizon.Page
+			if e := net.GetJSON("assets?limit=1&asset_issuer="+acct,
+				&assets); e != nil {
+				setErr(e)
+			} else {
+				ret.Issuers[acct] = len(assets.Embedded.Records) > 0
+			}
+		}
+	}
+	return ret, err
+}
+
+// Returns the (non-muxed) effective source account of op in
+// transaction e.
+func opSource(e *TransactionEnvelope, op *stx.Operation) *AccountID {
+	src := op.SourceAccount
+	if src == nil {
+		src = e.SourceAccount()
+	}
+	acct, _ := DemuxAcct(src)
+	return acct
+}
+
+// Signing configuration of an account as TxRisks simulates it.
+type riskAcct struct {
+	weights    map[string]uint32
+	thresholds [3]uint32
+}
+
+func newRiskAcct(ae *HorizonAccountEntry) *riskAcct {
+	ret := &riskAcct{
+		weights: make(map[string]uint32),
+		thresholds: [3]uint32{
+			uint32(ae.Thresholds.Low_threshold),
+			uint32(ae.Thresholds.Med_threshold),
+			uint32(ae.Thresholds.High_threshold),
+		},
+	}
+	for _, s := range ae.Signers {
+		if s.Weight > 0 {
+			ret.weights[s.Key.String()] = s.Weight
+		}
+	}
+	return ret
+}
+
+func (ra *riskAcct) totalWeight() uint32 {
+	var ret uint32
+	for _, w := range ra.weights {
+		if w > 255 {
+			w = 255
+		}
+		ret += w
+	}
+	return ret
+}
+
+func (ra *riskAcct) apply(acct string, op *stx.SetOptionsOp) {
+	if op.MasterWeight != nil {
+		if *op.MasterWeight == 0 {
+			delete(ra.weights, acct)
+		} else {
+			ra.weights[acct] = *op.MasterWeight
+		}
+	}
+	for i, t := range []*uint32{
+		op.LowThreshold, op.MedThreshold, op.HighThreshold,
+	} {
+		if t != nil {
+			ra.thresholds[i] = *t
+		}
+	}
+	if op.Signer != nil {
+		if key := op.Signer.Key.String(); op.Signer.Weight == 0 {
+			delete(ra.weights, key)
+		} else {
+			ra.weights[key] = op.Signer.Weight
+		}
+	}
+}
+
+// Checks a transaction for dangerous patterns, namely operations
+// that:
+//
+//   - leave an account's total signer weight below one of its
+//     thresholds, locking the account (or part of its functionality)
+//     forever;
+//   - remove an account's last signer;
+//   - merge an account that has issued assets, stranding its holders;
+//   - enable clawback on an account, which cannot later be disabled
+//     for trustlines created in the meantime;
+//   - create trustlines with no limit to issuers that have no comment
+//     in net.Accounts.
+//
+// If net.TxDefaults.RequireMemo is set, transactions without a memo
+// are also reported.
+//
+// The first three checks require account state from rc, which can be
+// obtained with GetRiskContext; rc may be nil for offline use, in
+// which case those checks are skipped.  Changes made by earlier
+// operations in the same transaction are taken into account, so that,
+// e.g., adding a new signer and then zeroing the master key weight is
+// not flagged.
+func (net *StellarNet) TxRisks(e *TransactionEnvelope,
+	rc *RiskContext) []TxRisk {
+	var ret []TxRisk
+	inner := innerTx(e)
+	ops := inner.Operations()
+	if ops == nil {
+		return nil
+	}
+	if rc == nil {
+		rc = &RiskContext{}
+	}
+	if net.TxDefaults.RequireMemo {
+		var memo *stx.Memo
+		switch inner.Type {
+		case stx.ENVELOPE_TYPE_TX_V0:
+			memo = &inner.V0().Tx.Memo
+		case stx.ENVELOPE_TYPE_TX:
+			memo = &inner.V1().Tx.Memo
+		}
+		if memo != nil && memo.Type == stx.MEMO_NONE {
+			ret = append(ret, TxRisk{Op: -1,
+				Message: "transaction has no memo, which the network's " +
+					"configuration requires"})
+		}
+	}
+	accts := make(map[string]*riskAcct)
+	for i := range *ops {
+		op := &(*ops)[i]
+		srcID := opSource(inner, op)
+		src, desc := srcID.String(), net.DescribeAccount(srcID)
+		risk := func(format string, args ...interface{}) {
+			ret = append(ret, TxRisk{Op: i,
+				Message: fmt.Sprintf(format, args...)})
+		}
+		switch op.Body.Type {
+		case stx.SET_OPTIONS:
+			so := op.Body.SetOptionsOp()
+			if so.SetFlags != nil &&
+				*so.SetFlags&uint32(stx.AUTH_CLAWBACK_ENABLED_FLAG) != 0 {
+				risk("enables clawback on %s; new trustlines to its "+
+					"assets can be clawed back", desc)
+			}
+			ra := accts[src]
+			if ra == nil {
+				ae := rc.Accounts[src]
+				if ae == nil {
+					continue
+				}
+				ra = newRiskAcct(ae)
+				accts[src] = ra
+			}
+			before := len(ra.weights)
+			ra.apply(src, so)
+			total := ra.totalWeight()
+			if total == 0 && before > 0 {
+				risk("removes the last signer of %s, locking it forever",
+					desc)
+				continue
+			}
+			for j, name := range []string{"low", "medium", "high"} {
+				need := ra.thresholds[j]
+				if need == 0 {
+					need = 1
+				}
+				if total < need {
+					risk("total signer weight %d of %s is below its %s "+
+						"threshold %d, locking it forever", total,
+						desc, name, need)
+					break
+				}
+			}
+		case stx.ACCOUNT_MERGE:
+			if rc.Issuers[src] {
+				risk("merges %s, which has issued assets", desc)
+			}
+		case stx.CHANGE_TRUST:
+			ct := op.Body.ChangeTrustOp()
+			if ct.Limit != MaxInt64 {
+				continue
+			}
+			var issuer *AccountID
+			switch ct.Line.Type {
+			case stx.ASSET_TYPE_CREDIT_ALPHANUM4:
+				issuer = &ct.Line.AlphaNum4().Issuer
+			case stx.ASSET_TYPE_CREDIT_ALPHANUM12:
+				issuer = &ct.Line.AlphaNum12().Issuer
+			default:
+				continue
+			}
+			if net.AccountIDNote(issuer.String()) == "" {
+				risk("trusts unknown issuer %s without limit", issuer)
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,212 @@
+package stc
+
+import (
+	"fmt"
+	"github.com/xdrpp/stc/ini"
+	"github.com/xdrpp/stc/stcdetail"
+	"github.com/xdrpp/stc/stx"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Suffix of the files in which scheduled transactions are stored.
+const ScheduledTxSuffix = ".job"
+
+// How long a scheduled transaction remains valid after it is
+// instantiated when the network's TxDefaults.Timeout is not set.
+const DefaultScheduleTimeout = 5 * time.Minute
+
+// A transaction template to be submitted on a recurring schedule,
+// such as a monthly payment.  Each time the transaction is due,
+// RefreshTx gives a copy of Tx a fresh sequence number, fee, and time
+// bounds, and then it is signed and submitted.  Scheduled
+// transactions are stored in INI files, normally in
+// ConfigPath("schedule"), of the form:
+//
+//	[schedule]
+//		cron = 0 9 1 * *
+//		network = main
+//		key = treasury
+//		last-run = 1767258000
+//		tx = AAAAAgAAAAB...
+type ScheduledTx struct {
+	// When to submit the transaction, as a crontab(5) time
+	// specification (see stcdetail.ParseCron), in local time
+	Cron string `ini:"cron"`
+
+	// Name of the network on which to submit the transaction
+	Network string `ini:"network"`
+
+	// Name of the private key that signs the transaction
+	Key string `ini:"key"`
+
+	// Unix time at which the transaction was last submitted (or the
+	// schedule created), from which the next run is computed
+	LastRun int64 `ini:"last-run"`
+
+	// The transaction template in base64 XDR
+	Tx string `ini:"tx"`
+}
+
+// Returns a ScheduledTx that submits e on network according to cron,
+// signed by key, with the first run at the next time matching cron.
+func NewScheduledTx(cron, network, key string,
+	e *TransactionEnvelope) (*ScheduledTx, error) {
+	if _, err := stcdetail.ParseCron(cron); err != nil {
+		return nil, err
+	}
+	return &ScheduledTx{
+		Cron:    cron,
+		Network: network,
+		Key:     key,
+		LastRun: time.Now().Unix(),
+		Tx:      TxToBase64(e),
+	}, nil
+}
+
+// Returns the next time at which the transaction should be submitted.
+func (s *ScheduledTx) Next() (time.Time, error) {
+	c, err := stcdetail.ParseCron(s.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := c.Next(time.Unix(s.LastRun, 0))
+	if next.IsZero() {
+		return next, fmt.Errorf("schedule %q never matches", s.Cron)
+	}
+	return next, nil
+}
+
+// Returns a copy of the transaction template.
+func (s *ScheduledTx) Template() (*TransactionEnvelope, error) {
+	return TxFromBase64(s.Tx)
+}
+
+// Writes the scheduled transaction to a file.
+func (s *ScheduledTx) Save(path string) error {
+	out := &strings.Builder{}
+	fmt.Fprintf(out, "[schedule]\n")
+	for _, kv := range [][2]string{
+		{"cron", s.Cron},
+		{"network", s.Network},
+		{"key", s.Key},
+		{"last-run", fmt.Sprint(s.LastRun)},
+		{"tx", s.Tx},
+	} {
+		fmt.Fprintf(out, "\t%s = %s\n", kv[0], ini.EscapeIniValue(kv[1]))
+	}
+	return stcdetail.SafeWriteFile(path, out.String(), 0600)
+}
+
+// Reads a scheduled transaction saved with ScheduledTx.Save.
+func LoadScheduledTx(path string) (*ScheduledTx, error) {
+	contents, _, err := stcdetail.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ret ScheduledTx
+	sink := ini.NewGenericSink("schedule")
+	sink.AddStruct(&ret)
+	if err = ini.IniParseContents(sink, path, contents); err != nil {
+		return nil, err
+	} else if ret.Cron == "" || ret.Network == "" || ret.Key == "" ||
+		ret.Tx == "" {
+		return nil, fmt.Errorf("%s: missing cron, network, key, or tx", path)
+	}
+	return &ret, nil
+}
+
+// Returns the names of the scheduled transactions in directory dir
+// (normally ConfigPath("schedule")), sorted.  A transaction named
+// NAME is stored in file NAME + ScheduledTxSuffix.
+func ScheduledTxNames(dir string) ([]string, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, fi := range fis {
+		if name := fi.Name(); !fi.IsDir() &&
+			strings.HasSuffix(name, ScheduledTxSuffix) {
+			ret = append(ret, strings.TrimSuffix(name, ScheduledTxSuffix))
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// Returns the path of the file storing the scheduled transaction name
+// in directory dir.
+func ScheduledTxPath(dir, name string) string {
+	return filepath.Join(dir, name+ScheduledTxSuffix)
+}
+
+// Gives e a fresh sequence number, fee, and time bounds so that it can
+// be submitted now:  the sequence number comes from net.NextSequence,
+// the fee is the network's DefaultFee, and the transaction expires
+// after TxDefaults.Timeout (or DefaultScheduleTimeout).
+func (net *StellarNet) RefreshTx(e *TransactionEnvelope) error {
+	if e.Type == stx.ENVELOPE_TYPE_TX_FEE_BUMP {
+		return fmt.Errorf("cannot refresh a fee-bump transaction")
+	}
+	fs, err := net.GetFeeStats()
+	if err != nil {
+		return err
+	}
+	e.SetFee(net.DefaultFee(fs))
+	if err = net.SetNextSequence(e); err != nil {
+		return err
+	}
+	timeout := net.TxDefaults.Timeout
+	if timeout <= 0 {
+		timeout = DefaultScheduleTimeout
+	}
+	e.SetTimeBounds(0, stx.TimePoint(time.Now().Add(timeout).Unix()))
+	return nil
+}
+
+// Instantiates the template of s with RefreshTx and signs it with sk,
+// returning a transaction ready to post.  Since nobody reviews the
+// transaction before it is signed, the following policy is enforced:
+// sk must be one of net.Approvers (if any are configured), and the
+// transaction must have no risks according to TxRisks, properly
+// paired sponsorships, and enough approvals for CheckApprovals.
+func (net *StellarNet) PrepareScheduledTx(s *ScheduledTx,
+	sk stcdetail.PrivateKeyInterface) (*TransactionEnvelope, error) {
+	if s.Network != net.Name {
+		return nil, fmt.Errorf("scheduled for network %s, not %s",
+			s.Network, net.Name)
+	}
+	if len(net.Approvers) > 0 {
+		if _, ok := net.Approvers[sk.Public().String()]; !ok {
+			return nil, fmt.Errorf("key %s is not an approver",
+				sk.Public())
+		}
+	}
+	e, err := s.Template()
+	if err != nil {
+		return nil, err
+	}
+	*e.Signatures() = nil
+	if err = net.RefreshTx(e); err != nil {
+		return nil, err
+	} else if err = CheckSponsorships(e); err != nil {
+		return nil, err
+	}
+	rc, _ := net.GetRiskContext(e)
+	if risks := net.TxRisks(e, rc); len(risks) > 0 {
+		return nil, fmt.Errorf("refusing risky transaction: %s", risks[0])
+	}
+	if err = net.SignTx(sk, e); err != nil {
+		return nil, err
+	} else if err = net.CheckApprovals(e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -0,0 +1,154 @@
+package stc
+
+import (
+	"fmt"
+	"github.com/xdrpp/stc/stx"
+	"sync"
+)
+
+// A source of sequence numbers for new transactions.  By default,
+// StellarNet.NextSequence looks up the account on horizon, which
+// races with any other transaction submitted from the same account.
+// To build many transactions from one account, set
+// StellarNet.Sequences to a provider that hands out consecutive
+// numbers, such as a SequenceCache, a SequenceRange, or your own
+// implementation backed by a database.
+type SequenceProvider interface {
+	// Reserves and returns the sequence number of the next
+	// transaction from acct.  Each call must return a different
+	// number.
+	NextSequence(acct AccountID) (stx.SequenceNumber, error)
+}
+
+// A SequenceProvider that looks up each account on horizon, which
+// returns the same number until a transaction from the account is
+// included in a ledger.  The lookup always goes to Net.Horizon, where
+// Post submits transactions, rather than to a mirror that may lag
+// behind it.
+type HorizonSequences struct {
+	Net *StellarNet
+}
+
+func (hs HorizonSequences) NextSequence(
+	acct AccountID) (stx.SequenceNumber, error) {
+	ae, err := hs.Net.getSubmitAccountEntry(nil, acct.String())
+	if err != nil {
+		return 0, err
+	}
+	return ae.NextSeq(), nil
+}
+
+// Implemented by a SequenceProvider that caches sequence numbers.
+// When Post or PostAsync learns that a transaction was not included
+// in a ledger, and hence did not use up its sequence number, it calls
+// InvalidateSequence with the transaction's source account, since
+// sequence numbers handed out afterwards would otherwise leave a gap
+// and cause every later transaction to fail with txBAD_SEQ.
+type SequenceInvalidator interface {
+	InvalidateSequence(acct AccountID)
+}
+
+// Tells net.Sequences that e did not use up its sequence number.
+func (net *StellarNet) invalidateSequence(e *TransactionEnvelope) {
+	si, ok := net.Sequences.(SequenceInvalidator)
+	if !ok {
+		return
+	}
+	if acct, _ := DemuxAcct(innerTx(e).SourceAccount()); acct != nil {
+		si.InvalidateSequence(*acct)
+	}
+}
+
+// Implements SequenceInvalidator by removing acct from the cache, so
+// that NextSequence fails for acct until its sequence number is
+// fetched again (e.g., with PrefetchSequences) and recorded with Set.
+func (sc *SequenceCache) InvalidateSequence(acct AccountID) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	delete(sc.seqs, acct.String())
+}
+
+// Implements SequenceProvider, returning an error for accounts not
+// in the cache.
+func (sc *SequenceCache) NextSequence(
+	acct AccountID) (stx.SequenceNumber, error) {
+	if seq, ok := sc.Next(acct); ok {
+		return seq, nil
+	}
+	return 0, fmt.Errorf("no cached sequence number for %s", acct)
+}
+
+// A SequenceProvider for a single account that hands out a range of
+// sequence numbers allocated in advance, for example when building
+// transactions offline to be submitted in order later.  Safe for
+// concurrent use.
+type SequenceRange struct {
+	lock    sync.Mutex
+	account string
+	next    stx.SequenceNumber
+	last    stx.SequenceNumber
+}
+
+// Returns a SequenceRange handing out first through last (inclusive)
+// for acct.
+func NewSequenceRange(acct AccountID,
+	first, last stx.SequenceNumber) *SequenceRange {
+	return &SequenceRange{account: acct.String(), next: first, last: last}
+}
+
+// Returns the number of sequence numbers left in the range.
+func (sr *SequenceRange) Remaining() int64 {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+	if sr.next > sr.last {
+		return 0
+	}
+	return int64(sr.last-sr.next) + 1
+}
+
+func (sr *SequenceRange) NextSequence(
+	acct AccountID) (stx.SequenceNumber, error) {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+	if acct.String() != sr.account {
+		return 0, fmt.Errorf("sequence range is for %s, not %s",
+			sr.account, acct)
+	} else if sr.next > sr.last {
+		return 0, fmt.Errorf("sequence range for %s exhausted", acct)
+	}
+	ret := sr.next
+	sr.next++
+	return ret, nil
+}
+
+// Returns the sequence number of the next transaction from acct,
+// from net.Sequences if set, or otherwise from horizon.
+func (net *StellarNet) NextSequence(
+	acct AccountID) (stx.SequenceNumber, error) {
+	if net.Sequences != nil {
+		return net.Sequences.NextSequence(acct)
+	}
+	return HorizonSequences{net}.NextSequence(acct)
+}
+
+// Sets the sequence number of e to the next one for its source
+// account, according to net.NextSequence.  Fails for fee-bump
+// transactions, which have no sequence number.
+func (net *StellarNet) SetNextSequence(e *TransactionEnvelope) error {
+	if e.Type != stx.ENVELOPE_TYPE_TX && e.Type != stx.ENVELOPE_TYPE_TX_V0 {
+		return fmt.Errorf("%s has no sequence number", e.Type)
+	}
+	acct, _ := DemuxAcct(e.SourceAccount())
+	if acct == nil {
+		return fmt.Errorf("transaction has no source account")
+	}
+	seq, err := net.NextSequence(*acct)
+	if err != nil {
+		return err
+	} else if e.Type == stx.ENVELOPE_TYPE_TX {
+		e.V1().Tx.SeqNum = seq
+	} else {
+		e.V0().Tx.SeqNum = seq
+	}
+	return nil
+}
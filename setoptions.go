@@ -0,0 +1,119 @@
+package stc
+
+import (
+	"github.com/xdrpp/goxdr/xdr"
+	"github.com/xdrpp/stc/stx"
+)
+
+// Account flags for SetOptionsBuilder.SetFlags and ClearFlags.
+const (
+	AuthRequiredFlag        = stx.AUTH_REQUIRED_FLAG
+	AuthRevocableFlag       = stx.AUTH_REVOCABLE_FLAG
+	AuthImmutableFlag       = stx.AUTH_IMMUTABLE_FLAG
+	AuthClawbackEnabledFlag = stx.AUTH_CLAWBACK_ENABLED_FLAG
+)
+
+// Builds a SET_OPTIONS operation, setting only the fields requested.
+// Methods return the builder so that calls can be chained, as in:
+//
+//	txe.Append(nil, NewSetOptions().
+//		AddSigner(backup.ToSignerKey(), 1).
+//		SetThresholds(1, 2, 2).
+//		SetFlags(AuthRequiredFlag, AuthRevocableFlag).
+//		Op())
+type SetOptionsBuilder struct {
+	op SetOptions
+}
+
+// Returns a builder for a SET_OPTIONS operation that does nothing.
+func NewSetOptions() *SetOptionsBuilder {
+	return &SetOptionsBuilder{}
+}
+
+// Returns the operation built so far.
+func (b *SetOptionsBuilder) Op() SetOptions {
+	return b.op
+}
+
+// Sets the low, medium, and high thresholds.
+func (b *SetOptionsBuilder) SetThresholds(low, med,
+	high uint8) *SetOptionsBuilder {
+	b.op.LowThreshold = NewUint(uint32(low))
+	b.op.MedThreshold = NewUint(uint32(med))
+	b.op.HighThreshold = NewUint(uint32(high))
+	return b
+}
+
+// Sets the weight of the account's master key.
+func (b *SetOptionsBuilder) SetMasterWeight(weight uint8) *SetOptionsBuilder {
+	b.op.MasterWeight = NewUint(uint32(weight))
+	return b
+}
+
+// Adds a signer to the account, or changes its weight if it is already
+// a signer.  A SET_OPTIONS operation can only change one signer, so
+// this panics if the operation already adds or removes a signer.
+func (b *SetOptionsBuilder) AddSigner(key stx.SignerKey,
+	weight uint8) *SetOptionsBuilder {
+	if b.op.Signer != nil {
+		xdr.XdrPanic("SetOptionsBuilder: operation already changes signer %s",
+			b.op.Signer.Key)
+	}
+	b.op.Signer = &stx.Signer{Key: key, Weight: uint32(weight)}
+	return b
+}
+
+// Removes a signer from the account (by setting its weight to 0).
+// Panics if the operation already adds or removes a signer.
+func (b *SetOptionsBuilder) RemoveSigner(key stx.SignerKey) *SetOptionsBuilder {
+	return b.AddSigner(key, 0)
+}
+
+// Sets the account's home domain, or clears it if domain is empty.
+func (b *SetOptionsBuilder) SetHomeDomain(domain string) *SetOptionsBuilder {
+	b.op.HomeDomain = NewString(domain)
+	return b
+}
+
+// Sets the account's inflation destination.
+func (b *SetOptionsBuilder) SetInflationDest(
+	dest AccountID) *SetOptionsBuilder {
+	b.op.InflationDest = NewAccountID(dest)
+	return b
+}
+
+func updateFlags(p **uint32, other *uint32, flags []stx.AccountFlags) {
+	if *p == nil {
+		*p = NewUint(0)
+	}
+	for _, f := range flags {
+		**p |= uint32(f)
+		if other != nil {
+			*other &^= uint32(f)
+		}
+	}
+}
+
+// Sets account flags (AuthRequiredFlag, etc.).  A flag set here is no
+// longer cleared by the operation if it was passed to ClearFlags.
+func (b *SetOptionsBuilder) SetFlags(
+	flags ...stx.AccountFlags) *SetOptionsBuilder {
+	updateFlags(&b.op.SetFlags, b.op.ClearFlags, flags)
+	return b
+}
+
+// Clears account flags (AuthRequiredFlag, etc.).  A flag cleared here
+// is no longer set by the operation if it was passed to SetFlags.
+func (b *SetOptionsBuilder) ClearFlags(
+	flags ...stx.AccountFlags) *SetOptionsBuilder {
+	updateFlags(&b.op.ClearFlags, b.op.SetFlags, flags)
+	return b
+}
+
+// Sets AuthClawbackEnabledFlag, along with AuthRevocableFlag, which
+// the network requires of accounts with clawback enabled.  Trustlines
+// to the account's assets created afterwards allow the account to
+// claw back the asset (see NewClawback).
+func (b *SetOptionsBuilder) EnableClawback() *SetOptionsBuilder {
+	return b.SetFlags(AuthRevocableFlag, AuthClawbackEnabledFlag)
+}
@@ -0,0 +1,411 @@
+package stc
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"github.com/xdrpp/stc/stcdetail"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A location shared by the parties who must sign a transaction, into
+// which each drops a partially signed copy (see CollectTxFrom and
+// ShareTxTo).  DirShare, WebDAVShare, and S3Share implement TxShare.
+type TxShare interface {
+	// Returns the names of the files in the share.
+	List() ([]string, error)
+	// Returns the contents of the named file.
+	Read(name string) ([]byte, error)
+	// Creates or replaces the named file.
+	Write(name string, contents []byte) error
+	// Describes the share (without any credentials) for messages.
+	String() string
+}
+
+// Returns the TxShare for location, which may be an s3://BUCKET/PREFIX
+// URL, an http:// or https:// URL of a WebDAV collection (optionally
+// with a USER:PASSWORD@ in front of the host), or else a directory.
+// S3 credentials come from the environment variables
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN,
+// the region from AWS_REGION (default us-east-1), and the endpoint
+// from AWS_ENDPOINT_URL (default Amazon's endpoint for the region).
+func OpenTxShare(location string) (TxShare, error) {
+	switch {
+	case strings.HasPrefix(location, "s3://"):
+		bucket := strings.TrimPrefix(location, "s3://")
+		prefix := ""
+		if i := strings.IndexByte(bucket, '/'); i >= 0 {
+			bucket, prefix = bucket[:i], strings.Trim(bucket[i+1:], "/")
+			if prefix != "" {
+				prefix += "/"
+			}
+		}
+		if bucket == "" {
+			return nil, fmt.Errorf("%s: missing bucket name", location)
+		}
+		s := &S3Share{
+			Region:       os.Getenv("AWS_REGION"),
+			Endpoint:     os.Getenv("AWS_ENDPOINT_URL"),
+			Bucket:       bucket,
+			Prefix:       prefix,
+			AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if s.AccessKey == "" || s.SecretKey == "" {
+			return nil, fmt.Errorf("%s: AWS_ACCESS_KEY_ID and "+
+				"AWS_SECRET_ACCESS_KEY must be set", location)
+		}
+		if s.Region == "" {
+			s.Region = "us-east-1"
+		}
+		if s.Endpoint == "" {
+			s.Endpoint = "https://s3." + s.Region + ".amazonaws.com"
+		}
+		return s, nil
+	case strings.HasPrefix(location, "http://"),
+		strings.HasPrefix(location, "https://"):
+		u, err := url.Parse(location)
+		if err != nil {
+			return nil, err
+		}
+		w := &WebDAVShare{}
+		if u.User != nil {
+			w.Username = u.User.Username()
+			w.Password, _ = u.User.Password()
+			u.User = nil
+		}
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+			u.RawPath = ""
+		}
+		w.URL = u.String()
+		return w, nil
+	}
+	return DirShare(location), nil
+}
+
+// A TxShare that is a directory, for instance on a network file
+// system.
+type DirShare string
+
+func (d DirShare) List() ([]string, error) {
+	fis, err := ioutil.ReadDir(string(d))
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, fi := range fis {
+		if fi.Mode().IsRegular() {
+			ret = append(ret, fi.Name())
+		}
+	}
+	return ret, nil
+}
+
+func (d DirShare) Read(name string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(string(d), name))
+}
+
+func (d DirShare) Write(name string, contents []byte) error {
+	return stcdetail.SafeWriteFile(filepath.Join(string(d), name),
+		string(contents), 0666)
+}
+
+func (d DirShare) String() string {
+	return string(d)
+}
+
+// A TxShare that is a collection on a WebDAV server.
+type WebDAVShare struct {
+	// URL of the collection, ending in "/"
+	URL string
+
+	// Credentials for HTTP basic authentication, if Username is not
+	// empty
+	Username, Password string
+
+	// Client to use, or nil for DefaultHTTPClient
+	Client *http.Client
+}
+
+func (w *WebDAVShare) do(method, name string, body []byte,
+	header map[string]string) ([]byte, error) {
+	req, err := http.NewRequest(method, w.URL+url.PathEscape(name),
+		bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	if w.Username != "" {
+		req.SetBasicAuth(w.Username, w.Password)
+	}
+	c := w.Client
+	if c == nil {
+		c = DefaultHTTPClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	ret, err := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s %s%s: %s", method, w, name, resp.Status)
+	}
+	return ret, err
+}
+
+// The parts of a WebDAV PROPFIND response that List needs.
+type davMultistatus struct {
+	Responses []struct {
+		Href       string    `xml:"href"`
+		Collection *struct{} `xml:"propstat>prop>resourcetype>collection"`
+	} `xml:"response"`
+}
+
+func (w *WebDAVShare) List() ([]string, error) {
+	body, err := w.do("PROPFIND", "", []byte(`<?xml version="1.0"?>`+
+		`<propfind xmlns="DAV:"><prop><resourcetype/></prop></propfind>`),
+		map[string]string{
+			"Depth":        "1",
+			"Content-Type": `application/xml; charset="utf-8"`,
+		})
+	if err != nil {
+		return nil, err
+	}
+	var ms davMultistatus
+	if err = xml.Unmarshal(body, &ms); err != nil {
+		return nil, fmt.Errorf("PROPFIND %s: %w", w, err)
+	}
+	var ret []string
+	for _, r := range ms.Responses {
+		if r.Collection != nil {
+			continue
+		}
+		href := r.Href
+		if u, err := url.Parse(href); err == nil {
+			href = u.Path
+		}
+		if name := path.Base(href); name != "/" && name != "." {
+			ret = append(ret, name)
+		}
+	}
+	return ret, nil
+}
+
+func (w *WebDAVShare) Read(name string) ([]byte, error) {
+	return w.do("GET", name, nil, nil)
+}
+
+func (w *WebDAVShare) Write(name string, contents []byte) error {
+	_, err := w.do("PUT", name, contents, nil)
+	return err
+}
+
+func (w *WebDAVShare) String() string {
+	return w.URL
+}
+
+// A TxShare that is the part of a bucket under Prefix in Amazon S3
+// or a compatible object store.  Requests use path-style URLs and AWS
+// Signature Version 4.
+type S3Share struct {
+	// Base URL of the service, e.g., https://s3.us-east-1.amazonaws.com
+	Endpoint string
+	Region   string
+	Bucket   string
+
+	// Prepended to the name of every file; should be empty or end in
+	// "/"
+	Prefix string
+
+	AccessKey, SecretKey, SessionToken string
+
+	// Client to use, or nil for DefaultHTTPClient
+	Client *http.Client
+}
+
+// Escapes s as AWS Signature Version 4 requires, leaving '/'
+// unescaped if keepSlash.
+func awsEscape(s string, keepSlash bool) string {
+	out := &strings.Builder{}
+	for _, c := range []byte(s) {
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' ||
+			c >= '0' && c <= '9' || c == '-' || c == '.' || c == '_' ||
+			c == '~' || (keepSlash && c == '/') {
+			out.WriteByte(c)
+		} else {
+			fmt.Fprintf(out, "%%%02X", c)
+		}
+	}
+	return out.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// Signs req with AWS Signature Version 4 for service s3, covering
+// the Host header and every header already set on req.  payloadHash
+// is the hex SHA-256 hash of the request body.
+func signS3(req *http.Request, payloadHash, region, accessKey,
+	secretKey, sessionToken string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	vals := map[string]string{"host": host}
+	names := []string{"host"}
+	for k, v := range req.Header {
+		k = strings.ToLower(k)
+		for i := range v {
+			v[i] = strings.Join(strings.Fields(v[i]), " ")
+		}
+		vals[k] = strings.Join(v, ",")
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	signedHeaders := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	var params []string
+	for k, vs := range query {
+		for _, v := range vs {
+			params = append(params, awsEscape(k, false)+"="+
+				awsEscape(v, false))
+		}
+	}
+	sort.Strings(params)
+	uri := req.URL.EscapedPath()
+	if uri == "" {
+		uri = "/"
+	}
+
+	creq := &strings.Builder{}
+	fmt.Fprintf(creq, "%s\n%s\n%s\n", req.Method, uri,
+		strings.Join(params, "&"))
+	for _, k := range names {
+		fmt.Fprintf(creq, "%s:%s\n", k, vals[k])
+	}
+	fmt.Fprintf(creq, "\n%s\n%s", signedHeaders, payloadHash)
+	creqHash := sha256.Sum256([]byte(creq.String()))
+
+	scope := amzDate[:8] + "/" + region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" +
+		hex.EncodeToString(creqHash[:])
+	key := hmacSHA256([]byte("AWS4"+secretKey), amzDate[:8])
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, "+
+			"Signature=%s", accessKey, scope, signedHeaders,
+		hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func (s *S3Share) do(method, key, query string, body []byte) (
+	[]byte, error) {
+	u := strings.TrimSuffix(s.Endpoint, "/") + "/" +
+		awsEscape(s.Bucket+"/"+key, true)
+	if query != "" {
+		u += "?" + query
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	payloadHash := sha256.Sum256(body)
+	signS3(req, hex.EncodeToString(payloadHash[:]), s.Region,
+		s.AccessKey, s.SecretKey, s.SessionToken, time.Now())
+	c := s.Client
+	if c == nil {
+		c = DefaultHTTPClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	ret, err := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s s3://%s/%s: %s", method, s.Bucket, key,
+			resp.Status)
+	}
+	return ret, err
+}
+
+// The parts of an S3 ListObjectsV2 response that List needs.
+type s3ListBucketResult struct {
+	Contents []struct {
+		Key string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (s *S3Share) List() ([]string, error) {
+	var ret []string
+	token := ""
+	for {
+		q := url.Values{
+			"list-type": {"2"},
+			"prefix":    {s.Prefix},
+			"delimiter": {"/"},
+		}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		body, err := s.do("GET", "", q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		var res s3ListBucketResult
+		if err = xml.Unmarshal(body, &res); err != nil {
+			return nil, fmt.Errorf("%s: %w", s, err)
+		}
+		for _, c := range res.Contents {
+			if name := strings.TrimPrefix(c.Key, s.Prefix); name != "" {
+				ret = append(ret, name)
+			}
+		}
+		if !res.IsTruncated || res.NextContinuationToken == "" {
+			return ret, nil
+		}
+		token = res.NextContinuationToken
+	}
+}
+
+func (s *S3Share) Read(name string) ([]byte, error) {
+	return s.do("GET", s.Prefix+name, "", nil)
+}
+
+func (s *S3Share) Write(name string, contents []byte) error {
+	_, err := s.do("PUT", s.Prefix+name, "", contents)
+	return err
+}
+
+func (s *S3Share) String() string {
+	return "s3://" + s.Bucket + "/" + s.Prefix
+}
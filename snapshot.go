@@ -0,0 +1,230 @@
+package stc
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/xdrpp/stc/stx"
+	"time"
+)
+
+// Version of the snapshot format written by NewAccountSnapshot.
+const AccountSnapshotVersion = 1
+
+// The state of an account (balances, trustlines, signers, thresholds,
+// flags, data entries, and offers) at some point in time, from which
+// RestoreTxs can build transactions recreating the account on a test
+// network.  Like a TxBundle, horizon's JSON is stored verbatim and
+// parsed with the same code used for online queries, and a snapshot
+// is stored as a single JSON object.
+type AccountSnapshot struct {
+	// Always AccountSnapshotVersion; identifies the file as a
+	// snapshot.
+	Version int `json:"stc_snapshot"`
+
+	// The network passphrase of the network the account is on.
+	NetworkId string `json:"network_id"`
+
+	// When the snapshot was taken.
+	Created time.Time `json:"created"`
+
+	// The account ID in strkey format.
+	Account string `json:"account"`
+
+	// Horizon's JSON for the account.
+	Entry json.RawMessage `json:"entry"`
+
+	// Horizon's JSON for each of the account's offers.
+	Offers []json.RawMessage `json:"offers"`
+}
+
+// Takes a snapshot of account acct (a strkey) by querying horizon.
+func (net *StellarNet) NewAccountSnapshot(acct string) (
+	*AccountSnapshot, error) {
+	ret := &AccountSnapshot{
+		Version:   AccountSnapshotVersion,
+		NetworkId: net.GetNetworkId(),
+		Created:   time.Now().UTC().Truncate(time.Second),
+		Account:   acct,
+		Offers:    []json.RawMessage{},
+	}
+	var err error
+	if ret.Entry, err = net.Get("accounts/" + acct); err != nil {
+		return nil, err
+	}
+	pager := net.NewPager(nil, "accounts/"+acct+"/offers",
+		PageParams{Limit: 200})
+	var offers []json.RawMessage
+	for pager.Next(&offers) {
+		ret.Offers = append(ret.Offers, offers...)
+	}
+	if err = pager.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Parses a snapshot written by json.Marshal.
+func ParseAccountSnapshot(input []byte) (*AccountSnapshot, error) {
+	var ret AccountSnapshot
+	if err := json.Unmarshal(input, &ret); err != nil {
+		return nil, err
+	} else if ret.Version != AccountSnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d",
+			ret.Version)
+	}
+	return &ret, nil
+}
+
+// Returns the snapshotted account entry.
+func (s *AccountSnapshot) AccountEntry(net *StellarNet) (
+	*HorizonAccountEntry, error) {
+	ret := &HorizonAccountEntry{Net: net}
+	if err := json.Unmarshal(s.Entry, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Returns the snapshotted offers.
+func (s *AccountSnapshot) AccountOffers(net *StellarNet) (
+	[]HorizonOffer, error) {
+	ret := make([]HorizonOffer, len(s.Offers))
+	for i := range s.Offers {
+		ret[i].Net = net
+		if err := json.Unmarshal(s.Offers[i], &ret[i]); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// Builds unsigned transactions that recreate the snapshotted account
+// as account acct (or as the original account if acct is nil) on a
+// test network such as a standalone network.  The first transaction,
+// whose source is funder, creates the account with its snapshotted
+// XLM balance, and includes funder's sequence number if it can be
+// fetched from horizon.  The remaining transactions, whose source is
+// the new account, add its data entries, trustlines, and offers, and
+// finally set its flags, home domain, signers, and thresholds.  Their
+// sequence numbers are 0, since the new account's sequence number is
+// unknown until it is created, and no transaction has a fee; use stc -u
+// to set both.  Balances of
+// other assets cannot be restored without the cooperation of their
+// issuers (which must already exist on the network), so offers
+// selling those assets will fail unless the account is funded in
+// between.  Trustlines for liquidity pool shares are omitted.
+func (net *StellarNet) RestoreTxs(s *AccountSnapshot, funder AccountID,
+	acct *AccountID) ([]*TransactionEnvelope, error) {
+	ae, err := s.AccountEntry(net)
+	if err != nil {
+		return nil, err
+	}
+	offers, err := s.AccountOffers(net)
+	if err != nil {
+		return nil, err
+	}
+	var target AccountID
+	if acct != nil {
+		target = *acct
+	} else if _, err = fmt.Sscan(s.Account, &target); err != nil {
+		return nil, err
+	}
+
+	create := NewTransactionEnvelope()
+	create.SetSourceAccount(funder)
+	if fae, err := net.GetAccountEntry(funder.String()); err == nil {
+		create.V1().Tx.SeqNum = fae.NextSeq()
+	}
+	create.Append(nil, CreateAccount{
+		Destination:     target,
+		StartingBalance: int64(ae.Balance),
+	})
+	ret := []*TransactionEnvelope{create}
+
+	var ops []OperationBody
+	data, err := ae.DataEntries()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range DataOps(nil, data) {
+		ops = append(ops, op)
+	}
+	for i := range ae.Balances {
+		b := &ae.Balances[i]
+		line := stx.ChangeTrustAsset{Type: b.Asset.Type}
+		switch b.Asset.Type {
+		case stx.ASSET_TYPE_CREDIT_ALPHANUM4:
+			*line.AlphaNum4() = *b.Asset.AlphaNum4()
+		case stx.ASSET_TYPE_CREDIT_ALPHANUM12:
+			*line.AlphaNum12() = *b.Asset.AlphaNum12()
+		default:
+			continue
+		}
+		ops = append(ops, ChangeTrust{Line: line, Limit: int64(b.Limit)})
+	}
+	for i := range offers {
+		o := &offers[i]
+		ops = append(ops, ManageSellOffer{
+			Selling: o.Selling,
+			Buying:  o.Buying,
+			Amount:  int64(o.Amount),
+			Price:   o.Price_r,
+		})
+	}
+
+	var flags uint32
+	for bit, set := range map[stx.AccountFlags]bool{
+		stx.AUTH_REQUIRED_FLAG:         ae.Flags.Auth_required,
+		stx.AUTH_REVOCABLE_FLAG:        ae.Flags.Auth_revocable,
+		stx.AUTH_CLAWBACK_ENABLED_FLAG: ae.Flags.Auth_clawback_enabled,
+		stx.AUTH_IMMUTABLE_FLAG:        ae.Flags.Auth_immutable,
+	} {
+		if set {
+			flags |= uint32(bit)
+		}
+	}
+	if flags != 0 || ae.Home_domain != "" {
+		so := SetOptions{}
+		if flags != 0 {
+			so.SetFlags = NewUint(flags)
+		}
+		if ae.Home_domain != "" {
+			so.HomeDomain = NewString(ae.Home_domain)
+		}
+		ops = append(ops, so)
+	}
+	master := uint32(1)
+	for i := range ae.Signers {
+		sk := &ae.Signers[i]
+		if sk.Key.String() == s.Account {
+			master = sk.Weight
+			continue
+		}
+		ops = append(ops, SetOptions{
+			Signer: &stx.Signer{Key: sk.Key, Weight: sk.Weight},
+		})
+	}
+	// Set thresholds and master weight last, since they may prevent
+	// the account from signing anything further.
+	ops = append(ops, SetOptions{
+		MasterWeight:  NewUint(master),
+		LowThreshold:  NewUint(uint32(ae.Thresholds.Low_threshold)),
+		MedThreshold:  NewUint(uint32(ae.Thresholds.Med_threshold)),
+		HighThreshold: NewUint(uint32(ae.Thresholds.High_threshold)),
+	})
+
+	for len(ops) > 0 {
+		n := len(ops)
+		if n > stx.MAX_OPS_PER_TX {
+			n = stx.MAX_OPS_PER_TX
+		}
+		e := NewTransactionEnvelope()
+		e.SetSourceAccount(target)
+		for _, op := range ops[:n] {
+			e.Append(nil, op)
+		}
+		ops = ops[n:]
+		ret = append(ret, e)
+	}
+	return ret, nil
+}
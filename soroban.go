@@ -0,0 +1,367 @@
+package stc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync/atomic"
+
+	"github.com/xdrpp/stc/stcdetail"
+	"github.com/xdrpp/stc/stx"
+)
+
+type LedgerKey = stx.LedgerKey
+type LedgerEntry = stx.LedgerEntry
+
+// An error returned by a Soroban RPC server in a JSON-RPC response.
+type SorobanError struct {
+	Code    int
+	Message string
+}
+
+func (e *SorobanError) Error() string {
+	return fmt.Sprintf("soroban-rpc error %d: %s", e.Code, e.Message)
+}
+
+const badSorobanURL horizonFailure = "Missing or invalid soroban-rpc URL"
+
+var sorobanRequestId int64
+
+// Send a JSON-RPC request to the network's Soroban RPC server and
+// unmarshal the result into out.  Since method may submit a
+// transaction (e.g., sendTransaction), fails with ErrReadOnly in
+// read-only mode; stc's own lookups (such as GetLedgerEntries) still
+// work.
+func (net *StellarNet) SorobanCall(method string, params interface{},
+	out interface{}) error {
+	if IsReadOnly() {
+		return ErrReadOnly
+	}
+	return net.sorobanCall(method, params, out)
+}
+
+func (net *StellarNet) sorobanCall(method string, params interface{},
+	out interface{}) error {
+	if net.SorobanRPC == "" {
+		return badSorobanURL
+	}
+	req, err := json.Marshal(struct {
+		Jsonrpc string      `json:"jsonrpc"`
+		Id      int64       `json:"id"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params,omitempty"`
+	}{"2.0", atomic.AddInt64(&sorobanRequestId, 1), method, params})
+	if err != nil {
+		return err
+	}
+	resp, err := net.httpClient().Post(net.SorobanRPC, "application/json",
+		bytes.NewReader(req))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return readHorizonError(resp)
+	}
+	var res struct {
+		Result json.RawMessage
+		Error  *SorobanError
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return err
+	} else if res.Error != nil {
+		return res.Error
+	} else if out == nil {
+		return nil
+	}
+	return json.Unmarshal(res.Result, out)
+}
+
+// Returned by GetLedgerEntry when soroban-rpc reports that the entry
+// does not exist.
+var ErrLedgerEntryNotFound = errors.New("ledger entry not found")
+
+// Fetch a single ledger entry by key.  See GetLedgerEntries.  Unlike
+// GetLedgerEntries, never returns a nil entry without an error:  a
+// missing entry yields ErrLedgerEntryNotFound (or, without
+// soroban-rpc, horizon's HorizonError).
+func (net *StellarNet) GetLedgerEntry(key *LedgerKey) (*LedgerEntry, error) {
+	es, err := net.GetLedgerEntries(*key)
+	if err != nil {
+		return nil, err
+	} else if es[0] == nil {
+		return nil, ErrLedgerEntryNotFound
+	}
+	return es[0], nil
+}
+
+// Fetch a set of ledger entries.  The returned slice is parallel to
+// keys.  If the network has a Soroban RPC server configured, all
+// keys are looked up there, and keys that do not exist in the ledger
+// yield nil entries.  Otherwise, only ACCOUNT, TRUSTLINE, OFFER, and
+// DATA keys can be fetched (from horizon), and a missing entry
+// results in an error.  Entries reconstructed from horizon carry only
+// the extensions needed to hold their non-default fields, and lack
+// the few fields horizon does not report (an offer's PASSIVE_FLAG
+// and a trustline's liquidity pool use count), so their XDR may
+// differ from the ledger's.
+func (net *StellarNet) GetLedgerEntries(keys ...LedgerKey) (
+	[]*LedgerEntry, error) {
+	if net.SorobanRPC != "" {
+		return net.sorobanLedgerEntries(keys)
+	}
+	ret := make([]*LedgerEntry, len(keys))
+	for i := range keys {
+		var err error
+		switch keys[i].Type {
+		case stx.ACCOUNT:
+			ret[i], err = net.horizonAccountLedgerEntry(keys[i].Account())
+		case stx.TRUSTLINE:
+			ret[i], err = net.horizonTrustLineLedgerEntry(
+				keys[i].TrustLine())
+		case stx.OFFER:
+			ret[i], err = net.horizonOfferLedgerEntry(keys[i].Offer())
+		case stx.DATA:
+			ret[i], err = net.horizonDataLedgerEntry(keys[i].Data())
+		default:
+			err = fmt.Errorf("cannot fetch %s without soroban-rpc",
+				showLedgerKey(keys[i]))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func (net *StellarNet) sorobanLedgerEntries(keys []LedgerKey) (
+	[]*LedgerEntry, error) {
+	var params struct {
+		Keys []string `json:"keys"`
+	}
+	for i := range keys {
+		params.Keys = append(params.Keys, stcdetail.XdrToBase64(&keys[i]))
+	}
+	var res struct {
+		Entries []struct {
+			Key                   string
+			Xdr                   string
+			LastModifiedLedgerSeq uint32
+		}
+	}
+	if err := net.sorobanCall("getLedgerEntries", &params, &res); err != nil {
+		return nil, err
+	}
+	index := make(map[string]int)
+	for i := range params.Keys {
+		index[params.Keys[i]] = i
+	}
+	ret := make([]*LedgerEntry, len(keys))
+	for _, e := range res.Entries {
+		i, ok := index[e.Key]
+		if !ok {
+			return nil, horizonFailure("soroban-rpc returned unrequested key")
+		}
+		le := &LedgerEntry{LastModifiedLedgerSeq: e.LastModifiedLedgerSeq}
+		if err := stcdetail.XdrFromBase64(&le.Data, e.Xdr); err != nil {
+			return nil, err
+		}
+		ret[i] = le
+	}
+	return ret, nil
+}
+
+// Records in le's extension that sponsor, if not nil, pays le's
+// reserve.
+func setLedgerEntrySponsor(le *LedgerEntry, sponsor *AccountID) {
+	if sponsor != nil {
+		le.Ext.V = 1
+		le.Ext.V1().SponsoringID = sponsor
+	}
+}
+
+func (net *StellarNet) horizonAccountLedgerEntry(
+	k *stx.XdrAnon_LedgerKey_Account) (*LedgerEntry, error) {
+	hae, err := net.GetAccountEntry(k.AccountID.String())
+	if err != nil {
+		return nil, err
+	}
+	le := &LedgerEntry{LastModifiedLedgerSeq: hae.Last_modified_ledger}
+	le.Data.Type = stx.ACCOUNT
+	ae := le.Data.Account()
+	ae.AccountID = k.AccountID
+	ae.Balance = int64(hae.Balance)
+	ae.SeqNum = stx.SequenceNumber(hae.Sequence)
+	ae.NumSubEntries = hae.Subentry_count
+	ae.InflationDest = hae.Inflation_destination
+	ae.HomeDomain = hae.Home_domain
+	if hae.Flags.Auth_required {
+		ae.Flags |= uint32(stx.AUTH_REQUIRED_FLAG)
+	}
+	if hae.Flags.Auth_revocable {
+		ae.Flags |= uint32(stx.AUTH_REVOCABLE_FLAG)
+	}
+	if hae.Flags.Auth_immutable {
+		ae.Flags |= uint32(stx.AUTH_IMMUTABLE_FLAG)
+	}
+	if hae.Flags.Auth_clawback_enabled {
+		ae.Flags |= uint32(stx.AUTH_CLAWBACK_ENABLED_FLAG)
+	}
+	acctkey := k.AccountID.ToSignerKey()
+	acctbin := stcdetail.XdrToBin(&acctkey)
+	ae.Thresholds[0] = 1
+	var sponsors []stx.SponsorshipDescriptor
+	sponsored := false
+	for _, s := range hae.Signers {
+		if stcdetail.XdrToBin(&s.Key) == acctbin {
+			ae.Thresholds[0] = byte(s.Weight)
+		} else {
+			ae.Signers = append(ae.Signers, stx.Signer{
+				Key:    s.Key,
+				Weight: s.Weight,
+			})
+			sponsors = append(sponsors, s.Sponsor)
+			sponsored = sponsored || s.Sponsor != nil
+		}
+	}
+	ae.Thresholds[1] = hae.Thresholds.Low_threshold
+	ae.Thresholds[2] = hae.Thresholds.Med_threshold
+	ae.Thresholds[3] = hae.Thresholds.High_threshold
+
+	// Each extension nests inside the previous one, so a later one
+	// forces the earlier ones.
+	v3 := hae.Sequence_ledger != 0 || hae.Sequence_time != 0
+	v2 := v3 || sponsored || hae.Num_sponsored != 0 ||
+		hae.Num_sponsoring != 0
+	if v2 || hae.Buying_liabilities != 0 || hae.Selling_liabilities != 0 {
+		ae.Ext.V = 1
+		v1 := ae.Ext.V1()
+		v1.Liabilities.Buying = int64(hae.Buying_liabilities)
+		v1.Liabilities.Selling = int64(hae.Selling_liabilities)
+		if v2 {
+			v1.Ext.V = 2
+			ext2 := v1.Ext.V2()
+			ext2.NumSponsored = hae.Num_sponsored
+			ext2.NumSponsoring = hae.Num_sponsoring
+			ext2.SignerSponsoringIDs = sponsors
+			if v3 {
+				ext2.Ext.V = 3
+				ext2.Ext.V3().SeqLedger = hae.Sequence_ledger
+				ext2.Ext.V3().SeqTime = stx.TimePoint(hae.Sequence_time)
+			}
+		}
+	}
+	setLedgerEntrySponsor(le, hae.Sponsor)
+	return le, nil
+}
+
+func (net *StellarNet) horizonTrustLineLedgerEntry(
+	k *stx.XdrAnon_LedgerKey_TrustLine) (*LedgerEntry, error) {
+	hae, err := net.GetAccountEntry(k.AccountID.String())
+	if err != nil {
+		return nil, err
+	}
+	var hb *HorizonBalance
+	for i := range hae.Balances {
+		b := &hae.Balances[i]
+		if b.Asset.Type != k.Asset.Type {
+			continue
+		}
+		switch k.Asset.Type {
+		case stx.ASSET_TYPE_CREDIT_ALPHANUM4:
+			if stcdetail.XdrToBin(b.Asset.AlphaNum4()) ==
+				stcdetail.XdrToBin(k.Asset.AlphaNum4()) {
+				hb = b
+			}
+		case stx.ASSET_TYPE_CREDIT_ALPHANUM12:
+			if stcdetail.XdrToBin(b.Asset.AlphaNum12()) ==
+				stcdetail.XdrToBin(k.Asset.AlphaNum12()) {
+				hb = b
+			}
+		case stx.ASSET_TYPE_POOL_SHARE:
+			pid := k.Asset.LiquidityPoolID()
+			if b.Liquidity_pool_id == hex.EncodeToString(pid[:]) {
+				hb = b
+			}
+		}
+	}
+	if hb == nil {
+		return nil, fmt.Errorf("trustline %s[%s] not found", k.AccountID,
+			k.Asset)
+	}
+	le := &LedgerEntry{LastModifiedLedgerSeq: hb.Last_modified_ledger}
+	le.Data.Type = stx.TRUSTLINE
+	tle := le.Data.TrustLine()
+	tle.AccountID = k.AccountID
+	tle.Asset = k.Asset
+	tle.Balance = int64(hb.Balance)
+	tle.Limit = int64(hb.Limit)
+	if hb.Is_authorized {
+		tle.Flags |= uint32(stx.AUTHORIZED_FLAG)
+	}
+	if hb.Is_authorized_to_maintain_liabilities {
+		tle.Flags |= uint32(stx.AUTHORIZED_TO_MAINTAIN_LIABILITIES_FLAG)
+	}
+	if hb.Is_clawback_enabled {
+		tle.Flags |= uint32(stx.TRUSTLINE_CLAWBACK_ENABLED_FLAG)
+	}
+	if hb.Buying_liabilities != 0 || hb.Selling_liabilities != 0 {
+		tle.Ext.V = 1
+		tle.Ext.V1().Liabilities.Buying = int64(hb.Buying_liabilities)
+		tle.Ext.V1().Liabilities.Selling = int64(hb.Selling_liabilities)
+	}
+	setLedgerEntrySponsor(le, hb.Sponsor)
+	return le, nil
+}
+
+func (net *StellarNet) horizonOfferLedgerEntry(
+	k *stx.XdrAnon_LedgerKey_Offer) (*LedgerEntry, error) {
+	var ho HorizonOffer
+	err := net.GetJSON(fmt.Sprintf("offers/%d", k.OfferID), &ho)
+	if err != nil {
+		return nil, err
+	} else if ho.Seller.String() != k.SellerID.String() {
+		return nil, fmt.Errorf("offer %d belongs to %s, not %s",
+			k.OfferID, ho.Seller, k.SellerID)
+	}
+	le := &LedgerEntry{LastModifiedLedgerSeq: ho.Last_modified_ledger}
+	le.Data.Type = stx.OFFER
+	oe := le.Data.Offer()
+	oe.SellerID = k.SellerID
+	oe.OfferID = k.OfferID
+	oe.Selling = ho.Selling
+	oe.Buying = ho.Buying
+	oe.Amount = int64(ho.Amount)
+	oe.Price = ho.Price_r
+	setLedgerEntrySponsor(le, ho.Sponsor)
+	return le, nil
+}
+
+func (net *StellarNet) horizonDataLedgerEntry(
+	k *stx.XdrAnon_LedgerKey_Data) (*LedgerEntry, error) {
+	var res struct {
+		Value   string
+		Sponsor *AccountID
+	}
+	err := net.GetJSON("accounts/"+k.AccountID.String()+"/data/"+
+		url.PathEscape(k.DataName), &res)
+	if err != nil {
+		return nil, err
+	}
+	val, err := base64.StdEncoding.DecodeString(res.Value)
+	if err != nil {
+		return nil, err
+	}
+	le := &LedgerEntry{}
+	le.Data.Type = stx.DATA
+	de := le.Data.Data()
+	de.AccountID = k.AccountID
+	de.DataName = k.DataName
+	de.DataValue = val
+	setLedgerEntrySponsor(le, res.Sponsor)
+	return le, nil
+}
@@ -0,0 +1,199 @@
+package stc
+
+import (
+	"fmt"
+	"github.com/xdrpp/goxdr/xdr"
+	"github.com/xdrpp/stc/stx"
+)
+
+// Appends a BEGIN_SPONSORING_FUTURE_RESERVES operation, after which
+// sponsor pays the reserves of entries created for sponsored until
+// sponsored ends the sponsorship (see EndSponsoring).  If sponsor is
+// nil, the transaction's source account is the sponsor.
+func (txe *TransactionEnvelope) BeginSponsoring(sponsor *MuxedAccount,
+	sponsored AccountID) {
+	txe.Append(sponsor, BeginSponsoringFutureReserves{
+		SponsoredID: sponsored,
+	})
+}
+
+// Appends the END_SPONSORING_FUTURE_RESERVES operation that ends the
+// sponsorship of sponsored, with sponsored as its source account (as
+// the network requires).
+func (txe *TransactionEnvelope) EndSponsoring(sponsored AccountID) {
+	txe.Append(sponsored.ToMuxedAccount(), EndSponsoringFutureReserves{})
+}
+
+// Appends the operations added by ops between a BeginSponsoring and an
+// EndSponsoring, so that sponsor pays the reserves of any entries
+// they create for sponsored.  For example, to create an account and
+// a trustline (for stx.ChangeTrustAsset line) without the new
+// account needing any XLM:
+//
+//	txe.AppendSponsored(nil, newacct, func() {
+//		txe.Append(nil, CreateAccount{Destination: newacct})
+//		txe.Append(newacct.ToMuxedAccount(), ChangeTrust{
+//			Line:  line,
+//			Limit: math.MaxInt64,
+//		})
+//	})
+//
+// Note that the transaction must be signed by sponsored as well as by
+// sponsor.
+func (txe *TransactionEnvelope) AppendSponsored(sponsor *MuxedAccount,
+	sponsored AccountID, ops func()) {
+	txe.BeginSponsoring(sponsor, sponsored)
+	ops()
+	txe.EndSponsoring(sponsored)
+}
+
+// Returns an error if the BEGIN_SPONSORING_FUTURE_RESERVES and
+// END_SPONSORING_FUTURE_RESERVES operations in e are not properly
+// paired, which would make the whole transaction fail.  Specifically,
+// every account sponsored must end the sponsorship later in the same
+// transaction; an account cannot be sponsored twice at once, sponsor
+// itself, or sponsor others while sponsored; and an account can only
+// end a sponsorship that exists.
+func CheckSponsorships(e *TransactionEnvelope) error {
+	inner := innerTx(e)
+	ops := inner.Operations()
+	if ops == nil {
+		return nil
+	}
+	txsrc, _ := DemuxAcct(inner.SourceAccount())
+	// For each account currently sponsored, the index of the
+	// operation that began its sponsorship and the sponsor
+	type sponsorship struct {
+		op      int
+		sponsor string
+	}
+	sponsored := map[string]sponsorship{}
+	// Number of accounts each account is currently sponsoring
+	sponsoring := map[string]int{}
+	for i := range *ops {
+		op := &(*ops)[i]
+		src := txsrc
+		if op.SourceAccount != nil {
+			src, _ = DemuxAcct(op.SourceAccount)
+		}
+		switch op.Body.Type {
+		case stx.BEGIN_SPONSORING_FUTURE_RESERVES:
+			sponsor := src.String()
+			target := op.Body.BeginSponsoringFutureReservesOp().
+				SponsoredID.String()
+			if sponsor == target {
+				return fmt.Errorf("operation %d: %s cannot sponsor itself",
+					i, sponsor)
+			} else if _, ok := sponsored[target]; ok {
+				return fmt.Errorf("operation %d: %s is already sponsored",
+					i, target)
+			} else if _, ok := sponsored[sponsor]; ok {
+				return fmt.Errorf("operation %d: %s cannot sponsor while "+
+					"sponsored", i, sponsor)
+			} else if sponsoring[target] > 0 {
+				return fmt.Errorf("operation %d: %s cannot be sponsored "+
+					"while sponsoring", i, target)
+			}
+			sponsored[target] = sponsorship{i, sponsor}
+			sponsoring[sponsor]++
+		case stx.END_SPONSORING_FUTURE_RESERVES:
+			s, ok := sponsored[src.String()]
+			if !ok {
+				return fmt.Errorf("operation %d: %s is not sponsored",
+					i, src)
+			}
+			delete(sponsored, src.String())
+			sponsoring[s.sponsor]--
+		}
+	}
+	first := -1
+	var acct string
+	for a, s := range sponsored {
+		if first < 0 || s.op < first {
+			first, acct = s.op, a
+		}
+	}
+	if first >= 0 {
+		return fmt.Errorf("operation %d: sponsorship of %s never ends",
+			first, acct)
+	}
+	return nil
+}
+
+// Returns a REVOKE_SPONSORSHIP operation for the ledger entry with the
+// given key.  The operation's source must be the entry's current
+// sponsor (or its owner, if the entry is not sponsored).  On its own,
+// the operation makes the owner pay the entry's reserve; between a
+// BeginSponsoring and an EndSponsoring, it transfers the sponsorship
+// to the new sponsor.  The functions below build the key for each
+// type of entry.
+func RevokeLedgerEntrySponsorship(key stx.LedgerKey) RevokeSponsorship {
+	op := stx.RevokeSponsorshipOp{
+		Type: stx.REVOKE_SPONSORSHIP_LEDGER_ENTRY,
+	}
+	*op.LedgerKey() = key
+	return RevokeSponsorship(op)
+}
+
+// Revokes the sponsorship of account acct's base reserve.
+func RevokeAccountSponsorship(acct AccountID) RevokeSponsorship {
+	key := stx.LedgerKey{Type: stx.ACCOUNT}
+	key.Account().AccountID = acct
+	return RevokeLedgerEntrySponsorship(key)
+}
+
+// Revokes the sponsorship of acct's trustline for asset, which must
+// not be native.
+func RevokeTrustLineSponsorship(acct AccountID,
+	asset stx.Asset) RevokeSponsorship {
+	key := stx.LedgerKey{Type: stx.TRUSTLINE}
+	key.TrustLine().AccountID = acct
+	line := &key.TrustLine().Asset
+	line.Type = asset.Type
+	switch asset.Type {
+	case stx.ASSET_TYPE_CREDIT_ALPHANUM4:
+		*line.AlphaNum4() = *asset.AlphaNum4()
+	case stx.ASSET_TYPE_CREDIT_ALPHANUM12:
+		*line.AlphaNum12() = *asset.AlphaNum12()
+	default:
+		xdr.XdrPanic("RevokeTrustLineSponsorship: no trustline for %s",
+			asset.Type)
+	}
+	return RevokeLedgerEntrySponsorship(key)
+}
+
+// Revokes the sponsorship of seller's offer offerID.
+func RevokeOfferSponsorship(seller AccountID,
+	offerID int64) RevokeSponsorship {
+	key := stx.LedgerKey{Type: stx.OFFER}
+	key.Offer().SellerID = seller
+	key.Offer().OfferID = offerID
+	return RevokeLedgerEntrySponsorship(key)
+}
+
+// Revokes the sponsorship of acct's data entry name.
+func RevokeDataSponsorship(acct AccountID, name string) RevokeSponsorship {
+	key := stx.LedgerKey{Type: stx.DATA}
+	key.Data().AccountID = acct
+	key.Data().DataName = name
+	return RevokeLedgerEntrySponsorship(key)
+}
+
+// Revokes the sponsorship of a claimable balance.  Unlike other
+// entries, a claimable balance must always have a sponsor, so this
+// operation only succeeds when transferring the sponsorship.
+func RevokeClaimableBalanceSponsorship(
+	id stx.ClaimableBalanceID) RevokeSponsorship {
+	key := stx.LedgerKey{Type: stx.CLAIMABLE_BALANCE}
+	key.ClaimableBalance().BalanceID = id
+	return RevokeLedgerEntrySponsorship(key)
+}
+
+// Revokes the sponsorship of signer key on account acct.
+func RevokeSignerSponsorship(acct AccountID,
+	key stx.SignerKey) RevokeSponsorship {
+	op := stx.RevokeSponsorshipOp{Type: stx.REVOKE_SPONSORSHIP_SIGNER}
+	op.Signer().AccountID = acct
+	op.Signer().SignerKey = key
+	return RevokeSponsorship(op)
+}
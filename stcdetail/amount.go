@@ -0,0 +1,68 @@
+package stcdetail
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Number of decimal places in amounts of assets (including XLM, for
+// which one unit is a stroop).
+const AmountDecimals = 7
+
+// Number of units (e.g., stroops) in one whole asset (e.g., XLM).
+const AmountUnit = 10000000
+
+// Parses a decimal amount such as "123.4567890" or "-0.5" into units
+// of 10^-7, exactly.  Returns an error if s has more than 7 digits
+// after the decimal point or the amount does not fit in an int64.
+func ParseAmount(s string) (int64, error) {
+	text, neg := s, false
+	if strings.HasPrefix(text, "-") {
+		text, neg = text[1:], true
+	}
+	whole, frac := text, ""
+	if point := strings.IndexByte(text, '.'); point >= 0 {
+		whole, frac = text[:point], text[point+1:]
+	}
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	} else if len(frac) > AmountDecimals {
+		return 0, fmt.Errorf("amount %q has more than %d decimal places",
+			s, AmountDecimals)
+	}
+	frac += strings.Repeat("0", AmountDecimals-len(frac))
+	var mag uint64
+	for _, c := range whole + frac {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		} else if mag > (math.MaxUint64-9)/10 {
+			return 0, fmt.Errorf("amount %q out of range", s)
+		}
+		mag = mag*10 + uint64(c-'0')
+	}
+	if neg && mag <= uint64(math.MaxInt64)+1 {
+		return -int64(mag-1) - 1, nil
+	} else if !neg && mag <= math.MaxInt64 {
+		return int64(mag), nil
+	}
+	return 0, fmt.Errorf("amount %q out of range", s)
+}
+
+// Formats an amount in units of 10^-7 with all 7 decimal places,
+// e.g., "-0.5000000".
+func formatAmountFixed(amount int64) string {
+	sign, mag := "", uint64(amount)
+	if amount < 0 {
+		sign, mag = "-", -mag
+	}
+	return fmt.Sprintf("%s%d.%07d", sign, mag/AmountUnit, mag%AmountUnit)
+}
+
+// Formats an amount in units of 10^-7 as an exact decimal without
+// trailing zeros, e.g., "123.456789" or "-0.5".  The result can be
+// parsed with ParseAmount.
+func FormatAmount(amount int64) string {
+	return strings.TrimSuffix(
+		strings.TrimRight(formatAmountFixed(amount), "0"), ".")
+}
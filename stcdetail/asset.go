@@ -0,0 +1,88 @@
+package stcdetail
+
+import (
+	"fmt"
+	"github.com/xdrpp/stc/stx"
+	"strings"
+)
+
+// Returns an error unless code is a valid asset code of 1 to 12 ASCII
+// letters and digits.
+func CheckAssetCode(code string) error {
+	if len(code) == 0 || len(code) > 12 {
+		return fmt.Errorf("asset code %q must be 1 to 12 characters", code)
+	}
+	for _, c := range code {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' ||
+			c >= '0' && c <= '9') {
+			return fmt.Errorf("asset code %q must contain only letters "+
+				"and digits", code)
+		}
+	}
+	return nil
+}
+
+// Returns an error unless a is an asset the network accepts:  either
+// native, or a code valid for CheckAssetCode padded with zero bytes,
+// of 1 to 4 characters for ASSET_TYPE_CREDIT_ALPHANUM4 or 5 to 12 for
+// ASSET_TYPE_CREDIT_ALPHANUM12.
+func CheckAsset(a *stx.Asset) error {
+	var code []byte
+	switch a.Type {
+	case stx.ASSET_TYPE_NATIVE:
+		return nil
+	case stx.ASSET_TYPE_CREDIT_ALPHANUM4:
+		code = a.AlphaNum4().AssetCode[:]
+	case stx.ASSET_TYPE_CREDIT_ALPHANUM12:
+		code = a.AlphaNum12().AssetCode[:]
+	default:
+		return fmt.Errorf("invalid asset type %s", a.Type)
+	}
+	n := len(code)
+	for n > 0 && code[n-1] == 0 {
+		n--
+	}
+	if err := CheckAssetCode(string(code[:n])); err != nil {
+		return err
+	} else if a.Type == stx.ASSET_TYPE_CREDIT_ALPHANUM12 && n <= 4 {
+		return fmt.Errorf("asset code %q too short for "+
+			"ASSET_TYPE_CREDIT_ALPHANUM12", code[:n])
+	}
+	return nil
+}
+
+// Parses an asset in its canonical string form, which is "native" or
+// CODE:ISSUER, where CODE is 1 to 12 letters and digits and ISSUER is
+// an account ID.  Codes of up to 4 characters produce
+// ASSET_TYPE_CREDIT_ALPHANUM4 assets, and longer ones
+// ASSET_TYPE_CREDIT_ALPHANUM12.  The String method of stx.Asset
+// produces the canonical form of a valid asset.
+func ParseAsset(s string) (stx.Asset, error) {
+	var ret stx.Asset
+	if s == "native" {
+		ret.Type = stx.ASSET_TYPE_NATIVE
+		return ret, nil
+	}
+	colon := strings.IndexByte(s, ':')
+	if colon < 0 {
+		return ret, fmt.Errorf("asset %q should be native or CODE:ISSUER", s)
+	}
+	code := s[:colon]
+	if err := CheckAssetCode(code); err != nil {
+		return ret, err
+	}
+	var issuer stx.AccountID
+	if err := issuer.UnmarshalText([]byte(s[colon+1:])); err != nil {
+		return ret, fmt.Errorf("asset %q has invalid issuer: %w", s, err)
+	}
+	if len(code) <= 4 {
+		ret.Type = stx.ASSET_TYPE_CREDIT_ALPHANUM4
+		copy(ret.AlphaNum4().AssetCode[:], code)
+		ret.AlphaNum4().Issuer = issuer
+	} else {
+		ret.Type = stx.ASSET_TYPE_CREDIT_ALPHANUM12
+		copy(ret.AlphaNum12().AssetCode[:], code)
+		ret.AlphaNum12().Issuer = issuer
+	}
+	return ret, nil
+}
@@ -2,6 +2,8 @@ package stcdetail
 
 import (
 	"encoding/base64"
+	"encoding/hex"
+	"fmt"
 	"github.com/xdrpp/goxdr/xdr"
 	"strings"
 )
@@ -21,18 +23,39 @@ func XdrToBase64(es ...xdr.XdrType) string {
 }
 
 // Parse base64-encoded binary XDR into an XDR aggregate structure.
-func XdrFromBase64(e xdr.XdrType, input string) (err error) {
-	defer func() {
-		if i := recover(); i != nil {
-			var ok bool
-			if err, ok = i.(error); !ok {
-				panic(i)
+func XdrFromBase64(e xdr.XdrType, input string) error {
+	bin, err := base64.StdEncoding.DecodeString(input)
+	if err != nil {
+		return err
+	}
+	var d XdrDecoder
+	return d.Decode(e, bin)
+}
+
+// Decodes s into dst, where s is either hex or base64 (standard or
+// URL-safe, with or without padding).  Returns an error unless s
+// encodes exactly len(dst) bytes, in which case dst is unchanged.
+// When s is valid in both encodings, hex takes precedence.
+func ParseOpaque(dst []byte, s string) error {
+	s = strings.TrimSpace(s)
+	if bin, err := hex.DecodeString(s); err == nil {
+		if len(bin) != len(dst) {
+			return fmt.Errorf("hex value is %d bytes; should be %d",
+				len(bin), len(dst))
+		}
+		copy(dst, bin)
+		return nil
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding,
+		base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if bin, err := enc.DecodeString(s); err == nil {
+			if len(bin) != len(dst) {
+				return fmt.Errorf("base64 value is %d bytes; should be %d",
+					len(bin), len(dst))
 			}
-			return
+			copy(dst, bin)
+			return nil
 		}
-	}()
-	in := strings.NewReader(input)
-	b64i := base64.NewDecoder(base64.StdEncoding, in)
-	e.XdrMarshal(&xdr.XdrIn{b64i}, "")
-	return nil
+	}
+	return fmt.Errorf("%q is neither hex nor base64", s)
 }
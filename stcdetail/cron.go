@@ -0,0 +1,139 @@
+package stcdetail
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A schedule in the format of a crontab(5) time specification:  five
+// fields giving the minute (0-59), hour (0-23), day of month (1-31),
+// month (1-12), and day of week (0-7, with 0 and 7 both Sunday).
+// Each field is "*" or a comma-separated list of numbers and ranges
+// (e.g., "1-5"), optionally followed by a step (e.g., "*/15").  As in
+// cron, when both the day of month and day of week are restricted, a
+// time matches if either one does.
+type CronSpec struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+var cronNicknames = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parses a single crontab field into a bitmask of allowed values.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var ret uint64
+	for _, term := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.IndexByte(term, '/'); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(term[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", term)
+			}
+			term = term[:i]
+		}
+		lo, hi := min, max
+		if term != "*" {
+			r := strings.SplitN(term, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(r[0]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", term)
+			}
+			hi = lo
+			if len(r) == 2 {
+				if hi, err = strconv.Atoi(r[1]); err != nil {
+					return 0, fmt.Errorf("invalid range %q", term)
+				}
+			} else if step > 1 {
+				// As in cron, "N/S" means N through max in steps of S
+				hi = max
+			}
+			if lo < min || hi > max || lo > hi {
+				return 0, fmt.Errorf("%q out of range %d-%d", term, min, max)
+			}
+		}
+		for i := lo; i <= hi; i += step {
+			ret |= 1 << uint(i)
+		}
+	}
+	return ret, nil
+}
+
+// Parses a crontab time specification such as "30 9 * * 1-5" (9:30
+// every weekday), or one of the nicknames @yearly, @annually,
+// @monthly, @weekly, @daily, @midnight, or @hourly.
+func ParseCron(spec string) (*CronSpec, error) {
+	if s, ok := cronNicknames[strings.TrimSpace(spec)]; ok {
+		spec = s
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron specification %q has %d fields, "+
+			"should have 5", spec, len(fields))
+	}
+	var ret CronSpec
+	for i, f := range []struct {
+		mask     *uint64
+		min, max int
+	}{
+		{&ret.minute, 0, 59},
+		{&ret.hour, 0, 23},
+		{&ret.dom, 1, 31},
+		{&ret.month, 1, 12},
+		{&ret.dow, 0, 7},
+	} {
+		var err error
+		if *f.mask, err = parseCronField(fields[i], f.min, f.max); err != nil {
+			return nil, fmt.Errorf("cron specification %q: %w", spec, err)
+		}
+	}
+	if ret.dow&(1<<7) != 0 {
+		ret.dow |= 1
+	}
+	ret.domStar = strings.HasPrefix(fields[2], "*")
+	ret.dowStar = strings.HasPrefix(fields[4], "*")
+	return &ret, nil
+}
+
+func (c *CronSpec) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Returns the first time strictly after t (to the minute, in t's time
+// zone) that matches the schedule, or the zero time if there is none
+// within the next five years (e.g., for "0 0 31 2 *").
+func (c *CronSpec) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		y, mon, d := t.Date()
+		switch {
+		case c.month&(1<<uint(mon)) == 0:
+			t = time.Date(y, mon+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(y, mon, d+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(y, mon, d, t.Hour()+1, 0, 0, 0, loc)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -13,6 +13,9 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
 )
 
 // Computes the SHA-256 hash of an arbitrary XDR data structure.
@@ -55,20 +58,113 @@ func Verify(pk *stx.PublicKey, message []byte, sig []byte) bool {
 // Verify the signature on a transaction.
 func VerifyTx(pk *stx.SignerKey, network string, tx stx.Signable,
 	sig []byte) bool {
+	if pk.Type == stx.SIGNER_KEY_TYPE_HASH_X ||
+		pk.Type == stx.SIGNER_KEY_TYPE_ED25519_SIGNED_PAYLOAD {
+		return verifyHash(pk, nil, sig)
+	}
+	return verifyHash(pk, TxPayloadHash(network, tx), sig)
+}
+
+// Verify a signature given the transaction hash returned by
+// TxPayloadHash.
+func verifyHash(pk *stx.SignerKey, hash *stx.Hash, sig []byte) bool {
 	switch pk.Type {
 	case stx.SIGNER_KEY_TYPE_ED25519:
-		return ed25519.Verify(pk.Ed25519()[:],
-			TxPayloadHash(network, tx)[:], sig)
+		return ed25519.Verify(pk.Ed25519()[:], hash[:], sig)
 	case stx.SIGNER_KEY_TYPE_PRE_AUTH_TX:
-		return bytes.Equal(TxPayloadHash(network, tx)[:], pk.PreAuthTx()[:])
+		return bytes.Equal(hash[:], pk.PreAuthTx()[:])
 	case stx.SIGNER_KEY_TYPE_HASH_X:
 		x := sha256.Sum256(sig)
 		return bytes.Equal(x[:], pk.HashX()[:])
+	case stx.SIGNER_KEY_TYPE_ED25519_SIGNED_PAYLOAD:
+		// Signs the payload, not the transaction
+		sp := pk.Ed25519SignedPayload()
+		return ed25519.Verify(sp.Ed25519[:], sp.Payload, sig)
 	default:
 		return false
 	}
 }
 
+// One signature to be checked by VerifyParallel.  Hash is the
+// transaction hash as returned by TxPayloadHash (and may be shared by
+// many SigChecks).
+type SigCheck struct {
+	Key  *stx.SignerKey
+	Hash *stx.Hash
+	Sig  []byte
+}
+
+// Options for VerifyParallel and VerifyTxParallel.
+type VerifyOptions struct {
+	// Maximum number of goroutines verifying signatures at once.  0
+	// means runtime.GOMAXPROCS(0); 1 means verify sequentially in
+	// the calling goroutine.
+	Parallelism int
+}
+
+// Verifies many signatures, potentially on different transactions,
+// by checking them individually on several goroutines at once.  (This
+// is not ed25519 batch verification, which would check all the
+// signatures with a single combined equation.)  Returns a slice
+// parallel to checks indicating which signatures are valid.  opts may
+// be nil.
+func VerifyParallel(checks []SigCheck, opts *VerifyOptions) []bool {
+	ret := make([]bool, len(checks))
+	par := runtime.GOMAXPROCS(0)
+	if opts != nil && opts.Parallelism > 0 {
+		par = opts.Parallelism
+	}
+	if par > len(checks) {
+		par = len(checks)
+	}
+	if par <= 1 {
+		for i := range checks {
+			ret[i] = verifyHash(checks[i].Key, checks[i].Hash, checks[i].Sig)
+		}
+		return ret
+	}
+
+	var wg sync.WaitGroup
+	next := int64(-1)
+	wg.Add(par)
+	for w := 0; w < par; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(checks) {
+					return
+				}
+				ret[i] = verifyHash(checks[i].Key, checks[i].Hash,
+					checks[i].Sig)
+			}
+		}()
+	}
+	wg.Wait()
+	return ret
+}
+
+// Verifies many (key, signature) pairs on the same transaction in
+// parallel (see VerifyParallel), hashing the transaction only once.
+// Returns a slice parallel to keys indicating which signatures are
+// valid.  keys[i] is checked against sigs[i]; keys with no
+// corresponding signature (if sigs is shorter than keys) are reported
+// invalid.  opts may be nil.
+func VerifyTxParallel(network string, tx stx.Signable, keys []stx.SignerKey,
+	sigs []stx.Signature, opts *VerifyOptions) []bool {
+	n := len(keys)
+	if len(sigs) < n {
+		n = len(sigs)
+	}
+	hash := TxPayloadHash(network, tx)
+	checks := make([]SigCheck, n)
+	for i := range checks {
+		checks[i] = SigCheck{Key: &keys[i], Hash: hash, Sig: sigs[i]}
+	}
+	ret := VerifyParallel(checks, opts)
+	return append(ret, make([]bool, len(keys)-n)...)
+}
+
 type PrivateKeyInterface interface {
 	String() string
 	Sign([]byte) ([]byte, error)
@@ -1,13 +1,18 @@
 package stcdetail_test
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"github.com/xdrpp/goxdr/xdr"
 	"github.com/xdrpp/stc"
 	. "github.com/xdrpp/stc/stcdetail"
 	"github.com/xdrpp/stc/stx"
 	"io/ioutil"
+	"math"
 	"math/rand"
 	"os"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -45,6 +50,67 @@ func TestJsonInt64e7Conv(t *testing.T) {
 	}
 }
 
+func TestParseAsset(t *testing.T) {
+	issuer := "GBKJFKUT4RKHNKPSCG5TN3IQHFIKMWIFYBWGTUYL3LPZ2IT5SNTGOP2T"
+	for _, c := range []struct {
+		in  string
+		typ stx.AssetType
+	}{
+		{"native", stx.ASSET_TYPE_NATIVE},
+		{"USD:" + issuer, stx.ASSET_TYPE_CREDIT_ALPHANUM4},
+		{"ABCDE:" + issuer, stx.ASSET_TYPE_CREDIT_ALPHANUM12},
+		{"ABCDEFGHIJKL:" + issuer, stx.ASSET_TYPE_CREDIT_ALPHANUM12},
+	} {
+		a, err := ParseAsset(c.in)
+		if err != nil {
+			t.Errorf("ParseAsset(%q): %s", c.in, err)
+		} else if a.Type != c.typ || a.String() != c.in {
+			t.Errorf("ParseAsset(%q) returned %s %s", c.in, a.Type, a)
+		} else if err = CheckAsset(&a); err != nil {
+			t.Errorf("CheckAsset(%s): %s", a, err)
+		}
+	}
+	for _, bad := range []string{"USD", "XLM", ":" + issuer,
+		"ABCDEFGHIJKLM:" + issuer, "U$D:" + issuer, "USD:" + issuer + "X",
+		"USD:GABC"} {
+		if a, err := ParseAsset(bad); err == nil {
+			t.Errorf("ParseAsset(%q) accepted as %s", bad, a)
+		}
+	}
+	a := stx.Asset{Type: stx.ASSET_TYPE_CREDIT_ALPHANUM12}
+	copy(a.AlphaNum12().AssetCode[:], "USD")
+	if CheckAsset(&a) == nil {
+		t.Error("CheckAsset accepted short ASSET_TYPE_CREDIT_ALPHANUM12 code")
+	}
+}
+
+func TestAmounts(t *testing.T) {
+	for _, c := range []struct {
+		in  string
+		val int64
+		out string
+	}{
+		{"123.4567890", 1234567890, "123.456789"},
+		{"-0.5", -5000000, "-0.5"},
+		{".0000001", 1, "0.0000001"},
+		{"7", 70000000, "7"},
+		{"922337203685.4775807", math.MaxInt64, "922337203685.4775807"},
+		{"-922337203685.4775808", math.MinInt64, "-922337203685.4775808"},
+	} {
+		if v, err := ParseAmount(c.in); err != nil || v != c.val {
+			t.Errorf("ParseAmount(%q) = %d, %v", c.in, v, err)
+		} else if s := FormatAmount(v); s != c.out {
+			t.Errorf("FormatAmount(%d) = %q, want %q", v, s, c.out)
+		}
+	}
+	for _, bad := range []string{"", ".", "-", "1.23456789", "1e7", "1,000",
+		"+1", "922337203685.4775808", "99999999999999999999"} {
+		if v, err := ParseAmount(bad); err == nil {
+			t.Errorf("ParseAmount(%q) accepted as %d", bad, v)
+		}
+	}
+}
+
 func TestJsonInt64Conv(t *testing.T) {
 	r := rand.New(rand.NewSource(0))
 	for i := 0; i < 10000; i++ {
@@ -196,6 +262,62 @@ func TestMissingByteArray(t *testing.T) {
 	}
 }
 
+func TestMemoHashEncodings(t *testing.T) {
+	var want stx.Hash
+	for i := range want {
+		want[i] = byte(i)
+	}
+	for _, val := range []string{
+		hex.EncodeToString(want[:]),
+		base64.StdEncoding.EncodeToString(want[:]),
+		base64.RawURLEncoding.EncodeToString(want[:]),
+	} {
+		var m stx.Memo
+		in := strings.NewReader("type: MEMO_RETURN\nretHash: " + val + "\n")
+		if err := XdrFromTxrep(in, "", &m); err != nil {
+			t.Errorf("%s: %s", val, err)
+		} else if *m.RetHash() != want {
+			t.Errorf("%s: parsed as %x", val, *m.RetHash())
+		}
+	}
+
+	var m stx.Memo
+	in := strings.NewReader("type: MEMO_HASH\nhash: " +
+		hex.EncodeToString(want[:31]) + "\n")
+	if err := XdrFromTxrep(in, "", &m); err == nil {
+		t.Error("accepted 31-byte MEMO_HASH")
+	}
+}
+
+func TestCron(t *testing.T) {
+	base := time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC) // a Thursday
+	for _, c := range []struct {
+		spec string
+		next time.Time
+	}{
+		{"30 9 * * 1-5", time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 15, 9, 45, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		// Either day of month or day of week
+		{"0 12 13 * 5", time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	} {
+		cs, err := ParseCron(c.spec)
+		if err != nil {
+			t.Errorf("%s: %s", c.spec, err)
+		} else if next := cs.Next(base); !next.Equal(c.next) {
+			t.Errorf("%s: next %s, expected %s", c.spec, next, c.next)
+		}
+	}
+	for _, bad := range []string{"* * * *", "60 * * * *", "5-1 * * * *",
+		"*/0 * * * *", "x * * * *"} {
+		if _, err := ParseCron(bad); err == nil {
+			t.Errorf("accepted invalid spec %q", bad)
+		}
+	}
+}
+
 func TestForEachXdrType(t *testing.T) {
 	var e stx.TransactionMetaV1
 	e.TxChanges = make([]stx.LedgerEntryChange, 5)
@@ -218,6 +340,28 @@ func TestXdrExtract(t *testing.T) {
 	}
 }
 
+func TestXdrTxrepFields(t *testing.T) {
+	out := &strings.Builder{}
+	XdrTxrepFields(out, "", XdrTypeByName("TransactionEnvelope"))
+	fields := out.String()
+	for _, want := range []string{
+		"\ntx.fee: uint32\n",
+		"\ntx.operations.len: uint32 (max 100)\n",
+		"\ntx.operations[i].body.type: OperationType (CREATE_ACCOUNT, ",
+		"\ntx.operations[i].body.paymentOp.destination: MuxedAccount\n",
+		"\nfeeBump.tx.fee: Int64\n",
+	} {
+		if !strings.Contains(fields, want) {
+			t.Errorf("fields missing %q", strings.TrimSpace(want))
+		}
+	}
+	if XdrTypeByName("Operation") == nil {
+		t.Error("XdrTypeByName cannot find Operation")
+	} else if XdrTypeByName("NoSuchType") != nil {
+		t.Error("XdrTypeByName found NoSuchType")
+	}
+}
+
 func TestFileChanged(t *testing.T) {
 	fi1, e := os.Stat("/etc/fstab")
 	if e != nil {
@@ -325,3 +469,154 @@ func ExampleGetTxrepField() {
 	// tx.ext.v: 0
 	// signatures.len: 0
 }
+
+func TestVerifyTxParallel(t *testing.T) {
+	net := stc.DefaultStellarNet("main")
+	txe := stc.NewTransactionEnvelope()
+	txe.V1().Tx.SeqNum = 1
+	var keys []stx.SignerKey
+	for i := 0; i < 20; i++ {
+		sk := stc.NewPrivateKey(stx.PUBLIC_KEY_TYPE_ED25519)
+		keys = append(keys, sk.Public().ToSignerKey())
+		if err := net.SignTx(&sk, txe); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sigs := make([]stx.Signature, len(keys))
+	for i, s := range *txe.Signatures() {
+		sigs[i] = s.Signature
+	}
+	sigs[3] = append([]byte{}, sigs[4]...)
+	sigs[17] = sigs[17][1:]
+
+	for _, par := range []int{0, 1, 3} {
+		res := VerifyTxParallel(net.GetNetworkId(), txe, keys, sigs,
+			&VerifyOptions{Parallelism: par})
+		for i := range keys {
+			if exp := VerifyTx(&keys[i], net.GetNetworkId(), txe,
+				sigs[i]); res[i] != exp {
+				t.Errorf("parallelism %d: signature %d: got %v, want %v",
+					par, i, res[i], exp)
+			} else if exp != (i != 3 && i != 17) {
+				t.Errorf("signature %d: unexpected validity %v", i, exp)
+			}
+		}
+	}
+
+	res := VerifyTxParallel(net.GetNetworkId(), txe, keys, sigs[:5], nil)
+	if len(res) != len(keys) || !res[4] || res[5] || res[19] {
+		t.Errorf("short sigs: got %v", res)
+	}
+	res = VerifyTxParallel(net.GetNetworkId(), txe, keys[:2], sigs, nil)
+	if len(res) != 2 || !res[0] || !res[1] {
+		t.Errorf("short keys: got %v", res)
+	}
+}
+
+func bigTransactionMeta() *stx.TransactionMeta {
+	var meta stx.TransactionMeta
+	meta.V = 1
+	for i := 0; i < 2000; i++ {
+		var c stx.LedgerEntryChange
+		c.Type = stx.LEDGER_ENTRY_CREATED
+		c.Created().LastModifiedLedgerSeq = uint32(i)
+		c.Created().Data.Type = stx.DATA
+		d := c.Created().Data.Data()
+		d.DataName = fmt.Sprintf("entry%d", i)
+		d.DataValue = make([]byte, 1+i%64)
+		rand.Read(d.DataValue)
+		meta.V1().TxChanges = append(meta.V1().TxChanges, c)
+	}
+	return &meta
+}
+
+func TestXdrDecoder(t *testing.T) {
+	meta := bigTransactionMeta()
+	bin := XdrToBin(meta)
+	var d XdrDecoder
+	for i := 0; i < 2; i++ {
+		var meta2 stx.TransactionMeta
+		if err := d.Decode(&meta2, []byte(bin)); err != nil {
+			t.Fatal(err)
+		} else if XdrToBin(&meta2) != bin {
+			t.Fatal("XdrDecoder round trip mismatch")
+		}
+	}
+	var meta3 stx.TransactionMeta
+	if err := d.Decode(&meta3, []byte(bin[:len(bin)-1])); err == nil {
+		t.Error("XdrDecoder accepted truncated input")
+	}
+}
+
+func TestXdrFromBinSmall(t *testing.T) {
+	var de stx.DataEntry
+	de.DataName = "name"
+	de.DataValue = []byte("value")
+	bin := XdrToBin(&de)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < 100; i++ {
+		var de2 stx.DataEntry
+		if err := XdrFromBin(&de2, bin); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runtime.ReadMemStats(&after)
+	if n := (after.TotalAlloc - before.TotalAlloc) / 100; n > 1024 {
+		t.Errorf("XdrFromBin allocated %d bytes for a %d-byte input",
+			n, len(bin))
+	}
+}
+
+func TestXdrDecoderArena(t *testing.T) {
+	input := []byte(XdrToBin(bigTransactionMeta()))
+	var d XdrDecoder
+	var meta stx.TransactionMeta
+	if err := d.DecodeArena(&meta, input); err != nil {
+		t.Fatal(err)
+	} else if XdrToBin(&meta) != string(input) {
+		t.Fatal("DecodeArena round trip mismatch")
+	}
+	// DataValue should alias the input buffer
+	v := meta.V1().TxChanges[0].Created().Data.Data().DataValue
+	v[0]++
+	if XdrToBin(&meta) != string(input) {
+		t.Error("DecodeArena copied opaque data")
+	}
+}
+
+func BenchmarkXdrDecoderArena(b *testing.B) {
+	bin := []byte(XdrToBin(bigTransactionMeta()))
+	b.SetBytes(int64(len(bin)))
+	b.ReportAllocs()
+	var d XdrDecoder
+	for i := 0; i < b.N; i++ {
+		var meta stx.TransactionMeta
+		if err := d.DecodeArena(&meta, bin); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkXdrIn(b *testing.B) {
+	bin := XdrToBin(bigTransactionMeta())
+	b.SetBytes(int64(len(bin)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var meta stx.TransactionMeta
+		meta.XdrMarshal(&xdr.XdrIn{In: strings.NewReader(bin)}, "")
+	}
+}
+
+func BenchmarkXdrDecoder(b *testing.B) {
+	bin := []byte(XdrToBin(bigTransactionMeta()))
+	b.SetBytes(int64(len(bin)))
+	b.ReportAllocs()
+	var d XdrDecoder
+	for i := 0; i < b.N; i++ {
+		var meta stx.TransactionMeta
+		if err := d.Decode(&meta, bin); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package stcdetail
+
+import (
+	"fmt"
+	"github.com/xdrpp/goxdr/xdr"
+	"github.com/xdrpp/stc/stx"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Walks every field that can appear in the txrep of a type, setting
+// each union to each of its valid arms in turn, each pointer to
+// present, and each vector to one element.
+type txrepFields struct {
+	txrState
+	out   io.Writer
+	seen  map[string]bool
+	types map[string]reflect.Type
+}
+
+func (xf *txrepFields) Sprintf(f string, args ...interface{}) string {
+	return fmt.Sprintf(f, args...)
+}
+
+func (xf *txrepFields) emit(name, desc string) {
+	if xf.seen[name] {
+		return
+	}
+	xf.seen[name] = true
+	if xf.out != nil {
+		fmt.Fprintf(xf.out, "%s: %s\n", name, desc)
+	}
+}
+
+// Record named types so they can be looked up by XdrTypeByName.
+func (xf *txrepFields) record(i xdr.XdrType) {
+	if xf.types == nil {
+		return
+	}
+	name := i.XdrTypeName()
+	t := reflect.TypeOf(i)
+	if _, ok := xf.types[name]; ok || t.Kind() != reflect.Ptr ||
+		strings.IndexFunc(name, func(r rune) bool {
+			return !(r == '_' || r >= '0' && r <= '9' ||
+				r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z')
+		}) >= 0 {
+		return
+	}
+	if x, ok := reflect.New(t.Elem()).Interface().(xdr.XdrType); ok &&
+		x.XdrTypeName() == name {
+		xf.types[name] = t.Elem()
+	}
+}
+
+// True if an enclosing structure or union has the same type as i.
+func (xf *txrepFields) recursive(i xdr.XdrType) bool {
+	for h := xf.front.next; h != nil; h = h.next {
+		switch h.obj.(type) {
+		case xdr.XdrPtr, xdr.XdrVec:
+		default:
+			if h.obj.XdrTypeName() == i.XdrTypeName() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (xf *txrepFields) enumValues(v xdr.XdrEnum) string {
+	valid := xf.validTags()
+	names := v.XdrEnumNames()
+	vals := make([]int, 0, len(names))
+	for n := range names {
+		if valid == nil || valid[int32(n)] {
+			vals = append(vals, int(n))
+		}
+	}
+	sort.Ints(vals)
+	out := make([]string, len(vals))
+	for i, n := range vals {
+		out[i] = names[int32(n)]
+	}
+	return strings.Join(out, ", ")
+}
+
+// Returns the tags of all arms of a union in numeric order.
+func unionTags(u xdr.XdrUnion) []int {
+	var ret []int
+	if valid := u.XdrValidTags(); valid != nil {
+		for n := range valid {
+			ret = append(ret, int(n))
+		}
+	} else if e, ok := u.XdrUnionTag().(xdr.XdrEnum); ok {
+		for n := range e.XdrEnumNames() {
+			ret = append(ret, int(n))
+		}
+	}
+	sort.Ints(ret)
+	return ret
+}
+
+func (xf *txrepFields) Marshal(field string, i xdr.XdrType) {
+	if xf.front != nil && field == "[0]" {
+		if _, ok := xf.front.obj.(xdr.XdrVec); ok {
+			field = "[i]"
+		}
+	}
+	if k, ok := i.(xdr.XdrArrayOpaque); ok && k.XdrArraySize() == 32 &&
+		field == "sourceAccountEd25519" {
+		field = "sourceAccount"
+		i = &stx.AccountID{}
+	}
+	xf.push(field, i)
+	defer xf.pop()
+	name := xf.name()
+	xf.record(i)
+
+	switch v := i.(type) {
+	case stx.XdrType_SequenceNumber, stx.XdrType_TimePoint, *stx.Asset,
+		stx.IsAccount, *stx.SignerKey:
+		xf.emit(name, i.XdrTypeName())
+	case xdr.XdrEnum:
+		xf.emit(name, fmt.Sprintf("%s (%s)", i.XdrTypeName(),
+			xf.enumValues(v)))
+	case stx.XdrType_Int64, xdr.XdrVecOpaque, fmt.Stringer:
+		xf.emit(name, i.XdrTypeName())
+	case xdr.XdrPtr:
+		xf.emit(xf.present(), "bool")
+		v.SetPresent(true)
+		v.XdrMarshalValue(xf, "")
+	case xdr.XdrVec:
+		if b := v.XdrBound(); b != 0xffffffff {
+			xf.emit(xf.length(), fmt.Sprintf("uint32 (max %d)", b))
+		} else {
+			xf.emit(xf.length(), "uint32")
+		}
+		if v.XdrBound() > 0 {
+			v.SetVecLen(1)
+			v.XdrMarshalN(xf, "", 1)
+		}
+	case *stx.DecoratedSignature:
+		xf.emit(name+".hint", "SignatureHint")
+		xf.emit(name+".signature", "Signature")
+	case xdr.XdrUnion:
+		if xf.recursive(i) {
+			xf.emit(name, i.XdrTypeName()+" (recursive)")
+			return
+		}
+		tag, ok := v.XdrUnionTag().(interface{ SetU32(uint32) })
+		if !ok {
+			v.XdrRecurse(xf, "")
+			return
+		}
+		for _, n := range unionTags(v) {
+			tag.SetU32(uint32(n))
+			v.XdrRecurse(xf, "")
+		}
+	case xdr.XdrAggregate:
+		if xf.recursive(i) {
+			xf.emit(name, i.XdrTypeName()+" (recursive)")
+			return
+		}
+		v.XdrRecurse(xf, "")
+	default:
+		xf.emit(name, i.XdrTypeName())
+	}
+}
+
+// Writes every field path that can appear in the txrep of t to out,
+// one per line, along with the field's XDR type and, for enums, the
+// allowed values.  All arms of every union are shown.  Array indices
+// are written [i], and a type nested within itself (such as a
+// ClaimPredicate) is shown only once.  Modifies t, so t should be a
+// freshly allocated value.
+func XdrTxrepFields(out io.Writer, name string, t xdr.XdrType) {
+	xf := &txrepFields{out: out, seen: map[string]bool{}}
+	xf.Marshal(name, t)
+}
+
+// Types whose fields are searched by XdrTypeByName.
+var fieldRoots = []func() xdr.XdrType{
+	func() xdr.XdrType { return &stx.TransactionEnvelope{} },
+	func() xdr.XdrType { return &stx.TransactionResult{} },
+	func() xdr.XdrType { return &stx.TransactionMeta{} },
+	func() xdr.XdrType { return &stx.LedgerHeader{} },
+	func() xdr.XdrType { return &stx.LedgerEntry{} },
+	func() xdr.XdrType { return &stx.LedgerKey{} },
+}
+
+var xdrTypesByName map[string]reflect.Type
+var xdrTypesOnce sync.Once
+
+func xdrTypes() map[string]reflect.Type {
+	xdrTypesOnce.Do(func() {
+		xf := &txrepFields{
+			seen:  map[string]bool{},
+			types: map[string]reflect.Type{},
+		}
+		for _, root := range fieldRoots {
+			xf.Marshal("", root())
+		}
+		xdrTypesByName = xf.types
+	})
+	return xdrTypesByName
+}
+
+// Returns a newly allocated value of the named XDR structure, union,
+// or enum type (e.g., "TransactionEnvelope" or "Operation"), or nil
+// if there is no such type in a transaction, transaction result or
+// meta, ledger header, ledger entry, or ledger key.
+func XdrTypeByName(name string) xdr.XdrType {
+	if t, ok := xdrTypes()[name]; ok {
+		return reflect.New(t).Interface().(xdr.XdrType)
+	}
+	return nil
+}
+
+// Returns the names of all types that XdrTypeByName can return, in
+// sorted order.
+func XdrTypeNames() []string {
+	ret := make([]string, 0, len(xdrTypes()))
+	for name := range xdrTypes() {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,69 @@
+package stcdetail
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"golang.org/x/crypto/pbkdf2"
+	"strings"
+)
+
+// Returns the canonical form of a BIP-39 mnemonic:  the words in
+// lower case separated by single spaces.  Returns an error unless the
+// mnemonic has 12, 15, 18, 21, or 24 words.  Note that the checksum
+// in the last word is not verified, since that would require the
+// BIP-39 word list.
+func NormalizeMnemonic(mnemonic string) (string, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if n := len(words); n < 12 || n > 24 || n%3 != 0 {
+		return "", fmt.Errorf("mnemonic has %d words; should be 12, 15, "+
+			"18, 21, or 24", n)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// Returns the 64-byte BIP-39 seed for a mnemonic and optional
+// passphrase.  BIP-39 calls for NFKD normalization of both strings,
+// which only matters for non-ASCII text and is not performed here.
+func MnemonicSeed(mnemonic, passphrase string) []byte {
+	return pbkdf2.Key([]byte(mnemonic), []byte("mnemonic"+passphrase),
+		2048, 64, sha512.New)
+}
+
+// Bit marking hardened indices in a SLIP-10 derivation path.
+const HardenedIndex uint32 = 0x80000000
+
+// Derives a 32-byte ed25519 seed from a BIP-39 seed along a SLIP-10
+// path.  Since SLIP-10 only supports hardened derivation for ed25519,
+// HardenedIndex is added to every element of path.
+func SLIP10Ed25519(seed []byte, path ...uint32) []byte {
+	mac := hmac.New(sha512.New, []byte("ed25519 seed"))
+	mac.Write(seed)
+	i := mac.Sum(nil)
+	for _, n := range path {
+		var data [37]byte
+		copy(data[1:33], i[:32])
+		binary.BigEndian.PutUint32(data[33:], n|HardenedIndex)
+		mac = hmac.New(sha512.New, i[32:])
+		mac.Write(data[:])
+		i = mac.Sum(nil)
+	}
+	return i[:32]
+}
+
+// Returns the SLIP-10 path SEP-5 uses for Stellar account number
+// index, namely m/44'/148'/index'.
+func SEP5Path(index uint32) []uint32 {
+	return []uint32{44, 148, index}
+}
+
+// Formats a SLIP-10 path of hardened indices, as in "m/44'/148'/0'".
+func SLIP10PathString(path []uint32) string {
+	out := &strings.Builder{}
+	out.WriteString("m")
+	for _, n := range path {
+		fmt.Fprintf(out, "/%d'", n&^HardenedIndex)
+	}
+	return out.String()
+}
@@ -115,6 +115,13 @@ needs to reconnect.
 
 */
 func Stream(ctx context.Context, url string,
+	cb func(eventType string, data []byte) error) error {
+	return StreamClient(ctx, http.DefaultClient, url, cb)
+}
+
+// Like Stream, but sends requests with client instead of
+// http.DefaultClient.
+func StreamClient(ctx context.Context, client *http.Client, url string,
 	cb func(eventType string, data []byte) error) error {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -142,7 +149,7 @@ func Stream(ctx context.Context, url string,
 
 	for ctx.Err() == nil {
 		cleanup()
-		resp, err = http.DefaultClient.Do(req)
+		resp, err = client.Do(req)
 		if err != nil || ctx.Err() != nil {
 			return err
 		}
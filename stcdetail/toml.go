@@ -0,0 +1,261 @@
+package stcdetail
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A TOML document as returned by ParseToml.  Values are strings (for
+// TOML strings, and the literal text of numbers, booleans, and dates)
+// or []interface{} (for arrays).
+type TomlDoc struct {
+	// Key/value pairs that precede the first table header.
+	Top map[string]interface{}
+
+	// Tables by name.  A [NAME] table yields a single element, while
+	// each [[NAME]] header appends another element.
+	Tables map[string][]map[string]interface{}
+}
+
+type tomlParser struct {
+	s    string
+	pos  int
+	line int
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *tomlParser) hasPrefix(prefix string) bool {
+	return strings.HasPrefix(p.s[p.pos:], prefix)
+}
+
+// Skips spaces, tabs, and a comment, but not the end of the line.
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+	if !p.eof() && p.s[p.pos] == '#' {
+		for !p.eof() && p.s[p.pos] != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// Skips whitespace, comments, and newlines.
+func (p *tomlParser) skipLines() {
+	for {
+		p.skipSpace()
+		if p.eof() || (p.s[p.pos] != '\n' && p.s[p.pos] != '\r') {
+			return
+		} else if p.s[p.pos] == '\n' {
+			p.line++
+		}
+		p.pos++
+	}
+}
+
+// Parses text up to (but not including) a delimiter, returning it
+// with surrounding whitespace removed.
+func (p *tomlParser) token(delims string) string {
+	start := p.pos
+	for !p.eof() && strings.IndexByte(delims, p.s[p.pos]) < 0 {
+		p.pos++
+	}
+	return strings.TrimSpace(p.s[start:p.pos])
+}
+
+// Parses a string starting at p.pos with the quote character q,
+// which may be tripled for a multi-line string.
+func (p *tomlParser) str(q byte) (string, error) {
+	delim := string([]byte{q})
+	if p.hasPrefix(delim + delim + delim) {
+		delim += delim + delim
+	}
+	p.pos += len(delim)
+	if len(delim) == 3 {
+		// A newline immediately after the opening delimiter is trimmed
+		if p.hasPrefix("\r\n") {
+			p.pos += 2
+			p.line++
+		} else if p.hasPrefix("\n") {
+			p.pos++
+			p.line++
+		}
+	}
+	start := p.pos
+	for {
+		if p.eof() || (len(delim) == 1 && p.s[p.pos] == '\n') {
+			return "", p.errorf("unterminated string")
+		} else if p.hasPrefix(delim) {
+			break
+		} else if p.s[p.pos] == '\\' && q == '"' {
+			p.pos++
+		} else if p.s[p.pos] == '\n' {
+			p.line++
+		}
+		p.pos++
+	}
+	body := p.s[start:p.pos]
+	p.pos += len(delim)
+	if q == '\'' {
+		return body, nil
+	}
+	var out strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] != '\\' {
+			out.WriteByte(body[i])
+			continue
+		}
+		// A backslash at the end of a line trims the following
+		// whitespace
+		j := i + 1
+		for j < len(body) && (body[j] == ' ' || body[j] == '\t') {
+			j++
+		}
+		if j < len(body) && (body[j] == '\n' || body[j] == '\r') {
+			for j < len(body) && strings.IndexByte(" \t\r\n", body[j]) >= 0 {
+				j++
+			}
+			i = j - 1
+			continue
+		}
+		n := 2
+		switch {
+		case i+1 < len(body) && body[i+1] == 'u':
+			n = 6
+		case i+1 < len(body) && body[i+1] == 'U':
+			n = 10
+		}
+		if i+n > len(body) {
+			return "", p.errorf("bad escape sequence in string")
+		}
+		r, _, _, err := strconv.UnquoteChar(body[i:i+n], '"')
+		if err != nil {
+			return "", p.errorf("bad escape sequence %q", body[i:i+n])
+		}
+		out.WriteRune(r)
+		i += n - 1
+	}
+	return out.String(), nil
+}
+
+func (p *tomlParser) value() (interface{}, error) {
+	if p.eof() {
+		return nil, p.errorf("missing value")
+	}
+	switch p.s[p.pos] {
+	case '"', '\'':
+		return p.str(p.s[p.pos])
+	case '[':
+		p.pos++
+		ret := []interface{}{}
+		for {
+			p.skipLines()
+			if p.eof() {
+				return nil, p.errorf("unterminated array")
+			} else if p.s[p.pos] == ']' {
+				p.pos++
+				return ret, nil
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, v)
+			p.skipLines()
+			if !p.eof() && p.s[p.pos] == ',' {
+				p.pos++
+			}
+		}
+	case '{':
+		return nil, p.errorf("inline tables not supported")
+	}
+	v := p.token(",]#\r\n")
+	if v == "" {
+		return nil, p.errorf("missing value")
+	}
+	return v, nil
+}
+
+func (p *tomlParser) key() (string, error) {
+	if !p.eof() && (p.s[p.pos] == '"' || p.s[p.pos] == '\'') {
+		return p.str(p.s[p.pos])
+	}
+	k := p.token("=]\r\n#")
+	if k == "" {
+		return "", p.errorf("missing key")
+	}
+	return k, nil
+}
+
+// Parses the subset of TOML used by configuration files such as
+// stellar.toml (SEP-1): comments, keys with string, number, boolean,
+// date, or array values, tables, and arrays of tables.  Inline tables
+// and dotted keys are not supported.
+func ParseToml(data []byte) (*TomlDoc, error) {
+	ret := &TomlDoc{
+		Top:    make(map[string]interface{}),
+		Tables: make(map[string][]map[string]interface{}),
+	}
+	p := &tomlParser{s: string(data), line: 1}
+	cur := ret.Top
+	for {
+		p.skipLines()
+		if p.eof() {
+			return ret, nil
+		}
+		if p.s[p.pos] == '[' {
+			array := p.hasPrefix("[[")
+			if array {
+				p.pos += 2
+			} else {
+				p.pos++
+			}
+			name, err := p.key()
+			if err != nil {
+				return nil, err
+			} else if (array && !p.hasPrefix("]]")) ||
+				(!array && !p.hasPrefix("]")) {
+				return nil, p.errorf("malformed table header")
+			} else if array {
+				p.pos += 2
+			} else {
+				p.pos++
+			}
+			if !array && len(ret.Tables[name]) > 0 {
+				return nil, p.errorf("table %s defined twice", name)
+			}
+			cur = make(map[string]interface{})
+			ret.Tables[name] = append(ret.Tables[name], cur)
+		} else {
+			k, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			p.skipSpace()
+			if p.eof() || p.s[p.pos] != '=' {
+				return nil, p.errorf("expected = after %s", k)
+			}
+			p.pos++
+			p.skipSpace()
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			} else if _, ok := cur[k]; ok {
+				return nil, p.errorf("key %s defined twice", k)
+			}
+			cur[k] = v
+		}
+		p.skipSpace()
+		if !p.eof() && p.s[p.pos] != '\n' && p.s[p.pos] != '\r' {
+			return nil, p.errorf("unexpected text after value")
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/xdrpp/goxdr/xdr"
 	"github.com/xdrpp/stc/stx"
 	"io"
+	"sort"
 	"strings"
 	"time"
 )
@@ -160,6 +161,15 @@ func (xs *txrState) pop() {
 	xs.front = xs.front.next
 }
 
+// True when the current field is an arm of a Memo.
+func (xs *txrState) inMemo() bool {
+	if xs.front == nil || xs.front.next == nil {
+		return false
+	}
+	_, ok := xs.front.next.obj.(*stx.Memo)
+	return ok
+}
+
 func (xs *txrState) envelope() *stx.TransactionEnvelope {
 	for h := xs.front; h != nil; h = h.next {
 		if e, ok := h.obj.(*stx.TransactionEnvelope); ok {
@@ -190,11 +200,32 @@ type txStringCtx struct {
 	sigNote       func(*stx.TransactionEnvelope, *stx.DecoratedSignature) string
 	signerNote    func(*stx.SignerKey) string
 	getHelp       func(string) bool
-	out           io.Writer
+	emit          func(TxrepRow)
 	native        string
 	txrState
 }
 
+// One line of txrep:  a field name, its value, and an optional
+// comment that parsers ignore (e.g., the comment on an account, or
+// the scaled value of an amount).
+type TxrepRow struct {
+	Field   string
+	Value   string
+	Comment string
+}
+
+// Formats a row as a line of txrep (without the newline).
+func (r TxrepRow) String() string {
+	if r.Comment != "" {
+		return fmt.Sprintf("%s: %s (%s)", r.Field, r.Value, r.Comment)
+	}
+	return fmt.Sprintf("%s: %s", r.Field, r.Value)
+}
+
+func (xp *txStringCtx) row(field, value, comment string) {
+	xp.emit(TxrepRow{Field: field, Value: value, Comment: comment})
+}
+
 func (xp *txStringCtx) Sprintf(f string, args ...interface{}) string {
 	return fmt.Sprintf(f, args...)
 }
@@ -244,7 +275,7 @@ func dateComment(ut uint64) string {
 	if it <= 0 {
 		return ""
 	}
-	return fmt.Sprintf(" (%s)", time.Unix(it, 0).Format(time.UnixDate))
+	return time.Unix(it, 0).UTC().Format(time.UnixDate)
 }
 
 // Convert an array of bytes into a string of hex digits.  Show an
@@ -284,75 +315,62 @@ func (xp *txStringCtx) Marshal(field string, i xdr.XdrType) {
 	}
 	switch v := i.(type) {
 	case stx.XdrType_SequenceNumber:
-		fmt.Fprintf(xp.out, "%s: %d\n", name, v.XdrValue())
+		xp.row(name, fmt.Sprintf("%d", v.XdrValue()), "")
 	case stx.XdrType_TimePoint:
 		tp := v.XdrValue().(stx.TimePoint)
-		fmt.Fprintf(xp.out, "%s: %d%s\n", name, tp, dateComment(tp))
+		xp.row(name, fmt.Sprintf("%d", tp), dateComment(uint64(tp)))
 	case *stx.Asset:
 		asset := v.String()
 		if asset == "native" {
 			asset = xp.native
 		}
-		fmt.Fprintf(xp.out, "%s: %s\n", name, asset)
+		xp.row(name, asset, "")
 	case stx.IsAccount:
 		ac := v.String()
-		if hint := xp.accountIDNote(ac); hint != "" {
-			fmt.Fprintf(xp.out, "%s: %s (%s)\n", name, ac, hint)
-		} else {
-			fmt.Fprintf(xp.out, "%s: %s\n", name, ac)
-		}
+		xp.row(name, ac, xp.accountIDNote(ac))
 	case *stx.SignerKey:
-		if hint := xp.signerNote(v); hint != "" {
-			fmt.Fprintf(xp.out, "%s: %s (%s)\n", name, v, hint)
-		} else {
-			fmt.Fprintf(xp.out, "%s: %s\n", name, v)
-		}
+		xp.row(name, v.String(), xp.signerNote(v))
 	case xdr.XdrEnum:
 		if xp.getHelp(name) {
-			fmt.Fprintf(xp.out, "%s: %s (", name, v.String())
-			var notfirst bool
+			var help []string
 			valid := xp.validTags()
-			for n, name := range v.XdrEnumNames() {
-				if valid != nil && !valid[n] {
+			names := v.XdrEnumNames()
+			vals := make([]int, 0, len(names))
+			for n := range names {
+				vals = append(vals, int(n))
+			}
+			// Sort so output does not depend on map iteration order
+			sort.Ints(vals)
+			for _, n := range vals {
+				if valid != nil && !valid[int32(n)] {
 					continue
 				}
-				if notfirst {
-					fmt.Fprintf(xp.out, ", %s", name)
-				} else {
-					notfirst = true
-					fmt.Fprintf(xp.out, "%s", name)
-				}
+				help = append(help, names[int32(n)])
 			}
-			fmt.Fprintf(xp.out, ")\n")
+			xp.row(name, v.String(), strings.Join(help, ", "))
 		} else {
-			fmt.Fprintf(xp.out, "%s: %s\n", name, v.String())
+			xp.row(name, v.String(), "")
 		}
 	case stx.XdrType_Int64:
-		fmt.Fprintf(xp.out, "%s: %s (%s)\n", name, v.String(),
-			ScaleFmt(int64(v.GetU64()), 7))
+		xp.row(name, v.String(), ScaleFmt(int64(v.GetU64()), 7))
 	case xdr.XdrVecOpaque:
-		fmt.Fprintf(xp.out, "%s: %s\n", name, PrintVecOpaque(v.GetByteSlice()))
+		xp.row(name, PrintVecOpaque(v.GetByteSlice()), "")
 	case fmt.Stringer:
-		fmt.Fprintf(xp.out, "%s: %s\n", name, v.String())
+		xp.row(name, v.String(), "")
 	case xdr.XdrPtr:
-		fmt.Fprintf(xp.out, "%s: %v\n", xp.present(), v.GetPresent())
+		xp.row(xp.present(), fmt.Sprintf("%v", v.GetPresent()), "")
 		v.XdrMarshalValue(xp, "")
 	case xdr.XdrVec:
-		fmt.Fprintf(xp.out, "%s: %d\n", xp.length(), v.GetVecLen())
+		xp.row(xp.length(), fmt.Sprintf("%d", v.GetVecLen()), "")
 		v.XdrMarshalN(xp, "", v.GetVecLen())
 	case *stx.DecoratedSignature:
-		var hint string
-		if note := xp.sigNote(xp.envelope(), v); note != "" {
-			hint = fmt.Sprintf("%x (%s)", v.Hint, note)
-		} else {
-			hint = fmt.Sprintf("%x", v.Hint)
-		}
-		fmt.Fprintf(xp.out, "%[1]s.hint: %[2]s\n%[1]s.signature: %[3]s\n",
-			name, hint, PrintVecOpaque(v.Signature))
+		xp.row(name+".hint", fmt.Sprintf("%x", v.Hint),
+			xp.sigNote(xp.envelope(), v))
+		xp.row(name+".signature", PrintVecOpaque(v.Signature), "")
 	case xdr.XdrAggregate:
 		v.XdrRecurse(xp, "")
 	default:
-		fmt.Fprintf(xp.out, "%s: %v\n", name, i)
+		xp.row(name, fmt.Sprintf("%v", i), "")
 	}
 }
 
@@ -371,7 +389,32 @@ func (xp *txStringCtx) Marshal(field string, i xdr.XdrType) {
 //
 // Help comment for field fieldname:
 //   GetHelp(fieldname string) bool
+//
+// The output is deterministic:  fields appear one per line in the
+// order they are declared in the XDR, with array elements in index
+// order and a union's discriminant before its arm; enum help comments
+// list values in numeric order; and times are commented in UTC.
+// Hence, given the same t and the same comment methods, XdrToTxrep
+// produces byte-identical output on any machine.
 func XdrToTxrep(out io.Writer, name string, t xdr.XdrType) XdrBadValue {
+	return xdrToTxrepRows(name, t, func(r TxrepRow) {
+		fmt.Fprintln(out, r.String())
+	})
+}
+
+// Like XdrToTxrep, but returns the lines of txrep as structured rows,
+// for programs (such as GUIs) that want to present the fields,
+// values, and comments with their own formatting.
+func XdrToTxrepRows(name string, t xdr.XdrType) ([]TxrepRow, XdrBadValue) {
+	var ret []TxrepRow
+	err := xdrToTxrepRows(name, t, func(r TxrepRow) {
+		ret = append(ret, r)
+	})
+	return ret, err
+}
+
+func xdrToTxrepRows(name string, t xdr.XdrType,
+	emit func(TxrepRow)) XdrBadValue {
 	ctx := txStringCtx{
 		accountIDNote: func(string) string { return "" },
 		signerNote: func(*stx.SignerKey) string { return "" },
@@ -380,7 +423,7 @@ func XdrToTxrep(out io.Writer, name string, t xdr.XdrType) XdrBadValue {
 			return ""
 		},
 		getHelp: func(string) bool { return false },
-		out:     out,
+		emit:    emit,
 	}
 
 	if i, ok := t.(interface{ AccountIDNote(string) string }); ok {
@@ -458,6 +501,7 @@ type xdrScan struct {
 	setHelp func(string)
 	native  *string
 	lastlv *lineval
+	accountAlias func(string) (string, bool)
 }
 
 func (*xdrScan) Sprintf(f string, args ...interface{}) string {
@@ -511,6 +555,12 @@ func (xs *xdrScan) Marshal(field string, i xdr.XdrType) {
 		}
 	}()
 	val := lv.val
+	if _, isAcct := i.(stx.IsAccount); isAcct && ok {
+		if acct, found := xs.accountAlias(strings.TrimSpace(val));
+		found {
+			val = acct
+		}
+	}
 	if init, hasInit := i.(interface{ XdrInitialize() }); hasInit {
 		init.XdrInitialize()
 	}
@@ -519,7 +569,15 @@ func (xs *xdrScan) Marshal(field string, i xdr.XdrType) {
 		if !ok {
 			return
 		}
-		_, err := fmt.Sscan(val, v)
+		var err error
+		if xs.inMemo() {
+			// Accept base64 as well as hex for MEMO_HASH and MEMO_RETURN
+			var word string
+			fmt.Sscan(val, &word)
+			err = ParseOpaque(v.GetByteSlice(), word)
+		} else {
+			_, err = fmt.Sscan(val, v)
+		}
 		if err != nil {
 			xs.setHelp(name)
 			xs.report(lv.line, "%s", err.Error())
@@ -652,7 +710,11 @@ func (xs *xdrScan) readKvs(in io.Reader) {
 
 // Parse input in Txrep format into an XdrType type.  If the XdrType
 // has a method named SetHelp(string), then it is called for field
-// names when the value ends with '?'.
+// names when the value ends with '?'.  If it has a method
+// LookupAccountAlias(string) (string, bool), then the entire value of
+// an account field is passed to it, and if it returns true the value
+// is replaced by the string returned (so that users can type names
+// instead of strkeys).
 func XdrFromTxrep(in io.Reader, name string, t xdr.XdrType) TxrepError {
 	xs := &xdrScan{}
 	if sh, ok := t.(interface{ SetHelp(string) }); ok {
@@ -660,6 +722,13 @@ func XdrFromTxrep(in io.Reader, name string, t xdr.XdrType) TxrepError {
 	} else {
 		xs.setHelp = func(string) {}
 	}
+	if la, ok := t.(interface {
+		LookupAccountAlias(string) (string, bool)
+	}); ok {
+		xs.accountAlias = la.LookupAccountAlias
+	} else {
+		xs.accountAlias = func(string) (string, bool) { return "", false }
+	}
 	if nam, ok := t.(interface{ GetNativeAsset() string }); ok {
 		na := nam.GetNativeAsset()
 		xs.native = &na
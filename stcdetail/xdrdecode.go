@@ -0,0 +1,141 @@
+package stcdetail
+
+import (
+	"encoding/binary"
+	"github.com/xdrpp/goxdr/xdr"
+)
+
+// Maximum size of the chunks from which an XdrDecoder carves
+// variable-length fields.  Fields larger than a quarter of this are
+// allocated individually.
+const xdrScratchSize = 8192
+
+// An XdrDecoder unmarshals RFC4506 binary XDR from a byte slice.  It
+// is a drop-in replacement for xdr.XdrIn that is considerably cheaper
+// when processing large volumes of XDR (such as TransactionMeta from
+// history archives):  it reads numbers directly out of the input
+// without going through an io.Reader, never formats field names, and
+// copies small opaque fields into shared scratch chunks instead of
+// allocating each one separately.  Chunks are no larger than the rest
+// of the input, so decoding a small value allocates little, but a
+// single retained opaque field may keep a whole chunk (up to a few
+// KiB) from being garbage collected.
+//
+// An XdrDecoder can be reused for many inputs, but is not safe for
+// concurrent use.  The zero value is ready to use.
+type XdrDecoder struct {
+	in      []byte
+	pos     int
+	scratch []byte
+
+	// If true, variable-length opaque fields alias the input.
+	arena bool
+
+	trivSprintf
+}
+
+func (d *XdrDecoder) take(n int) []byte {
+	if n < 0 || len(d.in)-d.pos < n {
+		xdr.XdrPanic("XdrDecoder: input truncated at byte %d", d.pos)
+	}
+	ret := d.in[d.pos : d.pos+n : d.pos+n]
+	d.pos += n
+	return ret
+}
+
+func (d *XdrDecoder) skipPad(n int) {
+	if pad := (4 - n&3) & 3; pad != 0 {
+		d.take(pad)
+	}
+}
+
+func (d *XdrDecoder) alloc(n int) []byte {
+	if n > xdrScratchSize/4 {
+		return make([]byte, n)
+	} else if len(d.scratch) < n {
+		// The rest of the input bounds what remains to be copied
+		size := n + len(d.in) - d.pos
+		if size > xdrScratchSize {
+			size = xdrScratchSize
+		}
+		d.scratch = make([]byte, size)
+	}
+	ret := d.scratch[:n:n]
+	d.scratch = d.scratch[n:]
+	return ret
+}
+
+func (d *XdrDecoder) Marshal(name string, i xdr.XdrType) {
+	switch v := i.(type) {
+	case xdr.XdrNum32:
+		v.SetU32(binary.BigEndian.Uint32(d.take(4)))
+	case xdr.XdrNum64:
+		v.SetU64(binary.BigEndian.Uint64(d.take(8)))
+	case xdr.XdrString:
+		n := binary.BigEndian.Uint32(d.take(4))
+		if n > v.XdrBound() {
+			xdr.XdrPanic("XdrDecoder: length %d exceeds bound %d", n,
+				v.XdrBound())
+		}
+		v.SetString(string(d.take(int(n))))
+		d.skipPad(int(n))
+	case xdr.XdrVarBytes:
+		n := binary.BigEndian.Uint32(d.take(4))
+		if n > v.XdrBound() {
+			xdr.XdrPanic("XdrDecoder: length %d exceeds bound %d", n,
+				v.XdrBound())
+		}
+		if src := d.take(int(n)); d.arena {
+			v.SetByteSlice(src)
+		} else {
+			bs := d.alloc(int(n))
+			copy(bs, src)
+			v.SetByteSlice(bs)
+		}
+		d.skipPad(int(n))
+	case xdr.XdrBytes:
+		bs := v.GetByteSlice()
+		copy(bs, d.take(len(bs)))
+		d.skipPad(len(bs))
+	case xdr.XdrAggregate:
+		v.XdrRecurse(d, name)
+	}
+}
+
+// Unmarshal t from the binary XDR in input.  The decoder does not
+// retain input, which the caller may reuse once Decode returns.
+func (d *XdrDecoder) Decode(t xdr.XdrType, input []byte) error {
+	d.arena = false
+	return d.decode(t, input)
+}
+
+// Like Decode, but variable-length opaque fields of t (such as
+// DataValue or the XDR blobs inside Soroban values) are slices of
+// input rather than copies, which avoids nearly all allocation for
+// opaque data when scanning large amounts of history.  The resulting
+// fields must be treated as read-only:  modifying them modifies input
+// (and any other structure decoded from it), and the caller must not
+// modify or reuse input while t is still in use.  Strings are always
+// copied, since Go strings are immutable.  Fixed-length opaque fields
+// (such as hashes and public keys) are stored inline and hence
+// copied as usual.
+func (d *XdrDecoder) DecodeArena(t xdr.XdrType, input []byte) error {
+	d.arena = true
+	return d.decode(t, input)
+}
+
+func (d *XdrDecoder) decode(t xdr.XdrType, input []byte) (err error) {
+	defer func() {
+		d.in = nil
+		if i := recover(); i != nil {
+			if xe, ok := i.(xdr.XdrError); ok {
+				err = xe
+				return
+			}
+			panic(i)
+		}
+	}()
+	d.in, d.pos = input, 0
+	t.XdrMarshal(d, "")
+	return nil
+}
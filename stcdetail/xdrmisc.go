@@ -25,19 +25,9 @@ func XdrToBin(t xdr.XdrType) string {
 }
 
 // Unmarshal an XDR type from the raw binary bytes defined in RFC4506.
-func XdrFromBin(t xdr.XdrType, input string) (err error) {
-	defer func() {
-		if i := recover(); i != nil {
-			if xe, ok := i.(xdr.XdrError); ok {
-				err = xe
-				return
-			}
-			panic(i)
-		}
-	}()
-	in := strings.NewReader(input)
-	t.XdrMarshal(&xdr.XdrIn{in}, "")
-	return
+func XdrFromBin(t xdr.XdrType, input string) error {
+	var d XdrDecoder
+	return d.Decode(t, []byte(input))
 }
 
 type forEachXdr struct {
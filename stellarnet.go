@@ -1,11 +1,20 @@
 package stc
 
 import (
+	"bufio"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/xdrpp/stc/ini"
+	"github.com/xdrpp/stc/internal/singleflight"
 	"github.com/xdrpp/stc/stcdetail"
 	"github.com/xdrpp/stc/stx"
+	"io"
+	"net/http"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -22,14 +31,86 @@ type StellarNet struct {
 	// Base URL of horizon (including trailing slash).
 	Horizon string
 
+	// Base URLs of other horizon servers for the same network.  After
+	// ProbeHorizons, reads go to the fastest healthy server among
+	// Horizon and HorizonMirrors.
+	HorizonMirrors []string
+
+	// URL of a Soroban RPC server (JSON-RPC endpoint), if any.
+	SorobanRPC string
+
+	// URL of friendbot for funding new accounts on test networks,
+	// if any.
+	Friendbot string
+
 	// Set of signers to recognize when checking signatures on
 	// transactions and annotations to show when printing signers.
+	// StellarNet's methods lock Signers, Accounts, and Edits (each
+	// with its own lock), so goroutines may share a StellarNet
+	// provided they change these only through methods such as
+	// AddSigner and AddHint.
 	Signers SignerCache
 
 	// Annotations to show on particular accounts when rendering them
 	// in human-readable txrep format.
 	Accounts AccountHints
 
+	// Number of distinct keys in Approvers that must sign a
+	// transaction before it may be posted (0 to disable the check).
+	// Configuration files may not set it to 1, which would let any
+	// single approver post alone.  See CheckApprovals.
+	Approvals int
+
+	// Public keys (in strkey format) of approvers, mapped to
+	// comments.
+	Approvers map[string]string
+
+	// Criteria for hiding dust payments and spam when listing an
+	// account's transactions.
+	Filter TxFilter
+
+	// Defaults for transactions built for this network.
+	TxDefaults TxDefaults
+
+	// If both are set, explanations show the approximate value of
+	// amounts in PriceCurrency (e.g., "USD") according to Prices.
+	Prices        PriceSource
+	PriceCurrency string
+
+	// HTTP client for requests to horizon and other servers of this
+	// network; nil means DefaultHTTPClient.
+	HTTPClient *http.Client
+
+	// Name (and optionally version) of the application using stc,
+	// such as "myapp/1.2".  It is sent in the User-Agent header of
+	// requests, ahead of stc's own version, so that server operators
+	// can identify the application's traffic.
+	AppName string
+
+	// How to retry failed horizon requests; nil means
+	// DefaultRetryPolicy.
+	Retry *RetryPolicy
+
+	// If non-nil, callbacks invoked on requests made for this network.
+	Hooks *HTTPHooks
+
+	// If non-nil, limits the rate of requests made for this network.
+	RateLimit *RateLimiter
+
+	// If non-nil, supplies sequence numbers for new transactions
+	// instead of horizon (see SequenceProvider).
+	Sequences SequenceProvider
+
+	// If non-nil, transactions submitted with Post and PostAsync are
+	// recorded here, so that a transaction already included in a
+	// ledger is never submitted twice.
+	Submissions *SubmissionLog
+
+	// If non-nil, called to report non-fatal problems, such as
+	// falling back to a simpler strategy because horizon lacks an
+	// optional endpoint.
+	Logf func(format string, args ...interface{})
+
 	// Changes will be saved to this file.
 	SavePath string
 
@@ -39,16 +120,86 @@ type StellarNet struct {
 	// Cache of fee stats
 	FeeCache *FeeStats
 	FeeCacheTime time.Time
+
+	// Horizon requests in progress, for coalescing duplicates.
+	inflight singleflight.Group
+
+	// Which horizon server to read from.
+	ranking horizonRanking
+
+	// Each cache has its own lock, so that, e.g., rendering account
+	// annotations does not wait on signers being learned.  Lookups, by
+	// far the most common use, only take the locks for reading.  When
+	// editsLock is taken with one of the others, it is taken last.
+	signersLock  sync.RWMutex // Signers and deletedSigners
+	accountsLock sync.RWMutex // Accounts
+	editsLock    sync.Mutex   // Edits
+
+	// Signers deleted in the configuration files, which binary signer
+	// caches must not bring back (see LoadSignersBinary).
+	deletedSigners map[string]bool
+}
+
+// Records an edit to be applied by Save.
+func (net *StellarNet) edit(sec, key, val string) {
+	net.editsLock.Lock()
+	defer net.editsLock.Unlock()
+	net.Edits.Set(sec, key, val)
 }
 
 func (net *StellarNet) AddHint(acct string, hint string) {
+	net.accountsLock.Lock()
+	defer net.accountsLock.Unlock()
+	if net.Accounts == nil {
+		net.Accounts = make(AccountHints)
+	}
 	net.Accounts[acct] = hint
-	net.Edits.Set("accounts", acct, hint)
+	net.edit("accounts", acct, hint)
 }
 
 func (net *StellarNet) AddSigner(signer, comment string) {
+	net.signersLock.Lock()
+	defer net.signersLock.Unlock()
+	if net.Signers == nil {
+		net.Signers = make(SignerCache)
+	}
 	net.Signers.Add(signer, comment)
-	net.Edits.Set("signers", signer, comment)
+	delete(net.deletedSigners, signer)
+	net.edit("signers", signer, comment)
+}
+
+// Returns a copy of the signers in net.Signers whose hint is hint.
+func (net *StellarNet) signersWithHint(
+	hint stx.SignatureHint) []SignerKeyInfo {
+	net.signersLock.RLock()
+	defer net.signersLock.RUnlock()
+	return append([]SignerKeyInfo(nil), net.Signers.LookupHint(hint)...)
+}
+
+// Returns the annotation on acct in net.Accounts.
+func (net *StellarNet) accountHint(acct string) string {
+	net.accountsLock.RLock()
+	defer net.accountsLock.RUnlock()
+	return net.Accounts[acct]
+}
+
+// Returns the account whose annotation in net.Accounts is exactly
+// alias, so that accounts can be referred to by name.  Returns false
+// if no account or more than one account has that annotation.
+func (net *StellarNet) LookupAccountAlias(alias string) (string, bool) {
+	var ret string
+	n := 0
+	if alias != "" {
+		net.accountsLock.RLock()
+		defer net.accountsLock.RUnlock()
+		for acct, hint := range net.Accounts {
+			if hint == alias {
+				ret = acct
+				n++
+			}
+		}
+	}
+	return ret, n == 1
 }
 
 func (net *StellarNet) GetNativeAsset() string {
@@ -62,18 +213,83 @@ func (net *StellarNet) VerifySig(
 	return stcdetail.VerifyTx(pk, net.GetNetworkId(), tx, sig)
 }
 
+// Options for parallel signature verification (see VerifySigs).
+type VerifyOptions = stcdetail.VerifyOptions
+
+// Check many signatures on the same transaction, hashing the
+// transaction only once and verifying signatures in parallel.  pks[i]
+// is checked against sigs[i], and the result is parallel to pks (with
+// false for keys beyond the end of sigs).  opts may be nil.
+func (net *StellarNet) VerifySigs(pks []SignerKey, tx stx.Signable,
+	sigs []Signature, opts *VerifyOptions) []bool {
+	return stcdetail.VerifyTxParallel(net.GetNetworkId(), tx, pks, sigs, opts)
+}
+
 // Return a transaction hash (which in Stellar is defined as the hash
 // of the constant ENVELOPE_TYPE_TX, the NetworkID, and the marshaled
 // XDR of the Transaction).
 func (net *StellarNet) HashTx(tx stx.Signable) *stx.Hash {
-	return stcdetail.TxPayloadHash(net.GetNetworkId(), tx)
+	return HashTxForNetwork(net.GetNetworkId(), tx)
+}
+
+// Like StellarNet.HashTx, but for the network whose ID (or
+// "passphrase") is passphrase, such as a private network or
+// standalone stellar-core, without needing a StellarNet.
+func HashTxForNetwork(passphrase string, tx stx.Signable) *stx.Hash {
+	return stcdetail.TxPayloadHash(passphrase, tx)
+}
+
+// Error returned by SignTx, SorobanCall, and the Post functions in
+// read-only mode.
+var ErrReadOnly = errors.New("signing and posting disabled in read-only mode")
+
+var readOnly = func() int32 {
+	if v := os.Getenv("STCREADONLY"); v != "" && v != "0" {
+		return 1
+	}
+	return 0
+}()
+
+// Puts the process in read-only mode, in which SignTx, SorobanCall,
+// and the Post functions fail with ErrReadOnly, so that a program
+// (such as one run on a shared analysis machine) cannot sign or
+// submit transactions even if it has access to keys.  There is no way
+// to leave read-only mode.  A process starts in read-only mode if the
+// environment variable STCREADONLY is set to anything other than ""
+// or "0".
+func SetReadOnly() {
+	atomic.StoreInt32(&readOnly, 1)
+}
+
+// Returns true in read-only mode (see SetReadOnly).
+func IsReadOnly() bool {
+	return atomic.LoadInt32(&readOnly) != 0
 }
 
 // Sign a transaction and append the signature to the
-// TransactionEnvelope.
+// TransactionEnvelope.  Reports through net.Logf when the transaction
+// has no maximum time (see HasMaxTime).
 func (net *StellarNet) SignTx(sk stcdetail.PrivateKeyInterface,
 	e *TransactionEnvelope) error {
-	sig, err := sk.Sign(net.HashTx(e)[:])
+	if IsReadOnly() {
+		return ErrReadOnly
+	}
+	if !e.HasMaxTime() {
+		net.logf("signing transaction with no upper time bound, which " +
+			"remains valid indefinitely")
+	}
+	return SignTxForNetwork(net.GetNetworkId(), sk, e)
+}
+
+// Like StellarNet.SignTx, but for the network whose ID (or
+// "passphrase") is passphrase, without needing a StellarNet.  Fails
+// with ErrReadOnly in read-only mode.
+func SignTxForNetwork(passphrase string, sk stcdetail.PrivateKeyInterface,
+	e *TransactionEnvelope) error {
+	if IsReadOnly() {
+		return ErrReadOnly
+	}
+	sig, err := sk.Sign(HashTxForNetwork(passphrase, e)[:])
 	if err != nil {
 		return err
 	}
@@ -85,6 +301,32 @@ func (net *StellarNet) SignTx(sk stcdetail.PrivateKeyInterface,
 	return nil
 }
 
+// Sign payload for an ed25519 signed-payload signer (see
+// SignedPayloadKey) and append the signature to the
+// TransactionEnvelope.  Unlike SignTx, the signature covers payload
+// rather than the transaction, and its hint combines the key's hint
+// with the end of payload, as CAP-40 requires.
+func (net *StellarNet) SignTxPayload(sk stcdetail.PrivateKeyInterface,
+	e *TransactionEnvelope, payload []byte) error {
+	if IsReadOnly() {
+		return ErrReadOnly
+	} else if len(payload) == 0 || len(payload) > 64 {
+		return fmt.Errorf("signed payload must be 1 to 64 bytes, not %d",
+			len(payload))
+	}
+	sig, err := sk.Sign(payload)
+	if err != nil {
+		return err
+	}
+	key := SignedPayloadKey(sk.Public(), payload)
+	sigs := e.Signatures()
+	*sigs = append(*sigs, stx.DecoratedSignature{
+		Hint:      key.Hint(),
+		Signature: sig,
+	})
+	return nil
+}
+
 // An annotated SignerKey that can be used to authenticate
 // transactions.  Prints and Scans as a StrKey-format SignerKey, a
 // space, and then the comment.
@@ -146,6 +388,13 @@ func (c SignerCache) LookupComment(key *stx.SignerKey) string {
 	return ""
 }
 
+// Returns all signers in the cache whose 4-byte SignatureHint matches
+// hint.  More than one key may match, and a match does not mean a
+// signature with that hint was actually produced by the key.
+func (c SignerCache) LookupHint(hint stx.SignatureHint) []SignerKeyInfo {
+	return c[hint]
+}
+
 // Finds the signer in a SignerCache that corresponds to a particular
 // signature on a transaction.
 func (c SignerCache) Lookup(networkID string, e *stx.TransactionEnvelope,
@@ -183,6 +432,17 @@ func (c SignerCache) Add(strkey, comment string) error {
 	return nil
 }
 
+func (c SignerCache) addKey(ski SignerKeyInfo) {
+	hint := ski.Key.Hint()
+	b := stcdetail.XdrToBin(&ski.Key)
+	for i := range c[hint] {
+		if stcdetail.XdrToBin(&c[hint][i].Key) == b {
+			return
+		}
+	}
+	c[hint] = append(c[hint], ski)
+}
+
 // Deletes a signer from the cache.
 func (c SignerCache) Del(strkey string) error {
 	var signer stx.SignerKey
@@ -213,6 +473,96 @@ func (c SignerCache) Del(strkey string) error {
 	return nil
 }
 
+// Magic number at the start of files written by WriteBinary.
+const signerCacheMagic = "STCSIGS1"
+
+// Writes the cache in a compact binary format that can be read back
+// by ReadBinary much faster than parsing the equivalent INI file.
+// This is intended for caches with many thousands of learned
+// signers; small or hand-edited sets of signers are better kept in
+// the [signers] section of an INI file.  The format is the magic
+// string "STCSIGS1" followed by one record per signer, where each
+// record is a 32-bit big-endian length and the binary XDR SignerKey,
+// then a 32-bit length and the comment.
+func (c SignerCache) WriteBinary(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	out.WriteString(signerCacheMagic)
+	var lenbuf [4]byte
+	writeField := func(s string) {
+		binary.BigEndian.PutUint32(lenbuf[:], uint32(len(s)))
+		out.Write(lenbuf[:])
+		out.WriteString(s)
+	}
+	for _, skis := range c {
+		for i := range skis {
+			writeField(stcdetail.XdrToBin(&skis[i].Key))
+			writeField(skis[i].Comment)
+		}
+	}
+	return out.Flush()
+}
+
+var errBadSignerFile = errors.New("invalid binary signer cache file")
+
+// Adds signers from a file written by WriteBinary to the cache.  As
+// with Add, signers already in the cache keep their comments.
+func (c SignerCache) ReadBinary(r io.Reader) error {
+	in := bufio.NewReader(r)
+	magic := make([]byte, len(signerCacheMagic))
+	if _, err := io.ReadFull(in, magic); err != nil {
+		return err
+	} else if string(magic) != signerCacheMagic {
+		return errBadSignerFile
+	}
+	var buf []byte
+	readField := func() (string, error) {
+		var lenbuf [4]byte
+		if _, err := io.ReadFull(in, lenbuf[:]); err != nil {
+			return "", err
+		}
+		n := binary.BigEndian.Uint32(lenbuf[:])
+		if n > 0x10000 {
+			return "", errBadSignerFile
+		} else if uint32(cap(buf)) < n {
+			buf = make([]byte, n)
+		}
+		buf = buf[:n]
+		if _, err := io.ReadFull(in, buf); err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	}
+	for {
+		var ski SignerKeyInfo
+		if bin, err := readField(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		} else if err = stcdetail.XdrFromBin(&ski.Key, bin); err != nil {
+			return err
+		} else if ski.Comment, err = readField(); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		c.addKey(ski)
+	}
+}
+
+// Returns a short form of a signer key, consisting of the first and
+// last four characters of its strkey representation, followed by the
+// hex SignatureHint in brackets.  E.g., "GDFR...CS2G [e1374741]".
+// Handy for recognizing keys at a glance when debugging multisig
+// setups, but obviously not unique.
+func KeyFingerprint(key *SignerKey) string {
+	s := key.String()
+	if len(s) > 8 {
+		s = s[:4] + "..." + s[len(s)-4:]
+	}
+	return fmt.Sprintf("%s [%x]", s, key.Hint())
+}
+
 // Set of annotations to show as comments when showing Stellar
 // AccountID values.
 type AccountHints map[string]string
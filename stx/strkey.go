@@ -9,6 +9,7 @@ package stx
 import (
 	"bytes"
 	"encoding/base32"
+	"encoding/binary"
 	"fmt"
 	"github.com/xdrpp/goxdr/xdr"
 	"io"
@@ -32,6 +33,7 @@ const (
 	STRKEY_PRIVKEY        StrKeyVersionByte = 18<<3 // 'S'
 	STRKEY_PRE_AUTH_TX    StrKeyVersionByte = 19<<3 // 'T',
 	STRKEY_HASH_X         StrKeyVersionByte = 23<<3 // 'X'
+	STRKEY_SIGNED_PAYLOAD StrKeyVersionByte = 15<<3 // 'P'
 	STRKEY_ERROR          StrKeyVersionByte = 255
 )
 
@@ -91,7 +93,13 @@ func FromStrKey(in []byte) ([]byte, StrKeyVersionByte) {
 	if err != nil || n != len(bin) || n < 3 {
 		return nil, STRKEY_ERROR
 	}
-	if targetlen, ok := payloadLen[StrKeyVersionByte(bin[0])]; !ok ||
+	if StrKeyVersionByte(bin[0]) == STRKEY_SIGNED_PAYLOAD {
+		// ed25519 key, length, and 1-64 bytes of payload padded to a
+		// multiple of 4 bytes
+		if n - 3 < 40 || n - 3 > 100 || (n - 3) % 4 != 0 {
+			return nil, STRKEY_ERROR
+		}
+	} else if targetlen, ok := payloadLen[StrKeyVersionByte(bin[0])]; !ok ||
 		targetlen != n - 3 {
 		return nil, STRKEY_ERROR
 	}
@@ -141,14 +149,19 @@ func (pk PublicKey) String() string {
 	}
 }
 
-// Renders a MuxedAccount in strkey format.
+// Renders a MuxedAccount in strkey format.  As specified by SEP-23,
+// the payload of a muxed ("M...") account is the ed25519 key followed
+// by the 64-bit ID in big-endian order, which is not the order of the
+// fields in the XDR.
 func (pk MuxedAccount) String() string {
 	switch pk.Type {
 	case KEY_TYPE_ED25519:
 		return ToStrKey(STRKEY_PUBKEY|STRKEY_ALG_ED25519, pk.Ed25519()[:])
 	case KEY_TYPE_MUXED_ED25519:
-		return ToStrKey(STRKEY_MUXED|STRKEY_ALG_ED25519,
-			XdrToBytes(pk.Med25519()))
+		var payload [40]byte
+		copy(payload[:32], pk.Med25519().Ed25519[:])
+		binary.BigEndian.PutUint64(payload[32:], pk.Med25519().Id)
+		return ToStrKey(STRKEY_MUXED|STRKEY_ALG_ED25519, payload[:])
 	default:
 		return fmt.Sprintf("MuxedAccount.Type#%d", int32(pk.Type))
 	}
@@ -163,6 +176,10 @@ func (pk SignerKey) String() string {
 		return ToStrKey(STRKEY_PRE_AUTH_TX, pk.PreAuthTx()[:])
 	case SIGNER_KEY_TYPE_HASH_X:
 		return ToStrKey(STRKEY_HASH_X, pk.HashX()[:])
+	case SIGNER_KEY_TYPE_ED25519_SIGNED_PAYLOAD:
+		// The strkey payload is the XDR of the signed payload
+		return ToStrKey(STRKEY_SIGNED_PAYLOAD,
+			XdrToBytes(pk.Ed25519SignedPayload()))
 	default:
 		return fmt.Sprintf("SignerKey.Type#%d", int32(pk.Type))
 	}
@@ -361,9 +378,8 @@ func (pk *MuxedAccount) UnmarshalText(bs []byte) error {
 		return nil
 	case STRKEY_MUXED|STRKEY_ALG_ED25519:
 		pk.Type = KEY_TYPE_MUXED_ED25519
-		if err := XdrFromBytes(pk.Med25519(), key); err != nil {
-			return err
-		}
+		copy(pk.Med25519().Ed25519[:], key[:32])
+		pk.Med25519().Id = binary.BigEndian.Uint64(key[32:])
 		return nil
 	default:
 		return StrKeyError("Invalid public key type")
@@ -383,6 +399,21 @@ func (pk *SignerKey) UnmarshalText(bs []byte) error {
 	case STRKEY_HASH_X:
 		pk.Type = SIGNER_KEY_TYPE_HASH_X
 		copy(pk.HashX()[:], key)
+	case STRKEY_SIGNED_PAYLOAD:
+		// Reject lengths and padding that would not round-trip
+		n := binary.BigEndian.Uint32(key[32:36])
+		if n == 0 || n > 64 || (n+3)&^3 != uint32(len(key)-36) {
+			return StrKeyError("Invalid signed payload length")
+		}
+		for _, b := range key[36+n:] {
+			if b != 0 {
+				return StrKeyError("Invalid signed payload padding")
+			}
+		}
+		pk.Type = SIGNER_KEY_TYPE_ED25519_SIGNED_PAYLOAD
+		sp := pk.Ed25519SignedPayload()
+		copy(sp.Ed25519[:], key[:32])
+		sp.Payload = append([]byte(nil), key[36:36+n]...)
 	default:
 		return StrKeyError("Invalid signer key string")
 	}
@@ -418,6 +449,21 @@ func (pk SignerKey) Hint() SignatureHint {
 		return signerHint(pk.PreAuthTx()[:])
 	case SIGNER_KEY_TYPE_HASH_X:
 		return signerHint(pk.HashX()[:])
+	case SIGNER_KEY_TYPE_ED25519_SIGNED_PAYLOAD:
+		// CAP-40:  the key's hint XORed with the last 4 bytes of the
+		// payload, which is padded with zeros if shorter
+		sp := pk.Ed25519SignedPayload()
+		ret := signerHint(sp.Ed25519[:])
+		var tail [4]byte
+		if len(sp.Payload) >= 4 {
+			copy(tail[:], sp.Payload[len(sp.Payload)-4:])
+		} else {
+			copy(tail[:], sp.Payload)
+		}
+		for i := range ret {
+			ret[i] ^= tail[i]
+		}
+		return ret
 	default:
 		panic(StrKeyError("Invalid signer key type"))
 	}
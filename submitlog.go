@@ -0,0 +1,147 @@
+package stc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// A record of the transactions a program has submitted, which Post
+// and PostAsync consult (when StellarNet.Submissions is set) so that
+// re-running a program, or resubmitting after a lost response, does
+// not submit a transaction that has already been included in a
+// ledger.  Safe for concurrent use.
+type SubmissionLog struct {
+	// If not empty, submissions are also appended to this file, one
+	// per line, so that they are remembered across program runs.
+	Path string
+
+	lock   sync.Mutex
+	loaded bool
+	subs   map[string]uint32
+}
+
+// The error returned by Post and PostAsync when a transaction in the
+// StellarNet's SubmissionLog has already been included in a ledger.
+type ErrAlreadySubmitted struct {
+	// The transaction's hash in hex.
+	Txid string
+
+	// The ledger that included the transaction.
+	Ledger uint32
+}
+
+func (e ErrAlreadySubmitted) Error() string {
+	return fmt.Sprintf("transaction %s already submitted in ledger %d",
+		e.Txid, e.Ledger)
+}
+
+// Must be called with sl.lock held.
+func (sl *SubmissionLog) load() error {
+	if sl.loaded {
+		return nil
+	}
+	sl.subs = make(map[string]uint32)
+	if sl.Path != "" {
+		f, err := os.Open(sl.Path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		} else if err == nil {
+			defer f.Close()
+			s := bufio.NewScanner(f)
+			for s.Scan() {
+				var txid string
+				var ledger uint32
+				_, err := fmt.Sscan(s.Text(), &txid, &ledger)
+				if err == nil {
+					sl.subs[txid] = ledger
+				}
+			}
+			if err = s.Err(); err != nil {
+				return err
+			}
+		}
+	}
+	sl.loaded = true
+	return nil
+}
+
+// Returns the ledger that included the transaction with hex hash
+// txid, or 0 if the transaction was submitted but is not known to
+// have been included.  Returns false if txid was never submitted.
+func (sl *SubmissionLog) Lookup(txid string) (uint32, bool) {
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+	if sl.load() != nil {
+		return 0, false
+	}
+	ledger, ok := sl.subs[txid]
+	return ledger, ok
+}
+
+// Record that the transaction with hex hash txid was submitted and,
+// if ledger is not 0, included in ledger.  Recording a ledger of 0
+// does not forget a ledger recorded earlier.
+func (sl *SubmissionLog) Record(txid string, ledger uint32) error {
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+	if err := sl.load(); err != nil {
+		return err
+	}
+	if old, ok := sl.subs[txid]; ok && (old == ledger || ledger == 0) {
+		return nil
+	}
+	sl.subs[txid] = ledger
+	if sl.Path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(sl.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE,
+		0600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "%s %d\n", txid, ledger)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// Records a submission in net.Submissions, if set, reporting (but
+// otherwise ignoring) failures to write the log.
+func (net *StellarNet) recordSubmission(txid string, ledger uint32) {
+	if net.Submissions != nil && txid != "" {
+		if err := net.Submissions.Record(txid, ledger); err != nil {
+			net.logf("cannot record submission of %s: %s", txid, err)
+		}
+	}
+}
+
+// If net.Submissions shows that transaction txid was already
+// submitted, checks whether it has been included in a ledger, and if
+// so returns ErrAlreadySubmitted.  A transaction that was submitted
+// but never included (e.g., because it was dropped) may be submitted
+// again, which is safe because the network executes a transaction at
+// most once.
+func (net *StellarNet) checkSubmitted(ctx context.Context,
+	txid string) error {
+	if net.Submissions == nil {
+		return nil
+	}
+	ledger, ok := net.Submissions.Lookup(txid)
+	if !ok {
+		return nil
+	} else if ledger == 0 {
+		txr, err := net.GetTxResultCtx(ctx, txid)
+		if notFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		ledger = txr.Ledger
+		net.recordSubmission(txid, ledger)
+	}
+	return ErrAlreadySubmitted{Txid: txid, Ledger: ledger}
+}
@@ -0,0 +1,159 @@
+package stc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/xdrpp/stc/stcdetail"
+	"github.com/xdrpp/stc/stx"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// An account entry together with information about the account that
+// horizon serves from other endpoints, as returned by
+// GetAccountSummary.
+type AccountSummary struct {
+	HorizonAccountEntry
+
+	// Number of outstanding claimable balances the account can claim.
+	Claimable_balances int
+
+	// Number of trustlines to assets (not counting liquidity pool
+	// shares) and to liquidity pools.
+	Trustlines      int
+	Pool_trustlines int
+
+	// Numbers of accounts, claimable balances, and offers whose
+	// reserves the account pays for.  Num_sponsoring, in the account
+	// entry, counts reserves rather than entries, and also includes
+	// trustlines, signers, and data entries sponsored by the account.
+	Sponsoring_accounts           int
+	Sponsoring_claimable_balances int
+	Sponsoring_offers             int
+
+	// Numbers of the account's trustlines and signers whose reserves
+	// another account pays for (also included in Num_sponsored).
+	Sponsored_trustlines int
+	Sponsored_signers    int
+
+	// Liquidity pools in which the account holds shares.
+	Pools []HorizonLiquidityPool
+}
+
+func (as *AccountSummary) String() string {
+	out := &strings.Builder{}
+	out.WriteString(as.HorizonAccountEntry.String())
+	fmt.Fprintf(out, "Trustlines: %d\n", as.Trustlines)
+	fmt.Fprintf(out, "Pool_trustlines: %d\n", as.Pool_trustlines)
+	fmt.Fprintf(out, "Claimable_balances: %d\n", as.Claimable_balances)
+	fmt.Fprintf(out, "Sponsoring_accounts: %d\n", as.Sponsoring_accounts)
+	fmt.Fprintf(out, "Sponsoring_claimable_balances: %d\n",
+		as.Sponsoring_claimable_balances)
+	fmt.Fprintf(out, "Sponsoring_offers: %d\n", as.Sponsoring_offers)
+	fmt.Fprintf(out, "Sponsored_trustlines: %d\n", as.Sponsored_trustlines)
+	fmt.Fprintf(out, "Sponsored_signers: %d\n", as.Sponsored_signers)
+	for i := range as.Pools {
+		p := &as.Pools[i]
+		var shares stcdetail.JsonInt64e7
+		for j := range as.Balances {
+			if as.Balances[j].Liquidity_pool_id == fmt.Sprintf("%x", p.Id) {
+				shares = as.Balances[j].Balance
+			}
+		}
+		fmt.Fprintf(out, "Pools[%d]: %x (%s of %s shares", i, p.Id,
+			shares, p.Total_shares)
+		for _, r := range p.Reserves {
+			fmt.Fprintf(out, "; %s %s", r.Amount, r.Asset.String())
+		}
+		fmt.Fprintf(out, ")\n")
+	}
+	return out.String()
+}
+
+// Returns the number of records in a horizon collection.
+func (net *StellarNet) countRecords(ctx context.Context, query string) (
+	int, error) {
+	var page []json.RawMessage
+	n := 0
+	pager := net.NewPager(ctx, query, PageParams{Limit: 200})
+	for pager.Next(&page) {
+		n += len(page)
+	}
+	return n, pager.Err()
+}
+
+// Fetches an account entry along with the number of claimable
+// balances the account can claim, the entries it sponsors, and the
+// liquidity pools in which it holds shares.  The requests are sent
+// to horizon concurrently.  ctx may be nil.
+func (net *StellarNet) GetAccountSummary(ctx context.Context, acct string) (
+	*AccountSummary, error) {
+	ret := &AccountSummary{}
+	var wg sync.WaitGroup
+	var errlock sync.Mutex
+	var firstErr error
+	fetch := func(fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(); err != nil {
+				errlock.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errlock.Unlock()
+			}
+		}()
+	}
+	count := func(out *int, collection, param string) {
+		fetch(func() (err error) {
+			*out, err = net.countRecords(ctx, collection+"?"+
+				url.Values{param: {acct}}.Encode())
+			return
+		})
+	}
+
+	fetch(func() error {
+		ae, err := net.GetAccountEntryCtx(ctx, acct)
+		if err == nil {
+			ret.HorizonAccountEntry = *ae
+		}
+		return err
+	})
+	count(&ret.Claimable_balances, "claimable_balances", "claimant")
+	count(&ret.Sponsoring_accounts, "accounts", "sponsor")
+	count(&ret.Sponsoring_claimable_balances, "claimable_balances", "sponsor")
+	count(&ret.Sponsoring_offers, "offers", "sponsor")
+	fetch(func() error {
+		var page []HorizonLiquidityPool
+		pager := net.NewPager(ctx, "liquidity_pools?"+
+			url.Values{"account": {acct}}.Encode(), PageParams{Limit: 200})
+		for pager.Next(&page) {
+			ret.Pools = append(ret.Pools, page...)
+		}
+		return pager.Err()
+	})
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	for i := range ret.Balances {
+		if ret.Balances[i].Asset.Type == stx.ASSET_TYPE_POOL_SHARE {
+			ret.Pool_trustlines++
+		} else {
+			ret.Trustlines++
+		}
+		if ret.Balances[i].Sponsor != nil {
+			ret.Sponsored_trustlines++
+		}
+	}
+	for i := range ret.Signers {
+		if ret.Signers[i].Sponsor != nil {
+			ret.Sponsored_signers++
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,123 @@
+package stc
+
+import (
+	"fmt"
+)
+
+// The plan for a transaction built by SweepTx.
+type Sweep struct {
+	// True if the transaction merges the source account into the
+	// destination, false if it makes a payment.
+	Merge bool
+
+	// The number of stroops of the native asset the destination
+	// will receive (for a merge, the source's balance minus the fee).
+	Amount int64
+
+	// Reasons the source account cannot be merged (e.g., it has
+	// trustlines or data entries), which must be removed before
+	// a merge is possible.  Empty if Merge is true.
+	Blockers []string
+}
+
+// Number of base reserves an account needs, given its entry.
+func minBalanceReserves(ae *HorizonAccountEntry) int64 {
+	return 2 + int64(ae.Subentry_count) + int64(ae.Num_sponsoring) -
+		int64(ae.Num_sponsored)
+}
+
+// Returns the reasons account ae cannot be merged.
+func (net *StellarNet) mergeBlockers(acct string,
+	ae *HorizonAccountEntry) []string {
+	var ret []string
+	subentries := int64(ae.Subentry_count)
+	for i := range ae.Balances {
+		b := &ae.Balances[i]
+		ret = append(ret, fmt.Sprintf("trustline for %s (balance %s)",
+			net.DescribeAsset(b.Asset), b.Balance))
+		subentries--
+	}
+	for name := range ae.Data {
+		ret = append(ret, fmt.Sprintf("data entry %q", name))
+		subentries--
+	}
+	for i := range ae.Signers {
+		if s := &ae.Signers[i]; s.Key.String() != acct {
+			ret = append(ret, fmt.Sprintf("signer %s", s.Key.String()))
+			subentries--
+		}
+	}
+	if subentries > 0 {
+		ret = append(ret, fmt.Sprintf("%d offers or other subentries",
+			subentries))
+	}
+	if ae.Num_sponsoring > 0 {
+		ret = append(ret, fmt.Sprintf("sponsoring %d reserves",
+			ae.Num_sponsoring))
+	}
+	return ret
+}
+
+// Builds a transaction that moves as much of the native asset as
+// possible from account from to account to (both strkeys), using the
+// current sequence number, fee statistics, and base reserve, as
+// adjusted by net.TxDefaults.  If from
+// has no subentries (trustlines, offers, data entries, or extra
+// signers) and is not sponsoring any reserves, the transaction merges
+// from into to.  Otherwise, it pays to the source's balance minus the
+// fee, its selling liabilities, and its minimum balance, and the
+// returned Sweep lists what prevents a merge.  The transaction is not
+// signed.
+func (net *StellarNet) SweepTx(from, to string) (
+	*TransactionEnvelope, *Sweep, error) {
+	var src, dest AccountID
+	if _, err := fmt.Sscan(from, &src); err != nil {
+		return nil, nil, err
+	} else if _, err = fmt.Sscan(to, &dest); err != nil {
+		return nil, nil, err
+	}
+	ae, err := net.GetAccountEntry(from)
+	if err != nil {
+		return nil, nil, fmt.Errorf("account %s: %w", from, err)
+	} else if _, err = net.GetAccountEntry(to); err != nil {
+		return nil, nil, fmt.Errorf("destination %s: %w", to, err)
+	}
+	lh, err := net.GetLedgerHeader()
+	if err != nil {
+		return nil, nil, err
+	}
+	fee := uint32(lh.BaseFee)
+	if fs, err := net.GetFeeCache(); err == nil {
+		fee = uint32(net.DefaultFee(fs))
+	}
+	reserve := int64(lh.BaseReserve)
+	if net.TxDefaults.BaseReserve > 0 {
+		reserve = net.TxDefaults.BaseReserve
+	}
+
+	e := NewTransactionEnvelope()
+	e.SetSourceAccount(src)
+	e.V1().Tx.SeqNum = ae.NextSeq()
+	ret := &Sweep{Blockers: net.mergeBlockers(from, ae)}
+	if len(ret.Blockers) == 0 {
+		ret.Merge = true
+		ret.Amount = int64(ae.Balance) - int64(fee)
+		e.Append(nil, AccountMerge(*dest.ToMuxedAccount()))
+	} else {
+		ret.Amount = int64(ae.Balance) - int64(fee) -
+			int64(ae.Selling_liabilities) -
+			minBalanceReserves(ae)*reserve
+		if ret.Amount <= 0 {
+			return nil, ret, fmt.Errorf("account %s has nothing to sweep",
+				from)
+		}
+		e.Append(nil, Payment{
+			Destination: *dest.ToMuxedAccount(),
+			Asset:       NativeAsset(),
+			Amount:      ret.Amount,
+		})
+	}
+	e.SetFee(fee)
+	net.ApplyTimeout(e)
+	return e, ret, nil
+}
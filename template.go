@@ -0,0 +1,85 @@
+package stc
+
+import (
+	"fmt"
+	"github.com/xdrpp/stc/stx"
+	"reflect"
+	"text/template"
+	"time"
+)
+
+// Converts any integer type (including named types such as
+// stx.Int64 and stcdetail.JsonInt64e7) to int64.
+func templateInt(v interface{}) (int64, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		return int64(rv.Uint()), nil
+	}
+	return 0, fmt.Errorf("%T is not an integer", v)
+}
+
+// Returns functions for use in text/template templates applied to
+// transactions and horizon results, such as those of stc's -template
+// option:
+//
+//	strkey V       V in strkey format (or whatever V's String method
+//	               returns)
+//	note ACCT      the comment on ACCT in net.Accounts, if any
+//	account ACCT   ACCT followed by its comment in parentheses, if any
+//	amount N       fixed-point amount N (e.g., 15000000) in decimal
+//	               (1.5)
+//	asset A        description of asset A (e.g., "USD issued by G...")
+//	time T         Unix time T (or a time.Time) in RFC 3339 format, UTC
+//	txhash E       hash of transaction envelope E on net, in hex
+//	explain E      plain-English description of E (see ExplainTx)
+func (net *StellarNet) TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"strkey": func(v interface{}) string {
+			return fmt.Sprint(v)
+		},
+		"note": func(v interface{}) string {
+			return net.AccountIDNote(fmt.Sprint(v))
+		},
+		"account": func(v interface{}) string {
+			return net.DescribeAccount(stringer(fmt.Sprint(v)))
+		},
+		"amount": func(v interface{}) (string, error) {
+			n, err := templateInt(v)
+			if err != nil {
+				return "", err
+			}
+			return FormatAmount(n), nil
+		},
+		"asset": func(a stx.Asset) string {
+			return net.DescribeAsset(a)
+		},
+		"time": func(v interface{}) (string, error) {
+			if t, ok := v.(time.Time); ok {
+				return t.UTC().Format(time.RFC3339), nil
+			}
+			n, err := templateInt(v)
+			if err != nil {
+				return "", err
+			}
+			return time.Unix(n, 0).UTC().Format(time.RFC3339), nil
+		},
+		"txhash": func(e *TransactionEnvelope) string {
+			return fmt.Sprintf("%x", *net.HashTx(e))
+		},
+		"explain": func(e *TransactionEnvelope) string {
+			return net.ExplainTx(e)
+		},
+	}
+}
+
+// A string with a String method.
+type stringer string
+
+func (s stringer) String() string {
+	return string(s)
+}
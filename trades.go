@@ -0,0 +1,175 @@
+package stc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/xdrpp/stc/stcdetail"
+	"github.com/xdrpp/stc/stx"
+	"math/big"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// A price as horizon represents it in JSON: the rational number N/D.
+// Unlike stx.Price, N and D may exceed 32 bits (e.g., for trades
+// against liquidity pools).  Horizon renders N and D as JSON strings
+// in some versions and as numbers in others; both are accepted.
+type HorizonPrice struct {
+	N int64
+	D int64
+}
+
+func (p *HorizonPrice) UnmarshalJSON(data []byte) error {
+	var jp struct {
+		N json.Number
+		D json.Number
+	}
+	if err := json.Unmarshal(data, &jp); err != nil {
+		return err
+	}
+	var err error
+	if p.N, err = jp.N.Int64(); err == nil {
+		p.D, err = jp.D.Int64()
+	}
+	return err
+}
+
+// Returns the price as an exact rational number, or nil if D is 0.
+func (p HorizonPrice) Rat() *big.Rat {
+	if p.D == 0 {
+		return nil
+	}
+	return big.NewRat(p.N, p.D)
+}
+
+// Returns the price as a floating-point number.
+func (p HorizonPrice) Float64() float64 {
+	return float64(p.N) / float64(p.D)
+}
+
+func (p HorizonPrice) String() string {
+	return fmt.Sprintf("%d/%d", p.N, p.D)
+}
+
+// A trade as returned by horizon's trades endpoints.  The base side
+// of the trade gave Base_amount of Base in exchange for Counter_amount
+// of Counter; Base_is_seller says whether the base side's offer was
+// the one already on the books.  Price is the price of Base in units
+// of Counter.  For trades against a liquidity pool, the pool's side
+// has a Liquidity_pool_id instead of an account and offer ID.
+type HorizonTrade struct {
+	Net                       *StellarNet `json:"-"`
+	Id                        string
+	Paging_token              string
+	Ledger_close_time         time.Time
+	Trade_type                string
+	Liquidity_pool_fee_bp     uint32
+	Base_offer_id             stcdetail.JsonInt64
+	Base_account              AccountID
+	Base_liquidity_pool_id    string
+	Base_amount               stcdetail.JsonInt64e7
+	Base                      stx.Asset `json:"-"`
+	Counter_offer_id          stcdetail.JsonInt64
+	Counter_account           AccountID
+	Counter_liquidity_pool_id string
+	Counter_amount            stcdetail.JsonInt64e7
+	Counter                   stx.Asset `json:"-"`
+	Base_is_seller            bool
+	Price                     HorizonPrice
+}
+
+func (t *HorizonTrade) UnmarshalJSON(data []byte) error {
+	type jt HorizonTrade
+	if err := json.Unmarshal(data, (*jt)(t)); err != nil {
+		return err
+	}
+	return unmarshalAssets(data, map[string]*stx.Asset{
+		"base_":    &t.Base,
+		"counter_": &t.Counter,
+	})
+}
+
+func (t *HorizonTrade) String() string {
+	return stcdetail.PrettyPrintAux(t.Net.prettyPrintAux, t)
+}
+
+// Selects the trades returned by GetTrades.  At most one of Account,
+// Offer_id, and Liquidity_pool_id may be set.  Base and Counter must
+// be set together, and restrict results to trades between the two
+// assets (in which case Price is always the price of Base in units of
+// Counter).  The zero value selects all trades.
+type TradeFilter struct {
+	Account           string
+	Offer_id          int64
+	Liquidity_pool_id string
+	Base              *stx.Asset
+	Counter           *stx.Asset
+
+	// "orderbook" or "liquidity_pool" to select only trades of that
+	// type; "" for both.
+	Trade_type string
+}
+
+// Sets the horizon query parameters for asset a with prefix (e.g.,
+// "base_asset_type").
+func setAssetParams(v url.Values, prefix string, a *stx.Asset) {
+	var code []byte
+	var issuer *AccountID
+	switch a.Type {
+	case stx.ASSET_TYPE_NATIVE:
+		v.Set(prefix+"asset_type", "native")
+		return
+	case stx.ASSET_TYPE_CREDIT_ALPHANUM4:
+		v.Set(prefix+"asset_type", "credit_alphanum4")
+		code, issuer = a.AlphaNum4().AssetCode[:], &a.AlphaNum4().Issuer
+	case stx.ASSET_TYPE_CREDIT_ALPHANUM12:
+		v.Set(prefix+"asset_type", "credit_alphanum12")
+		code, issuer = a.AlphaNum12().AssetCode[:], &a.AlphaNum12().Issuer
+	}
+	v.Set(prefix+"asset_code", string(bytes.TrimRight(code, "\x00")))
+	v.Set(prefix+"asset_issuer", issuer.String())
+}
+
+// Fetches a page of the trades selected by f.  The first element of
+// opts, if any, selects the page; use NewPager with the same query to
+// walk through more trades.  For example, to compute recent prices of
+// XLM in some asset usd:
+//
+//	xlm := NativeAsset()
+//	trades, err := net.GetTrades(TradeFilter{Base: &xlm, Counter: &usd},
+//		PageParams{Desc: true})
+func (net *StellarNet) GetTrades(f TradeFilter, opts ...PageParams) (
+	[]HorizonTrade, error) {
+	query := "trades"
+	v := url.Values{}
+	switch {
+	case f.Account != "":
+		query = "accounts/" + f.Account + "/trades"
+	case f.Offer_id != 0:
+		query = "offers/" + strconv.FormatInt(f.Offer_id, 10) + "/trades"
+	case f.Liquidity_pool_id != "":
+		query = "liquidity_pools/" + f.Liquidity_pool_id + "/trades"
+	}
+	if (f.Base == nil) != (f.Counter == nil) {
+		return nil, fmt.Errorf("TradeFilter must set both Base and Counter")
+	} else if f.Base != nil {
+		setAssetParams(v, "base_", f.Base)
+		setAssetParams(v, "counter_", f.Counter)
+	}
+	if f.Trade_type != "" {
+		v.Set("trade_type", f.Trade_type)
+	}
+	if len(v) > 0 {
+		query += "?" + v.Encode()
+	}
+	var params PageParams
+	if len(opts) > 0 {
+		params = opts[0]
+	}
+	var ret []HorizonTrade
+	pager := net.NewPager(nil, query, params)
+	pager.Next(&ret)
+	return ret, pager.Err()
+}
@@ -0,0 +1,85 @@
+package stc
+
+import (
+	"fmt"
+	"github.com/xdrpp/stc/stx"
+)
+
+// Trustline flags for SET_TRUST_LINE_FLAGS operations.
+const (
+	AuthorizedFlag                      = stx.AUTHORIZED_FLAG
+	AuthorizedToMaintainLiabilitiesFlag = stx.AUTHORIZED_TO_MAINTAIN_LIABILITIES_FLAG
+	TrustLineClawbackEnabledFlag        = stx.TRUSTLINE_CLAWBACK_ENABLED_FLAG
+)
+
+func trustLineFlagBits(flags []stx.TrustLineFlags) (ret uint32) {
+	for _, f := range flags {
+		ret |= uint32(f)
+	}
+	return
+}
+
+// Returns a SET_TRUST_LINE_FLAGS operation by which the issuer of
+// asset sets and clears flags on trustor's trustline.  This replaces
+// the deprecated ALLOW_TRUST operation.  The result should be checked
+// with CheckTrustLineFlags (or the whole transaction with ValidateTx);
+// more convenient are AuthorizeTrustLine,
+// AuthorizeToMaintainLiabilities, DeauthorizeTrustLine, and
+// DisableTrustLineClawback, which always produce valid operations.
+func NewSetTrustLineFlags(trustor AccountID, asset stx.Asset,
+	set, clear []stx.TrustLineFlags) SetTrustLineFlags {
+	return SetTrustLineFlags{
+		Trustor:    trustor,
+		Asset:      asset,
+		SetFlags:   trustLineFlagBits(set),
+		ClearFlags: trustLineFlagBits(clear),
+	}
+}
+
+// Returns a SET_TRUST_LINE_FLAGS operation that fully authorizes
+// trustor to hold asset.
+func AuthorizeTrustLine(trustor AccountID,
+	asset stx.Asset) SetTrustLineFlags {
+	return NewSetTrustLineFlags(trustor, asset,
+		[]stx.TrustLineFlags{AuthorizedFlag},
+		[]stx.TrustLineFlags{AuthorizedToMaintainLiabilitiesFlag})
+}
+
+// Returns a SET_TRUST_LINE_FLAGS operation that authorizes trustor
+// only to maintain its existing offers and other liabilities in asset,
+// not to send, receive, or make new offers.
+func AuthorizeToMaintainLiabilities(trustor AccountID,
+	asset stx.Asset) SetTrustLineFlags {
+	return NewSetTrustLineFlags(trustor, asset,
+		[]stx.TrustLineFlags{AuthorizedToMaintainLiabilitiesFlag},
+		[]stx.TrustLineFlags{AuthorizedFlag})
+}
+
+// Returns a SET_TRUST_LINE_FLAGS operation that revokes trustor's
+// authorization to hold asset, which also cancels its offers.  The
+// issuer must have AuthRevocableFlag set.
+func DeauthorizeTrustLine(trustor AccountID,
+	asset stx.Asset) SetTrustLineFlags {
+	return NewSetTrustLineFlags(trustor, asset, nil,
+		[]stx.TrustLineFlags{AuthorizedFlag,
+			AuthorizedToMaintainLiabilitiesFlag})
+}
+
+// Returns an error if the network will reject op as malformed:  if it
+// sets and clears the same flag, sets TrustLineClawbackEnabledFlag
+// (which can only be cleared), or sets both AuthorizedFlag and
+// AuthorizedToMaintainLiabilitiesFlag, which a trustline cannot have
+// at once.
+func CheckTrustLineFlags(op *stx.SetTrustLineFlagsOp) error {
+	both := uint32(AuthorizedFlag | AuthorizedToMaintainLiabilitiesFlag)
+	switch {
+	case op.SetFlags&op.ClearFlags != 0:
+		return fmt.Errorf("sets and clears the same flags")
+	case op.SetFlags&uint32(TrustLineClawbackEnabledFlag) != 0:
+		return fmt.Errorf("trustline clawback can be cleared but not set")
+	case op.SetFlags&both == both:
+		return fmt.Errorf("sets both %s and %s", AuthorizedFlag,
+			AuthorizedToMaintainLiabilitiesFlag)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package stc
+
+import (
+	"github.com/xdrpp/stc/stx"
+	"time"
+)
+
+// Defaults for transactions built for a network, normally set in the
+// [tx] section of the network's configuration so that everyone in an
+// organization builds transactions the same way.  The zero value
+// leaves every choice to the code building the transaction.
+type TxDefaults struct {
+	// Percentile (1-99) of recently offered fees to bid per
+	// operation, or 0 to use the fee suggested by the current level
+	// of congestion (see DefaultFee).
+	FeePercentile int
+
+	// How long a transaction remains valid after it is built or
+	// updated, or 0 to leave its time bounds alone.
+	Timeout time.Duration
+
+	// If true, TxRisks reports transactions with no memo, as many
+	// exchanges and custodians require one to credit a deposit.
+	RequireMemo bool
+
+	// Base reserve in stroops to assume instead of the value in the
+	// latest ledger header, or 0 to use the ledger header.
+	BaseReserve int64
+}
+
+// Returns the fee per operation to bid according to fs:  the
+// TxDefaults.FeePercentile percentile of recently offered fees if
+// set, and otherwise fs.SuggestedFee().
+func (net *StellarNet) DefaultFee(fs *FeeStats) FeeVal {
+	if p := net.TxDefaults.FeePercentile; p > 0 {
+		return fs.Percentile(p)
+	}
+	return fs.SuggestedFee()
+}
+
+// Returns the base reserve in stroops:  TxDefaults.BaseReserve if
+// set, and otherwise the base reserve of the latest ledger.
+func (net *StellarNet) GetBaseReserve() (int64, error) {
+	if net.TxDefaults.BaseReserve > 0 {
+		return net.TxDefaults.BaseReserve, nil
+	}
+	lh, err := net.GetLedgerHeader()
+	if err != nil {
+		return 0, err
+	}
+	return int64(lh.BaseReserve), nil
+}
+
+// Applies the network's TxDefaults.Timeout to e, setting its maximum
+// time to Timeout from now (and keeping any minimum time).  Does
+// nothing if Timeout is 0 or e is a fee-bump envelope, whose time
+// bounds are those of the inner transaction.
+func (net *StellarNet) ApplyTimeout(e *TransactionEnvelope) {
+	if net.TxDefaults.Timeout <= 0 ||
+		e.Type == stx.ENVELOPE_TYPE_TX_FEE_BUMP {
+		return
+	}
+	e.SetTimeout(net.TxDefaults.Timeout)
+}
+
+// Sets the maximum time of e to d after the close time of the latest
+// ledger, keeping any minimum time.  Unlike SetTimeout, this does not
+// depend on the accuracy of the local clock.
+func (net *StellarNet) SetTimeoutFromLedger(e *TransactionEnvelope,
+	d time.Duration) error {
+	lh, err := net.GetLedgerHeader()
+	if err != nil {
+		return err
+	}
+	e.setMaxTime(time.Unix(int64(lh.ScpValue.CloseTime), 0).Add(d))
+	return nil
+}
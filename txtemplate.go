@@ -0,0 +1,352 @@
+package stc
+
+import (
+	"fmt"
+	"github.com/xdrpp/stc/stx"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Returns a transaction in which funder creates account dest with a
+// starting balance of balance.  If balance is 0, funder instead
+// sponsors dest's reserve, so dest needs no XLM, though the
+// transaction must then also be signed by dest.
+func CreateAccountTx(funder, dest AccountID,
+	balance int64) *TransactionEnvelope {
+	e := NewTransactionEnvelope()
+	e.SetSourceAccount(funder)
+	if balance > 0 {
+		e.Append(nil, CreateAccount{
+			Destination:     dest,
+			StartingBalance: balance,
+		})
+		return e
+	}
+	e.AppendSponsored(nil, dest, func() {
+		e.Append(nil, CreateAccount{Destination: dest})
+	})
+	return e
+}
+
+// Returns a transaction in which funder creates and funds an escrow
+// account, escrow, controlled by signers, each with weight 1, of
+// whom needed must sign any later transaction (such as one releasing
+// the funds).  The escrow account's master key is disabled, so the
+// transaction must be signed by both funder and escrow's master key,
+// which is useless afterwards.
+func EscrowTx(funder, escrow AccountID, balance int64,
+	signers []AccountID, needed uint8) (*TransactionEnvelope, error) {
+	if len(signers) == 0 || len(signers) > 20 {
+		return nil, fmt.Errorf("escrow requires 1 to 20 signers")
+	} else if needed == 0 || int(needed) > len(signers) {
+		return nil, fmt.Errorf("escrow cannot require %d of %d signers",
+			needed, len(signers))
+	} else if balance <= 0 {
+		return nil, fmt.Errorf("escrow balance must be positive")
+	}
+	e := NewTransactionEnvelope()
+	e.SetSourceAccount(funder)
+	e.Append(nil, CreateAccount{
+		Destination:     escrow,
+		StartingBalance: balance,
+	})
+	for _, s := range signers {
+		e.Append(escrow.ToMuxedAccount(),
+			NewSetOptions().AddSigner(s.ToSignerKey(), 1).Op())
+	}
+	e.Append(escrow.ToMuxedAccount(), NewSetOptions().
+		SetMasterWeight(0).SetThresholds(needed, needed, needed).Op())
+	return e, nil
+}
+
+// Returns a transaction in which a pays amountA of assetA to b and b
+// pays amountB of assetB to a.  Since a transaction succeeds or fails
+// as a whole, neither party can receive without paying.  The
+// transaction must be signed by both a and b.
+func SwapTx(a AccountID, assetA stx.Asset, amountA int64, b AccountID,
+	assetB stx.Asset, amountB int64) *TransactionEnvelope {
+	e := NewTransactionEnvelope()
+	e.SetSourceAccount(a)
+	e.Append(nil, Payment{
+		Destination: *b.ToMuxedAccount(),
+		Asset:       assetA,
+		Amount:      amountA,
+	})
+	e.Append(b.ToMuxedAccount(), Payment{
+		Destination: *a.ToMuxedAccount(),
+		Asset:       assetB,
+		Amount:      amountB,
+	})
+	return e
+}
+
+// Returns a transaction that cancels all of acct's open offers (as
+// fetched from horizon) and then merges acct into dest.  The merge
+// still fails if acct has trustlines, data entries, or signers other
+// than its master key (see SweepTx).
+func (net *StellarNet) MergeAccountTx(acct AccountID,
+	dest MuxedAccount) (*TransactionEnvelope, error) {
+	offers, err := net.GetAccountOffers(acct.String())
+	if err != nil {
+		return nil, err
+	} else if len(offers) >= stx.MAX_OPS_PER_TX {
+		return nil, fmt.Errorf("%s has too many offers (%d) to cancel "+
+			"in one transaction", acct, len(offers))
+	}
+	e := NewTransactionEnvelope()
+	e.SetSourceAccount(acct)
+	for i := range offers {
+		e.Append(nil, offers[i].Cancel())
+	}
+	e.Append(nil, AccountMerge(dest))
+	return e, nil
+}
+
+// A parameter of a TxTemplate.
+type TxTemplateParam struct {
+	Name        string
+	Description string
+
+	// Value used when the parameter is omitted, or "" if the
+	// parameter is required
+	Default string
+}
+
+// A named, parameterized transaction, such as stc's -txtemplate
+// option instantiates.  Build receives a value for every parameter in
+// Params.  Accounts in parameters may be given as strkeys or as
+// annotations in net.Accounts, amounts in decimal (e.g., 1.5), and
+// assets as for ParseAsset.
+type TxTemplate struct {
+	Name        string
+	Description string
+	Params      []TxTemplateParam
+	Build       func(net *StellarNet,
+		args map[string]string) (*TransactionEnvelope, error)
+}
+
+// Checks args against t.Params, filling in defaults, and then calls
+// t.Build.  Returns an error if an argument is unknown or a required
+// parameter is missing.
+func (t *TxTemplate) Instantiate(net *StellarNet,
+	args map[string]string) (*TransactionEnvelope, error) {
+	known := make(map[string]bool)
+	full := make(map[string]string)
+	for _, p := range t.Params {
+		known[p.Name] = true
+		if v, ok := args[p.Name]; ok {
+			full[p.Name] = v
+		} else if p.Default != "" {
+			full[p.Name] = p.Default
+		} else {
+			return nil, fmt.Errorf("template %s: missing parameter %s",
+				t.Name, p.Name)
+		}
+	}
+	for name := range args {
+		if !known[name] {
+			return nil, fmt.Errorf("template %s: unknown parameter %s",
+				t.Name, name)
+		}
+	}
+	return t.Build(net, full)
+}
+
+// Returns a description of the template and its parameters.
+func (t *TxTemplate) String() string {
+	out := &strings.Builder{}
+	fmt.Fprintf(out, "%s: %s\n", t.Name, t.Description)
+	for _, p := range t.Params {
+		fmt.Fprintf(out, "    %s  %s", p.Name, p.Description)
+		if p.Default != "" {
+			fmt.Fprintf(out, " (default %s)", p.Default)
+		}
+		fmt.Fprintln(out)
+	}
+	return out.String()
+}
+
+var txTemplates = struct {
+	lock sync.RWMutex
+	m    map[string]*TxTemplate
+}{m: map[string]*TxTemplate{}}
+
+// Register (or replace) a transaction template under t.Name.  Passing
+// a template with a nil Build removes the template.
+func RegisterTxTemplate(t *TxTemplate) {
+	txTemplates.lock.Lock()
+	defer txTemplates.lock.Unlock()
+	if t.Build == nil {
+		delete(txTemplates.m, t.Name)
+	} else {
+		txTemplates.m[t.Name] = t
+	}
+}
+
+// Returns the transaction template registered under name, or nil.
+func LookupTxTemplate(name string) *TxTemplate {
+	txTemplates.lock.RLock()
+	defer txTemplates.lock.RUnlock()
+	return txTemplates.m[name]
+}
+
+// Returns the names of all registered transaction templates, sorted.
+func TxTemplateNames() []string {
+	txTemplates.lock.RLock()
+	defer txTemplates.lock.RUnlock()
+	ret := make([]string, 0, len(txTemplates.m))
+	for name := range txTemplates.m {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Parses template arguments, remembering the first error.
+type templateArgs struct {
+	net  *StellarNet
+	args map[string]string
+	err  error
+}
+
+func (ta *templateArgs) fail(name string, err error) {
+	if ta.err == nil && err != nil {
+		ta.err = fmt.Errorf("%s: %w", name, err)
+	}
+}
+
+// Scans argument name into out, resolving account aliases.
+func (ta *templateArgs) scan(name string, out interface{}) {
+	s := ta.args[name]
+	if acct, ok := ta.net.LookupAccountAlias(s); ok {
+		s = acct
+	}
+	_, err := fmt.Sscan(s, out)
+	ta.fail(name, err)
+}
+
+func (ta *templateArgs) account(name string) (ret AccountID) {
+	ta.scan(name, &ret)
+	return
+}
+
+func (ta *templateArgs) amount(name string) int64 {
+	ret, err := ParseAmount(ta.args[name])
+	if err == nil && ret < 0 {
+		err = fmt.Errorf("amount must not be negative")
+	}
+	ta.fail(name, err)
+	return ret
+}
+
+func (ta *templateArgs) asset(name string) stx.Asset {
+	ret, err := ParseAsset(ta.args[name])
+	ta.fail(name, err)
+	return ret
+}
+
+func init() {
+	for _, t := range []*TxTemplate{
+		{
+			Name:        "create-account",
+			Description: "create and fund a new account (see CreateAccountTx)",
+			Params: []TxTemplateParam{
+				{"funder", "account paying for the new account", ""},
+				{"account", "the new account", ""},
+				{"balance", "starting balance in XLM, or 0 to sponsor " +
+					"the reserve", "1"},
+			},
+			Build: func(net *StellarNet,
+				args map[string]string) (*TransactionEnvelope, error) {
+				ta := &templateArgs{net: net, args: args}
+				funder, dest := ta.account("funder"), ta.account("account")
+				balance := ta.amount("balance")
+				if ta.err != nil {
+					return nil, ta.err
+				}
+				return CreateAccountTx(funder, dest, balance), nil
+			},
+		},
+		{
+			Name:        "escrow",
+			Description: "create an escrow account (see EscrowTx)",
+			Params: []TxTemplateParam{
+				{"funder", "account funding the escrow", ""},
+				{"escrow", "the new escrow account", ""},
+				{"balance", "XLM to place in escrow", ""},
+				{"signers", "comma-separated accounts controlling " +
+					"the escrow", ""},
+				{"needed", "number of signers required to release " +
+					"the escrow", ""},
+			},
+			Build: func(net *StellarNet,
+				args map[string]string) (*TransactionEnvelope, error) {
+				ta := &templateArgs{net: net, args: args}
+				funder, escrow := ta.account("funder"), ta.account("escrow")
+				balance := ta.amount("balance")
+				var signers []AccountID
+				for _, s := range strings.Split(args["signers"], ",") {
+					sub := &templateArgs{net: net, args: map[string]string{
+						"signers": strings.TrimSpace(s),
+					}, err: ta.err}
+					signers = append(signers, sub.account("signers"))
+					ta.err = sub.err
+				}
+				needed, err := strconv.ParseUint(args["needed"], 10, 8)
+				ta.fail("needed", err)
+				if ta.err != nil {
+					return nil, ta.err
+				}
+				return EscrowTx(funder, escrow, balance, signers,
+					uint8(needed))
+			},
+		},
+		{
+			Name:        "swap",
+			Description: "atomically exchange assets between two accounts",
+			Params: []TxTemplateParam{
+				{"a", "first account", ""},
+				{"asset-a", "asset a pays", ""},
+				{"amount-a", "amount a pays", ""},
+				{"b", "second account", ""},
+				{"asset-b", "asset b pays", ""},
+				{"amount-b", "amount b pays", ""},
+			},
+			Build: func(net *StellarNet,
+				args map[string]string) (*TransactionEnvelope, error) {
+				ta := &templateArgs{net: net, args: args}
+				e := SwapTx(ta.account("a"), ta.asset("asset-a"),
+					ta.amount("amount-a"), ta.account("b"),
+					ta.asset("asset-b"), ta.amount("amount-b"))
+				if ta.err != nil {
+					return nil, ta.err
+				}
+				return e, nil
+			},
+		},
+		{
+			Name: "merge",
+			Description: "cancel an account's offers and merge it " +
+				"(see MergeAccountTx)",
+			Params: []TxTemplateParam{
+				{"account", "account to merge", ""},
+				{"dest", "account receiving the balance", ""},
+			},
+			Build: func(net *StellarNet,
+				args map[string]string) (*TransactionEnvelope, error) {
+				ta := &templateArgs{net: net, args: args}
+				acct := ta.account("account")
+				var dest MuxedAccount
+				ta.scan("dest", &dest)
+				if ta.err != nil {
+					return nil, ta.err
+				}
+				return net.MergeAccountTx(acct, dest)
+			},
+		},
+	} {
+		RegisterTxTemplate(t)
+	}
+}
@@ -0,0 +1,290 @@
+package stc
+
+import (
+	"fmt"
+	"github.com/xdrpp/stc/stx"
+)
+
+// Minimum fee per operation, in stroops, that the network accepts.
+// (The actual minimum is the base fee of the latest ledger header,
+// which is never lower.)
+const MinBaseFee = 100
+
+// Maximum number of signatures on a transaction envelope.
+const MaxTxSignatures = 20
+
+// A problem with a transaction found by ValidateTx.
+type TxProblem struct {
+	// Index of the offending operation in the (inner) transaction,
+	// or -1 if the problem is not specific to one operation.
+	Op int
+
+	// SeverityError if the network will reject the transaction,
+	// SeverityWarning if it will probably fail or not do what was
+	// intended.
+	Severity Severity
+
+	// Human-readable description of the problem.
+	Message string
+}
+
+func (p TxProblem) String() string {
+	if p.Op < 0 {
+		return p.Message
+	}
+	return fmt.Sprintf("operation %d: %s", p.Op, p.Message)
+}
+
+// Checks e for mistakes that would make the network reject it, without
+// consulting the network:  no operations, a fee below MinBaseFee per
+// operation, too many signatures, an overlong memo, invalid asset
+// codes, non-positive amounts or prices, missing or overlong fields
+// (such as data entry names and values), and so on.  The result is
+// empty if no problems were found.  Note that a transaction passing
+// ValidateTx may still fail, e.g., for lack of funds or signatures.
+func ValidateTx(e *TransactionEnvelope) []TxProblem {
+	var ret []TxProblem
+	problem := func(op int, sev Severity, format string,
+		args ...interface{}) {
+		ret = append(ret, TxProblem{Op: op, Severity: sev,
+			Message: fmt.Sprintf(format, args...)})
+	}
+	inner := innerTx(e)
+	ops := inner.Operations()
+	if ops == nil {
+		problem(-1, SeverityError, "invalid envelope type %s", e.Type)
+		return ret
+	}
+	if len(*ops) == 0 {
+		problem(-1, SeverityError, "transaction has no operations")
+	} else if len(*ops) > stx.MAX_OPS_PER_TX {
+		problem(-1, SeverityError, "transaction has %d operations, "+
+			"more than the maximum of %d", len(*ops), stx.MAX_OPS_PER_TX)
+	}
+
+	var fee, nops int64
+	var memo *stx.Memo
+	switch e.Type {
+	case stx.ENVELOPE_TYPE_TX_V0:
+		fee, memo = int64(e.V0().Tx.Fee), &e.V0().Tx.Memo
+		nops = int64(len(*ops))
+	case stx.ENVELOPE_TYPE_TX:
+		fee, memo = int64(e.V1().Tx.Fee), &e.V1().Tx.Memo
+		nops = int64(len(*ops))
+	case stx.ENVELOPE_TYPE_TX_FEE_BUMP:
+		// Fee bumps pay for one extra operation
+		fee, memo = e.FeeBump().Tx.Fee, &inner.V1().Tx.Memo
+		nops = int64(len(*ops)) + 1
+		if n := len(inner.V1().Signatures); n > MaxTxSignatures {
+			problem(-1, SeverityError, "inner transaction has %d "+
+				"signatures, more than the maximum of %d", n,
+				MaxTxSignatures)
+		}
+	}
+	if nops > 0 && fee < MinBaseFee*nops {
+		problem(-1, SeverityError, "fee of %d stroops is below the "+
+			"minimum of %d for %d operation(s)", fee, MinBaseFee*nops, nops)
+	}
+	if n := len(*e.Signatures()); n > MaxTxSignatures {
+		problem(-1, SeverityError, "transaction has %d signatures, "+
+			"more than the maximum of %d", n, MaxTxSignatures)
+	}
+	if memo.Type == stx.MEMO_TEXT && len(*memo.Text()) > 28 {
+		problem(-1, SeverityError, "memo text exceeds 28 bytes")
+	}
+
+	for i := range *ops {
+		validateOp(&(*ops)[i].Body, func(sev Severity, format string,
+			args ...interface{}) {
+			problem(i, sev, format, args...)
+		})
+	}
+	return ret
+}
+
+func validateOp(body *stx.XdrAnon_Operation_Body,
+	problem func(Severity, string, ...interface{})) {
+	asset := func(what string, a *stx.Asset) {
+		if err := CheckAsset(*a); err != nil {
+			problem(SeverityError, "%s: %s", what, err)
+		}
+	}
+	positive := func(what string, amount int64) {
+		if amount <= 0 {
+			problem(SeverityError, "%s must be positive", what)
+		}
+	}
+	price := func(p *stx.Price) {
+		if p.N <= 0 || p.D <= 0 {
+			problem(SeverityError, "price %d/%d must be positive", p.N, p.D)
+		}
+	}
+	path := func(p []stx.Asset) {
+		if len(p) > 5 {
+			problem(SeverityError, "path has %d assets, more than the "+
+				"maximum of 5", len(p))
+		}
+		for j := range p {
+			asset("path", &p[j])
+		}
+	}
+	switch body.Type {
+	case stx.CREATE_ACCOUNT:
+		op := body.CreateAccountOp()
+		if op.StartingBalance < 0 {
+			problem(SeverityError, "starting balance is negative")
+		} else if op.StartingBalance == 0 {
+			problem(SeverityWarning, "starting balance is zero, so the "+
+				"account's reserve must be sponsored")
+		}
+	case stx.PAYMENT:
+		op := body.PaymentOp()
+		asset("asset", &op.Asset)
+		positive("amount", op.Amount)
+	case stx.PATH_PAYMENT_STRICT_RECEIVE:
+		op := body.PathPaymentStrictReceiveOp()
+		asset("send asset", &op.SendAsset)
+		asset("destination asset", &op.DestAsset)
+		positive("maximum send amount", op.SendMax)
+		positive("destination amount", op.DestAmount)
+		path(op.Path)
+	case stx.PATH_PAYMENT_STRICT_SEND:
+		op := body.PathPaymentStrictSendOp()
+		asset("send asset", &op.SendAsset)
+		asset("destination asset", &op.DestAsset)
+		positive("send amount", op.SendAmount)
+		positive("minimum destination amount", op.DestMin)
+		path(op.Path)
+	case stx.MANAGE_SELL_OFFER:
+		op := body.ManageSellOfferOp()
+		asset("selling", &op.Selling)
+		asset("buying", &op.Buying)
+		if op.Amount < 0 {
+			problem(SeverityError, "amount is negative")
+		} else if op.Amount == 0 && op.OfferID == 0 {
+			problem(SeverityError, "deletes an offer but has no offer ID")
+		}
+		price(&op.Price)
+	case stx.MANAGE_BUY_OFFER:
+		op := body.ManageBuyOfferOp()
+		asset("selling", &op.Selling)
+		asset("buying", &op.Buying)
+		if op.BuyAmount < 0 {
+			problem(SeverityError, "buy amount is negative")
+		} else if op.BuyAmount == 0 && op.OfferID == 0 {
+			problem(SeverityError, "deletes an offer but has no offer ID")
+		}
+		price(&op.Price)
+	case stx.CREATE_PASSIVE_SELL_OFFER:
+		op := body.CreatePassiveSellOfferOp()
+		asset("selling", &op.Selling)
+		asset("buying", &op.Buying)
+		positive("amount", op.Amount)
+		price(&op.Price)
+	case stx.SET_OPTIONS:
+		op := body.SetOptionsOp()
+		for _, w := range []struct {
+			name string
+			val  *uint32
+		}{
+			{"master key weight", op.MasterWeight},
+			{"low threshold", op.LowThreshold},
+			{"medium threshold", op.MedThreshold},
+			{"high threshold", op.HighThreshold},
+		} {
+			if w.val != nil && *w.val > 255 {
+				problem(SeverityError, "%s %d exceeds 255", w.name, *w.val)
+			}
+		}
+		if op.Signer != nil && op.Signer.Weight > 255 {
+			problem(SeverityError, "signer weight %d exceeds 255",
+				op.Signer.Weight)
+		}
+		if op.SetFlags != nil && op.ClearFlags != nil &&
+			*op.SetFlags&*op.ClearFlags != 0 {
+			problem(SeverityError, "sets and clears the same flags")
+		}
+		if op.SetFlags != nil &&
+			*op.SetFlags&uint32(stx.AUTH_CLAWBACK_ENABLED_FLAG) != 0 &&
+			*op.SetFlags&uint32(stx.AUTH_REVOCABLE_FLAG) == 0 {
+			problem(SeverityWarning, "enables clawback without setting "+
+				"AUTH_REVOCABLE_FLAG, which fails unless it is already set")
+		}
+		if op.HomeDomain != nil && len(*op.HomeDomain) > 32 {
+			problem(SeverityError, "home domain exceeds 32 bytes")
+		}
+	case stx.CHANGE_TRUST:
+		op := body.ChangeTrustOp()
+		var a stx.Asset
+		switch op.Line.Type {
+		case stx.ASSET_TYPE_NATIVE:
+			problem(SeverityError, "cannot trust the native asset")
+		case stx.ASSET_TYPE_CREDIT_ALPHANUM4:
+			a.Type = op.Line.Type
+			*a.AlphaNum4() = *op.Line.AlphaNum4()
+			asset("line", &a)
+		case stx.ASSET_TYPE_CREDIT_ALPHANUM12:
+			a.Type = op.Line.Type
+			*a.AlphaNum12() = *op.Line.AlphaNum12()
+			asset("line", &a)
+		}
+		if op.Limit < 0 {
+			problem(SeverityError, "limit is negative")
+		}
+	case stx.MANAGE_DATA:
+		op := body.ManageDataOp()
+		if len(op.DataName) == 0 {
+			problem(SeverityError, "data entry name is empty")
+		} else if len(op.DataName) > 64 {
+			problem(SeverityError, "data entry name exceeds 64 bytes")
+		}
+		if op.DataValue != nil && len(*op.DataValue) > 64 {
+			problem(SeverityError, "data entry value exceeds 64 bytes")
+		}
+	case stx.BUMP_SEQUENCE:
+		if body.BumpSequenceOp().BumpTo < 0 {
+			problem(SeverityError, "bumps to a negative sequence number")
+		}
+	case stx.CLAWBACK:
+		op := body.ClawbackOp()
+		if op.Asset.Type == stx.ASSET_TYPE_NATIVE {
+			problem(SeverityError, "cannot claw back the native asset")
+		}
+		asset("asset", &op.Asset)
+		positive("amount", op.Amount)
+	case stx.LIQUIDITY_POOL_DEPOSIT:
+		op := body.LiquidityPoolDepositOp()
+		positive("maximum amount of asset A", op.MaxAmountA)
+		positive("maximum amount of asset B", op.MaxAmountB)
+		price(&op.MinPrice)
+		price(&op.MaxPrice)
+		if int64(op.MinPrice.N)*int64(op.MaxPrice.D) >
+			int64(op.MaxPrice.N)*int64(op.MinPrice.D) {
+			problem(SeverityError, "minimum price exceeds maximum price")
+		}
+	case stx.LIQUIDITY_POOL_WITHDRAW:
+		op := body.LiquidityPoolWithdrawOp()
+		positive("amount", op.Amount)
+		if op.MinAmountA < 0 || op.MinAmountB < 0 {
+			problem(SeverityError, "minimum amount is negative")
+		}
+	case stx.SET_TRUST_LINE_FLAGS:
+		op := body.SetTrustLineFlagsOp()
+		asset("asset", &op.Asset)
+		both := uint32(AuthorizedFlag | AuthorizedToMaintainLiabilitiesFlag)
+		if err := CheckTrustLineFlags(op); err != nil {
+			problem(SeverityError, "%s", err)
+		} else if op.SetFlags&both != 0 && op.ClearFlags&both == 0 {
+			problem(SeverityWarning, "sets one authorization flag "+
+				"without clearing the other, which fails if the "+
+				"trustline has the other set")
+		}
+	case stx.ALLOW_TRUST:
+		switch op := body.AllowTrustOp(); op.Authorize {
+		case 0, uint32(AuthorizedFlag),
+			uint32(AuthorizedToMaintainLiabilitiesFlag):
+		default:
+			problem(SeverityError, "invalid authorization %d", op.Authorize)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package stc
+
+import (
+	"fmt"
+	"github.com/xdrpp/stc/stx"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+const modulePath = "github.com/xdrpp/stc"
+
+var version struct {
+	once sync.Once
+	v    string
+}
+
+// Returns the version of stc linked into the running program (e.g.,
+// "v0.1.4"), as recorded by the go tool when the program was built
+// from a released module, or "(devel)" if stc was built from a source
+// tree.
+func Version() string {
+	version.once.Do(func() {
+		version.v = "(devel)"
+		bi, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		} else if bi.Main.Path == modulePath && bi.Main.Version != "" {
+			version.v = bi.Main.Version
+			return
+		}
+		for _, m := range bi.Deps {
+			if m.Path == modulePath {
+				if m.Replace != nil && m.Replace.Version != "" {
+					m = m.Replace
+				}
+				if m.Version != "" {
+					version.v = m.Version
+				}
+				return
+			}
+		}
+	})
+	return version.v
+}
+
+// The commit from which stc was built, normally left empty so that
+// the go tool's record of the VCS revision is used instead.  Release
+// scripts and packagers building outside a git checkout can set it
+// with:
+//
+//	go build -ldflags "-X github.com/xdrpp/stc.BuildCommit=REV"
+var BuildCommit string
+
+// Information identifying the code that produced a transaction, for
+// logging alongside it.
+type BuildInfo struct {
+	// The module version (see Version).
+	Version string `json:"version"`
+
+	// The git commit stc was built from, if known, and whether the
+	// source tree had uncommitted changes.
+	Commit   string `json:"commit,omitempty"`
+	Modified bool   `json:"modified,omitempty"`
+
+	// The stellar-core commit whose XDR definitions were compiled into
+	// package stx, which determines the protocol features stc supports.
+	XdrCommit string `json:"xdr_commit"`
+
+	// The version of Go that compiled the program.
+	GoVersion string `json:"go_version"`
+}
+
+// Returns information about the build of stc linked into the running
+// program.
+func GetBuildInfo() BuildInfo {
+	ret := BuildInfo{
+		Version:   Version(),
+		Commit:    BuildCommit,
+		XdrCommit: stx.StellarCommit,
+		GoVersion: runtime.Version(),
+	}
+	// VCS settings describe the main module, so only apply when stc is
+	// the main module (e.g., the stc command itself).
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Path == modulePath {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if ret.Commit == "" {
+					ret.Commit = s.Value
+				}
+			case "vcs.modified":
+				ret.Modified = s.Value == "true"
+			}
+		}
+	}
+	return ret
+}
+
+func (bi BuildInfo) String() string {
+	out := &strings.Builder{}
+	fmt.Fprintf(out, "version: %s\n", bi.Version)
+	if bi.Commit != "" {
+		fmt.Fprintf(out, "commit: %s", bi.Commit)
+		if bi.Modified {
+			fmt.Fprint(out, " (modified)")
+		}
+		fmt.Fprintln(out)
+	}
+	fmt.Fprintf(out, "xdr_commit: %s\ngo_version: %s\n", bi.XdrCommit,
+		bi.GoVersion)
+	return out.String()
+}
+
+// Returns the User-Agent header stc sends on requests for net: the
+// StellarNet's AppName, if any, followed by "stc/" and Version().
+func (net *StellarNet) UserAgent() string {
+	ua := "stc/" + Version()
+	if net.AppName != "" {
+		ua = net.AppName + " " + ua
+	}
+	return ua
+}
+
+// A RoundTripper that adds a User-Agent header to requests that do
+// not already have one.
+type userAgentTransport struct {
+	base http.RoundTripper
+	ua   string
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (
+	*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.ua)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// Returns a copy of c that sends User-Agent ua.
+func withUserAgent(c *http.Client, ua string) *http.Client {
+	ret := *c
+	base := c.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	ret.Transport = userAgentTransport{base: base, ua: ua}
+	return &ret
+}
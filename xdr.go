@@ -14,7 +14,9 @@ import (
 	"github.com/xdrpp/stc/stx"
 	"io"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 )
 
 type PublicKey = stx.PublicKey
@@ -43,13 +45,26 @@ func MkAsset(acc AccountID, code string) stx.Asset {
 	} else if len(code) <= 12 {
 		ret.Type = stx.ASSET_TYPE_CREDIT_ALPHANUM12
 		copy(ret.AlphaNum12().AssetCode[:], code)
-		ret.AlphaNum4().Issuer = acc
+		ret.AlphaNum12().Issuer = acc
 	} else {
 		xdr.XdrPanic("MkAsset: %q exceeds 12 characters", code)
 	}
 	return ret
 }
 
+// Parses an asset of the form "native" or CODE:ISSUER, checking that
+// CODE is 1 to 12 letters and digits.  The String method of stx.Asset
+// renders an asset in the same form.
+func ParseAsset(s string) (stx.Asset, error) {
+	return stcdetail.ParseAsset(s)
+}
+
+// Returns an error if a is not a valid asset, e.g., because its code
+// contains invalid characters or is too short for its type.
+func CheckAsset(a stx.Asset) error {
+	return stcdetail.CheckAsset(&a)
+}
+
 func MkAssetCode(code string) stx.AssetCode {
 	var ret stx.AssetCode
 	if len(code) <= 4 {
@@ -64,6 +79,33 @@ func MkAssetCode(code string) stx.AssetCode {
 	return ret
 }
 
+// Returns the parameters of the constant-product liquidity pool for
+// assets a and b (in either order) with the standard fee, as used in
+// CHANGE_TRUST operations for pool shares.
+func NewLiquidityPoolParameters(a, b stx.Asset) stx.LiquidityPoolParameters {
+	// Pools require the assets in the order in which their XDR sorts
+	if stcdetail.XdrToBin(&a) > stcdetail.XdrToBin(&b) {
+		a, b = b, a
+	}
+	ret := stx.LiquidityPoolParameters{
+		Type: stx.LIQUIDITY_POOL_CONSTANT_PRODUCT,
+	}
+	*ret.ConstantProduct() = stx.LiquidityPoolConstantProductParameters{
+		AssetA: a,
+		AssetB: b,
+		Fee:    stx.LIQUIDITY_POOL_FEE_V18,
+	}
+	return ret
+}
+
+// Returns the ID of the constant-product liquidity pool for assets a
+// and b (in either order), as used in LIQUIDITY_POOL_DEPOSIT and
+// LIQUIDITY_POOL_WITHDRAW operations.
+func LiquidityPoolID(a, b stx.Asset) stx.PoolID {
+	params := NewLiquidityPoolParameters(a, b)
+	return stx.PoolID(stcdetail.XdrSHA256(&params))
+}
+
 // Return a pointer to an account ID
 func NewAccountID(id AccountID) *AccountID {
 	return &id
@@ -94,6 +136,43 @@ func NewSignerHashX(x stx.Hash, weight uint32) *stx.Signer {
 	return &ret
 }
 
+// Returns an ed25519 signed-payload signer key (CAP-40), which is
+// satisfied by pk's signature on payload rather than on the
+// transaction.  payload must be 1 to 64 bytes long.
+func SignedPayloadKey(pk PublicKey, payload []byte) stx.SignerKey {
+	if len(payload) == 0 || len(payload) > 64 {
+		xdr.XdrPanic("SignedPayloadKey: payload must be 1 to 64 bytes")
+	}
+	ret := stx.SignerKey{Type: stx.SIGNER_KEY_TYPE_ED25519_SIGNED_PAYLOAD}
+	ret.Ed25519SignedPayload().Ed25519 = *pk.Ed25519()
+	ret.Ed25519SignedPayload().Payload = append([]byte(nil), payload...)
+	return ret
+}
+
+// Create a signer that requires pk's signature on payload (see
+// SignedPayloadKey).
+func NewSignerSignedPayload(pk PublicKey, payload []byte,
+	weight uint32) *stx.Signer {
+	return &stx.Signer{
+		Key:    SignedPayloadKey(pk, payload),
+		Weight: weight,
+	}
+}
+
+// Parses a decimal amount of an asset, such as "123.4567890", into
+// an int64 in units of 10^-7 (stroops, for XLM).  The conversion is
+// exact:  amounts with more than 7 decimal places or that do not fit
+// in an int64 are errors.
+func ParseAmount(s string) (int64, error) {
+	return stcdetail.ParseAmount(s)
+}
+
+// Formats an amount in units of 10^-7 as an exact decimal without
+// trailing zeros, such as "123.456789".  The inverse of ParseAmount.
+func FormatAmount(amount int64) string {
+	return stcdetail.FormatAmount(amount)
+}
+
 // Allocate a uint32 when initializing types that take an XDR int*.
 func NewUint(v uint32) *uint32 { return &v }
 
@@ -142,6 +221,32 @@ func DemuxAcct(macct *MuxedAccount) (*AccountID, *uint64) {
 	return nil, nil
 }
 
+// Converts a destination identified the traditional way, by an
+// account and a MEMO_ID memo, to the equivalent muxed account.  memo
+// may be nil or MEMO_NONE for an unmuxed account.  Returns nil if
+// memo is of any other type.
+func MuxAcctMemo(acct *AccountID, memo *stx.Memo) *MuxedAccount {
+	if memo == nil || memo.Type == stx.MEMO_NONE {
+		return MuxAcct(acct, nil)
+	} else if memo.Type == stx.MEMO_ID {
+		return MuxAcct(acct, memo.Id())
+	}
+	return nil
+}
+
+// The inverse of MuxAcctMemo, for sending to services that do not
+// understand muxed accounts.  Returns a MEMO_ID memo with the muxed
+// account's identifier, or a MEMO_NONE memo if macct is not muxed.
+func DemuxAcctMemo(macct *MuxedAccount) (*AccountID, stx.Memo) {
+	var memo stx.Memo
+	acct, id := DemuxAcct(macct)
+	if id != nil {
+		memo.Type = stx.MEMO_ID
+		*memo.Id() = *id
+	}
+	return acct, memo
+}
+
 // This is a wrapper around the XDR TransactionEnvelope structure.
 // The wrapper allows transactions to be built up more easily via the
 // Append() method and various helper types.  When parsing and
@@ -199,6 +304,15 @@ The helper types are:
 	type BumpSequence stx.BumpSequenceOp
 	type ManageBuyOffer stx.ManageBuyOfferOp
 	type PathPaymentStrictSend stx.PathPaymentStrictSendOp
+	type ClaimClaimableBalance stx.ClaimClaimableBalanceOp
+	type Clawback stx.ClawbackOp
+	type ClawbackClaimableBalance stx.ClawbackClaimableBalanceOp
+	type SetTrustLineFlags stx.SetTrustLineFlagsOp
+	type LiquidityPoolDeposit stx.LiquidityPoolDepositOp
+	type LiquidityPoolWithdraw stx.LiquidityPoolWithdrawOp
+
+AllowTrust is deprecated in favor of SetTrustLineFlags (see
+NewSetTrustLineFlags), but remains supported.
 
 */
 func (txe *TransactionEnvelope) Append(
@@ -249,6 +363,141 @@ func (txe *TransactionEnvelope) SetFee(baseFee uint32) {
 	xdr.XdrPanic("SetFee: Invalid envelope type %s", txe.Type)
 }
 
+// Restrict a transaction to ledgers min through max-1.  A max of 0
+// means no upper bound.  Converts the transaction's preconditions to
+// PRECOND_V2 if necessary, preserving any time bounds.  Only
+// ENVELOPE_TYPE_TX envelopes support ledger bounds.
+func (txe *TransactionEnvelope) SetLedgerBounds(min, max uint32) {
+	if txe.Type != stx.ENVELOPE_TYPE_TX {
+		xdr.XdrPanic("SetLedgerBounds: Invalid envelope type %s", txe.Type)
+	}
+	cond := &txe.V1().Tx.Cond
+	switch cond.Type {
+	case stx.PRECOND_NONE:
+		cond.Type = stx.PRECOND_V2
+	case stx.PRECOND_TIME:
+		tb := *cond.TimeBounds()
+		cond.Type = stx.PRECOND_V2
+		cond.V2().TimeBounds = &tb
+	}
+	cond.V2().LedgerBounds = &stx.LedgerBounds{
+		MinLedger: min,
+		MaxLedger: max,
+	}
+}
+
+// Returns the time bounds of a transaction (or, for a fee-bump
+// envelope, of the inner transaction), or nil if it has none.
+func (txe *TransactionEnvelope) TimeBounds() *stx.TimeBounds {
+	var tx *stx.Transaction
+	switch txe.Type {
+	case stx.ENVELOPE_TYPE_TX_V0:
+		return txe.V0().Tx.TimeBounds
+	case stx.ENVELOPE_TYPE_TX:
+		tx = &txe.V1().Tx
+	case stx.ENVELOPE_TYPE_TX_FEE_BUMP:
+		tx = &txe.FeeBump().Tx.InnerTx.V1().Tx
+	default:
+		return nil
+	}
+	switch tx.Cond.Type {
+	case stx.PRECOND_TIME:
+		return tx.Cond.TimeBounds()
+	case stx.PRECOND_V2:
+		return tx.Cond.V2().TimeBounds
+	}
+	return nil
+}
+
+// Returns true if the transaction (or, for a fee-bump envelope, the
+// inner transaction) has a maximum time.  A transaction without one
+// remains valid indefinitely, so that anyone holding a signed copy
+// can submit it long after the signers expected it to be used.
+func (txe *TransactionEnvelope) HasMaxTime() bool {
+	tb := txe.TimeBounds()
+	return tb != nil && tb.MaxTime != 0
+}
+
+// Sets the maximum time of a transaction to d from now according to
+// the local clock, keeping any minimum time.  (See
+// StellarNet.SetTimeoutFromLedger to use the network's time instead.)
+// Fee-bump envelopes do not support this.
+func (txe *TransactionEnvelope) SetTimeout(d time.Duration) {
+	txe.setMaxTime(time.Now().Add(d))
+}
+
+func (txe *TransactionEnvelope) setMaxTime(t time.Time) {
+	var min stx.TimePoint
+	if tb := txe.TimeBounds(); tb != nil {
+		min = tb.MinTime
+	}
+	txe.SetTimeBounds(min, stx.TimePoint(t.Unix()))
+}
+
+// Restrict a transaction to the times (in seconds since the Unix
+// epoch) from min through max, where a max of 0 means no upper bound.
+// Keeps any other preconditions.  Fee-bump envelopes do not support
+// this, as their time bounds are part of the signed inner
+// transaction.
+func (txe *TransactionEnvelope) SetTimeBounds(min, max stx.TimePoint) {
+	tb := &stx.TimeBounds{MinTime: min, MaxTime: max}
+	switch txe.Type {
+	case stx.ENVELOPE_TYPE_TX_V0:
+		txe.V0().Tx.TimeBounds = tb
+		return
+	case stx.ENVELOPE_TYPE_TX:
+		cond := &txe.V1().Tx.Cond
+		switch cond.Type {
+		case stx.PRECOND_NONE, stx.PRECOND_TIME:
+			cond.Type = stx.PRECOND_TIME
+			*cond.TimeBounds() = *tb
+		case stx.PRECOND_V2:
+			cond.V2().TimeBounds = tb
+		}
+		return
+	}
+	xdr.XdrPanic("SetTimeBounds: Invalid envelope type %s", txe.Type)
+}
+
+func (txe *TransactionEnvelope) SetMemo(memo stx.Memo) {
+	switch txe.Type {
+	case stx.ENVELOPE_TYPE_TX_V0:
+		txe.V0().Tx.Memo = memo
+		return
+	case stx.ENVELOPE_TYPE_TX:
+		txe.V1().Tx.Memo = memo
+		return
+	}
+	xdr.XdrPanic("SetMemo: Invalid envelope type %s", txe.Type)
+}
+
+// Sets a MEMO_HASH memo, returning an error unless hash is exactly 32
+// bytes.  (A shorter hash is not padded, since the recipient would
+// then see a different value from the one intended.)
+func (txe *TransactionEnvelope) SetMemoHash(hash []byte) error {
+	var h stx.Hash
+	if len(hash) != len(h) {
+		return fmt.Errorf("MEMO_HASH is %d bytes; should be %d",
+			len(hash), len(h))
+	}
+	copy(h[:], hash)
+	txe.SetMemo(MemoHash(h))
+	return nil
+}
+
+// Sets a MEMO_RETURN memo, returning an error unless hash is exactly
+// 32 bytes.
+func (txe *TransactionEnvelope) SetMemoReturn(hash []byte) error {
+	var h stx.Hash
+	if len(hash) != len(h) {
+		return fmt.Errorf("MEMO_RETURN is %d bytes; should be %d",
+			len(hash), len(h))
+	}
+	copy(h[:], hash)
+	txe.SetMemo(MemoReturn(h))
+	return nil
+}
+
 func (txe *TransactionEnvelope) SourceAccount() *stx.MuxedAccount {
 	switch txe.Type {
 	case stx.ENVELOPE_TYPE_TX_V0:
@@ -299,42 +548,281 @@ func (net *StellarNet) SigNote(txe *stx.TransactionEnvelope,
 	sig *stx.DecoratedSignature) string {
 	if txe == nil {
 		return ""
-	} else if ski := net.Signers.Lookup(net.GetNetworkId(), txe, sig); ski != nil {
-		return ski.String()
 	}
+	for _, ski := range net.signersWithHint(sig.Hint) {
+		if stcdetail.VerifyTx(&ski.Key, net.GetNetworkId(), txe,
+			sig.Signature) {
+			return ski.String()
+		}
+	}
+	return net.badSigNote()
+}
+
+func (net *StellarNet) badSigNote() string {
 	return fmt.Sprintf("bad signature/unknown key/%s is wrong network",
 		net.Name)
 }
 
+// A known signer whose hint matches that of a signature, and whether
+// or not the signature is actually valid for that signer.
+type SigCandidate struct {
+	SignerKeyInfo
+	Valid bool
+}
+
+// For each signature on a transaction, returns the signers in
+// net.Signers whose hint matches the signature's hint.  The result is
+// parallel to e.Signatures().
+func (net *StellarNet) SigCandidates(e *TransactionEnvelope) [][]SigCandidate {
+	sigs := *e.Signatures()
+	hash := net.HashTx(e)
+	ret := make([][]SigCandidate, len(sigs))
+	var checks []stcdetail.SigCheck
+	for i := range sigs {
+		for _, ski := range net.signersWithHint(sigs[i].Hint) {
+			ret[i] = append(ret[i], SigCandidate{SignerKeyInfo: ski})
+		}
+		for j := range ret[i] {
+			checks = append(checks, stcdetail.SigCheck{
+				Key:  &ret[i][j].Key,
+				Hash: hash,
+				Sig:  sigs[i].Signature,
+			})
+		}
+	}
+	valid := stcdetail.VerifyParallel(checks, nil)
+	for i := range ret {
+		for j := range ret[i] {
+			ret[i][j].Valid, valid = valid[0], valid[1:]
+		}
+	}
+	return ret
+}
+
+// The signature weight an account has contributed to a transaction,
+// compared to the weight the transaction requires of it.
+type SigWeight struct {
+	Account AccountID
+
+	// Total weight of the account's signers that have valid
+	// signatures on the transaction (or, for pre-auth transaction
+	// signers, whose hash matches the transaction).
+	Have uint32
+
+	// The account's threshold for the most demanding operation for
+	// which it is the source (at least 1).
+	Need uint32
+}
+
+// True if the account has signed with enough weight.
+func (w SigWeight) Sufficient() bool {
+	return w.Have >= w.Need
+}
+
+// Returns the threshold level (0 low, 1 medium, 2 high) an operation
+// requires of its source account.
+func opThresholdLevel(op *stx.Operation) int {
+	switch op.Body.Type {
+	case stx.ALLOW_TRUST, stx.BUMP_SEQUENCE, stx.SET_TRUST_LINE_FLAGS:
+		return 0
+	case stx.ACCOUNT_MERGE:
+		return 2
+	case stx.SET_OPTIONS:
+		so := op.Body.SetOptionsOp()
+		if so.MasterWeight != nil || so.LowThreshold != nil ||
+			so.MedThreshold != nil || so.HighThreshold != nil ||
+			so.Signer != nil {
+			return 2
+		}
+	}
+	return 1
+}
+
+func signedBy(key *SignerKey, hash *stx.Hash,
+	sigs []stx.DecoratedSignature) bool {
+	if key.Type == stx.SIGNER_KEY_TYPE_PRE_AUTH_TX {
+		return stcdetail.VerifyParallel([]stcdetail.SigCheck{
+			{Key: key, Hash: hash},
+		}, nil)[0]
+	}
+	hint := key.Hint()
+	for i := range sigs {
+		if sigs[i].Hint == hint && stcdetail.VerifyParallel(
+			[]stcdetail.SigCheck{
+				{Key: key, Hash: hash, Sig: sigs[i].Signature},
+			}, nil)[0] {
+			return true
+		}
+	}
+	return false
+}
+
+func (net *StellarNet) sigWeights(e *TransactionEnvelope,
+	accts map[string]*HorizonAccountEntry, order []string,
+	levels map[string]int) []SigWeight {
+	var ret []SigWeight
+	hash := net.HashTx(e)
+	sigs := *e.Signatures()
+	for _, acct := range order {
+		ae := accts[acct]
+		if ae == nil {
+			continue
+		}
+		var w SigWeight
+		fmt.Sscan(acct, &w.Account)
+		w.Need = uint32([]uint8{
+			ae.Thresholds.Low_threshold,
+			ae.Thresholds.Med_threshold,
+			ae.Thresholds.High_threshold,
+		}[levels[acct]])
+		if w.Need == 0 {
+			w.Need = 1
+		}
+		for i := range ae.Signers {
+			s := &ae.Signers[i]
+			if s.Weight > 0 && signedBy(&s.Key, hash, sigs) {
+				if s.Weight > 255 {
+					w.Have += 255
+				} else {
+					w.Have += s.Weight
+				}
+			}
+		}
+		ret = append(ret, w)
+	}
+	return ret
+}
+
+// Performs threshold analysis on a transaction:  for each source
+// account (of the transaction or one of its operations), computes the
+// weight of its valid signatures and the threshold it must meet.
+// Account state comes from accts, indexed by strkey, and accounts
+// missing from accts are omitted from the result.  For fee-bump
+// transactions, the result includes the fee source (which signs the
+// outer envelope) as well as the inner transaction's accounts.
+func (net *StellarNet) SigWeights(e *TransactionEnvelope,
+	accts map[string]*HorizonAccountEntry) []SigWeight {
+	var ret []SigWeight
+	if e.Type == stx.ENVELOPE_TYPE_TX_FEE_BUMP {
+		fs, _ := DemuxAcct(&e.FeeBump().Tx.FeeSource)
+		ret = net.sigWeights(e, accts, []string{fs.String()},
+			map[string]int{fs.String(): 0})
+	}
+	inner := innerTx(e)
+	ops := inner.Operations()
+	if ops == nil {
+		return ret
+	}
+	txsrc, _ := DemuxAcct(inner.SourceAccount())
+	order := []string{txsrc.String()}
+	levels := map[string]int{txsrc.String(): 0}
+	for i := range *ops {
+		acct := opSource(inner, &(*ops)[i]).String()
+		level := opThresholdLevel(&(*ops)[i])
+		if old, ok := levels[acct]; !ok {
+			order = append(order, acct)
+			levels[acct] = level
+		} else if level > old {
+			levels[acct] = level
+		}
+	}
+	return append(ret, net.sigWeights(inner, accts, order, levels)...)
+}
+
+// Returns the keys in net.Approvers (sorted by strkey) that have
+// validly signed e.  For fee-bump transactions, signatures on both the
+// outer envelope and the inner transaction count.
+func (net *StellarNet) TxApprovers(e *TransactionEnvelope) []string {
+	var ret []string
+	envs := []*TransactionEnvelope{e}
+	if e.Type == stx.ENVELOPE_TYPE_TX_FEE_BUMP {
+		envs = append(envs, innerTx(e))
+	}
+	for approver := range net.Approvers {
+		var pk PublicKey
+		if _, err := fmt.Sscan(approver, &pk); err != nil {
+			continue
+		}
+		key := pk.ToSignerKey()
+		for _, env := range envs {
+			if signedBy(&key, net.HashTx(env), *env.Signatures()) {
+				ret = append(ret, approver)
+				break
+			}
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Returns an error unless e carries valid signatures from at least
+// net.Approvals distinct keys in net.Approvers.  This enforces an
+// organizational policy (e.g., that two people approve every
+// transaction) independent of the thresholds of the accounts
+// involved, so it only protects against mistakes by users of stc, not
+// against anyone who can post transactions by other means.
+func (net *StellarNet) CheckApprovals(e *TransactionEnvelope) error {
+	if net.Approvals <= 0 {
+		return nil
+	}
+	if n := len(net.TxApprovers(e)); n < net.Approvals {
+		return fmt.Errorf("transaction has %d of %d required approver " +
+			"signatures", n, net.Approvals)
+	}
+	return nil
+}
+
+// Returns the comment on an account in net.Accounts.  For a muxed
+// account with no comment of its own, returns the underlying account
+// (or its comment) and the muxed account's identifier.
 func (net *StellarNet) AccountIDNote(acct string) string {
-	return net.Accounts[acct]
+	hint := net.accountHint(acct)
+	if hint != "" || !strings.HasPrefix(acct, "M") {
+		return hint
+	}
+	var m MuxedAccount
+	if m.UnmarshalText([]byte(acct)) != nil {
+		return ""
+	} else if a, id := DemuxAcct(&m); id != nil {
+		base := a.String()
+		if h := net.accountHint(base); h != "" {
+			base = h
+		}
+		return fmt.Sprintf("%s id %d", base, *id)
+	}
+	return ""
 }
 
 func (net *StellarNet) SignerNote(key *stx.SignerKey) string {
+	net.signersLock.RLock()
+	defer net.signersLock.RUnlock()
 	return net.Signers.LookupComment(key)
 }
 
 // Write the human-readable Txrep of an XDR structure to a Writer.
 func (net *StellarNet) WriteRep(out io.Writer, name string, txe xdr.XdrType) {
+	stcdetail.XdrToTxrep(out, name, net.annotate(txe))
+}
+
+// Returns txe with the comment methods of net (AccountIDNote,
+// SignerNote, etc.) attached, for use with stcdetail.XdrToTxrep.
+func (net *StellarNet) annotate(txe xdr.XdrType) xdr.XdrType {
 	type helper interface {
 		xdr.XdrType
 		GetHelp(string) bool
 	}
 	if net == nil {
-		stcdetail.XdrToTxrep(out, name, txe)
+		return txe
 	} else if e, ok := txe.(helper); ok {
-		ntxe := struct {
+		return struct {
 			helper
 			*StellarNet
 		}{e, (*StellarNet)(net)}
-		stcdetail.XdrToTxrep(out, name, ntxe)
-	} else {
-		ntxe := struct {
-			xdr.XdrType
-			*StellarNet
-		}{txe, (*StellarNet)(net)}
-		stcdetail.XdrToTxrep(out, name, ntxe)
 	}
+	return struct {
+		xdr.XdrType
+		*StellarNet
+	}{txe, (*StellarNet)(net)}
 }
 
 // Convert an arbitrary XDR data structure to human-readable Txrep
@@ -350,6 +838,18 @@ func (net *StellarNet) TxToRep(txe *TransactionEnvelope) string {
 	return net.ToRep(txe)
 }
 
+// Convert a TransactionEnvelope to Txrep without any comments that
+// depend on local state (account and signer comments, signature
+// validity, help requests, or the network's name for the native
+// asset).  The result depends only on the envelope, so independently
+// generated canonical Txreps of the same envelope are byte-identical
+// and can be compared with diff in place of the XDR.
+func TxToCanonicalRep(txe *TransactionEnvelope) string {
+	var out strings.Builder
+	stcdetail.XdrToTxrep(&out, "", txe.TransactionEnvelope)
+	return out.String()
+}
+
 // Parse a transaction in human-readable Txrep format into a
 // TransactionEnvelope.
 func TxFromRep(rep string) (*TransactionEnvelope, error) {
@@ -361,6 +861,22 @@ func TxFromRep(rep string) (*TransactionEnvelope, error) {
 	return txe, nil
 }
 
+// Like TxFromRep, but account fields may contain the annotation of an
+// account in net.Accounts instead of a strkey (see
+// LookupAccountAlias).
+func (net *StellarNet) TxFromRep(rep string) (*TransactionEnvelope, error) {
+	in := strings.NewReader(rep)
+	txe := NewTransactionEnvelope()
+	ntxe := struct {
+		*TransactionEnvelope
+		*StellarNet
+	}{txe, net}
+	if err := stcdetail.XdrFromTxrep(in, "", ntxe); err != nil {
+		return txe, err
+	}
+	return txe, nil
+}
+
 // Convert a TransactionEnvelope to base64-encoded binary XDR format.
 func TxToBase64(tx *TransactionEnvelope) string {
 	return stcdetail.XdrToBase64(tx)